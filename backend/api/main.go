@@ -5,6 +5,7 @@ package main
 import (
 	_ "backend/docs"
 	"backend/internal/api/routes"
+	"backend/internal/auth"
 	"backend/internal/config"
 	"backend/internal/database"
 	"backend/internal/health"
@@ -35,6 +36,10 @@ const (
 // @schemes         http https
 // @produce         json
 // @consumes        json
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Access token from /api/v1/auth/login, as "Bearer <token>". Required when AUTH_ENABLED is set.
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig()
@@ -57,13 +62,46 @@ func main() {
 	})
 	healthChecker.SetReady(true)
 
-	// Create and start WebSocket hub
+	// Load the token keys when authentication is enabled
+	var tokens *auth.Service
+	if cfg.Auth.Enabled {
+		tokens, err = auth.NewService(cfg.Auth)
+		if err != nil {
+			slog.Error("Failed to initialize authentication", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Create and start WebSocket hub, relaying events between instances when
+	// a backplane is configured
 	hub := websocket.NewHub()
+	hub.SetHistorySize(int(cfg.WebSocket.HistorySize))
+	hub.SetMaxMessageSize(int64(cfg.WebSocket.MaxMessageSize))
+	hub.SetCallRateLimit(int(cfg.WebSocket.CallRateLimit), time.Minute)
+	if cfg.WebSocket.Backpressure != "" {
+		policy, err := websocket.ParseBackpressure(cfg.WebSocket.Backpressure)
+		if err != nil {
+			slog.Error("Invalid WebSocket backpressure policy", "error", err)
+			os.Exit(1)
+		}
+		hub.SetBackpressure(policy)
+	}
+	backplane, err := websocket.NewBackplane(cfg.WebSocket)
+	if err != nil {
+		slog.Error("Failed to initialize WebSocket backplane", "error", err)
+		os.Exit(1)
+	}
+	if backplane != nil {
+		defer backplane.Close()
+		hub.SetBackplane(backplane)
+		healthChecker.AddCheck("backplane", backplane.Ping)
+		slog.Info("WebSocket backplane enabled", "backplane", cfg.WebSocket.Backplane, "node", hub.NodeID())
+	}
 	go hub.Run()
 
 	// Setup router — use gin.New() since SetupRoutes registers its own Logger and Recovery middleware.
 	router := gin.New()
-	rateLimiter := routes.SetupRoutes(router, repo, healthChecker, cfg, hub)
+	rateLimiter := routes.SetupRoutes(router, repo, healthChecker, cfg, hub, tokens)
 	defer rateLimiter.Stop()
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -33,7 +33,7 @@ func (m *MockDB) DB() *gorm.DB {
 	return args.Get(0).(*gorm.DB)
 }
 
-// MockRepository is a mock implementation of the models.Repository interface
+// MockRepository is a mock implementation of the models.Store interface
 type MockRepository struct {
 	mock.Mock
 }
@@ -43,8 +43,8 @@ func (m *MockRepository) Create(ctx context.Context, entity interface{}) error {
 	return args.Error(0)
 }
 
-func (m *MockRepository) FindByID(ctx context.Context, id uint, dest interface{}) error {
-	args := m.Called(ctx, id, dest)
+func (m *MockRepository) FindByID(ctx context.Context, id uint, dest interface{}, conditions ...interface{}) error {
+	args := m.Called(ctx, id, dest, conditions)
 	return args.Error(0)
 }
 
@@ -58,6 +58,16 @@ func (m *MockRepository) Delete(ctx context.Context, entity interface{}) error {
 	return args.Error(0)
 }
 
+func (m *MockRepository) Restore(ctx context.Context, entity interface{}) (bool, error) {
+	args := m.Called(ctx, entity)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Purge(ctx context.Context, model interface{}, deletedBefore time.Time) (int64, error) {
+	args := m.Called(ctx, model, deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepository) List(ctx context.Context, dest interface{}, conditions ...interface{}) error {
 	args := m.Called(ctx, dest, conditions)
 	return args.Error(0)
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves maintenance endpoints. It is separate from Handler
+// because it works across every registered model through the untyped Store.
+type AdminHandler struct {
+	store     models.Store
+	retention time.Duration
+}
+
+// NewAdminHandler creates an AdminHandler. retention is how long soft-deleted
+// records are kept before PurgeDeleted removes them.
+func NewAdminHandler(store models.Store, retention time.Duration) *AdminHandler {
+	return &AdminHandler{store: store, retention: retention}
+}
+
+// PurgeResult reports how many records PurgeDeleted removed per model.
+type PurgeResult struct {
+	DeletedBefore time.Time        `json:"deleted_before"`
+	Purged        map[string]int64 `json:"purged"`
+}
+
+// PurgeDeleted godoc
+// @Summary Purge soft-deleted records
+// @Description Permanently remove records of every model that were soft-deleted longer ago than the configured retention (SOFT_DELETE_RETENTION)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} handlers.PurgeResult
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/purge [post]
+func (h *AdminHandler) PurgeDeleted(c *gin.Context) {
+	result := PurgeResult{
+		DeletedBefore: time.Now().UTC().Add(-h.retention),
+		Purged:        make(map[string]int64),
+	}
+
+	for _, info := range models.RegisteredModels() {
+		n, err := h.store.Purge(c.Request.Context(), reflect.New(info.Type).Interface(), result.DeletedBefore)
+		if err != nil {
+			slog.Error("Failed to purge soft-deleted records", "model", info.Name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		result.Purged[info.Name] = n
+	}
+
+	slog.Info("Purged soft-deleted records", "deleted_before", result.DeletedBefore, "purged", result.Purged)
+	c.JSON(http.StatusOK, result)
+}
+
+// RolesRequest is the body of a role assignment.
+type RolesRequest struct {
+	Roles []string `json:"roles" example:"editor"`
+}
+
+// ListRoles godoc
+// @Summary List roles
+// @Description List the roles that can be assigned to users and the permissions each grants
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} auth.Role
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/admin/roles [get]
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.Roles())
+}
+
+// SetUserRoles godoc
+// @Summary Assign roles to a user
+// @Description Replace a user's roles. Tokens already issued keep the previous roles until they are refreshed.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param roles body handlers.RolesRequest true "Roles"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/roles [put]
+func (h *AdminHandler) SetUserRoles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var input RolesRequest
+	if err := c.ShouldBindJSON(&input); err != nil || input.Roles == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	for _, role := range input.Roles {
+		if !auth.IsRole(role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + role})
+			return
+		}
+	}
+	roles := slices.Clone(input.Roles)
+	slices.Sort(roles)
+	roles = slices.Compact(roles)
+
+	users := models.NewTypedRepository[models.User](h.store)
+	user, err := users.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	user.Roles = roles
+	if err := users.Update(c.Request.Context(), user); err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	slog.Info("Assigned user roles", "user_id", user.ID, "roles", roles)
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupAdminTestRouter(t *testing.T, retention time.Duration) (*gin.Engine, *MockRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	mockRepo := NewMockRepository()
+	handler := NewAdminHandler(mockRepo, retention)
+	router.POST("/api/v1/admin/purge", handler.PurgeDeleted)
+	router.GET("/api/v1/admin/roles", handler.ListRoles)
+	router.PUT("/api/v1/admin/users/:id/roles", handler.SetUserRoles)
+	return router, mockRepo
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		retention  time.Duration
+		dbErr      error
+		wantStatus int
+		wantItems  int64
+		wantUsers  int64
+	}{
+		{name: "purges rows older than retention", retention: time.Hour, wantStatus: http.StatusOK, wantItems: 1, wantUsers: 1},
+		{name: "long retention keeps everything", retention: 1000 * time.Hour, wantStatus: http.StatusOK},
+		{name: "database error", retention: time.Hour, dbErr: errors.New("boom"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupAdminTestRouter(t, tt.retention)
+			ctx := context.Background()
+
+			longAgo := gorm.DeletedAt{Time: time.Now().Add(-48 * time.Hour), Valid: true}
+			oldItem := &models.Item{Name: "old", Price: 1}
+			recentItem := &models.Item{Name: "recent", Price: 1}
+			require.NoError(t, mockRepo.Create(ctx, oldItem))
+			require.NoError(t, mockRepo.Create(ctx, recentItem))
+			require.NoError(t, mockRepo.Create(ctx, &models.Item{Name: "live", Price: 1}))
+			require.NoError(t, mockRepo.Delete(ctx, oldItem))
+			require.NoError(t, mockRepo.Delete(ctx, recentItem))
+			oldItem.DeletedAt = longAgo
+
+			oldUser := &models.User{Username: "old", Email: "old@example.com"}
+			require.NoError(t, mockRepo.Create(ctx, oldUser))
+			require.NoError(t, mockRepo.Delete(ctx, oldUser))
+			mockRepo.users[oldUser.ID].DeletedAt = longAgo
+
+			mockRepo.SetError(tt.dbErr)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/admin/purge", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var result PurgeResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, tt.wantItems, result.Purged["items"])
+			assert.Equal(t, tt.wantUsers, result.Purged["users"])
+			assert.WithinDuration(t, time.Now().Add(-tt.retention), result.DeletedBefore, time.Minute)
+		})
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		userID     string
+		body       string
+		wantStatus int
+		wantRoles  []string
+	}{
+		{
+			name:       "assign roles",
+			userID:     "1",
+			body:       `{"roles":["editor","admin","editor"]}`,
+			wantStatus: http.StatusOK,
+			wantRoles:  []string{"admin", "editor"},
+		},
+		{
+			name:       "clear roles",
+			userID:     "1",
+			body:       `{"roles":[]}`,
+			wantStatus: http.StatusOK,
+			wantRoles:  []string{},
+		},
+		{
+			name:       "unknown role",
+			userID:     "1",
+			body:       `{"roles":["root"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing roles",
+			userID:     "1",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non-existent user",
+			userID:     "999",
+			body:       `{"roles":["viewer"]}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid ID",
+			userID:     "abc",
+			body:       `{"roles":["viewer"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupAdminTestRouter(t, 0)
+			user := &models.User{Username: "alice", Email: "alice@example.com", Roles: []string{"viewer"}}
+			require.NoError(t, mockRepo.Create(context.Background(), user))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/users/"+tt.userID+"/roles", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+				return
+			}
+			var got models.User
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.wantRoles, got.Roles)
+
+			var stored models.User
+			require.NoError(t, mockRepo.FindByID(context.Background(), user.ID, &stored))
+			assert.Equal(t, tt.wantRoles, stored.Roles)
+		})
+	}
+}
+
+func TestListRoles(t *testing.T) {
+	t.Parallel()
+	router, _ := setupAdminTestRouter(t, 0)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/roles", http.NoBody))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var roles []auth.Role
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &roles))
+	require.Len(t, roles, 3)
+	assert.Equal(t, "admin", roles[0].Name)
+	assert.Contains(t, roles[0].Permissions, auth.PermRolesWrite)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"backend/internal/api/middleware"
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler serves the API key management endpoints.
+type APIKeyHandler struct {
+	apiKeys *auth.APIKeys
+	keys    models.Repository[models.APIKey]
+}
+
+// NewAPIKeyHandler creates an APIKeyHandler for the API keys in store.
+func NewAPIKeyHandler(store models.Store) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeys: auth.NewAPIKeys(store),
+		keys:    models.NewTypedRepository[models.APIKey](store),
+	}
+}
+
+// APIKeyRequest is the body of an API key creation.
+type APIKeyRequest struct {
+	Name   string   `json:"name" binding:"required" example:"nightly-import"`
+	Scopes []string `json:"scopes" binding:"required" example:"items:read,items:write"`
+	// RateLimit overrides the requests allowed per minute; 0 uses the default.
+	RateLimit int        `json:"rate_limit" example:"1000"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse is a created API key. Key is only ever returned here.
+type APIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key" example:"ak_0123456789abcdef_..."`
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create an API key for service-to-service callers, sent in the X-API-Key header. The key is only returned in this response. Its scopes must be permissions the caller has; API keys cannot manage API keys.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param key body handlers.APIKeyRequest true "API key"
+// @Success 201 {object} handlers.APIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/admin/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var input APIKeyRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	claims, authenticated := middleware.ClaimsFromContext(c)
+	for _, scope := range input.Scopes {
+		if !auth.IsPermission(scope) || scope == auth.PermAPIKeysWrite {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope})
+			return
+		}
+		if authenticated && !claims.HasPermission(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied: " + scope + " required"})
+			return
+		}
+	}
+	scopes := slices.Clone(input.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	key := &models.APIKey{
+		Name:      input.Name,
+		Scopes:    scopes,
+		RateLimit: input.RateLimit,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if authenticated {
+		// Only users get here: keys cannot hold the apikeys:write scope.
+		key.UserID, _ = claims.UserID()
+	}
+
+	raw, err := h.apiKeys.Create(c.Request.Context(), key)
+	if err != nil {
+		status, message := handleEntityDBError(err, "API key")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	slog.Info("Created API key", "api_key_id", key.ID, "prefix", key.Prefix, "user_id", key.UserID, "scopes", key.Scopes)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, APIKeyResponse{APIKey: *key, Key: raw})
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List the API keys that have not been revoked, with when each was last used
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/admin/api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.keys.List(c.Request.Context())
+	if err != nil {
+		status, message := handleEntityDBError(err, "API key")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key by its ID. Requests using it are rejected from then on.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	key := &models.APIKey{Base: models.Base{ID: uint(id)}}
+	if err := h.keys.Delete(c.Request.Context(), key); err != nil {
+		status, message := handleEntityDBError(err, "API key")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	slog.Info("Revoked API key", "api_key_id", id)
+	c.Status(http.StatusNoContent)
+}
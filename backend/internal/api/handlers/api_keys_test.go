@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"backend/internal/api/middleware"
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupAPIKeyTestRouter serves the API key endpoints from a SQLite store,
+// since MockRepository does not store API keys. Requests act as a user with
+// roles.
+func setupAPIKeyTestRouter(t *testing.T, roles ...string) (*gin.Engine, models.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.APIKey{}))
+	store := models.NewRepository(db)
+
+	tokens := newTestAuthService(t)
+	pair, err := tokens.Issue(&models.User{Base: models.Base{ID: 5}, Username: "ops", Roles: roles})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	}, middleware.Auth(tokens))
+	handler := NewAPIKeyHandler(store)
+	router.POST("/api/v1/admin/api-keys", handler.CreateAPIKey)
+	router.GET("/api/v1/admin/api-keys", handler.ListAPIKeys)
+	router.DELETE("/api/v1/admin/api-keys/:id", handler.RevokeAPIKey)
+	return router, store
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		roles      []string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "create", roles: []string{auth.RoleAdmin}, body: `{"name":"import","scopes":["items:write","items:read","items:write"],"rate_limit":500}`, wantStatus: http.StatusCreated},
+		{name: "missing name", roles: []string{auth.RoleAdmin}, body: `{"scopes":["items:read"]}`, wantStatus: http.StatusBadRequest, wantError: "Invalid request format"},
+		{name: "no scopes", roles: []string{auth.RoleAdmin}, body: `{"name":"import","scopes":[]}`, wantStatus: http.StatusBadRequest, wantError: "API key needs at least one scope"},
+		{name: "unknown scope", roles: []string{auth.RoleAdmin}, body: `{"name":"import","scopes":["items:delete"]}`, wantStatus: http.StatusBadRequest, wantError: "Invalid scope: items:delete"},
+		{name: "keys cannot manage keys", roles: []string{auth.RoleAdmin}, body: `{"name":"import","scopes":["apikeys:write"]}`, wantStatus: http.StatusBadRequest, wantError: "Invalid scope: apikeys:write"},
+		{name: "scope the caller lacks", roles: []string{auth.RoleEditor}, body: `{"name":"import","scopes":["users:write"]}`, wantStatus: http.StatusForbidden, wantError: "Permission denied: users:write required"},
+		{name: "negative rate limit", roles: []string{auth.RoleAdmin}, body: `{"name":"import","scopes":["items:read"],"rate_limit":-1}`, wantStatus: http.StatusBadRequest, wantError: "rate limit cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, _ := setupAPIKeyTestRouter(t, tt.roles...)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/api-keys", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusCreated {
+				assert.Contains(t, w.Body.String(), tt.wantError)
+				return
+			}
+			var resp APIKeyResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.True(t, strings.HasPrefix(resp.Key, "ak_"+resp.Prefix+"_"))
+			assert.Equal(t, []string{auth.PermItemsRead, auth.PermItemsWrite}, resp.Scopes)
+			assert.Equal(t, uint(5), resp.UserID)
+			assert.Equal(t, 500, resp.RateLimit)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			assert.NotContains(t, w.Body.String(), "key_hash")
+		})
+	}
+}
+
+func TestListAndRevokeAPIKeys(t *testing.T) {
+	t.Parallel()
+	router, store := setupAPIKeyTestRouter(t, auth.RoleAdmin)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func() []models.APIKey {
+		w := serve(http.MethodGet, "/api/v1/admin/api-keys", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var keys []models.APIKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+		return keys
+	}
+
+	w := serve(http.MethodPost, "/api/v1/admin/api-keys", `{"name":"import","scopes":["items:read"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	keys := list()
+	require.Len(t, keys, 1)
+	assert.Nil(t, keys[0].LastUsedAt)
+
+	_, err := auth.NewAPIKeys(store).Verify(context.Background(), created.Key)
+	require.NoError(t, err)
+	keys = list()
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt, "last use is listed")
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/v1/admin/api-keys/1", "").Code)
+	assert.Empty(t, list())
+	_, err = auth.NewAPIKeys(store).Verify(context.Background(), created.Key)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/api/v1/admin/api-keys/1", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, "/api/v1/admin/api-keys/abc", "").Code)
+}
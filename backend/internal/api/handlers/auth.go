@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler serves the token endpoints.
+type AuthHandler struct {
+	users  models.Repository[models.User]
+	tokens *auth.Service
+}
+
+// NewAuthHandler creates an AuthHandler that checks credentials against the
+// users in store and issues tokens with tokens.
+func NewAuthHandler(store models.Store, tokens *auth.Service) *AuthHandler {
+	return &AuthHandler{
+		users:  models.NewTypedRepository[models.User](store),
+		tokens: tokens,
+	}
+}
+
+// LoginRequest is the body of a login.
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest is the body of a token refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+var (
+	dummyPasswordOnce sync.Once
+	dummyPassword     models.User
+)
+
+// checkNoPassword spends the time of a password check on a login naming no
+// user, so response times do not reveal which usernames exist.
+func checkNoPassword(password string) {
+	dummyPasswordOnce.Do(func() {
+		if err := dummyPassword.SetPassword("not a real password"); err != nil {
+			slog.Error("Failed to hash dummy password", "error", err)
+		}
+	})
+	dummyPassword.CheckPassword(password)
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Exchange a username and password for an access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body handlers.LoginRequest true "Credentials"
+// @Success 200 {object} auth.TokenPair
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input LoginRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	users, err := h.users.List(c.Request.Context(),
+		models.Filter{Field: "username", Op: "exact", Value: input.Username})
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	if len(users) == 0 {
+		checkNoPassword(input.Password)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if !users[0].CheckPassword(input.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+
+	h.issue(c, &users[0])
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body handlers.RefreshRequest true "Refresh token"
+// @Success 200 {object} auth.TokenPair
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input RefreshRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	claims, err := h.tokens.Verify(input.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	id, err := claims.UserID()
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	// The user is reloaded so that deleted users cannot refresh.
+	user, err := h.users.FindByID(c.Request.Context(), id)
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		if status == http.StatusNotFound {
+			status, message = http.StatusUnauthorized, "Invalid or expired refresh token"
+		}
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	h.issue(c, user)
+}
+
+func (h *AuthHandler) issue(c *gin.Context, user *models.User) {
+	pair, err := h.tokens.Issue(user)
+	if err != nil {
+		slog.Error("Failed to issue tokens", "user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, pair)
+}
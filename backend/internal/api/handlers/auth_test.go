@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/config"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthService(t *testing.T) *auth.Service {
+	t.Helper()
+	tokens, err := auth.NewService(config.AuthConfig{
+		Enabled:         true,
+		Algorithm:       "HS256",
+		Secret:          "0123456789abcdef0123456789abcdef",
+		Issuer:          "backend-api",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	return tokens
+}
+
+func setupAuthTestRouter(t *testing.T) (*gin.Engine, *MockRepository, *auth.Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	mockRepo := NewMockRepository()
+	tokens := newTestAuthService(t)
+
+	authHandler := NewAuthHandler(mockRepo, tokens)
+	router.POST("/api/v1/auth/login", authHandler.Login)
+	router.POST("/api/v1/auth/refresh", authHandler.Refresh)
+	handler := NewHandler(mockRepo)
+	router.PUT("/api/v1/users/:id", handler.UpdateUser)
+	router.DELETE("/api/v1/users/:id", handler.DeleteUser)
+
+	return router, mockRepo, tokens
+}
+
+func TestAuthEndpoints(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		method, path, body string
+	}
+	login := func(username, password string) request {
+		b, _ := json.Marshal(LoginRequest{Username: username, Password: password})
+		return request{"POST", "/api/v1/auth/login", string(b)}
+	}
+
+	tests := []struct {
+		name string
+		// setup runs requests before the one under test; refresh builds the
+		// request under test from the tokens of a login as alice.
+		setup      []request
+		req        request
+		refresh    func(pair *auth.TokenPair) request
+		wantStatus int
+	}{
+		{
+			name:       "login",
+			req:        login("alice", "correct horse"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong password",
+			req:        login("alice", "wrong horse"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown user",
+			req:        login("mallory", "correct horse"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "user without a password",
+			req:        login("bob", ""),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "user without a password guessing one",
+			req:        login("bob", "correct horse"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed body",
+			req:        request{"POST", "/api/v1/auth/login", `{"username":`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "updating a user without a password keeps it",
+			setup:      []request{{"PUT", "/api/v1/users/1", `{"username":"alice","email":"alice@example.com","name":"Alice"}`}},
+			req:        login("alice", "correct horse"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "updating a user's password",
+			setup:      []request{{"PUT", "/api/v1/users/1", `{"username":"alice","email":"alice@example.com","password":"battery staple"}`}},
+			req:        login("alice", "correct horse"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "refresh",
+			refresh: func(pair *auth.TokenPair) request {
+				return request{"POST", "/api/v1/auth/refresh", `{"refresh_token":"` + pair.RefreshToken + `"}`}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "refresh with an access token",
+			refresh: func(pair *auth.TokenPair) request {
+				return request{"POST", "/api/v1/auth/refresh", `{"refresh_token":"` + pair.AccessToken + `"}`}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:  "refresh for a deleted user",
+			setup: []request{{"DELETE", "/api/v1/users/1", ""}},
+			refresh: func(pair *auth.TokenPair) request {
+				return request{"POST", "/api/v1/auth/refresh", `{"refresh_token":"` + pair.RefreshToken + `"}`}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "refresh with a malformed token",
+			req:        request{"POST", "/api/v1/auth/refresh", `{"refresh_token":"abc"}`},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo, tokens := setupAuthTestRouter(t)
+			alice := &models.User{Username: "alice", Email: "alice@example.com"}
+			require.NoError(t, alice.SetPassword("correct horse"))
+			require.NoError(t, mockRepo.Create(context.Background(), alice))
+			seedUser(t, mockRepo, "bob", "bob@example.com")
+
+			serve := func(r request) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(r.method, r.path, bytes.NewBufferString(r.body))
+				req.Header.Set("Content-Type", "application/json")
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			req := tt.req
+			if tt.refresh != nil {
+				w := serve(login("alice", "correct horse"))
+				require.Equal(t, http.StatusOK, w.Code)
+				var pair auth.TokenPair
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+				req = tt.refresh(&pair)
+			}
+			for _, r := range tt.setup {
+				w := serve(r)
+				require.Less(t, w.Code, 300, w.Body.String())
+			}
+
+			w := serve(req)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+				return
+			}
+
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			var pair auth.TokenPair
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+			assert.Equal(t, "Bearer", pair.TokenType)
+			assert.Equal(t, 60, pair.ExpiresIn)
+			claims, err := tokens.Verify(pair.AccessToken, auth.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, "1", claims.Subject)
+			assert.Equal(t, "alice", claims.Username)
+			_, err = tokens.Verify(pair.RefreshToken, auth.RefreshToken)
+			assert.NoError(t, err)
+		})
+	}
+}
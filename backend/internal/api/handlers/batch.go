@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchOperations caps the number of operations in one batch request.
+const maxBatchOperations = 1000
+
+// BatchOperation is one operation of a batch request. Create needs item,
+// update needs id and item (with an optional version for optimistic locking)
+// and delete needs id.
+type BatchOperation struct {
+	Op   string       `json:"op" enums:"create,update,delete" example:"create"`
+	ID   uint         `json:"id,omitempty" example:"1"`
+	Item *models.Item `json:"item,omitempty"`
+}
+
+// BatchRequest is the body of POST /api/v1/items:batch.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult reports the outcome of one operation, in request order. Status
+// is the HTTP status the operation would have had on its own, or 424 when it
+// was not applied because another operation failed.
+type BatchResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	Status int          `json:"status"`
+	ID     uint         `json:"id,omitempty"`
+	Item   *models.Item `json:"item,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// BatchResponse is the response of POST /api/v1/items:batch.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchEvent is the payload of the item.batch WebSocket message sent once per
+// applied batch.
+type BatchEvent struct {
+	Created []models.Item `json:"created"`
+	Updated []models.Item `json:"updated"`
+	Deleted []uint        `json:"deleted"`
+}
+
+// BatchItems godoc
+// @Summary Create, update and delete items in one request
+// @Description Apply up to 1000 operations as a unit: if any operation fails, none are applied and
+// @Description the response status is that of the failed operation. On Azure Table Storage the
+// @Description operations are committed in transactions of 100, so when a later transaction fails
+// @Description the operations of earlier ones stay applied and are reported with their own status.
+// @Description One item.batch WebSocket message describes every applied change. With the event outbox it
+// @Description is recorded in the last transaction, so it is only sent once every operation is committed.
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param batch body handlers.BatchRequest true "Operations"
+// @Success 200 {object} handlers.BatchResponse
+// @Failure 400 {object} handlers.BatchResponse
+// @Failure 404 {object} handlers.BatchResponse
+// @Failure 409 {object} handlers.BatchResponse
+// @Security BearerAuth
+// @Router /api/v1/items:batch [post]
+func (h *Handler) BatchItems(c *gin.Context) {
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if len(req.Operations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one operation is required"})
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d operations are allowed", maxBatchOperations)})
+		return
+	}
+
+	results := make([]BatchResult, len(req.Operations))
+	ops := make([]models.BatchOp, len(req.Operations))
+	seen := make(map[uint]bool, len(req.Operations))
+	failed := false
+	for i, op := range req.Operations {
+		results[i] = BatchResult{Index: i, Op: op.Op, ID: op.ID}
+		var (
+			entity *models.Item
+			status int
+			msg    string
+		)
+		switch models.BatchAction(op.Op) {
+		case models.BatchCreate:
+			if op.Item == nil {
+				status, msg = http.StatusBadRequest, "Item is required"
+				break
+			}
+			if err := prepareNewItem(op.Item); err != nil {
+				status, msg = errorResponse(err)
+				break
+			}
+			entity = op.Item
+		case models.BatchUpdate, models.BatchDelete:
+			switch {
+			case op.ID == 0:
+				status, msg = http.StatusBadRequest, "ID is required"
+			case seen[op.ID]:
+				status, msg = http.StatusBadRequest, "Item appears more than once in the batch"
+			case op.Op == string(models.BatchDelete):
+				entity = &models.Item{Base: models.Base{ID: op.ID}}
+			case op.Item == nil:
+				status, msg = http.StatusBadRequest, "Item is required"
+			default:
+				entity, status, msg = h.batchUpdateTarget(c, op)
+			}
+			seen[op.ID] = true
+		default:
+			status, msg = http.StatusBadRequest, "Unknown operation"
+		}
+		if entity == nil {
+			results[i].Status, results[i].Error = status, msg
+			failed = true
+			continue
+		}
+		ops[i] = models.BatchOp{Action: models.BatchAction(op.Op), Entity: entity}
+	}
+	if failed {
+		// Report every invalid operation at once; the valid ones were not applied.
+		status := http.StatusBadRequest
+		for i := range results {
+			if results[i].Status == 0 {
+				results[i].Status, results[i].Error = http.StatusFailedDependency, "Not applied: another operation failed"
+			} else if results[i].Status != http.StatusBadRequest {
+				status = results[i].Status
+			}
+		}
+		c.JSON(status, BatchResponse{Results: results})
+		return
+	}
+
+	err := h.change(c.Request.Context(), func(tx *Handler) (*event, error) {
+		if err := tx.items.Batch(c.Request.Context(), ops); err != nil {
+			return nil, err
+		}
+		return batchEvent(ops, nil), nil
+	})
+	if err == nil {
+		for i := range ops {
+			setBatchSuccess(&results[i], ops[i])
+		}
+		c.JSON(http.StatusOK, BatchResponse{Results: results})
+		return
+	}
+
+	status, msg := batchErrorStatus(err)
+	var batchErr *models.BatchError
+	if !errors.As(err, &batchErr) {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	applied := make(map[int]bool, len(batchErr.Applied))
+	for _, i := range batchErr.Applied {
+		applied[i] = true
+		setBatchSuccess(&results[i], ops[i])
+	}
+	for i := range results {
+		switch {
+		case applied[i]:
+		case i == batchErr.Index || batchErr.Index < 0:
+			results[i].Status, results[i].Error = status, msg
+		default:
+			results[i].Status, results[i].Error = http.StatusFailedDependency, "Not applied: another operation failed"
+		}
+	}
+	// The ops applied before the failure are not in the outbox, which only
+	// records the events of changes made in full.
+	if e := batchEvent(ops, applied); e != nil {
+		h.publish(e.topic, e.msgType, e.payload)
+	}
+	c.JSON(status, BatchResponse{Results: results})
+}
+
+// batchUpdateTarget loads the item an update operation applies to and copies
+// the requested fields onto it, as UpdateItem does.
+func (h *Handler) batchUpdateTarget(c *gin.Context, op BatchOperation) (*models.Item, int, string) {
+	current, err := h.items.FindByID(c.Request.Context(), op.ID)
+	if err != nil {
+		status, msg := handleDBError(err)
+		return nil, status, msg
+	}
+	current.Name = op.Item.Name
+	current.Price = op.Item.Price
+	if op.Item.Version > 0 {
+		current.Version = op.Item.Version
+	}
+	return current, 0, ""
+}
+
+// setBatchSuccess records the outcome of an applied operation.
+func setBatchSuccess(result *BatchResult, op models.BatchOp) {
+	item := op.Entity.(*models.Item)
+	result.ID = item.ID
+	switch op.Action {
+	case models.BatchCreate:
+		result.Status, result.Item = http.StatusCreated, item
+	case models.BatchUpdate:
+		result.Status, result.Item = http.StatusOK, item
+	case models.BatchDelete:
+		result.Status = http.StatusNoContent
+	}
+}
+
+// batchErrorStatus maps a batch error to a status and client-safe message,
+// treating version conflicts as UpdateItem does.
+func batchErrorStatus(err error) (int, string) {
+	if strings.Contains(err.Error(), "version mismatch") {
+		return http.StatusConflict, "Item has been modified by another request"
+	}
+	var batchErr *models.BatchError
+	if errors.As(err, &batchErr) {
+		err = batchErr.Err
+	}
+	return handleDBError(err)
+}
+
+// batchEvent returns the item.batch event for the applied operations: all
+// of them when applied is nil, otherwise those it marks. It returns nil when
+// no operation was applied.
+func batchEvent(ops []models.BatchOp, applied map[int]bool) *event {
+	payload := BatchEvent{Created: []models.Item{}, Updated: []models.Item{}, Deleted: []uint{}}
+	n := 0
+	for i, op := range ops {
+		if applied != nil && !applied[i] {
+			continue
+		}
+		item := op.Entity.(*models.Item)
+		switch op.Action {
+		case models.BatchCreate:
+			payload.Created = append(payload.Created, *item)
+		case models.BatchUpdate:
+			payload.Updated = append(payload.Updated, *item)
+		case models.BatchDelete:
+			payload.Deleted = append(payload.Deleted, item.ID)
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	return &event{itemsTopic, "item.batch", payload}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/api/middleware"
+	"backend/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsKeepAlive is how often an idle event stream gets a comment, so that
+// proxies do not close it.
+const eventsKeepAlive = 30 * time.Second
+
+// EventsHandler streams hub events as Server-Sent Events, for clients that
+// cannot open WebSockets.
+type EventsHandler struct {
+	hub *websocket.Hub
+}
+
+// NewEventsHandler creates an EventsHandler streaming the events of hub.
+func NewEventsHandler(hub *websocket.Hub) *EventsHandler {
+	return &EventsHandler{hub: hub}
+}
+
+// StreamEvents godoc
+// @Summary Stream events
+// @Description Streams the events of the given topics as Server-Sent Events, like a WebSocket subscribed to them. The data of each event is the message a WebSocket client gets, and its id the message's seq. A client reconnecting with the Last-Event-ID header is sent the events it missed, or a resync message if they are no longer buffered.
+// @Tags events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param topics query string true "Comma-separated topics, e.g. items,users:7"
+// @Param Last-Event-ID header int false "Seq of the last event received before reconnecting"
+// @Param backpressure query string false "What to do when the client does not keep up, as for /ws" Enums(disconnect, drop-oldest, coalesce)
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/events [get]
+func (h *EventsHandler) StreamEvents(c *gin.Context) {
+	opts := websocket.ClientOptions{RemoteAddr: c.ClientIP()}
+	if raw := c.GetHeader("Last-Event-ID"); raw != "" {
+		seq, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID header"})
+			return
+		}
+		opts.Since = &seq
+	}
+	if !backpressureQuery(c, &opts) {
+		return
+	}
+
+	var topics []string
+	for _, topic := range strings.Split(c.Query("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+
+	if claims, ok := middleware.ClaimsFromContext(c); ok {
+		opts.Identity = identityOf(claims)
+	}
+
+	stream, err := websocket.OpenStream(h.hub, topics, opts)
+	switch {
+	case errors.Is(err, websocket.ErrHubClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event stream unavailable"})
+		return
+	case errors.Is(err, websocket.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot subscribe: " + err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot subscribe: " + err.Error()})
+		return
+	}
+	defer stream.Close()
+
+	var expired <-chan time.Time
+	if opts.Identity != nil && !opts.Identity.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(opts.Identity.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	// The server's write timeout is meant for ordinary responses; a stream
+	// would be cut when it expires, and the client reconnect in a loop.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Debug("Cannot clear the write deadline of an event stream", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expired:
+			// The client reconnects with fresh credentials and its Last-Event-ID.
+			return
+		case <-keepAlive.C:
+			_, err = io.WriteString(c.Writer, ": keep-alive\n\n")
+		case message, ok := <-stream.Messages():
+			if !ok {
+				return
+			}
+			err = writeEvent(c.Writer, message)
+		}
+		if err != nil {
+			slog.Debug("Event stream closed", "error", err)
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeEvent writes message as a Server-Sent Event, with the message's seq
+// as its id. Messages are single-line JSON, so one data field holds them.
+func writeEvent(w io.Writer, message []byte) error {
+	var msg struct {
+		Seq uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		slog.Warn("Skipping event that is not a message", "error", err)
+		return nil
+	}
+	if msg.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", msg.Seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", message)
+	return err
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/api/middleware"
+	"backend/internal/auth"
+	"backend/internal/models"
+	"backend/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupEventsServer serves StreamEvents for hub, authenticating requests
+// with tokens when it is not nil.
+func setupEventsServer(t *testing.T, hub *websocket.Hub, tokens *auth.Service) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if tokens != nil {
+		router.Use(middleware.Auth(tokens))
+	}
+	router.GET("/api/v1/events", NewEventsHandler(hub).StreamEvents)
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = eventsTestWriteTimeout
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// eventsTestWriteTimeout is the write timeout of the servers of
+// setupEventsServer, which streams must outlive.
+const eventsTestWriteTimeout = 100 * time.Millisecond
+
+// sseEvent is an event read from an event stream.
+type sseEvent struct {
+	id   string
+	data string
+}
+
+// readEvent reads the next event from r, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.data != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamEvents_Rejected(t *testing.T) {
+	t.Parallel()
+	tokens := newTestAuthService(t)
+	pair, err := tokens.Issue(&models.User{Base: models.Base{ID: 3}, Username: "viewer"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		query      string
+		header     http.Header
+		wantStatus int
+		wantError  string
+	}{
+		{name: "no topics", query: "", wantStatus: http.StatusBadRequest, wantError: "no topics given"},
+		{name: "invalid topic", query: "?topics=Items!", wantStatus: http.StatusBadRequest, wantError: "invalid topic"},
+		{name: "invalid Last-Event-ID", query: "?topics=items", header: http.Header{"Last-Event-Id": {"abc"}}, wantStatus: http.StatusBadRequest, wantError: "Invalid Last-Event-ID header"},
+		{name: "topic not permitted", query: "?topics=items,users", wantStatus: http.StatusForbidden, wantError: `permission denied for topic \"users\"`},
+		{name: "unauthenticated", query: "?topics=items", header: http.Header{"Authorization": nil}, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hub := websocket.NewHub()
+			go hub.Run()
+			defer hub.Shutdown()
+			hub.RequirePermission("items", auth.PermItemsRead)
+			hub.RequirePermission("users", auth.PermUsersWrite)
+			server := setupEventsServer(t, hub, tokens)
+
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/events"+tt.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			for name, values := range tt.header {
+				req.Header.Del(name)
+				for _, v := range values {
+					req.Header.Add(name, v)
+				}
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			var body strings.Builder
+			_, _ = bufio.NewReader(resp.Body).WriteTo(&body)
+			assert.Contains(t, body.String(), tt.wantError)
+			assert.Equal(t, 0, hub.ClientCount())
+		})
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	t.Parallel()
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	server := setupEventsServer(t, hub, nil)
+
+	open := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/events?topics=items", nil)
+		require.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return resp, bufio.NewReader(resp.Body)
+	}
+	decode := func(event sseEvent) websocket.Message {
+		var msg websocket.Message
+		require.NoError(t, json.Unmarshal([]byte(event.data), &msg))
+		assert.Equal(t, strconv.FormatUint(msg.Seq, 10), event.id, "the id is the seq")
+		return msg
+	}
+
+	resp, events := open("")
+	waitForHubClients(t, hub, 1)
+	hub.Publish("items:1", []byte(`{"type":"item.created","payload":{"id":1}}`))
+	created := decode(readEvent(t, events))
+	assert.Equal(t, "item.created", created.Type)
+	assert.JSONEq(t, `{"id":1}`, string(created.Payload))
+	resp.Body.Close()
+	waitForHubClients(t, hub, 0)
+
+	// Published while the client was away.
+	hub.Publish("users:1", []byte(`{"type":"user.created","payload":{"id":1}}`))
+	hub.Publish("items:1", []byte(`{"type":"item.updated","payload":{"id":1}}`))
+
+	resp, events = open(strconv.FormatUint(created.Seq, 10))
+	defer resp.Body.Close()
+	updated := decode(readEvent(t, events))
+	assert.Equal(t, "item.updated", updated.Type)
+	assert.Equal(t, created.Seq+2, updated.Seq, "the user event is skipped but sequenced")
+
+	hub.Publish("items:2", []byte(`{"type":"item.created","payload":{"id":2}}`))
+	assert.Equal(t, updated.Seq+1, decode(readEvent(t, events)).Seq)
+
+	hub.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		_, _ = events.WriteTo(new(strings.Builder))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream not ended by hub shutdown")
+	}
+}
+
+func TestStreamEvents_OutlivesWriteTimeout(t *testing.T) {
+	t.Parallel()
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	server := setupEventsServer(t, hub, nil)
+
+	resp, err := http.Get(server.URL + "/api/v1/events?topics=items")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	waitForHubClients(t, hub, 1)
+
+	time.Sleep(3 * eventsTestWriteTimeout)
+	hub.Publish("items:1", []byte(`{"type":"item.created","payload":{"id":1}}`))
+	event := readEvent(t, bufio.NewReader(resp.Body))
+	assert.Contains(t, event.data, `"type":"item.created"`)
+}
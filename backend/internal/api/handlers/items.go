@@ -1,33 +1,60 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"backend/internal/database"
 	"backend/internal/models"
+	"backend/internal/outbox"
 	"backend/internal/websocket"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	repository models.Repository
-	hub        websocket.BroadcastSender
+	store          models.Store
+	items          models.Repository[models.Item]
+	users          models.Repository[models.User]
+	hub            websocket.BroadcastSender
+	outbox         *outbox.Dispatcher
+	requireIfMatch bool
 }
 
-func NewHandler(repository models.Repository) *Handler {
-	return &Handler{repository: repository}
+func NewHandler(store models.Store) *Handler {
+	return &Handler{
+		store: store,
+		items: models.NewTypedRepository[models.Item](store),
+		users: models.NewTypedRepository[models.User](store),
+	}
 }
 
-func NewHandlerWithHub(repository models.Repository, hub websocket.BroadcastSender) *Handler {
-	return &Handler{repository: repository, hub: hub}
+func NewHandlerWithHub(store models.Store, hub websocket.BroadcastSender) *Handler {
+	h := NewHandler(store)
+	h.hub = hub
+	return h
 }
 
-func (h *Handler) broadcast(msgType string, payload interface{}) {
+// Topics handlers publish events to, with the entity topics of their
+// members (see websocket.Topic).
+const (
+	itemsTopic = "items"
+	usersTopic = "users"
+)
+
+// publish sends an event of msgType to the WebSocket clients subscribed to
+// topic.
+func (h *Handler) publish(topic, msgType string, payload interface{}) {
 	if h.hub == nil {
 		return
 	}
@@ -41,10 +68,111 @@ func (h *Handler) broadcast(msgType string, payload interface{}) {
 		slog.Error("Failed to serialise WebSocket message", "type", msgType, "error", err)
 		return
 	}
-	h.hub.Broadcast(b)
+	h.hub.Publish(topic, b)
+}
+
+// SetOutbox records change events in the store's outbox, in the same
+// transaction as the changes, for d to deliver. It has no effect unless the
+// store implements models.Outboxer.
+func (h *Handler) SetOutbox(d *outbox.Dispatcher) {
+	h.outbox = d
+}
+
+// event is a change event, published once its change is made.
+type event struct {
+	topic   string
+	msgType string
+	payload interface{}
+}
+
+// record returns e as an outbox event, with a new EventID.
+func (e *event) record() (models.OutboxEvent, error) {
+	msg, err := websocket.NewMessage(e.msgType, e.payload)
+	if err != nil {
+		return models.OutboxEvent{}, err
+	}
+	msg.EventID = models.NewEventID()
+	b, err := msg.Bytes()
+	if err != nil {
+		return models.OutboxEvent{}, err
+	}
+	return models.OutboxEvent{EventID: msg.EventID, Topic: e.topic, Message: b, CreatedAt: msg.Time}, nil
+}
+
+// change makes a change with write and publishes the event write returns,
+// if any. With an outbox, write is given a Handler writing in a transaction
+// that also records the event, for the outbox to deliver; otherwise the
+// event is published directly, and lost if the hub's queue is full.
+func (h *Handler) change(ctx context.Context, write func(tx *Handler) (*event, error)) error {
+	store, ok := h.store.(models.Outboxer)
+	if h.outbox == nil || !ok {
+		e, err := write(h)
+		if err == nil && e != nil {
+			h.publish(e.topic, e.msgType, e.payload)
+		}
+		return err
+	}
+
+	err := store.WithEvents(ctx, func(tx models.Store) ([]models.OutboxEvent, error) {
+		e, err := write(h.in(tx))
+		if err != nil || e == nil {
+			return nil, err
+		}
+		record, err := e.record()
+		if err != nil {
+			return nil, err
+		}
+		return []models.OutboxEvent{record}, nil
+	})
+	if err == nil {
+		h.outbox.Notify()
+	}
+	return err
+}
+
+// in returns a copy of h using tx, a transaction's store.
+func (h *Handler) in(tx models.Store) *Handler {
+	c := *h
+	c.store = tx
+	c.items = models.NewTypedRepository[models.Item](tx)
+	c.users = models.NewTypedRepository[models.User](tx)
+	return &c
 }
 
 func handleDBError(err error) (int, string) {
+	return handleEntityDBError(err, "Item")
+}
+
+// requestError is the failure of an item mutation that is the client's
+// doing, with the status and message to report it with.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string { return e.message }
+
+// errorResponse returns the status and client-safe message of an item
+// mutation's error: those of a *requestError, or those handleDBError maps a
+// store error to.
+func errorResponse(err error) (int, string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.status, reqErr.message
+	}
+	return handleDBError(err)
+}
+
+// respondError responds with the status and message of err; see
+// errorResponse.
+func respondError(c *gin.Context, err error) {
+	status, message := errorResponse(err)
+	c.JSON(status, gin.H{"error": message})
+}
+
+// handleEntityDBError maps repository errors to an HTTP status and a client-safe
+// message naming the affected entity (e.g. "User not found").
+func handleEntityDBError(err error, entity string) (int, string) {
 	if err == nil {
 		return http.StatusOK, ""
 	}
@@ -55,16 +183,16 @@ func handleDBError(err error) (int, string) {
 			return http.StatusBadRequest, dbErr.Error()
 		}
 		if errors.Is(dbErr.Err, database.ErrNotFound) {
-			return http.StatusNotFound, "Item not found"
+			return http.StatusNotFound, entity + " not found"
 		}
 		if errors.Is(dbErr.Err, database.ErrDuplicateKey) {
-			return http.StatusConflict, "Item already exists"
+			return http.StatusConflict, entity + " already exists"
 		}
 		return http.StatusInternalServerError, "Internal server error"
 	}
 
 	if strings.Contains(err.Error(), "not found") {
-		return http.StatusNotFound, "Item not found"
+		return http.StatusNotFound, entity + " not found"
 	}
 	// Never leak raw error messages to clients
 	return http.StatusInternalServerError, "Internal server error"
@@ -78,7 +206,9 @@ func handleDBError(err error) (int, string) {
 // @Produce json
 // @Param item body models.Item true "Item object"
 // @Success 201 {object} models.Item
+// @Header 201 {string} ETag "Entity tag of the item's version"
 // @Failure 400 {object} map[string]string
+// @Security BearerAuth
 // @Router /api/v1/items [post]
 func (h *Handler) CreateItem(c *gin.Context) {
 	var item models.Item
@@ -87,30 +217,64 @@ func (h *Handler) CreateItem(c *gin.Context) {
 		return
 	}
 
-	if item.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+	if err := h.createItem(c.Request.Context(), &item); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Version is server-managed; force initial value regardless of client input.
-	item.Version = 1
+	setItemETag(c, &item)
+	c.JSON(http.StatusCreated, item)
+}
 
-	if err := h.repository.Create(c.Request.Context(), &item); err != nil {
-		status, message := handleDBError(err)
-		c.JSON(status, gin.H{"error": message})
-		return
+// createItem creates item, for CreateItem and the items.create call.
+func (h *Handler) createItem(ctx context.Context, item *models.Item) error {
+	if err := prepareNewItem(item); err != nil {
+		return err
 	}
+	return h.change(ctx, func(tx *Handler) (*event, error) {
+		if err := tx.items.Create(ctx, item); err != nil {
+			return nil, err
+		}
+		return &event{websocket.Topic(itemsTopic, item.ID), "item.created", *item}, nil
+	})
+}
 
-	h.broadcast("item.created", item)
-	c.JSON(http.StatusCreated, item)
+// prepareNewItem validates an item sent to be created, by itself or in a
+// batch, and resets the fields the server manages.
+func prepareNewItem(item *models.Item) error {
+	if item.Name == "" {
+		return &requestError{http.StatusBadRequest, "Name is required"}
+	}
+	// ID and Version are server-managed; ignore any client input.
+	item.ID = 0
+	item.Version = 1
+	return nil
 }
 
 // GetItems godoc
 // @Summary Get all items
-// @Description Get a list of all items
+// @Description Get a list of all items. Soft-deleted items are omitted unless include_deleted=true.
+// @Description When the cursor parameter is present (empty for the first page) the response is a
+// @Description page object whose next_cursor is passed back to fetch the following page.
+// @Description Otherwise X-Total-Count holds the number of matching items and, when limit or
+// @Description envelope is given, Link holds RFC 8288 next/prev/first/last page links.
+// @Description With envelope=true the page is wrapped in an object carrying the same information.
 // @Tags items
 // @Produce json
+// @Param limit query int false "Page size (defaults to 20 with cursor or envelope)"
+// @Param offset query int false "Number of items to skip"
+// @Param include_deleted query bool false "Include soft-deleted items"
+// @Param cursor query string false "Opaque keyset pagination cursor; cannot be combined with offset"
+// @Param envelope query bool false "Wrap the page in an envelope with total count and links; cannot be combined with cursor"
+// @Param filter query string false "Filter expression over name and price, e.g. price gt 10 and (name contains \"foo\" or name startswith \"bar\"). Operators: eq, ne, gt, ge, lt, le, contains, startswith, endswith, in (...), between ... and ..., isnull; combined with and, or, not and parentheses"
+// @Param sort query string false "Comma-separated sort fields (name, price, id, created_at, updated_at), prefixed with - for descending, e.g. -price,name; cannot be combined with cursor"
 // @Success 200 {array} models.Item
+// @Success 200 {object} handlers.Page "When cursor is present"
+// @Success 200 {object} handlers.Envelope "When envelope=true"
+// @Header 200 {integer} X-Total-Count "Number of items matching the filters"
+// @Header 200 {string} Link "RFC 8288 pagination links"
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
 // @Router /api/v1/items [get]
 func (h *Handler) GetItems(c *gin.Context) {
 	// Parse query parameters
@@ -128,9 +292,48 @@ func (h *Handler) GetItems(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
 		return
 	}
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted parameter"})
+		return
+	}
+	token, useCursor := c.GetQuery("cursor")
+	if useCursor && c.Query("offset") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and offset cannot be combined"})
+		return
+	}
+	useEnvelope, err := boolQuery(c, "envelope")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid envelope parameter"})
+		return
+	}
+	if useCursor && useEnvelope {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and envelope cannot be combined"})
+		return
+	}
+	var filter models.Expr
+	if value := c.Query("filter"); value != "" {
+		if filter, err = models.ParseFilter(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter parameter: " + err.Error()})
+			return
+		}
+	}
+	var order models.Sort
+	if value := c.Query("sort"); value != "" {
+		if order, err = models.ParseSort(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort parameter"})
+			return
+		}
+		if useCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and sort cannot be combined"})
+			return
+		}
+	}
 
-	var items []models.Item
 	conditions := make([]interface{}, 0)
+	if withDeleted {
+		conditions = append(conditions, models.IncludeDeleted{})
+	}
 
 	// Handle name filtering
 	if c.Query("name_exact") != "" {
@@ -151,27 +354,79 @@ func (h *Handler) GetItems(c *gin.Context) {
 	if maxPrice > 0 {
 		conditions = append(conditions, models.Filter{Field: "price", Op: "<=", Value: maxPrice})
 	}
-	if limit > 0 {
-		conditions = append(conditions, models.Pagination{Limit: limit, Offset: offset})
+	if filter != nil {
+		conditions = append(conditions, filter)
+	}
+	if len(order) > 0 {
+		conditions = append(conditions, order)
+	}
+	var (
+		cursor *models.Cursor
+		count  *models.Count
+	)
+	switch {
+	case useCursor:
+		if limit <= 0 {
+			limit = defaultPageSize
+		}
+		cursor = &models.Cursor{Token: token, Limit: limit}
+		conditions = append(conditions, cursor)
+	case limit > 0 || useEnvelope:
+		if limit <= 0 {
+			limit = defaultPageSize
+		}
+		count = &models.Count{}
+		conditions = append(conditions, models.Pagination{Limit: limit, Offset: offset}, count)
 	}
 
-	if err := h.repository.List(c.Request.Context(), &items, conditions...); err != nil {
+	items, err := h.items.List(c.Request.Context(), conditions...)
+	if err != nil {
 		status, message := handleDBError(err)
 		c.JSON(status, gin.H{"error": message})
 		return
 	}
 
+	if cursor != nil {
+		c.JSON(http.StatusOK, Page{Data: items, NextCursor: cursor.Next})
+		return
+	}
+	if count == nil {
+		setPaginationHeaders(c, int64(len(items)), nil)
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	links := newPageLinks(c.Request.URL, limit, offset, count.Total)
+	setPaginationHeaders(c, count.Total, &links)
+	if useEnvelope {
+		c.JSON(http.StatusOK, Envelope{
+			Data:   items,
+			Total:  count.Total,
+			Limit:  limit,
+			Offset: offset,
+			Next:   links.Next,
+			Prev:   links.Prev,
+		})
+		return
+	}
 	c.JSON(http.StatusOK, items)
 }
 
 // GetItem godoc
 // @Summary Get an item by ID
-// @Description Get an item by its ID
+// @Description Get an item by its ID. Soft-deleted items are not found unless include_deleted=true.
+// @Description The ETag header identifies the item's version; sending it back in If-None-Match
+// @Description returns 304 while the item is unchanged.
 // @Tags items
 // @Produce json
 // @Param id path int true "Item ID"
+// @Param include_deleted query bool false "Include soft-deleted items"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} models.Item
+// @Header 200,304 {string} ETag "Entity tag of the item's version"
+// @Success 304 "Not Modified"
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /api/v1/items/{id} [get]
 func (h *Handler) GetItem(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -179,27 +434,50 @@ func (h *Handler) GetItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
 		return
 	}
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted parameter"})
+		return
+	}
 
-	var item models.Item
-	if err := h.repository.FindByID(c.Request.Context(), uint(id), &item); err != nil {
+	var conditions []interface{}
+	if withDeleted {
+		conditions = append(conditions, models.IncludeDeleted{})
+	}
+	item, err := h.items.FindByID(c.Request.Context(), uint(id), conditions...)
+	if err != nil {
 		status, message := handleDBError(err)
 		c.JSON(status, gin.H{"error": message})
 		return
 	}
 
+	setItemETag(c, item)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, itemETag(item), true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 	c.JSON(http.StatusOK, item)
 }
 
 // UpdateItem godoc
 // @Summary Update an item
-// @Description Update an item's information
+// @Description Update an item's information. With If-Match the update only succeeds while the item
+// @Description still has that ETag (412 otherwise); the server may be configured to require it (428).
+// @Description Without it, a version in the body is checked instead (409 on mismatch); with it, a
+// @Description version in the body must be the one If-Match names (400 otherwise).
 // @Tags items
 // @Accept json
 // @Produce json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag the item must still have"
 // @Param item body models.Item true "Item object"
 // @Success 200 {object} models.Item
+// @Header 200 {string} ETag "Entity tag of the item's new version"
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Security BearerAuth
 // @Router /api/v1/items/{id} [put]
 func (h *Handler) UpdateItem(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -208,55 +486,259 @@ func (h *Handler) UpdateItem(c *gin.Context) {
 		return
 	}
 
-	// Get the current version from the database
-	var currentItem models.Item
-	if err := h.repository.FindByID(c.Request.Context(), uint(id), &currentItem); err != nil {
-		status, message := handleDBError(err)
-		c.JSON(status, gin.H{"error": message})
-		return
-	}
-
 	var updateItem models.Item
 	if err := c.ShouldBindJSON(&updateItem); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
+	item, err := h.updateItem(c.Request.Context(), uint(id), c.GetHeader("If-Match"), updateItem)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setItemETag(c, item)
+	c.JSON(http.StatusOK, item)
+}
+
+// updateItem replaces the name and price of the item with id by those of
+// input, provided it still has the ETag ifMatch, if any, for UpdateItem and
+// the items.update call.
+func (h *Handler) updateItem(ctx context.Context, id uint, ifMatch string, input models.Item) (*models.Item, error) {
+	// Get the current version from the database
+	currentItem, err := h.items.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkIfMatch(ifMatch, currentItem); err != nil {
+		return nil, err
+	}
+	if err := checkBodyVersion(ifMatch, input.Version, currentItem); err != nil {
+		return nil, err
+	}
+
 	// Update fields from request
-	currentItem.Name = updateItem.Name
-	currentItem.Price = updateItem.Price
+	currentItem.Name = input.Name
+	currentItem.Price = input.Price
 
 	// Optimistic locking: if the client provided a version, use it so the
 	// repository can detect conflicts. If version=0 (not provided), the
 	// repository uses the version we just read — this still detects conflicts
 	// that occur between our FindByID and the repository's WHERE-version check,
 	// but the client must send the version to guarantee end-to-end safety.
-	if updateItem.Version > 0 {
-		currentItem.Version = updateItem.Version
+	if input.Version > 0 {
+		currentItem.Version = input.Version
 	}
 
-	if err := h.repository.Update(c.Request.Context(), &currentItem); err != nil {
-		if strings.Contains(err.Error(), "version mismatch") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Item has been modified by another request"})
-			return
+	err = h.change(ctx, func(tx *Handler) (*event, error) {
+		if err := tx.items.Update(ctx, currentItem); err != nil {
+			return nil, err
 		}
-		status, message := handleDBError(err)
-		c.JSON(status, gin.H{"error": message})
+		return &event{websocket.Topic(itemsTopic, currentItem.ID), "item.updated", currentItem}, nil
+	})
+	if err != nil {
+		return nil, versionConflict(err, ifMatch)
+	}
+	return currentItem, nil
+}
+
+// Media types accepted by PatchItem.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// patchableItemFields are the JSON fields of an item a patch may change,
+// mapped to their columns. version may also be set, to the version the
+// client last read, for optimistic locking.
+var patchableItemFields = map[string]string{"name": "name", "price": "price"}
+
+// PatchItem godoc
+// @Summary Partially update an item
+// @Description Apply an RFC 7396 JSON Merge Patch (application/merge-patch+json, also assumed for
+// @Description application/json) or an RFC 6902 JSON Patch (application/json-patch+json) to an item.
+// @Description Only name and price can change. Setting version, or a JSON Patch test of /version,
+// @Description makes the update conditional on the item still having that version, as does If-Match
+// @Description with its ETag (412 otherwise); the server may be configured to require If-Match (428).
+// @Description A version set with If-Match must be the one If-Match names (400 otherwise).
+// @Tags items
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag the item must still have"
+// @Param patch body object true "Merge patch object or JSON Patch operation array"
+// @Success 200 {object} models.Item
+// @Header 200 {string} ETag "Entity tag of the item's version"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/items/{id} [patch]
+func (h *Handler) PatchItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	contentType := c.ContentType()
+	if contentType != mergePatchType && contentType != jsonPatchType && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + mergePatchType + " or " + jsonPatchType})
+		return
+	}
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	item, err := h.patchItem(c.Request.Context(), uint(id), c.GetHeader("If-Match"), contentType == jsonPatchType, patch)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	h.broadcast("item.updated", currentItem)
-	c.JSON(http.StatusOK, currentItem)
+	setItemETag(c, item)
+	c.JSON(http.StatusOK, item)
+}
+
+// patchItem applies patch, a JSON Patch if jsonPatch is set and a merge
+// patch otherwise, to the item with id, provided it still has the ETag
+// ifMatch, if any, for PatchItem and the items.patch call.
+func (h *Handler) patchItem(ctx context.Context, id uint, ifMatch string, jsonPatch bool, patch []byte) (*models.Item, error) {
+	current, err := h.items.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkIfMatch(ifMatch, current); err != nil {
+		return nil, err
+	}
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	if jsonPatch {
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = ops.Apply(original)
+		}
+	} else {
+		patched, err = jsonpatch.MergePatch(original, patch)
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, &requestError{http.StatusConflict, "Patch test failed"}
+	}
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid patch: " + err.Error()}
+	}
+
+	columns, version, err := patchedItemFields(original, patched)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid patch: " + err.Error()}
+	}
+	var result models.Item
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid patch: patched item is not valid"}
+	}
+	if result.Name == "" {
+		return nil, &requestError{http.StatusBadRequest, "Name is required"}
+	}
+	if version != nil {
+		if err := checkBodyVersion(ifMatch, *version, current); err != nil {
+			return nil, err
+		}
+	}
+	if version != nil && *version != current.Version {
+		return nil, &requestError{http.StatusConflict, "Item has been modified by another request"}
+	}
+	if len(columns) == 0 {
+		return current, nil
+	}
+
+	current.Name = result.Name
+	current.Price = result.Price
+	err = h.change(ctx, func(tx *Handler) (*event, error) {
+		if err := tx.items.Patch(ctx, current, columns...); err != nil {
+			return nil, err
+		}
+		return &event{websocket.Topic(itemsTopic, current.ID), "item.updated", current}, nil
+	})
+	if err != nil {
+		return nil, versionConflict(err, ifMatch)
+	}
+	return current, nil
+}
+
+// patchedItemFields compares an item's JSON before and after a patch and
+// returns the columns that changed and the version the patch set, if any.
+// Changes to any other field are rejected.
+func patchedItemFields(original, patched []byte) ([]string, *uint, error) {
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, nil, errors.New("patched item is not an object")
+	}
+
+	var (
+		columns []string
+		version *uint
+	)
+	for field := range keysOf(before, after) {
+		if reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+		if field == "version" {
+			n, ok := after[field].(float64)
+			if !ok || n < 1 || n != math.Trunc(n) {
+				return nil, nil, errors.New("version must be a positive integer")
+			}
+			v := uint(n)
+			version = &v
+			continue
+		}
+		column, ok := patchableItemFields[field]
+		if !ok {
+			return nil, nil, fmt.Errorf("field %q cannot be changed", field)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns, version, nil
+}
+
+// keysOf returns the union of the keys of maps.
+func keysOf(maps ...map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, m := range maps {
+		for k := range m {
+			keys[k] = struct{}{}
+		}
+	}
+	return keys
 }
 
 // DeleteItem godoc
 // @Summary Delete an item
-// @Description Delete an item by its ID
+// @Description Soft-delete an item by its ID. It can be restored until it is purged.
+// @Description With If-Match the item is only deleted while it still has that ETag (412 otherwise);
+// @Description the server may be configured to require it (428).
 // @Tags items
 // @Produce json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag the item must still have"
 // @Success 204 "No Content"
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Security BearerAuth
 // @Router /api/v1/items/{id} [delete]
 func (h *Handler) DeleteItem(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -265,15 +747,97 @@ func (h *Handler) DeleteItem(c *gin.Context) {
 		return
 	}
 
+	if err := h.deleteItem(c.Request.Context(), uint(id), c.GetHeader("If-Match")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// deleteItem soft-deletes the item with id, provided it still has the ETag
+// ifMatch, if any, for DeleteItem and the items.delete call.
+func (h *Handler) deleteItem(ctx context.Context, id uint, ifMatch string) error {
 	// Delete directly — the repository returns ErrNotFound if the item doesn't exist.
 	// This avoids a race condition between a FindByID check and the actual delete.
-	item := &models.Item{Base: models.Base{ID: uint(id)}}
-	if err := h.repository.Delete(c.Request.Context(), item); err != nil {
-		status, message := handleDBError(err)
-		c.JSON(status, gin.H{"error": message})
+	item := &models.Item{Base: models.Base{ID: id}}
+	if h.ifMatchRequested(ifMatch) {
+		// The precondition needs the current ETag. Passing its version on makes
+		// the delete fail if the item changes after this read.
+		current, err := h.items.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.checkIfMatch(ifMatch, current); err != nil {
+			return err
+		}
+		if ifMatch != "*" {
+			item.Version = current.Version
+		}
+	}
+	err := h.change(ctx, func(tx *Handler) (*event, error) {
+		if err := tx.items.Delete(ctx, item); err != nil {
+			return nil, err
+		}
+		return &event{websocket.Topic(itemsTopic, id), "item.deleted", gin.H{"id": id}}, nil
+	})
+	return versionConflict(err, ifMatch)
+}
+
+// RestoreItem godoc
+// @Summary Restore a deleted item
+// @Description Restore a soft-deleted item. Restoring an item that is not deleted returns it unchanged.
+// @Tags items
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} models.Item
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/items/{id}/restore [post]
+func (h *Handler) RestoreItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
 		return
 	}
 
-	h.broadcast("item.deleted", gin.H{"id": id})
-	c.Status(http.StatusNoContent)
+	item, err := h.restoreItem(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setItemETag(c, item)
+	c.JSON(http.StatusOK, item)
+}
+
+// restoreItem restores the item with id, for RestoreItem and the
+// items.restore call.
+func (h *Handler) restoreItem(ctx context.Context, id uint) (*models.Item, error) {
+	item := &models.Item{Base: models.Base{ID: id}}
+	err := h.change(ctx, func(tx *Handler) (*event, error) {
+		restored, err := tx.items.Restore(ctx, item)
+		if err != nil || !restored {
+			return nil, err
+		}
+		return &event{websocket.Topic(itemsTopic, item.ID), "item.restored", item}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// includeDeleted parses the optional include_deleted query parameter.
+func includeDeleted(c *gin.Context) (bool, error) {
+	return boolQuery(c, "include_deleted")
+}
+
+// boolQuery parses an optional boolean query parameter, which defaults to false.
+func boolQuery(c *gin.Context, name string) (bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -37,7 +39,9 @@ func setupTestRouter() (*gin.Engine, *MockRepository) {
 		items.GET("/:id", handler.GetItem)
 		items.POST("", handler.CreateItem)
 		items.PUT("/:id", handler.UpdateItem)
+		items.PATCH("/:id", handler.PatchItem)
 		items.DELETE("/:id", handler.DeleteItem)
+		items.POST("/:id/restore", handler.RestoreItem)
 	}
 
 	return router, mockRepo
@@ -629,6 +633,81 @@ func TestRateLimiting(t *testing.T) {
 	})
 }
 
+func TestRateLimitBy(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	rl := NewRateLimiter(2, time.Minute)
+	defer rl.Stop()
+
+	router := gin.New()
+	router.Use(rl.RateLimitBy(func(c *gin.Context) (string, int) {
+		switch key := c.GetHeader("X-API-Key"); key {
+		case "":
+			return c.ClientIP(), 0
+		case "busy":
+			return "key:" + key, 4
+		default:
+			return "key:" + key, 0
+		}
+	}))
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	allowed := func(key string, n int) int {
+		ok := 0
+		for i := 0; i < n; i++ {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/ping", http.NoBody)
+			if key != "" {
+				req.Header.Set("X-API-Key", key)
+			}
+			router.ServeHTTP(w, req)
+			if w.Code == http.StatusOK {
+				ok++
+			}
+		}
+		return ok
+	}
+
+	assert.Equal(t, 2, allowed("", 5), "default limit per IP")
+	assert.Equal(t, 4, allowed("busy", 6), "key with its own limit")
+	assert.Equal(t, 2, allowed("quiet", 5), "key without an override gets the default")
+}
+
+func TestLimitFailures(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	rl := NewRateLimiter(2, time.Minute)
+	defer rl.Stop()
+
+	handled := 0
+	router := gin.New()
+	router.Use(rl.LimitFailures(http.StatusUnauthorized))
+	router.GET("/ping", func(c *gin.Context) {
+		handled++
+		if c.GetHeader("X-API-Key") != "valid" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(key string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", http.NoBody)
+		req.Header.Set("X-API-Key", key)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("valid"), "successes are not counted")
+	}
+	assert.Equal(t, http.StatusUnauthorized, serve("guess"))
+	assert.Equal(t, http.StatusUnauthorized, serve("guess"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("guess"))
+	assert.Equal(t, 5, handled, "refused requests are not handled")
+}
+
 func BenchmarkItemOperations(b *testing.B) {
 	benchmarks := []struct {
 		pathGen func(mockRepo *MockRepository) string // 8 bytes (func pointer)
@@ -951,8 +1030,11 @@ func setupTestRouterWithHub(t *testing.T, hub *MockBroadcastSender) (*gin.Engine
 		items.GET("/:id", handler.GetItem)
 		items.POST("", handler.CreateItem)
 		items.PUT("/:id", handler.UpdateItem)
+		items.PATCH("/:id", handler.PatchItem)
 		items.DELETE("/:id", handler.DeleteItem)
+		items.POST("/:id/restore", handler.RestoreItem)
 	}
+	router.POST("/api/v1/items:batch", handler.BatchItems)
 
 	return router, mockRepo
 }
@@ -1034,6 +1116,7 @@ func TestBroadcastItemEvents(t *testing.T) {
 				}
 				assert.NoError(t, json.Unmarshal(msgs[0], &env))
 				assert.Equal(t, tt.wantType, env.Type)
+				assert.Regexp(t, `^items:\d+$`, hub.Topics()[0], "published to the item's topic")
 			}
 		})
 	}
@@ -1104,6 +1187,7 @@ func TestBroadcastNilHub(t *testing.T) {
 				items.GET("/:id", handler.GetItem)
 				items.POST("", handler.CreateItem)
 				items.PUT("/:id", handler.UpdateItem)
+				items.PATCH("/:id", handler.PatchItem)
 				items.DELETE("/:id", handler.DeleteItem)
 			}
 
@@ -1293,3 +1377,769 @@ func TestBroadcastPayloadContent(t *testing.T) {
 		assert.Equal(t, uint64(id), payload.ID)
 	})
 }
+
+func TestSoftDeletedItems(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCount  int // for list requests
+	}{
+		{name: "get hides deleted item", method: "GET", path: "/api/v1/items/1", wantStatus: http.StatusNotFound},
+		{name: "get with include_deleted", method: "GET", path: "/api/v1/items/1?include_deleted=true", wantStatus: http.StatusOK},
+		{name: "list hides deleted item", method: "GET", path: "/api/v1/items", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "list with include_deleted", method: "GET", path: "/api/v1/items?include_deleted=true", wantStatus: http.StatusOK, wantCount: 2},
+		{name: "invalid include_deleted", method: "GET", path: "/api/v1/items?include_deleted=maybe", wantStatus: http.StatusBadRequest},
+		{name: "update deleted item", method: "PUT", path: "/api/v1/items/1", wantStatus: http.StatusNotFound},
+		{name: "delete deleted item", method: "DELETE", path: "/api/v1/items/1", wantStatus: http.StatusNotFound},
+		{name: "restore deleted item", method: "POST", path: "/api/v1/items/1/restore", wantStatus: http.StatusOK},
+		{name: "restore live item", method: "POST", path: "/api/v1/items/2/restore", wantStatus: http.StatusOK},
+		{name: "restore non-existent item", method: "POST", path: "/api/v1/items/999/restore", wantStatus: http.StatusNotFound},
+		{name: "restore invalid ID", method: "POST", path: "/api/v1/items/abc/restore", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupTestRouter()
+			deleted := &models.Item{Name: "Deleted", Price: 1}
+			require.NoError(t, mockRepo.Create(context.Background(), deleted))
+			require.NoError(t, mockRepo.Create(context.Background(), &models.Item{Name: "Live", Price: 2}))
+			require.NoError(t, mockRepo.Delete(context.Background(), deleted))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(`{"name":"Renamed","price":3}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantCount > 0 {
+				var items []models.Item
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+				assert.Len(t, items, tt.wantCount)
+			}
+		})
+	}
+
+	t.Run("restored item is visible again", func(t *testing.T) {
+		t.Parallel()
+		hub := &MockBroadcastSender{}
+		router, mockRepo := setupTestRouterWithHub(t, hub)
+		item := &models.Item{Name: "Deleted", Price: 1}
+		require.NoError(t, mockRepo.Create(context.Background(), item))
+		require.NoError(t, mockRepo.Delete(context.Background(), item))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/items/%d/restore", item.ID), nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, validateJSONSchema(t, itemSchema, w.Body.Bytes()))
+
+		var restored models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+		assert.False(t, restored.DeletedAt.Valid)
+		assert.Equal(t, uint(2), restored.Version)
+
+		msgs := hub.Messages()
+		require.Len(t, msgs, 1)
+		assert.Contains(t, string(msgs[0]), `"type":"item.restored"`)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/items/%d", item.ID), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("restoring a live item announces nothing", func(t *testing.T) {
+		t.Parallel()
+		hub := &MockBroadcastSender{}
+		router, mockRepo := setupTestRouterWithHub(t, hub)
+		item := &models.Item{Name: "Live", Price: 1}
+		require.NoError(t, mockRepo.Create(context.Background(), item))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/items/%d/restore", item.ID), nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var restored models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+		assert.Equal(t, uint(1), restored.Version, "a live item is returned unchanged")
+		assert.Empty(t, hub.Messages())
+	})
+}
+
+func TestListItemsCursor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "first page", query: "?cursor=&limit=2", wantStatus: http.StatusOK},
+		{name: "default limit", query: "?cursor=", wantStatus: http.StatusOK},
+		{name: "cursor with offset", query: "?cursor=&offset=2", wantStatus: http.StatusBadRequest},
+		{name: "malformed cursor", query: "?cursor=%25%25%25", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupTestRouter()
+			for i := 1; i <= 3; i++ {
+				require.NoError(t, mockRepo.Create(context.Background(), &models.Item{Name: fmt.Sprintf("Item %d", i), Price: 1}))
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/items"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+				return
+			}
+			assert.True(t, validateJSONSchema(t, itemPageSchema, w.Body.Bytes()))
+		})
+	}
+
+	t.Run("walks every page", func(t *testing.T) {
+		t.Parallel()
+		router, mockRepo := setupTestRouter()
+		for i := 1; i <= 5; i++ {
+			require.NoError(t, mockRepo.Create(context.Background(), &models.Item{Name: fmt.Sprintf("Item %d", i), Price: 1}))
+		}
+
+		var names []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 5, "cursor did not terminate")
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/items?limit=2&cursor="+url.QueryEscape(cursor), nil)
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var page struct {
+				Data       []models.Item `json:"data"`
+				NextCursor string        `json:"next_cursor"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			for _, item := range page.Data {
+				names = append(names, item.Name)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"Item 1", "Item 2", "Item 3", "Item 4", "Item 5"}, names)
+	})
+}
+
+func TestListItemsEnvelope(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTotal  string
+		wantLink   string
+		wantBody   *Envelope
+	}{
+		{
+			name:       "bare array reports the total",
+			query:      "",
+			wantStatus: http.StatusOK,
+			wantTotal:  "5",
+		},
+		{
+			name:       "limit adds links",
+			query:      "?limit=2&offset=2&name=Item",
+			wantStatus: http.StatusOK,
+			wantTotal:  "5",
+			wantLink: `</api/v1/items?limit=2&name=Item&offset=4>; rel="next", ` +
+				`</api/v1/items?limit=2&name=Item&offset=0>; rel="prev", ` +
+				`</api/v1/items?limit=2&name=Item&offset=0>; rel="first", ` +
+				`</api/v1/items?limit=2&name=Item&offset=4>; rel="last"`,
+		},
+		{
+			name:       "envelope on the first page",
+			query:      "?envelope=true&limit=2",
+			wantStatus: http.StatusOK,
+			wantTotal:  "5",
+			wantLink: `</api/v1/items?envelope=true&limit=2&offset=2>; rel="next", ` +
+				`</api/v1/items?envelope=true&limit=2&offset=0>; rel="first", ` +
+				`</api/v1/items?envelope=true&limit=2&offset=4>; rel="last"`,
+			wantBody: &Envelope{Total: 5, Limit: 2, Offset: 0, Next: "/api/v1/items?envelope=true&limit=2&offset=2"},
+		},
+		{
+			name:       "envelope on the last page",
+			query:      "?envelope=true&limit=2&offset=4",
+			wantStatus: http.StatusOK,
+			wantTotal:  "5",
+			wantLink: `</api/v1/items?envelope=true&limit=2&offset=2>; rel="prev", ` +
+				`</api/v1/items?envelope=true&limit=2&offset=0>; rel="first", ` +
+				`</api/v1/items?envelope=true&limit=2&offset=4>; rel="last"`,
+			wantBody: &Envelope{Total: 5, Limit: 2, Offset: 4, Prev: "/api/v1/items?envelope=true&limit=2&offset=2"},
+		},
+		{
+			name:       "envelope uses the default page size",
+			query:      "?envelope=1",
+			wantStatus: http.StatusOK,
+			wantTotal:  "5",
+			wantLink: `</api/v1/items?envelope=1&limit=20&offset=0>; rel="first", ` +
+				`</api/v1/items?envelope=1&limit=20&offset=0>; rel="last"`,
+			wantBody: &Envelope{Total: 5, Limit: defaultPageSize},
+		},
+		{
+			name:       "invalid envelope",
+			query:      "?envelope=maybe",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "envelope with cursor",
+			query:      "?envelope=true&cursor=",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupTestRouter()
+			for i := 1; i <= 5; i++ {
+				require.NoError(t, mockRepo.Create(context.Background(), &models.Item{Name: fmt.Sprintf("Item %d", i), Price: 1}))
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/items"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+				return
+			}
+			assert.Equal(t, tt.wantTotal, w.Header().Get("X-Total-Count"))
+			assert.Equal(t, tt.wantLink, w.Header().Get("Link"))
+
+			if tt.wantBody == nil {
+				assert.True(t, validateJSONSchema(t, itemListSchema, w.Body.Bytes()))
+				return
+			}
+			assert.True(t, validateJSONSchema(t, itemEnvelopeSchema, w.Body.Bytes()))
+			var got Envelope
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			got.Data = nil
+			assert.Equal(t, *tt.wantBody, got)
+		})
+	}
+}
+
+func TestListItemsSort(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantNames  []string
+	}{
+		{name: "price descending then name", query: "?sort=-price,name", wantStatus: http.StatusOK, wantNames: []string{"pear", "apple", "fig", "kiwi"}},
+		{name: "name ascending", query: "?sort=name", wantStatus: http.StatusOK, wantNames: []string{"apple", "fig", "kiwi", "pear"}},
+		{name: "sort with limit breaks ties by id", query: "?sort=price&limit=2", wantStatus: http.StatusOK, wantNames: []string{"kiwi", "fig"}},
+		{name: "field not sortable", query: "?sort=description", wantStatus: http.StatusBadRequest},
+		{name: "malformed sort", query: "?sort=name,,price", wantStatus: http.StatusBadRequest},
+		{name: "sort with cursor", query: "?sort=name&cursor=", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupTestRouter()
+			for _, item := range []models.Item{
+				{Name: "kiwi", Price: 1},
+				{Name: "pear", Price: 3},
+				{Name: "fig", Price: 1},
+				{Name: "apple", Price: 2},
+			} {
+				item := item
+				require.NoError(t, mockRepo.Create(context.Background(), &item))
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/items"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+				return
+			}
+			var items []models.Item
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+			names := make([]string, len(items))
+			for i, item := range items {
+				names[i] = item.Name
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestListItemsFilterExpression(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		filter     string
+		wantStatus int
+		wantNames  []string
+	}{
+		{name: "comparison", filter: "price gt 2", wantStatus: http.StatusOK, wantNames: []string{"football", "barbell"}},
+		{name: "nested or", filter: `price gt 1 and (name contains "BALL" or name startswith "bar")`, wantStatus: http.StatusOK, wantNames: []string{"football", "barbell"}},
+		{name: "in and not", filter: `name in ("chess", "barbell") and not price ge 10`, wantStatus: http.StatusOK, wantNames: []string{"chess"}},
+		{name: "between", filter: "price between 2 and 5", wantStatus: http.StatusOK, wantNames: []string{"football"}},
+		{name: "no match", filter: `name eq "racket"`, wantStatus: http.StatusOK, wantNames: []string{}},
+		{name: "syntax error", filter: "price >> 2", wantStatus: http.StatusBadRequest},
+		{name: "field not filterable", filter: "version eq 1", wantStatus: http.StatusBadRequest},
+		{name: "wrong value type", filter: `price eq "cheap"`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupTestRouter()
+			for _, item := range []models.Item{
+				{Name: "chess", Price: 1},
+				{Name: "football", Price: 3},
+				{Name: "barbell", Price: 40},
+			} {
+				item := item
+				require.NoError(t, mockRepo.Create(context.Background(), &item))
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/items?filter="+url.QueryEscape(tt.filter), nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+				return
+			}
+			var items []models.Item
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+			names := make([]string, len(items))
+			for i, item := range items {
+				names[i] = item.Name
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestBatchItems(t *testing.T) {
+	t.Parallel()
+
+	seed := func(t *testing.T, repo *MockRepository) (*models.Item, *models.Item) {
+		t.Helper()
+		keep := &models.Item{Name: "keep", Price: 1}
+		drop := &models.Item{Name: "drop", Price: 2}
+		require.NoError(t, repo.Create(context.Background(), keep))
+		require.NoError(t, repo.Create(context.Background(), drop))
+		return keep, drop
+	}
+
+	tests := []struct {
+		name         string
+		body         func(keep, drop *models.Item) string
+		wantCode     int
+		wantStatuses []int
+		wantNames    []string // live item names afterwards
+		wantMsgs     int
+	}{
+		{
+			name: "applies every operation",
+			body: func(keep, drop *models.Item) string {
+				return fmt.Sprintf(`{"operations":[
+					{"op":"create","item":{"name":"new","price":3}},
+					{"op":"update","id":%d,"item":{"name":"kept","price":4,"version":1}},
+					{"op":"delete","id":%d}]}`, keep.ID, drop.ID)
+			},
+			wantCode:     http.StatusOK,
+			wantStatuses: []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+			wantNames:    []string{"kept", "new"},
+			wantMsgs:     1,
+		},
+		{
+			name: "a failed operation rolls back the others",
+			body: func(keep, drop *models.Item) string {
+				return fmt.Sprintf(`{"operations":[
+					{"op":"create","item":{"name":"new","price":3}},
+					{"op":"delete","id":%d},
+					{"op":"update","id":%d,"item":{"name":"stale","price":4,"version":7}}]}`, drop.ID, keep.ID)
+			},
+			wantCode:     http.StatusConflict,
+			wantStatuses: []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusConflict},
+			wantNames:    []string{"keep", "drop"},
+		},
+		{
+			name: "invalid operations are all reported before anything is applied",
+			body: func(keep, drop *models.Item) string {
+				return fmt.Sprintf(`{"operations":[
+					{"op":"create","item":{"price":3}},
+					{"op":"delete","id":%d},
+					{"op":"delete","id":%d},
+					{"op":"upsert","id":1},
+					{"op":"update","id":999,"item":{"name":"x"}}]}`, drop.ID, drop.ID)
+			},
+			wantCode: http.StatusNotFound,
+			wantStatuses: []int{http.StatusBadRequest, http.StatusFailedDependency, http.StatusBadRequest,
+				http.StatusBadRequest, http.StatusNotFound},
+			wantNames: []string{"keep", "drop"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hub := &MockBroadcastSender{}
+			router, repo := setupTestRouterWithHub(t, hub)
+			keep, drop := seed(t, repo)
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/items:batch", bytes.NewBufferString(tt.body(keep, drop)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			var resp BatchResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			statuses := make([]int, len(resp.Results))
+			for i, r := range resp.Results {
+				assert.Equal(t, i, r.Index)
+				statuses[i] = r.Status
+			}
+			assert.Equal(t, tt.wantStatuses, statuses)
+
+			var live []models.Item
+			require.NoError(t, repo.List(context.Background(), &live))
+			names := make([]string, len(live))
+			for i, item := range live {
+				names[i] = item.Name
+			}
+			assert.ElementsMatch(t, tt.wantNames, names)
+			assert.Len(t, hub.Messages(), tt.wantMsgs)
+		})
+	}
+
+	t.Run("one aggregated message describes the batch", func(t *testing.T) {
+		t.Parallel()
+		hub := &MockBroadcastSender{}
+		router, repo := setupTestRouterWithHub(t, hub)
+		keep, drop := seed(t, repo)
+
+		body := fmt.Sprintf(`{"operations":[{"op":"create","item":{"name":"a","price":1}},{"op":"create","item":{"name":"b","price":1}},{"op":"delete","id":%d},{"op":"update","id":%d,"item":{"name":"k","price":1}}]}`, drop.ID, keep.ID)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/items:batch", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		msgs := hub.Messages()
+		require.Len(t, msgs, 1)
+		var env struct {
+			Type    string     `json:"type"`
+			Payload BatchEvent `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(msgs[0], &env))
+		assert.Equal(t, "item.batch", env.Type)
+		assert.Equal(t, []string{"items"}, hub.Topics(), "batches go to the collection topic")
+		assert.Len(t, env.Payload.Created, 2)
+		require.Len(t, env.Payload.Updated, 1)
+		assert.Equal(t, uint(2), env.Payload.Updated[0].Version)
+		assert.Equal(t, []uint{drop.ID}, env.Payload.Deleted)
+	})
+
+	t.Run("creates get server-managed fields", func(t *testing.T) {
+		t.Parallel()
+		// MockRepository assigns IDs itself, so a store honouring the
+		// client's ID is needed to tell whether it was cleared.
+		router, _, _ := setupOutboxTestRouter(t, &MockBroadcastSender{})
+		serve := func(path, body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := serve("/api/v1/items", `{"id":1,"name":"first","price":1,"version":7}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var first models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+		assert.Equal(t, uint(1), first.Version)
+
+		w = serve("/api/v1/items:batch", fmt.Sprintf(`{"operations":[{"op":"create","item":{"id":%d,"name":"new","price":1,"version":7}}]}`, first.ID))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp BatchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Results, 1)
+		assert.NotEqual(t, first.ID, resp.Results[0].ID, "a client-supplied ID is ignored")
+		assert.Equal(t, uint(1), resp.Results[0].Item.Version)
+	})
+
+	t.Run("request limits", func(t *testing.T) {
+		t.Parallel()
+		router, _ := setupTestRouterWithHub(t, &MockBroadcastSender{})
+
+		tooMany := BatchRequest{Operations: make([]BatchOperation, maxBatchOperations+1)}
+		tooManyBody, err := json.Marshal(tooMany)
+		require.NoError(t, err)
+
+		for _, body := range []string{`{"operations":[]}`, `not json`, string(tooManyBody)} {
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/items:batch", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestPatchItem(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		missing     bool
+		wantStatus  int
+		wantName    string
+		wantPrice   float64
+		wantVersion uint
+	}{
+		{
+			name:        "merge patch leaves omitted fields alone",
+			contentType: "application/merge-patch+json",
+			body:        `{"price":12.5}`,
+			wantStatus:  http.StatusOK,
+			wantName:    "Widget", wantPrice: 12.5, wantVersion: 2,
+		},
+		{
+			name:        "plain JSON is a merge patch",
+			contentType: "application/json",
+			body:        `{"name":"Gadget","version":1}`,
+			wantStatus:  http.StatusOK,
+			wantName:    "Gadget", wantPrice: 10, wantVersion: 2,
+		},
+		{
+			name:        "JSON patch with a version test",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/version","value":1},{"op":"replace","path":"/name","value":"Gizmo"}]`,
+			wantStatus:  http.StatusOK,
+			wantName:    "Gizmo", wantPrice: 10, wantVersion: 2,
+		},
+		{
+			name:        "unchanged item is not written",
+			contentType: "application/merge-patch+json",
+			body:        `{"name":"Widget"}`,
+			wantStatus:  http.StatusOK,
+			wantName:    "Widget", wantPrice: 10, wantVersion: 1,
+		},
+		{
+			name:        "failed JSON patch test",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/name","value":"Other"},{"op":"remove","path":"/price"}]`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "stale version",
+			contentType: "application/merge-patch+json",
+			body:        `{"price":1,"version":5}`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "server-managed field",
+			contentType: "application/merge-patch+json",
+			body:        `{"id":99}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/merge-patch+json",
+			body:        `{"colour":"red"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "removing the name",
+			contentType: "application/merge-patch+json",
+			body:        `{"name":null}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "wrong value type",
+			contentType: "application/merge-patch+json",
+			body:        `{"price":"cheap"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "malformed JSON patch",
+			contentType: "application/json-patch+json",
+			body:        `{"op":"replace"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "text/plain",
+			body:        `price=1`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "missing item",
+			contentType: "application/merge-patch+json",
+			body:        `{"price":1}`,
+			missing:     true,
+			wantStatus:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, repo := setupTestRouter()
+			item := &models.Item{Name: "Widget", Price: 10}
+			require.NoError(t, repo.Create(context.Background(), item))
+			id := item.ID
+			if tt.missing {
+				id = 999
+			}
+
+			req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("/api/v1/items/%d", id), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			stored, err := models.NewTypedRepository[models.Item](repo).FindByID(context.Background(), item.ID)
+			require.NoError(t, err)
+			if tt.wantStatus != http.StatusOK {
+				assert.Equal(t, uint(1), stored.Version, "a rejected patch must not write")
+				return
+			}
+			var got models.Item
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			for _, it := range []*models.Item{&got, stored} {
+				assert.Equal(t, tt.wantName, it.Name)
+				assert.Equal(t, tt.wantPrice, it.Price)
+				assert.Equal(t, tt.wantVersion, it.Version)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		header         string
+		value          string
+		body           string
+		requireIfMatch bool
+		wantStatus     int
+		wantETag       string
+	}{
+		{name: "get emits the version's ETag", method: http.MethodGet, wantStatus: http.StatusOK, wantETag: `"1"`},
+		{name: "get with a matching If-None-Match", method: http.MethodGet, header: "If-None-Match", value: `"1"`, wantStatus: http.StatusNotModified, wantETag: `"1"`},
+		{name: "get with a weak If-None-Match", method: http.MethodGet, header: "If-None-Match", value: `"0", W/"1"`, wantStatus: http.StatusNotModified, wantETag: `"1"`},
+		{name: "get with If-None-Match any", method: http.MethodGet, header: "If-None-Match", value: "*", wantStatus: http.StatusNotModified, wantETag: `"1"`},
+		{name: "get with a stale If-None-Match", method: http.MethodGet, header: "If-None-Match", value: `"0"`, wantStatus: http.StatusOK, wantETag: `"1"`},
+		{name: "put with a matching If-Match", method: http.MethodPut, header: "If-Match", value: `"1"`, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "put with one matching tag of several", method: http.MethodPut, header: "If-Match", value: `"7", "1"`, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "put with a stale If-Match", method: http.MethodPut, header: "If-Match", value: `"7"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "put with a weak If-Match", method: http.MethodPut, header: "If-Match", value: `W/"1"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "put without If-Match when optional", method: http.MethodPut, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "put without If-Match when required", method: http.MethodPut, requireIfMatch: true, wantStatus: http.StatusPreconditionRequired},
+		{name: "put with If-Match when required", method: http.MethodPut, header: "If-Match", value: `"1"`, requireIfMatch: true, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "put with a matching If-Match and the same body version", method: http.MethodPut, header: "If-Match", value: `"1"`, body: `{"name":"Renamed","price":2,"version":1}`, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "put with a matching If-Match and another body version", method: http.MethodPut, header: "If-Match", value: `"1"`, body: `{"name":"Renamed","price":2,"version":3}`, wantStatus: http.StatusBadRequest},
+		{name: "put with If-Match any and another body version", method: http.MethodPut, header: "If-Match", value: "*", body: `{"name":"Renamed","price":2,"version":3}`, wantStatus: http.StatusConflict},
+		{name: "patch with a matching If-Match", method: http.MethodPatch, header: "If-Match", value: `"1"`, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "patch with a matching If-Match and another version", method: http.MethodPatch, header: "If-Match", value: `"1"`, body: `{"price":2,"version":3}`, wantStatus: http.StatusBadRequest},
+		{name: "patch with a stale If-Match", method: http.MethodPatch, header: "If-Match", value: `"7"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "patch without If-Match when required", method: http.MethodPatch, requireIfMatch: true, wantStatus: http.StatusPreconditionRequired},
+		{name: "delete with a matching If-Match", method: http.MethodDelete, header: "If-Match", value: `"1"`, wantStatus: http.StatusNoContent},
+		{name: "delete with If-Match any", method: http.MethodDelete, header: "If-Match", value: "*", requireIfMatch: true, wantStatus: http.StatusNoContent},
+		{name: "delete with a stale If-Match", method: http.MethodDelete, header: "If-Match", value: `"7"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "delete without If-Match when required", method: http.MethodDelete, requireIfMatch: true, wantStatus: http.StatusPreconditionRequired},
+		{name: "delete a missing item with If-Match", method: http.MethodDelete, id: "999", header: "If-Match", value: `"1"`, wantStatus: http.StatusNotFound},
+	}
+
+	bodies := map[string]string{
+		http.MethodPut:   `{"name":"Renamed","price":2}`,
+		http.MethodPatch: `{"price":2}`,
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gin.SetMode(gin.TestMode)
+			repo := NewMockRepository()
+			handler := NewHandler(repo)
+			handler.SetRequireIfMatch(tt.requireIfMatch)
+			router := gin.New()
+			router.GET("/api/v1/items/:id", handler.GetItem)
+			router.PUT("/api/v1/items/:id", handler.UpdateItem)
+			router.PATCH("/api/v1/items/:id", handler.PatchItem)
+			router.DELETE("/api/v1/items/:id", handler.DeleteItem)
+
+			item := &models.Item{Name: "Widget", Price: 1, Version: 1}
+			require.NoError(t, repo.Create(context.Background(), item))
+			id := tt.id
+			if id == "" {
+				id = fmt.Sprint(item.ID)
+			}
+
+			body := tt.body
+			if body == "" {
+				body = bodies[tt.method]
+			}
+			req, _ := http.NewRequest(tt.method, "/api/v1/items/"+id, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			assert.Equal(t, tt.wantETag, w.Header().Get("ETag"))
+			if tt.wantStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+			if tt.wantStatus >= http.StatusBadRequest {
+				stored, err := models.NewTypedRepository[models.Item](repo).FindByID(context.Background(), item.ID)
+				require.NoError(t, err, "a failed precondition must not delete the item")
+				assert.Equal(t, uint(1), stored.Version, "a failed precondition must not write")
+			}
+		})
+	}
+}
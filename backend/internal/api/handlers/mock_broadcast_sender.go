@@ -1,12 +1,17 @@
 package handlers
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // MockBroadcastSender is a test double for websocket.BroadcastSender that
-// records all messages passed to Broadcast for assertion in unit tests.
+// records all messages passed to Broadcast and Publish for assertion in unit
+// tests.
 type MockBroadcastSender struct {
 	mu       sync.Mutex
 	messages [][]byte
+	topics   []string
 }
 
 // Broadcast records the message for later inspection.
@@ -16,6 +21,24 @@ func (m *MockBroadcastSender) Broadcast(message []byte) {
 	cp := make([]byte, len(message))
 	copy(cp, message)
 	m.messages = append(m.messages, cp)
+	m.topics = append(m.topics, "")
+}
+
+// Publish records the message and its topic for later inspection.
+func (m *MockBroadcastSender) Publish(topic string, message []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := make([]byte, len(message))
+	copy(cp, message)
+	m.messages = append(m.messages, cp)
+	m.topics = append(m.topics, topic)
+}
+
+// PublishWait records the message and its topic like Publish, so that the
+// mock can also stand in for the hub of an outbox.Dispatcher.
+func (m *MockBroadcastSender) PublishWait(_ context.Context, topic string, message []byte) error {
+	m.Publish(topic, message)
+	return nil
 }
 
 // Messages returns a deep copy of all recorded messages.
@@ -31,9 +54,18 @@ func (m *MockBroadcastSender) Messages() [][]byte {
 	return result
 }
 
+// Topics returns the topic of each recorded message, in order; "" for
+// broadcasts.
+func (m *MockBroadcastSender) Topics() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.topics...)
+}
+
 // Reset clears all recorded messages.
 func (m *MockBroadcastSender) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.messages = nil
+	m.topics = nil
 }
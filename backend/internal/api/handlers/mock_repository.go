@@ -1,20 +1,26 @@
 package handlers
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"backend/internal/models"
+	"backend/pkg/dberrors"
+
+	"gorm.io/gorm"
 )
 
 // MockRepository is a mock implementation of the Repository interface for testing
 type MockRepository struct {
 	sync.RWMutex                       // size: 8
 	items        map[uint]*models.Item // size: 8 (pointer)
+	users        map[uint]*models.User // size: 8 (pointer)
 	err          error                 // size: 8 (interface)
 	updateError  error                 // size: 8 (interface)
 	nextID       uint                  // size: 8
@@ -23,6 +29,7 @@ type MockRepository struct {
 func NewMockRepository() *MockRepository {
 	return &MockRepository{
 		items:  make(map[uint]*models.Item),
+		users:  make(map[uint]*models.User),
 		nextID: 1,
 	}
 }
@@ -35,6 +42,17 @@ func (m *MockRepository) Create(_ context.Context, entity interface{}) error {
 		return m.err
 	}
 
+	if user, ok := entity.(*models.User); ok {
+		if m.userConflict(user) {
+			return dberrors.NewDatabaseError("create", dberrors.ErrDuplicateKey)
+		}
+		user.ID = m.nextID
+		m.nextID++
+		stored := *user
+		m.users[user.ID] = &stored
+		return nil
+	}
+
 	item, ok := entity.(*models.Item)
 	if !ok {
 		return errors.New("invalid entity type")
@@ -47,7 +65,7 @@ func (m *MockRepository) Create(_ context.Context, entity interface{}) error {
 	return nil
 }
 
-func (m *MockRepository) FindByID(_ context.Context, id uint, dest interface{}) error {
+func (m *MockRepository) FindByID(_ context.Context, id uint, dest interface{}, conditions ...interface{}) error {
 	m.RLock()
 	defer m.RUnlock()
 
@@ -55,13 +73,23 @@ func (m *MockRepository) FindByID(_ context.Context, id uint, dest interface{})
 		return fmt.Errorf("database error: %w", m.err)
 	}
 
+	includeDeleted := models.IncludesDeleted(conditions)
+	if userDest, ok := dest.(*models.User); ok {
+		user, exists := m.users[id]
+		if !exists || (user.DeletedAt.Valid && !includeDeleted) {
+			return errors.New("user not found")
+		}
+		*userDest = *user
+		return nil
+	}
+
 	itemDest, ok := dest.(*models.Item)
 	if !ok {
 		return errors.New("invalid destination type")
 	}
 
 	item, exists := m.items[id]
-	if !exists {
+	if !exists || (item.DeletedAt.Valid && !includeDeleted) {
 		return errors.New("item not found")
 	}
 
@@ -81,13 +109,25 @@ func (m *MockRepository) Update(_ context.Context, entity interface{}) error {
 		return m.err
 	}
 
+	if user, ok := entity.(*models.User); ok {
+		if existing, exists := m.users[user.ID]; !exists || existing.DeletedAt.Valid {
+			return errors.New("user not found")
+		}
+		if m.userConflict(user) {
+			return dberrors.NewDatabaseError("update", dberrors.ErrDuplicateKey)
+		}
+		stored := *user
+		m.users[user.ID] = &stored
+		return nil
+	}
+
 	item, ok := entity.(*models.Item)
 	if !ok {
 		return errors.New("invalid entity type")
 	}
 
 	currentItem, exists := m.items[item.ID]
-	if !exists {
+	if !exists || currentItem.DeletedAt.Valid {
 		return errors.New("item not found")
 	}
 
@@ -109,6 +149,16 @@ func (m *MockRepository) Update(_ context.Context, entity interface{}) error {
 	return nil
 }
 
+// Patch implements models.Patcher. The mock stores whole copies, so it
+// checks the columns and then behaves like Update.
+func (m *MockRepository) Patch(ctx context.Context, entity interface{}, columns ...string) error {
+	if err := models.PatchColumns(entity, columns); err != nil {
+		return dberrors.NewDatabaseError("patch", err)
+	}
+	return m.Update(ctx, entity)
+}
+
+// Delete soft-deletes the entity, mirroring the real repositories.
 func (m *MockRepository) Delete(_ context.Context, entity interface{}) error {
 	m.Lock()
 	defer m.Unlock()
@@ -117,19 +167,103 @@ func (m *MockRepository) Delete(_ context.Context, entity interface{}) error {
 		return m.err
 	}
 
+	deletedAt := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	if user, ok := entity.(*models.User); ok {
+		existing, exists := m.users[user.ID]
+		if !exists || existing.DeletedAt.Valid {
+			return errors.New("user not found")
+		}
+		existing.DeletedAt = deletedAt
+		return nil
+	}
+
 	item, ok := entity.(*models.Item)
 	if !ok {
 		return errors.New("invalid entity type")
 	}
 
-	if _, exists := m.items[item.ID]; !exists {
+	existing, exists := m.items[item.ID]
+	if !exists || existing.DeletedAt.Valid {
 		return errors.New("item not found")
 	}
-
-	delete(m.items, item.ID)
+	if item.Version > 0 && item.Version != existing.Version {
+		return errors.New("version mismatch")
+	}
+	existing.DeletedAt = deletedAt
 	return nil
 }
 
+// Restore implements the Store interface
+func (m *MockRepository) Restore(_ context.Context, entity interface{}) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.err != nil {
+		return false, m.err
+	}
+
+	if user, ok := entity.(*models.User); ok {
+		existing, exists := m.users[user.ID]
+		if !exists {
+			return false, dberrors.NewDatabaseError("restore", dberrors.ErrNotFound)
+		}
+		restored := existing.DeletedAt.Valid
+		existing.DeletedAt = gorm.DeletedAt{}
+		*user = *existing
+		return restored, nil
+	}
+
+	item, ok := entity.(*models.Item)
+	if !ok {
+		return false, errors.New("invalid entity type")
+	}
+
+	existing, exists := m.items[item.ID]
+	if !exists {
+		return false, dberrors.NewDatabaseError("restore", dberrors.ErrNotFound)
+	}
+	restored := existing.DeletedAt.Valid
+	if restored {
+		existing.DeletedAt = gorm.DeletedAt{}
+		existing.Version++
+	}
+	*item = *existing
+	return restored, nil
+}
+
+// Purge implements the Store interface
+func (m *MockRepository) Purge(_ context.Context, model interface{}, deletedBefore time.Time) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.err != nil {
+		return 0, m.err
+	}
+
+	var purged int64
+	switch model.(type) {
+	case *models.User:
+		for id, user := range m.users {
+			if user.DeletedAt.Valid && user.DeletedAt.Time.Before(deletedBefore) {
+				delete(m.users, id)
+				purged++
+			}
+		}
+	case *models.Item:
+		for id, item := range m.items {
+			if item.DeletedAt.Valid && item.DeletedAt.Time.Before(deletedBefore) {
+				delete(m.items, id)
+				purged++
+			}
+		}
+	case *models.APIKey:
+		// API keys are not stored by the mock; tests of them use SQLite.
+	default:
+		return 0, errors.New("invalid entity type")
+	}
+	return purged, nil
+}
+
 func (m *MockRepository) List(_ context.Context, dest interface{}, conditions ...interface{}) error {
 	m.RLock()
 	defer m.RUnlock()
@@ -138,6 +272,12 @@ func (m *MockRepository) List(_ context.Context, dest interface{}, conditions ..
 		return m.err
 	}
 
+	includeDeleted := models.IncludesDeleted(conditions)
+	if users, ok := dest.(*[]models.User); ok {
+		*users = m.listUsers(includeDeleted, conditions...)
+		return nil
+	}
+
 	items, ok := dest.(*[]models.Item)
 	if !ok {
 		return errors.New("invalid destination type")
@@ -154,11 +294,16 @@ func (m *MockRepository) List(_ context.Context, dest interface{}, conditions ..
 		return ids[i] < ids[j]
 	})
 	for _, id := range ids {
+		if m.items[id].DeletedAt.Valid && !includeDeleted {
+			continue
+		}
 		allItems = append(allItems, *m.items[id])
 	}
 
 	// Apply filters and pagination
 	var pagination *models.Pagination
+	var cursor *models.Cursor
+	var count *models.Count
 	filteredItems := allItems // Start with all items
 
 	// Apply filters
@@ -200,9 +345,48 @@ func (m *MockRepository) List(_ context.Context, dest interface{}, conditions ..
 				}
 				filteredItems = tmpItems
 			}
+		case models.Expr:
+			info, _ := models.LookupModel(models.Item{})
+			bound, err := models.BindExpr(cond, info.Type, info.FilterFields)
+			if err != nil {
+				return dberrors.NewDatabaseError("list", err)
+			}
+			tmpItems := make([]models.Item, 0)
+			for _, item := range filteredItems {
+				matched, err := models.MatchExpr(bound, item)
+				if err != nil {
+					return err
+				}
+				if matched {
+					tmpItems = append(tmpItems, item)
+				}
+			}
+			filteredItems = tmpItems
 		case models.Pagination:
 			pagination = &cond
+		case *models.Cursor:
+			cursor = cond
+		case *models.Count:
+			count = cond
+		case models.Sort:
+			if err := sortItems(filteredItems, cond); err != nil {
+				return err
+			}
+		}
+	}
+
+	if count != nil {
+		count.Total = int64(len(filteredItems))
+	}
+
+	// Apply keyset pagination on ID, mirroring GenericRepository
+	if cursor != nil {
+		page, err := keysetPage(filteredItems, cursor)
+		if err != nil {
+			return err
 		}
+		*items = page
+		return nil
 	}
 
 	// Apply pagination
@@ -224,6 +408,137 @@ func (m *MockRepository) List(_ context.Context, dest interface{}, conditions ..
 	return nil
 }
 
+// sortItems orders items (already sorted by ID) by the sortable item fields,
+// mirroring the validation and tie-breaking of the real repositories.
+func sortItems(items []models.Item, order models.Sort) error {
+	for _, f := range order {
+		switch f.Field {
+		case "id", "name", "price", "created_at", "updated_at":
+		default:
+			return dberrors.NewDatabaseError("list", fmt.Errorf("%w: invalid sort field: %q", dberrors.ErrValidation, f.Field))
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		for _, f := range order {
+			var c int
+			switch f.Field {
+			case "id":
+				c = cmp.Compare(a.ID, b.ID)
+			case "name":
+				c = cmp.Compare(a.Name, b.Name)
+			case "price":
+				c = cmp.Compare(a.Price, b.Price)
+			case "created_at":
+				c = a.CreatedAt.Compare(b.CreatedAt)
+			case "updated_at":
+				c = a.UpdatedAt.Compare(b.UpdatedAt)
+			}
+			if c != 0 {
+				return (c < 0) != f.Desc
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// mockCursor is the token payload issued by MockRepository.
+type mockCursor struct {
+	ID uint `json:"id"`
+}
+
+// keysetPage returns the items after cursor.Token (items must be sorted by
+// ID) and sets cursor.Next.
+func keysetPage(items []models.Item, cursor *models.Cursor) ([]models.Item, error) {
+	if cursor.Limit <= 0 {
+		return nil, dberrors.NewDatabaseError("list", dberrors.ErrValidation)
+	}
+	var after mockCursor
+	if cursor.Token != "" {
+		if err := models.DecodeCursor(cursor.Token, &after); err != nil {
+			return nil, dberrors.NewDatabaseError("list", fmt.Errorf("%w: %s", dberrors.ErrValidation, err.Error()))
+		}
+	}
+
+	page := make([]models.Item, 0, cursor.Limit)
+	cursor.Next = ""
+	for _, item := range items {
+		if item.ID <= after.ID {
+			continue
+		}
+		if len(page) == cursor.Limit {
+			cursor.Next = models.EncodeCursor(mockCursor{ID: page[len(page)-1].ID})
+			break
+		}
+		page = append(page, item)
+	}
+	return page, nil
+}
+
+// listUsers returns users sorted by ID, applying exact username/email filters
+// and pagination. The caller must hold at least a read lock.
+func (m *MockRepository) listUsers(includeDeleted bool, conditions ...interface{}) []models.User {
+	ids := make([]uint, 0, len(m.users))
+	for id := range m.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var pagination *models.Pagination
+	result := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		if m.users[id].DeletedAt.Valid && !includeDeleted {
+			continue
+		}
+		result = append(result, *m.users[id])
+	}
+	for _, condition := range conditions {
+		switch cond := condition.(type) {
+		case models.Filter:
+			value, _ := cond.Value.(string)
+			filtered := make([]models.User, 0, len(result))
+			for _, user := range result {
+				if (cond.Field == "username" && user.Username == value) ||
+					(cond.Field == "email" && user.Email == value) {
+					filtered = append(filtered, user)
+				}
+			}
+			result = filtered
+		case models.Pagination:
+			pagination = &cond
+		}
+	}
+
+	if pagination != nil {
+		start := pagination.Offset
+		if start >= len(result) {
+			return []models.User{}
+		}
+		end := start + pagination.Limit
+		if end > len(result) {
+			end = len(result)
+		}
+		result = result[start:end]
+	}
+	return result
+}
+
+// userConflict reports whether another user already has the same username or
+// email, mimicking the unique indexes of the real database.
+// The caller must hold at least a read lock.
+func (m *MockRepository) userConflict(user *models.User) bool {
+	for id, existing := range m.users {
+		if id == user.ID {
+			continue
+		}
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return true
+		}
+	}
+	return false
+}
+
 // Ping implements the Repository interface
 func (m *MockRepository) Ping(_ context.Context) error {
 	return nil
@@ -245,3 +560,52 @@ func (m *MockRepository) SetUpdateError(err error) {
 	defer m.Unlock()
 	m.updateError = err
 }
+
+// Batch implements models.Batcher. The stored items and users are restored
+// if any op fails, so batches are all-or-nothing like the GORM repository.
+func (m *MockRepository) Batch(ctx context.Context, ops []models.BatchOp) error {
+	m.RLock()
+	items := make(map[uint]models.Item, len(m.items))
+	for id, item := range m.items {
+		items[id] = *item
+	}
+	users := make(map[uint]models.User, len(m.users))
+	for id, user := range m.users {
+		users[id] = *user
+	}
+	nextID := m.nextID
+	m.RUnlock()
+
+	for i, op := range ops {
+		var err error
+		switch op.Action {
+		case models.BatchCreate:
+			err = m.Create(ctx, op.Entity)
+		case models.BatchUpdate:
+			err = m.Update(ctx, op.Entity)
+		case models.BatchDelete:
+			err = m.Delete(ctx, op.Entity)
+		default:
+			err = dberrors.NewDatabaseError("batch", dberrors.ErrValidation)
+		}
+		if err == nil {
+			continue
+		}
+
+		m.Lock()
+		m.items = make(map[uint]*models.Item, len(items))
+		for id, item := range items {
+			item := item
+			m.items[id] = &item
+		}
+		m.users = make(map[uint]*models.User, len(users))
+		for id, user := range users {
+			user := user
+			m.users[id] = &user
+		}
+		m.nextID = nextID
+		m.Unlock()
+		return &models.BatchError{Index: i, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+	"backend/internal/outbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupOutboxTestRouter serves the item endpoints from a SQLite store with
+// an outbox, since MockRepository has none. The outbox's dispatcher is
+// returned without being run.
+func setupOutboxTestRouter(t *testing.T, hub *MockBroadcastSender) (*gin.Engine, models.Outboxer, *outbox.Dispatcher) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Each connection to ":memory:" opens a database of its own.
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.Item{}, &models.OutboxEvent{}))
+	store := models.NewRepository(db).(models.Outboxer)
+
+	dispatcher := outbox.NewDispatcher(store, hub)
+	handler := NewHandlerWithHub(store.(models.Store), hub)
+	handler.SetOutbox(dispatcher)
+
+	router := gin.New()
+	router.POST("/api/v1/items", handler.CreateItem)
+	router.PUT("/api/v1/items/:id", handler.UpdateItem)
+	router.POST("/api/v1/items:batch", handler.BatchItems)
+	return router, store, dispatcher
+}
+
+func TestItemEventsThroughOutbox(t *testing.T) {
+	t.Parallel()
+
+	hub := &MockBroadcastSender{}
+	router, store, dispatcher := setupOutboxTestRouter(t, hub)
+	requests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{method: "POST", path: "/api/v1/items", body: `{"name":"Widget","price":1}`, wantStatus: http.StatusCreated},
+		{method: "PUT", path: "/api/v1/items/999", body: `{"name":"Missing","price":1}`, wantStatus: http.StatusNotFound},
+		{method: "POST", path: "/api/v1/items:batch", body: `{"operations":[{"op":"create","item":{"name":"A","price":1}},{"op":"create","item":{"name":"B","price":2}}]}`, wantStatus: http.StatusOK},
+		{method: "POST", path: "/api/v1/items:batch", body: `{"operations":[{"op":"delete","id":999}]}`, wantStatus: http.StatusNotFound},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewBufferString(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, r.wantStatus, w.Code, "%s %s: %s", r.method, r.path, w.Body.String())
+	}
+
+	assert.Empty(t, hub.Messages(), "events are delivered by the dispatcher, not published directly")
+	pending, err := store.PendingEvents(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2, "only changes that were made record events")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go dispatcher.Run(stop)
+	require.Eventually(t, func() bool { return len(hub.Messages()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"items:1", "items"}, hub.Topics())
+	for i, message := range hub.Messages() {
+		var msg struct {
+			Type    string `json:"type"`
+			EventID string `json:"event_id"`
+		}
+		require.NoError(t, json.Unmarshal(message, &msg))
+		assert.Equal(t, []string{"item.created", "item.batch"}[i], msg.Type)
+		assert.Equal(t, pending[i].EventID, msg.EventID, "messages carry their event's ID")
+	}
+	require.Eventually(t, func() bool {
+		pending, err := store.PendingEvents(context.Background(), 10)
+		return err == nil && len(pending) == 0
+	}, time.Second, 10*time.Millisecond, "delivered events are marked sent")
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPageSize is the page size used for cursor pagination, and for the
+// envelope, when the client does not send a limit.
+const defaultPageSize = 20
+
+// Page is the response body of list endpoints in cursor pagination mode.
+type Page struct {
+	Data interface{} `json:"data"`
+	// NextCursor is passed back as ?cursor= to fetch the following page.
+	// It is omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Envelope is the response body of list endpoints in offset pagination mode
+// when the client asks for it with ?envelope=true.
+type Envelope struct {
+	Data interface{} `json:"data"`
+	// Total is the number of records matching the filters, across all pages.
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+	// Next and Prev are relative URLs of the neighbouring pages, omitted at
+	// either end of the result set.
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// pageLinks are the relative URLs of the pages around an offset page.
+// Empty fields have no corresponding page.
+type pageLinks struct {
+	First, Prev, Next, Last string
+}
+
+// newPageLinks builds the links for the page at offset of size limit out of
+// total records. The URLs keep every other query parameter of u.
+func newPageLinks(u *url.URL, limit, offset int, total int64) pageLinks {
+	at := func(offset int) string {
+		query := u.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String()
+	}
+
+	last := 0
+	if total > 0 {
+		last = int((total-1)/int64(limit)) * limit
+	}
+	links := pageLinks{First: at(0), Last: at(last)}
+	if int64(offset+limit) < total {
+		links.Next = at(offset + limit)
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = at(prev)
+	}
+	return links
+}
+
+// header formats the links as an RFC 8288 Link header value.
+func (l pageLinks) header() string {
+	var parts []string
+	for _, link := range []struct{ rel, url string }{
+		{"next", l.Next},
+		{"prev", l.Prev},
+		{"first", l.First},
+		{"last", l.Last},
+	} {
+		if link.url != "" {
+			parts = append(parts, fmt.Sprintf("<%s>; rel=%q", link.url, link.rel))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setPaginationHeaders sets X-Total-Count and, for paginated responses, Link.
+func setPaginationHeaders(c *gin.Context, total int64, links *pageLinks) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if links != nil {
+		c.Header("Link", links.header())
+	}
+}
@@ -37,37 +37,84 @@ func (rl *RateLimiter) Stop() {
 	})
 }
 
+// LimitFunc identifies the client a request counts against and returns that
+// client's limit per window; a limit of 0 means the RateLimiter's default.
+type LimitFunc func(c *gin.Context) (client string, limit int)
+
+// RateLimit limits requests per client IP.
 func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
+	return rl.RateLimitBy(nil)
+}
 
-		// Single write lock for the check-and-add to avoid a TOCTOU race
-		// where concurrent requests could both pass the limit check.
-		rl.Lock()
-		// Filter expired timestamps during counting to prevent unbounded
-		// slice growth between periodic cleanup cycles.
-		valid := rl.requests[ip][:0]
-		for _, t := range rl.requests[ip] {
-			if t.After(windowStart) {
-				valid = append(valid, t)
-			}
+// RateLimitBy limits requests per client as identified by limitFor, which
+// also lets clients such as API keys have limits of their own. A nil
+// limitFor limits requests per client IP.
+func (rl *RateLimiter) RateLimitBy(limitFor LimitFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, limit := c.ClientIP(), 0
+		if limitFor != nil {
+			client, limit = limitFor(c)
+		}
+		if !rl.allow(client, limit, true) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			c.Abort()
+			return
 		}
-		rl.requests[ip] = valid
+		c.Next()
+	}
+}
 
-		if len(valid) >= rl.limit {
-			rl.Unlock()
+// LimitFailures limits, per client IP, the requests failing with status,
+// such as 401 for invalid credentials: once the failures of an IP reach the
+// default limit within the window, its requests are refused with 429 before
+// the handlers after it run. It bounds how many credentials can be tried,
+// which RateLimitBy cannot do when the credentials decide the client.
+func (rl *RateLimiter) LimitFailures(status int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := "failures:" + c.ClientIP()
+		if !rl.allow(client, rl.limit, false) {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
 			c.Abort()
 			return
 		}
+		c.Next()
+		if c.Writer.Status() == status {
+			rl.allow(client, rl.limit, true)
+		}
+	}
+}
 
-		rl.requests[ip] = append(rl.requests[ip], now)
-		rl.Unlock()
+// allow reports whether client has made fewer than limit requests in the
+// window, or rl's limit if limit is 0, and if so counts one more when count
+// is set.
+func (rl *RateLimiter) allow(client string, limit int, count bool) bool {
+	if limit <= 0 {
+		limit = rl.limit
+	}
+	now := time.Now()
+	windowStart := now.Add(-rl.window)
 
-		c.Next()
+	// Single write lock for the check-and-add to avoid a TOCTOU race
+	// where concurrent requests could both pass the limit check.
+	rl.Lock()
+	defer rl.Unlock()
+	// Filter expired timestamps during counting to prevent unbounded
+	// slice growth between periodic cleanup cycles.
+	valid := rl.requests[client][:0]
+	for _, t := range rl.requests[client] {
+		if t.After(windowStart) {
+			valid = append(valid, t)
+		}
+	}
+	rl.requests[client] = valid
+
+	if len(valid) >= limit {
+		return false
+	}
+	if count {
+		rl.requests[client] = append(valid, now)
 	}
+	return true
 }
 
 // cleanup periodically removes expired entries to prevent memory leaks.
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/idempotency"
+	"backend/internal/models"
+	"backend/internal/websocket"
+)
+
+const (
+	// maxIdempotencyKeyLength bounds params.idempotency_key, as the
+	// Idempotency-Key header is bounded.
+	maxIdempotencyKeyLength = 255
+
+	// rpcIdempotencyLockTimeout is how long a key stays reserved by a call
+	// that never completes, e.g. because its server instance stopped.
+	rpcIdempotencyLockTimeout = time.Minute
+)
+
+// rpcMethod is a method WebSocket clients can call, served by the item
+// mutation the equivalent REST request makes.
+type rpcMethod struct {
+	// permission is the permission the REST route requires.
+	permission string
+	// hasID is set for methods on an existing item, which need params.id.
+	hasID bool
+	// call makes the mutation and returns its result, which is nil when the
+	// REST response has no body.
+	call func(ctx context.Context, p rpcParams) (interface{}, error)
+}
+
+// rpcParams are the params of item calls: the ID of the item, the ETag it
+// must still have, as with If-Match, the body of the REST request and the
+// key making the call safe to retry, as with Idempotency-Key.
+type rpcParams struct {
+	ID             uint            `json:"id"`
+	IfMatch        string          `json:"if_match"`
+	Body           json.RawMessage `json:"body"`
+	IdempotencyKey string          `json:"idempotency_key"`
+}
+
+// RPCHandler serves the item mutations WebSocket clients call, such as
+// {"id":1,"method":"items.update","params":{"id":42,"body":{"name":"Pen","price":2}}},
+// with the same validation and events as the REST API. It implements
+// websocket.Dispatcher.
+type RPCHandler struct {
+	methods map[string]rpcMethod
+
+	// idempotency stores the results of calls sent with an idempotency key
+	// for idempotencyTTL; it is nil unless set with SetIdempotency.
+	idempotency    idempotency.Store
+	idempotencyTTL time.Duration
+}
+
+// NewRPCHandler creates an RPCHandler serving calls with the item mutations
+// of items.
+func NewRPCHandler(items *Handler) *RPCHandler {
+	return &RPCHandler{methods: map[string]rpcMethod{
+		"items.create": {permission: auth.PermItemsWrite, call: func(ctx context.Context, p rpcParams) (interface{}, error) {
+			var item models.Item
+			if err := json.Unmarshal(p.Body, &item); err != nil {
+				return nil, &requestError{http.StatusBadRequest, "Invalid request format"}
+			}
+			if err := items.createItem(ctx, &item); err != nil {
+				return nil, err
+			}
+			return item, nil
+		}},
+		"items.update": {permission: auth.PermItemsWrite, hasID: true, call: func(ctx context.Context, p rpcParams) (interface{}, error) {
+			var input models.Item
+			if err := json.Unmarshal(p.Body, &input); err != nil {
+				return nil, &requestError{http.StatusBadRequest, "Invalid request format"}
+			}
+			return items.updateItem(ctx, p.ID, p.IfMatch, input)
+		}},
+		"items.patch": {permission: auth.PermItemsWrite, hasID: true, call: func(ctx context.Context, p rpcParams) (interface{}, error) {
+			// An array is a JSON Patch, anything else a merge patch.
+			trimmed := bytes.TrimSpace(p.Body)
+			return items.patchItem(ctx, p.ID, p.IfMatch, len(trimmed) > 0 && trimmed[0] == '[', p.Body)
+		}},
+		"items.delete": {permission: auth.PermItemsWrite, hasID: true, call: func(ctx context.Context, p rpcParams) (interface{}, error) {
+			return nil, items.deleteItem(ctx, p.ID, p.IfMatch)
+		}},
+		"items.restore": {permission: auth.PermItemsWrite, hasID: true, call: func(ctx context.Context, p rpcParams) (interface{}, error) {
+			return items.restoreItem(ctx, p.ID)
+		}},
+	}}
+}
+
+// SetIdempotency makes calls sent with params.idempotency_key safe to retry,
+// as the Idempotency-Key header makes REST requests: the first call with a
+// key is made and its outcome stored in store for ttl, and retries with the
+// same key and params get that outcome again. Reusing a key for another call,
+// or by another user, fails with 422, and a retry while the first call is
+// still being made with 409. Internal errors are not stored.
+func (h *RPCHandler) SetIdempotency(store idempotency.Store, ttl time.Duration) {
+	h.idempotency = store
+	h.idempotencyTTL = ttl
+}
+
+// Call implements websocket.Dispatcher. The result is the body the REST
+// request would have returned, or null when it has none; failures carry its
+// status and error message.
+func (h *RPCHandler) Call(ctx context.Context, identity *websocket.Identity, method string, params json.RawMessage) (json.RawMessage, error) {
+	m, ok := h.methods[method]
+	if !ok {
+		return nil, &websocket.CallError{Status: http.StatusNotFound, Message: fmt.Sprintf("Unknown method %q", method)}
+	}
+	// Without authentication there are no roles to check, as for REST.
+	if identity != nil && identity.Permissions != nil && !identity.Permissions.HasPermission(m.permission) {
+		return nil, &websocket.CallError{Status: http.StatusForbidden, Message: "Permission denied: " + m.permission + " required"}
+	}
+
+	var p rpcParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &websocket.CallError{Status: http.StatusBadRequest, Message: "Invalid params"}
+		}
+	}
+	if m.hasID && p.ID == 0 {
+		return nil, &websocket.CallError{Status: http.StatusBadRequest, Message: "params.id is required"}
+	}
+
+	if p.IdempotencyKey != "" && h.idempotency != nil {
+		var principal string
+		if identity != nil {
+			principal = identity.Subject
+		}
+		return h.callOnce(ctx, idempotency.Fingerprint(principal, "CALL", method, params), m, p)
+	}
+	return call(ctx, m, p)
+}
+
+// call makes the call of m with p.
+func call(ctx context.Context, m rpcMethod, p rpcParams) (json.RawMessage, error) {
+	result, err := m.call(ctx, p)
+	if err != nil {
+		status, message := errorResponse(err)
+		if status >= http.StatusInternalServerError {
+			// Reported as an internal error once logged.
+			return nil, err
+		}
+		return nil, &websocket.CallError{Status: status, Message: message}
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return json.Marshal(result)
+}
+
+// callOnce makes the call of m with p, identified by fingerprint, unless a
+// call with the same idempotency key was made; see SetIdempotency.
+func (h *RPCHandler) callOnce(ctx context.Context, fingerprint string, m rpcMethod, p rpcParams) (json.RawMessage, error) {
+	key := p.IdempotencyKey
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, &websocket.CallError{Status: http.StatusBadRequest, Message: "params.idempotency_key is too long"}
+	}
+
+	// Store writes must not be cut short by the call timing out.
+	storeCtx := context.WithoutCancel(ctx)
+	existing, reserved, err := h.idempotency.Reserve(storeCtx, key, fingerprint, rpcIdempotencyLockTimeout)
+	if err != nil {
+		slog.Error("Failed to reserve idempotency key", "error", err)
+		return nil, &websocket.CallError{Status: http.StatusServiceUnavailable, Message: "Service unavailable"}
+	}
+	if !reserved {
+		switch {
+		case existing.Fingerprint != fingerprint:
+			return nil, &websocket.CallError{Status: http.StatusUnprocessableEntity,
+				Message: "params.idempotency_key was already used for a different call"}
+		case existing.Response == nil:
+			return nil, &websocket.CallError{Status: http.StatusConflict,
+				Message: "A call with this params.idempotency_key is still being made"}
+		default:
+			return replayCall(existing.Response)
+		}
+	}
+
+	result, err := call(ctx, m, p)
+	resp := idempotency.Response{Status: http.StatusOK, Body: result}
+	if err != nil {
+		var callErr *websocket.CallError
+		if !errors.As(err, &callErr) {
+			if err := h.idempotency.Release(storeCtx, key, fingerprint); err != nil {
+				slog.Error("Failed to release idempotency key", "error", err)
+			}
+			return nil, err
+		}
+		body, _ := json.Marshal(map[string]string{"error": callErr.Message})
+		resp = idempotency.Response{Status: callErr.Status, Body: body}
+	}
+	if err := h.idempotency.Complete(storeCtx, key, fingerprint, resp, h.idempotencyTTL); err != nil {
+		// The key stays reserved until rpcIdempotencyLockTimeout, so retries
+		// get 409 rather than being applied again.
+		slog.Error("Failed to store idempotent call result", "error", err)
+	}
+	return result, err
+}
+
+// replayCall returns the stored outcome of a call.
+func replayCall(resp *idempotency.Response) (json.RawMessage, error) {
+	if resp.Status >= http.StatusBadRequest {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(resp.Body, &body)
+		return nil, &websocket.CallError{Status: resp.Status, Message: body.Error}
+	}
+	if len(resp.Body) == 0 {
+		return nil, nil
+	}
+	return resp.Body, nil
+}
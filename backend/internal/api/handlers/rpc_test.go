@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/idempotency"
+	"backend/internal/models"
+	"backend/internal/websocket"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grants is a websocket.Authorizer holding the listed permissions.
+type grants []string
+
+func (g grants) HasPermission(permission string) bool {
+	return slices.Contains(g, permission)
+}
+
+func TestRPCHandler_Call(t *testing.T) {
+	t.Parallel()
+	writer := &websocket.Identity{Subject: "1", Permissions: grants{auth.PermItemsRead, auth.PermItemsWrite}}
+	viewer := &websocket.Identity{Subject: "3", Permissions: grants{auth.PermItemsRead}}
+
+	tests := []struct {
+		name       string
+		identity   *websocket.Identity
+		method     string
+		params     string
+		wantResult string
+		wantStatus int
+		wantError  string
+		wantEvent  string
+	}{
+		{
+			name:       "create",
+			identity:   writer,
+			method:     "items.create",
+			params:     `{"body":{"name":"Ink","price":3}}`,
+			wantResult: `{"id":2,"name":"Ink","price":3,"version":1}`,
+			wantEvent:  "item.created",
+		},
+		{
+			name:       "update without authentication",
+			method:     "items.update",
+			params:     `{"id":1,"body":{"name":"Pencil","price":2}}`,
+			wantResult: `{"id":1,"name":"Pencil","price":2,"version":2}`,
+			wantEvent:  "item.updated",
+		},
+		{
+			name:       "merge patch",
+			identity:   writer,
+			method:     "items.patch",
+			params:     `{"id":1,"if_match":"\"1\"","body":{"price":5}}`,
+			wantResult: `{"id":1,"name":"Pen","price":5,"version":2}`,
+			wantEvent:  "item.updated",
+		},
+		{
+			name:       "JSON patch",
+			identity:   writer,
+			method:     "items.patch",
+			params:     `{"id":1,"body":[{"op":"replace","path":"/name","value":"Quill"}]}`,
+			wantResult: `{"id":1,"name":"Quill","price":1,"version":2}`,
+			wantEvent:  "item.updated",
+		},
+		{
+			name:       "delete has no result",
+			identity:   writer,
+			method:     "items.delete",
+			params:     `{"id":1}`,
+			wantResult: `null`,
+			wantEvent:  "item.deleted",
+		},
+		{
+			name:       "unknown method",
+			identity:   writer,
+			method:     "items.purge",
+			wantStatus: http.StatusNotFound,
+			wantError:  `Unknown method "items.purge"`,
+		},
+		{
+			name:       "permission denied",
+			identity:   viewer,
+			method:     "items.update",
+			params:     `{"id":1,"body":{"name":"Pencil","price":2}}`,
+			wantStatus: http.StatusForbidden,
+			wantError:  "Permission denied: items:write required",
+		},
+		{
+			name:       "invalid params",
+			identity:   writer,
+			method:     "items.update",
+			params:     `[1]`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "Invalid params",
+		},
+		{
+			name:       "missing id",
+			identity:   writer,
+			method:     "items.delete",
+			params:     `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "params.id is required",
+		},
+		{
+			name:       "invalid body",
+			identity:   writer,
+			method:     "items.create",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "Invalid request format",
+		},
+		{
+			name:       "not found",
+			identity:   writer,
+			method:     "items.restore",
+			params:     `{"id":99}`,
+			wantStatus: http.StatusNotFound,
+			wantError:  "Item not found",
+		},
+		{
+			name:       "precondition failed",
+			identity:   writer,
+			method:     "items.update",
+			params:     `{"id":1,"if_match":"\"7\"","body":{"name":"Pencil","price":2}}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantError:  "Item has been modified by another request",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			repo := NewMockRepository()
+			require.NoError(t, repo.Create(context.Background(), &models.Item{Name: "Pen", Price: 1}))
+			hub := &MockBroadcastSender{}
+			rpc := NewRPCHandler(NewHandlerWithHub(repo, hub))
+
+			result, err := rpc.Call(context.Background(), tt.identity, tt.method, json.RawMessage(tt.params))
+			if tt.wantStatus != 0 {
+				var callErr *websocket.CallError
+				require.True(t, errors.As(err, &callErr), "got %v", err)
+				assert.Equal(t, tt.wantStatus, callErr.Status)
+				assert.Equal(t, tt.wantError, callErr.Message)
+				assert.Empty(t, hub.Messages(), "failed calls publish no event")
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantResult == "null" {
+				assert.Nil(t, result)
+			} else {
+				var item map[string]interface{}
+				require.NoError(t, json.Unmarshal(result, &item))
+				got, err := json.Marshal(map[string]interface{}{
+					"id": item["id"], "name": item["name"], "price": item["price"], "version": item["version"],
+				})
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.wantResult, string(got))
+			}
+
+			messages := hub.Messages()
+			require.Len(t, messages, 1, "the call publishes the REST event")
+			var event websocket.Message
+			require.NoError(t, json.Unmarshal(messages[0], &event))
+			assert.Equal(t, tt.wantEvent, event.Type)
+		})
+	}
+}
+
+func TestRPCHandler_Idempotency(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	writer := &websocket.Identity{Subject: "1"}
+	other := &websocket.Identity{Subject: "2"}
+
+	repo := NewMockRepository()
+	hub := &MockBroadcastSender{}
+	rpc := NewRPCHandler(NewHandlerWithHub(repo, hub))
+	rpc.SetIdempotency(idempotency.NewMemoryStore(), time.Hour)
+
+	create := json.RawMessage(`{"idempotency_key":"k1","body":{"name":"Ink","price":3}}`)
+	first, err := rpc.Call(ctx, writer, "items.create", create)
+	require.NoError(t, err)
+	retried, err := rpc.Call(ctx, writer, "items.create", create)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(first), string(retried), "a retry gets the first result")
+	assert.Len(t, hub.Messages(), 1, "a retry is not applied again")
+
+	callStatus := func(identity *websocket.Identity, method, params string) int {
+		_, err := rpc.Call(ctx, identity, method, json.RawMessage(params))
+		var callErr *websocket.CallError
+		require.True(t, errors.As(err, &callErr), "got %v", err)
+		return callErr.Status
+	}
+	assert.Equal(t, http.StatusUnprocessableEntity,
+		callStatus(writer, "items.create", `{"idempotency_key":"k1","body":{"name":"Other","price":3}}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, callStatus(other, "items.create", string(create)),
+		"a key is bound to its user")
+
+	// The next item created gets ID 2.
+	notFound := `{"idempotency_key":"k2","id":2}`
+	assert.Equal(t, http.StatusNotFound, callStatus(writer, "items.delete", notFound))
+	require.NoError(t, repo.Create(ctx, &models.Item{Name: "Late", Price: 1}))
+	assert.Equal(t, http.StatusNotFound, callStatus(writer, "items.delete", notFound), "failures are replayed too")
+}
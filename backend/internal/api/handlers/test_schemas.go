@@ -26,6 +26,48 @@ const itemListSchema = `{
 "items": ` + itemSchema + `
 }`
 
+// Schema for a cursor-paginated list of items
+const itemPageSchema = `{
+"type": "object",
+"required": ["data"],
+"properties": {
+"data": ` + itemListSchema + `,
+"next_cursor": {
+"type": "string",
+"minLength": 1
+}
+}
+}`
+
+// Schema for an offset page wrapped in an envelope
+const itemEnvelopeSchema = `{
+"type": "object",
+"required": ["data", "total", "limit", "offset"],
+"properties": {
+"data": ` + itemListSchema + `,
+"total": {
+"type": "integer",
+"minimum": 0
+},
+"limit": {
+"type": "integer",
+"minimum": 1
+},
+"offset": {
+"type": "integer",
+"minimum": 0
+},
+"next": {
+"type": "string",
+"minLength": 1
+},
+"prev": {
+"type": "string",
+"minLength": 1
+}
+}
+}`
+
 // Schema for error responses
 const errorSchema = `{
 "type": "object",
@@ -36,3 +78,32 @@ const errorSchema = `{
 }
 }
 }`
+
+// Schema for a single user
+const userSchema = `{
+"type": "object",
+"required": ["id", "username", "email"],
+"properties": {
+"id": {
+"type": "integer",
+"minimum": 1
+},
+"username": {
+"type": "string",
+"minLength": 1
+},
+"email": {
+"type": "string",
+"minLength": 3
+},
+"name": {
+"type": "string"
+}
+}
+}`
+
+// Schema for an array of users
+const userListSchema = `{
+"type": "array",
+"items": ` + userSchema + `
+}`
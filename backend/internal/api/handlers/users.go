@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/models"
+	"backend/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserRequest is the writable subset of models.User accepted from clients.
+// Server-managed fields (ID, timestamps) are ignored on create and update.
+// Password is optional; when given it replaces the user's password, and
+// when omitted on update the password is kept.
+type UserRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+}
+
+// CreateUser godoc
+// @Summary Create a new user
+// @Description Create a new user. Username and email must be unique. Users created without a password cannot log in.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body handlers.UserRequest true "User object"
+// @Success 201 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/users [post]
+func (h *Handler) CreateUser(c *gin.Context) {
+	var input UserRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	user := models.User{
+		Username: strings.TrimSpace(input.Username),
+		Email:    strings.TrimSpace(input.Email),
+		Name:     input.Name,
+	}
+	if err := user.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Password != "" {
+		if err := user.SetPassword(input.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := h.change(c.Request.Context(), func(tx *Handler) (*event, error) {
+		if err := tx.users.Create(c.Request.Context(), &user); err != nil {
+			return nil, err
+		}
+		return &event{websocket.Topic(usersTopic, user.ID), "user.created", user}, nil
+	})
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// GetUsers godoc
+// @Summary Get all users
+// @Description Get a list of users, optionally filtered by exact username or email
+// @Tags users
+// @Produce json
+// @Param username query string false "Exact username"
+// @Param email query string false "Exact email"
+// @Param limit query int false "Maximum number of users to return"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {array} models.User
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/users [get]
+func (h *Handler) GetUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	if c.Query("limit") != "" && limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+	if c.Query("offset") != "" && offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+		return
+	}
+
+	conditions := make([]interface{}, 0)
+	if username := c.Query("username"); username != "" {
+		conditions = append(conditions, models.Filter{Field: "username", Op: "exact", Value: username})
+	}
+	if email := c.Query("email"); email != "" {
+		conditions = append(conditions, models.Filter{Field: "email", Op: "exact", Value: email})
+	}
+	if limit > 0 {
+		conditions = append(conditions, models.Pagination{Limit: limit, Offset: offset})
+	}
+
+	users, err := h.users.List(c.Request.Context(), conditions...)
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUser godoc
+// @Summary Get a user by ID
+// @Description Get a user by its ID
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/users/{id} [get]
+func (h *Handler) GetUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	user, err := h.users.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUser godoc
+// @Summary Update a user
+// @Description Replace a user's username, email and name, and their password if one is given
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body handlers.UserRequest true "User object"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/users/{id} [put]
+func (h *Handler) UpdateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	user, err := h.users.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	var input UserRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	user.Username = strings.TrimSpace(input.Username)
+	user.Email = strings.TrimSpace(input.Email)
+	user.Name = input.Name
+	if err := user.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Password != "" {
+		if err := user.SetPassword(input.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err = h.change(c.Request.Context(), func(tx *Handler) (*event, error) {
+		if err := tx.users.Update(c.Request.Context(), user); err != nil {
+			return nil, err
+		}
+		return &event{websocket.Topic(usersTopic, user.ID), "user.updated", user}, nil
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "version mismatch") {
+			c.JSON(http.StatusConflict, gin.H{"error": "User has been modified by another request"})
+			return
+		}
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Delete a user by its ID
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/users/{id} [delete]
+func (h *Handler) DeleteUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	user := &models.User{Base: models.Base{ID: uint(id)}}
+	err = h.change(c.Request.Context(), func(tx *Handler) (*event, error) {
+		if err := tx.users.Delete(c.Request.Context(), user); err != nil {
+			return nil, err
+		}
+		return &event{websocket.Topic(usersTopic, uint(id)), "user.deleted", gin.H{"id": id}}, nil
+	})
+	if err != nil {
+		status, message := handleEntityDBError(err, "User")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupUserTestRouter(t *testing.T, hub *MockBroadcastSender) (*gin.Engine, *MockRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	mockRepo := NewMockRepository()
+	handler := NewHandler(mockRepo)
+	if hub != nil {
+		handler = NewHandlerWithHub(mockRepo, hub)
+	}
+
+	rateLimiter := NewRateLimiter(100, time.Second)
+	t.Cleanup(rateLimiter.Stop)
+
+	users := router.Group("/api/v1/users")
+	users.Use(rateLimiter.RateLimit())
+	{
+		users.GET("", handler.GetUsers)
+		users.GET("/:id", handler.GetUser)
+		users.POST("", handler.CreateUser)
+		users.PUT("/:id", handler.UpdateUser)
+		users.DELETE("/:id", handler.DeleteUser)
+	}
+
+	return router, mockRepo
+}
+
+func seedUser(t *testing.T, repo *MockRepository, username, email string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: email, Name: "Seeded " + username}
+	require.NoError(t, repo.Create(context.Background(), user))
+	return user
+}
+
+func TestCreateUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid user",
+			body:       `{"username":"bob","email":"bob@example.com","name":"Bob"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "missing username",
+			body:       `{"email":"bob@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid email",
+			body:       `{"username":"bob","email":"not-an-email"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed JSON",
+			body:       `{"username":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "with a password",
+			body:       `{"username":"bob","email":"bob@example.com","password":"correct horse"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "password too short",
+			body:       `{"username":"bob","email":"bob@example.com","password":"short"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "duplicate username",
+			body:       `{"username":"alice","email":"other@example.com"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "duplicate email",
+			body:       `{"username":"other","email":"alice@example.com"}`,
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupUserTestRouter(t, nil)
+			seedUser(t, mockRepo, "alice", "alice@example.com")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusCreated {
+				assert.NotContains(t, w.Body.String(), "password")
+				assert.True(t, validateJSONSchema(t, userSchema, w.Body.Bytes()))
+			} else {
+				assert.True(t, validateJSONSchema(t, errorSchema, w.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{name: "all users", query: "", wantStatus: http.StatusOK, wantCount: 3},
+		{name: "filter by username", query: "?username=bob", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "filter by email", query: "?email=carol@example.com", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "no match", query: "?username=nobody", wantStatus: http.StatusOK, wantCount: 0},
+		{name: "pagination", query: "?limit=2&offset=2", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "invalid limit", query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "invalid offset", query: "?limit=1&offset=-1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupUserTestRouter(t, nil)
+			seedUser(t, mockRepo, "alice", "alice@example.com")
+			seedUser(t, mockRepo, "bob", "bob@example.com")
+			seedUser(t, mockRepo, "carol", "carol@example.com")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/users"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.True(t, validateJSONSchema(t, userListSchema, w.Body.Bytes()))
+				var users []models.User
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+				assert.Len(t, users, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		userID     string
+		wantStatus int
+	}{
+		{name: "existing user", userID: "1", wantStatus: http.StatusOK},
+		{name: "non-existent user", userID: "999", wantStatus: http.StatusNotFound},
+		{name: "invalid ID", userID: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupUserTestRouter(t, nil)
+			seedUser(t, mockRepo, "alice", "alice@example.com")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/users/"+tt.userID, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusNotFound {
+				assert.JSONEq(t, `{"error":"User not found"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		userID     string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid update",
+			userID:     "1",
+			body:       `{"username":"alice2","email":"alice2@example.com","name":"Alice"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "keeping own username and email",
+			userID:     "1",
+			body:       `{"username":"alice","email":"alice@example.com","name":"Renamed"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "email taken by another user",
+			userID:     "1",
+			body:       `{"username":"alice","email":"bob@example.com"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "invalid email",
+			userID:     "1",
+			body:       `{"username":"alice","email":"nope"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non-existent user",
+			userID:     "999",
+			body:       `{"username":"ghost","email":"ghost@example.com"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid ID",
+			userID:     "abc",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupUserTestRouter(t, nil)
+			seedUser(t, mockRepo, "alice", "alice@example.com")
+			seedUser(t, mockRepo, "bob", "bob@example.com")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/api/v1/users/"+tt.userID, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.True(t, validateJSONSchema(t, userSchema, w.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		userID     string
+		wantStatus int
+	}{
+		{name: "existing user", userID: "1", wantStatus: http.StatusNoContent},
+		{name: "non-existent user", userID: "999", wantStatus: http.StatusNotFound},
+		{name: "invalid ID", userID: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router, mockRepo := setupUserTestRouter(t, nil)
+			seedUser(t, mockRepo, "alice", "alice@example.com")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/api/v1/users/"+tt.userID, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestBroadcastUserEvents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     func(user *models.User) string
+		body     string
+		wantCode int
+		wantType string
+	}{
+		{
+			name:     "CreateUser broadcasts user.created",
+			method:   "POST",
+			path:     func(*models.User) string { return "/api/v1/users" },
+			body:     `{"username":"dave","email":"dave@example.com"}`,
+			wantCode: http.StatusCreated,
+			wantType: "user.created",
+		},
+		{
+			name:     "UpdateUser broadcasts user.updated",
+			method:   "PUT",
+			path:     func(u *models.User) string { return fmt.Sprintf("/api/v1/users/%d", u.ID) },
+			body:     `{"username":"alice","email":"alice@example.org"}`,
+			wantCode: http.StatusOK,
+			wantType: "user.updated",
+		},
+		{
+			name:     "DeleteUser broadcasts user.deleted",
+			method:   "DELETE",
+			path:     func(u *models.User) string { return fmt.Sprintf("/api/v1/users/%d", u.ID) },
+			wantCode: http.StatusNoContent,
+			wantType: "user.deleted",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hub := &MockBroadcastSender{}
+			router, mockRepo := setupUserTestRouter(t, hub)
+			user := seedUser(t, mockRepo, "alice", "alice@example.com")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path(user), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			msgs := hub.Messages()
+			require.Len(t, msgs, 1)
+			var env struct {
+				Type string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal(msgs[0], &env))
+			assert.Equal(t, tt.wantType, env.Type)
+			assert.Regexp(t, `^users:\d+$`, hub.Topics()[0], "published to the user's topic")
+		})
+	}
+}
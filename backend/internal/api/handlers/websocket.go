@@ -1,22 +1,34 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
+	"backend/internal/api/middleware"
+	"backend/internal/auth"
 	"backend/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 	gorilla "github.com/gorilla/websocket"
 )
 
+// bearerProtocol is the WebSocket subprotocol a client offers together with
+// its access token, as in "Sec-WebSocket-Protocol: bearer, <token>", since
+// browsers cannot set an Authorization header on WebSocket requests.
+const bearerProtocol = "bearer"
+
 // WebSocketHandler handles WebSocket connection upgrades.
 // It is a separate struct from Handler because it depends on *websocket.Hub
-// rather than models.Repository.
+// rather than models.Store.
 type WebSocketHandler struct {
 	hub            *websocket.Hub
 	allowedOrigins string
+	tokens         *auth.Service // nil accepts unauthenticated connections
+	compression    bool
 }
 
 // NewWebSocketHandler creates a new WebSocketHandler with the given hub and allowed origins config.
@@ -27,19 +39,64 @@ func NewWebSocketHandler(hub *websocket.Hub, allowedOrigins string) *WebSocketHa
 	}
 }
 
+// SetTokens requires connections to present an access token verified by
+// tokens. Clients then only receive the events their roles allow.
+func (h *WebSocketHandler) SetTokens(tokens *auth.Service) {
+	h.tokens = tokens
+}
+
+// SetCompression negotiates permessage-deflate with clients that offer it,
+// compressing the larger messages sent to them.
+func (h *WebSocketHandler) SetCompression(enabled bool) {
+	h.compression = enabled
+}
+
 // HandleWebSocket godoc
 // @Summary Open a WebSocket connection
-// @Description Upgrades the HTTP connection to a WebSocket for real-time events.
+// @Description Upgrades the HTTP connection to a WebSocket for real-time events. When authentication is enabled, the access token is sent in the access_token query parameter, as the subprotocol following "bearer" in Sec-WebSocket-Protocol, or in an Authorization header. Clients only receive events their roles allow, and are disconnected when the token expires.
+// @Description Every event carries a seq. A client reconnecting with since set to the last seq it received is sent the events it missed after its first subscribe, or a resync message if they are no longer buffered.
+// @Description Clients offering the msgpack subprotocol (before "bearer", if they authenticate with it) exchange MessagePack-encoded messages in binary frames instead of JSON. permessage-deflate compression is negotiated with clients that offer it, unless disabled.
+// @Description Clients may also call item mutations, as {"id":1,"method":"items.update","params":{"id":42,"if_match":"\"3\"","body":{...}}}, with the methods items.create, items.update, items.patch, items.delete and items.restore. They need the permissions of the REST route and are answered with {"type":"result","id":1,"payload":<REST response body>}, or an error message with the id, the REST status and the error.
 // @Tags websocket
+// @Param access_token query string false "Access token"
+// @Param since query int false "Seq of the last event received before reconnecting"
+// @Param backpressure query string false "What to do when the client does not keep up: disconnect (the default unless configured otherwise), drop-oldest or coalesce" Enums(disconnect, drop-oldest, coalesce)
 // @Success 101 "Switching Protocols"
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Router /ws [get]
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
+	opts := websocket.ClientOptions{RemoteAddr: c.ClientIP()}
+	if raw := c.Query("since"); raw != "" {
+		seq, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter"})
+			return
+		}
+		opts.Since = &seq
+	}
+	if !backpressureQuery(c, &opts) {
+		return
+	}
+
+	if h.tokens != nil {
+		claims, err := h.tokens.Verify(accessToken(c.Request), auth.AccessToken)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		opts.Identity = identityOf(claims)
+	}
+
 	upgrader := gorilla.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     h.checkOrigin,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		CheckOrigin:       h.checkOrigin,
+		EnableCompression: h.compression,
+		// The first the client offers is selected; the token is not echoed.
+		Subprotocols: []string{websocket.MsgpackProtocol, bearerProtocol},
 	}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -47,13 +104,117 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		slog.Error("WebSocket upgrade failed", "error", err)
 		return
 	}
+	if conn.Subprotocol() == websocket.MsgpackProtocol {
+		opts.Encoding = websocket.Msgpack
+	}
 
-	if _, err := websocket.NewClient(h.hub, conn); err != nil {
+	if _, err := websocket.NewClient(h.hub, conn, opts); err != nil {
 		slog.Error("WebSocket client creation failed", "error", err)
 		return
 	}
 }
 
+// backpressureQuery sets opts.Backpressure from the optional backpressure
+// query parameter. If it is invalid, it responds with 400 and returns false.
+func backpressureQuery(c *gin.Context, opts *websocket.ClientOptions) bool {
+	raw := c.Query("backpressure")
+	if raw == "" {
+		return true
+	}
+	policy, err := websocket.ParseBackpressure(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid backpressure parameter"})
+		return false
+	}
+	opts.Backpressure = policy
+	return true
+}
+
+// WebSocketClients is the response of ListClients.
+type WebSocketClients struct {
+	Clients []websocket.ClientInfo `json:"clients"`
+	Stats   websocket.Stats        `json:"stats"`
+}
+
+// ListClients godoc
+// @Summary List WebSocket clients
+// @Description Lists the WebSocket and event stream clients connected to this instance, with their subscriptions, backpressure policy and the number of messages waiting to be sent to them, and counts the messages the hub dropped or coalesced because they could not be delivered in time.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} handlers.WebSocketClients
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/admin/ws/clients [get]
+func (h *WebSocketHandler) ListClients(c *gin.Context) {
+	c.JSON(http.StatusOK, WebSocketClients{Clients: h.hub.Clients(), Stats: h.hub.Stats()})
+}
+
+// GetPresence godoc
+// @Summary Get presence
+// @Description Lists the clients viewing a resource, that is subscribed to its topic, with their user, since when and when they were last active. With a WebSocket backplane, the clients of every instance are listed; those of other instances follow their presence events and are refreshed every 10 seconds. For a collection, such as items, the viewers of each of its resources are listed. Viewers joining and leaving are announced to the resource's other viewers as presence.joined and presence.left messages.
+// @Tags events
+// @Produce json
+// @Security BearerAuth
+// @Param resource query string true "Resource topic, e.g. items:42, or collection topic"
+// @Success 200 {array} websocket.Presence
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/presence [get]
+func (h *WebSocketHandler) GetPresence(c *gin.Context) {
+	resource := c.Query("resource")
+	if resource == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Resource query parameter is required"})
+		return
+	}
+	var identity *websocket.Identity
+	if claims, ok := middleware.ClaimsFromContext(c); ok {
+		identity = identityOf(claims)
+	}
+	presences, err := h.hub.Presence(identity, resource)
+	switch {
+	case errors.Is(err, websocket.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot get presence: " + err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot get presence: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, presences)
+}
+
+// identityOf returns the hub identity of a client authenticated with claims.
+func identityOf(claims *auth.Claims) *websocket.Identity {
+	identity := &websocket.Identity{
+		Subject:     claims.Subject,
+		Username:    claims.Username,
+		Permissions: claims,
+	}
+	// API keys do not expire unless given an expiry, which their claims omit.
+	if claims.ExpiresAt != nil {
+		identity.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return identity
+}
+
+// accessToken returns the access token of a WebSocket handshake: the
+// subprotocol following bearerProtocol, the access_token query parameter or
+// a bearer Authorization header.
+func accessToken(r *http.Request) string {
+	protocols := gorilla.Subprotocols(r)
+	if i := slices.Index(protocols, bearerProtocol); i >= 0 && i+1 < len(protocols) {
+		return protocols[i+1]
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
 // checkOrigin validates the request origin against the configured allowed origins.
 func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
 	if h.allowedOrigins == "" || h.allowedOrigins == "*" {
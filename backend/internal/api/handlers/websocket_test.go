@@ -1,18 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"backend/internal/api/middleware"
+	"backend/internal/auth"
+	"backend/internal/models"
 	"backend/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 	gorilla "github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestCheckOrigin(t *testing.T) {
@@ -173,12 +179,21 @@ func TestHandleWebSocket_BroadcastReceived(t *testing.T) {
 	hub.Broadcast(msg)
 
 	// Read the message from the WebSocket connection
-	err = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
-	require.NoError(t, err)
+	received := readWSMessage(t, conn)
+	assert.Equal(t, "test", received.Type)
+	assert.JSONEq(t, `"hello"`, string(received.Payload))
+	assert.NotZero(t, received.Seq)
+}
 
-	_, received, err := conn.ReadMessage()
+// readWSMessage reads the next message from conn.
+func readWSMessage(t *testing.T, conn *gorilla.Conn) websocket.Message {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
 	require.NoError(t, err)
-	assert.Equal(t, msg, received)
+	var msg websocket.Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	return msg
 }
 
 func TestHandleWebSocket_MultipleClients(t *testing.T) {
@@ -213,12 +228,9 @@ func TestHandleWebSocket_MultipleClients(t *testing.T) {
 	hub.Broadcast(msg)
 
 	for i, conn := range []*gorilla.Conn{conn1, conn2} {
-		err = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
-		require.NoError(t, err, "client %d set deadline", i+1)
-
-		_, received, err := conn.ReadMessage()
-		require.NoError(t, err, "client %d read", i+1)
-		assert.Equal(t, msg, received, "client %d message", i+1)
+		received := readWSMessage(t, conn)
+		assert.Equal(t, "broadcast", received.Type, "client %d message", i+1)
+		assert.JSONEq(t, `"all"`, string(received.Payload), "client %d message", i+1)
 	}
 }
 
@@ -379,3 +391,372 @@ func TestHandleWebSocket_ClientDisconnect(t *testing.T) {
 	// Hub should eventually unregister the client
 	waitForHubClients(t, hub, 0)
 }
+
+func TestHandleWebSocket_Authentication(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	tokens := newTestAuthService(t)
+	viewer, err := tokens.Issue(&models.User{Base: models.Base{ID: 3}, Username: "viewer"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		query        string
+		header       http.Header
+		wantStatus   int
+		wantProtocol string
+	}{
+		{name: "query parameter", query: "?access_token=" + viewer.AccessToken, wantStatus: http.StatusSwitchingProtocols},
+		{name: "subprotocol", header: http.Header{"Sec-WebSocket-Protocol": {"bearer, " + viewer.AccessToken}}, wantStatus: http.StatusSwitchingProtocols, wantProtocol: "bearer"},
+		{name: "authorization header", header: http.Header{"Authorization": {"Bearer " + viewer.AccessToken}}, wantStatus: http.StatusSwitchingProtocols},
+		{name: "subprotocol with msgpack", header: http.Header{"Sec-WebSocket-Protocol": {"msgpack, bearer, " + viewer.AccessToken}}, wantStatus: http.StatusSwitchingProtocols, wantProtocol: "msgpack"},
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "refresh token", query: "?access_token=" + viewer.RefreshToken, wantStatus: http.StatusUnauthorized},
+		{name: "bearer subprotocol without a token", header: http.Header{"Sec-WebSocket-Protocol": {"bearer"}}, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hub := websocket.NewHub()
+			go hub.Run()
+			defer hub.Shutdown()
+
+			handler := NewWebSocketHandler(hub, "*")
+			handler.SetTokens(tokens)
+			router := gin.New()
+			router.GET("/ws", handler.HandleWebSocket)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + tt.query
+			conn, resp, err := gorilla.DefaultDialer.Dial(wsURL, tt.header)
+			require.NotNil(t, resp)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusSwitchingProtocols {
+				assert.Error(t, err)
+				assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+			assert.Equal(t, tt.wantProtocol, conn.Subprotocol())
+			waitForHubClients(t, hub, 1)
+		})
+	}
+}
+
+func TestHandleWebSocket_Subscriptions(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	tokens := newTestAuthService(t)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.RequirePermission("items", auth.PermItemsRead)
+	hub.RequirePermission("users", auth.PermUsersWrite)
+
+	handler := NewWebSocketHandler(hub, "*")
+	handler.SetTokens(tokens)
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	pair, err := tokens.Issue(&models.User{Base: models.Base{ID: 3}, Username: "viewer"})
+	require.NoError(t, err)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?access_token=" + pair.AccessToken
+	conn, _, err := gorilla.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForHubClients(t, hub, 1)
+
+	read := func() string {
+		msg := readWSMessage(t, conn)
+		return `{"type":"` + msg.Type + `","payload":` + string(msg.Payload) + `}`
+	}
+
+	// A viewer may read items but not manage users.
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["users"]}`)))
+	assert.JSONEq(t, `{"type":"error","payload":{"error":"permission denied for topic \"users\""}}`, read())
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["items:1"]}`)))
+	assert.JSONEq(t, `{"type":"subscribed","payload":{"topics":["items:1"]}}`, read())
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`not json`)))
+	assert.JSONEq(t, `{"type":"error","payload":{"error":"unknown message type \"\""}}`, read())
+
+	other := []byte(`{"type":"item.created","payload":{"id":2}}`)
+	item := []byte(`{"type":"item.created","payload":{"id":1}}`)
+	hub.Publish("users:1", []byte(`{"type":"user.created","payload":{"id":1}}`))
+	hub.Publish("items:2", other)
+	hub.Publish("items:1", item)
+	assert.JSONEq(t, string(item), read())
+}
+
+func TestHandleWebSocket_Resume(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	router := gin.New()
+	router.GET("/ws", NewWebSocketHandler(hub, "*").HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	_, resp, err := gorilla.DefaultDialer.Dial(wsURL+"?since=abc", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	subscribe := func(conn *gorilla.Conn) {
+		require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["items"]}`)))
+		assert.Equal(t, websocket.SubscribedMessage, readWSMessage(t, conn).Type)
+	}
+
+	conn, _, err := gorilla.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	waitForHubClients(t, hub, 1)
+	subscribe(conn)
+	hub.Publish("items:1", []byte(`{"type":"item.created","payload":{"id":1}}`))
+	last := readWSMessage(t, conn)
+	conn.Close()
+	waitForHubClients(t, hub, 0)
+
+	// Published while the client was away.
+	hub.Publish("items:2", []byte(`{"type":"item.created","payload":{"id":2}}`))
+	hub.Publish("items:1", []byte(`{"type":"item.deleted","payload":{"id":1}}`))
+
+	conn, _, err = gorilla.DefaultDialer.Dial(fmt.Sprintf("%s?since=%d", wsURL, last.Seq), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForHubClients(t, hub, 1)
+	subscribe(conn)
+	created := readWSMessage(t, conn)
+	assert.Equal(t, "item.created", created.Type)
+	assert.Equal(t, last.Seq+1, created.Seq)
+	deleted := readWSMessage(t, conn)
+	assert.Equal(t, "item.deleted", deleted.Type)
+	assert.Equal(t, last.Seq+2, deleted.Seq)
+
+	resync, _, err := gorilla.DefaultDialer.Dial(fmt.Sprintf("%s?since=%d", wsURL, deleted.Seq+10), nil)
+	require.NoError(t, err)
+	defer resync.Close()
+	waitForHubClients(t, hub, 2)
+	subscribe(resync)
+	msg := readWSMessage(t, resync)
+	assert.Equal(t, websocket.ResyncMessage, msg.Type)
+	assert.JSONEq(t, fmt.Sprintf(`{"seq":%d}`, deleted.Seq), string(msg.Payload))
+}
+
+func TestHandleWebSocket_Calls(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.SetDispatcher(NewRPCHandler(NewHandlerWithHub(NewMockRepository(), hub)))
+
+	router := gin.New()
+	router.GET("/ws", NewWebSocketHandler(hub, "*").HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForHubClients(t, hub, 1)
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["items"]}`)))
+	assert.Equal(t, websocket.SubscribedMessage, readWSMessage(t, conn).Type)
+
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"id":7,"method":"items.create","params":{"body":{"name":"Pen","price":1}}}`)))
+	// The reply and the event the call published may arrive in either order.
+	replies := make(map[string]websocket.Message)
+	for i := 0; i < 2; i++ {
+		msg := readWSMessage(t, conn)
+		replies[msg.Type] = msg
+	}
+	require.Contains(t, replies, websocket.ResultMessage)
+	require.Contains(t, replies, "item.created")
+	assert.JSONEq(t, `7`, string(replies[websocket.ResultMessage].ID))
+	assert.Zero(t, replies[websocket.ResultMessage].Seq, "replies are not events")
+	assert.JSONEq(t, string(replies["item.created"].Payload), string(replies[websocket.ResultMessage].Payload))
+
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"id":"x","method":"items.delete","params":{"id":99}}`)))
+	msg := readWSMessage(t, conn)
+	assert.Equal(t, websocket.ErrorMessage, msg.Type)
+	assert.JSONEq(t, `"x"`, string(msg.ID))
+	assert.JSONEq(t, `{"error":"Item not found","status":404}`, string(msg.Payload))
+}
+
+func TestWebSocketHandler_ListClients(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	handler := NewWebSocketHandler(hub, "*")
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	router.GET("/api/v1/admin/ws/clients", handler.ListClients)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	_, resp, err := gorilla.DefaultDialer.Dial(wsURL+"?backpressure=block", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, _, err := gorilla.DefaultDialer.Dial(wsURL+"?backpressure=drop-oldest", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForHubClients(t, hub, 1)
+
+	resp, err = http.Get(server.URL + "/api/v1/admin/ws/clients")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list WebSocketClients
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Clients, 1)
+	assert.Equal(t, "websocket", list.Clients[0].Transport)
+	assert.Equal(t, websocket.DropOldest, list.Clients[0].Backpressure)
+	assert.Equal(t, "127.0.0.1", list.Clients[0].RemoteAddr)
+	assert.Equal(t, websocket.Stats{}, list.Stats)
+}
+
+func TestHandleWebSocket_MsgpackAndCompression(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	handler := NewWebSocketHandler(hub, "*")
+	handler.SetCompression(true)
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := gorilla.Dialer{Subprotocols: []string{websocket.MsgpackProtocol}, EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, websocket.MsgpackProtocol, conn.Subprotocol())
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	waitForHubClients(t, hub, 1)
+
+	read := func() map[string]interface{} {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		frameType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, gorilla.BinaryMessage, frameType)
+		var msg map[string]interface{}
+		require.NoError(t, msgpack.Unmarshal(data, &msg))
+		return msg
+	}
+
+	subscribe, err := msgpack.Marshal(map[string]interface{}{"type": "subscribe", "topics": []string{"items"}})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(gorilla.BinaryMessage, subscribe))
+	assert.Equal(t, websocket.SubscribedMessage, read()["type"])
+
+	// Large enough to be compressed.
+	name := strings.Repeat("widget ", 200)
+	hub.Publish("items:1", []byte(`{"type":"item.updated","payload":{"id":1,"name":"`+name+`"}}`))
+	msg := read()
+	assert.Equal(t, "item.updated", msg["type"])
+	assert.NotZero(t, msg["seq"])
+	assert.IsType(t, time.Time{}, msg["time"])
+	payload, ok := msg["payload"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, name, payload["name"])
+
+	info := hub.Clients()
+	require.Len(t, info, 1)
+	assert.Equal(t, websocket.Msgpack, info[0].Encoding)
+}
+
+func TestWebSocketHandler_GetPresence(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	tokens := newTestAuthService(t)
+	pair, err := tokens.Issue(&models.User{Base: models.Base{ID: 3}, Username: "viewer"})
+	require.NoError(t, err)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.RequirePermission("items", auth.PermItemsRead)
+	hub.RequirePermission("users", auth.PermUsersWrite)
+
+	handler := NewWebSocketHandler(hub, "*")
+	handler.SetTokens(tokens)
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	router.GET("/api/v1/presence", middleware.Auth(tokens), handler.GetPresence)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?access_token=" + pair.AccessToken
+	conn, _, err := gorilla.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["items:42"]}`)))
+	assert.Equal(t, websocket.SubscribedMessage, readWSMessage(t, conn).Type)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantUsers  []string
+		wantError  string
+	}{
+		{name: "resource", query: "?resource=items:42", wantStatus: http.StatusOK, wantUsers: []string{"viewer"}},
+		{name: "collection", query: "?resource=items", wantStatus: http.StatusOK, wantUsers: []string{"viewer"}},
+		{name: "no viewers", query: "?resource=items:7", wantStatus: http.StatusOK, wantUsers: []string{}},
+		{name: "no resource", wantStatus: http.StatusBadRequest, wantError: "Resource query parameter is required"},
+		{name: "invalid resource", query: "?resource=items:", wantStatus: http.StatusBadRequest, wantError: `Cannot get presence: invalid topic "items:"`},
+		{name: "not permitted", query: "?resource=users:1", wantStatus: http.StatusForbidden, wantError: `Cannot get presence: permission denied for topic "users:1"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/presence"+tt.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantError != "" {
+				var body map[string]string
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, tt.wantError, body["error"])
+				return
+			}
+			var presences []websocket.Presence
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&presences))
+			users := []string{}
+			for _, p := range presences {
+				users = append(users, p.Username)
+				assert.Equal(t, "items:42", p.Resource)
+				assert.Equal(t, "3", p.Subject)
+			}
+			assert.Equal(t, tt.wantUsers, users)
+		})
+	}
+}
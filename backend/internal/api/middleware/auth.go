@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys of the authenticated request's claims and API key.
+const (
+	claimsKey = "auth_claims"
+	apiKeyKey = "auth_api_key"
+)
+
+// Auth requires a valid access token in an "Authorization: Bearer" header
+// and puts its claims on the context, where ClaimsFromContext finds them.
+// Requests without one are rejected with 401, unless APIKey has already
+// authenticated them.
+func Auth(tokens *auth.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := ClaimsFromContext(c); ok {
+			c.Next()
+			return
+		}
+
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Header("WWW-Authenticate", `Bearer`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		claims, err := tokens.Verify(strings.TrimSpace(token), auth.AccessToken)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// APIKey authenticates requests carrying an X-API-Key header, putting the
+// key's claims (see auth.APIKeyClaims) and the key itself on the context.
+// Invalid keys are rejected with 401; requests without the header are left
+// to Auth.
+func APIKey(keys *auth.APIKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(auth.APIKeyHeader)
+		if raw == "" {
+			c.Next()
+			return
+		}
+
+		key, err := keys.Verify(c.Request.Context(), raw)
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to verify API key", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.Set(claimsKey, auth.APIKeyClaims(key))
+		c.Set(apiKeyKey, key)
+		c.Next()
+	}
+}
+
+// APIKeyFromContext returns the API key APIKey authenticated c with, if any.
+func APIKeyFromContext(c *gin.Context) (*models.APIKey, bool) {
+	v, ok := c.Get(apiKeyKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := v.(*models.APIKey)
+	return key, ok
+}
+
+// ClaimsFromContext returns the claims Auth or APIKey put on c, if any.
+func ClaimsFromContext(c *gin.Context) (*auth.Claims, bool) {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*auth.Claims)
+	return claims, ok
+}
+
+// RequirePermission rejects requests whose token's roles or API key's scopes
+// do not grant permission with 403. It must run after Auth.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		if !claims.HasPermission(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied: " + permission + " required"})
+			return
+		}
+		c.Next()
+	}
+}
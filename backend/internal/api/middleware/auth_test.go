@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/config"
+	"backend/internal/idempotency"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestTokens(t *testing.T) *auth.Service {
+	t.Helper()
+	tokens, err := auth.NewService(config.AuthConfig{
+		Enabled:         true,
+		Algorithm:       "HS256",
+		Secret:          "0123456789abcdef0123456789abcdef",
+		Issuer:          "backend-api",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	return tokens
+}
+
+func issueTokens(t *testing.T, tokens *auth.Service, id uint) *auth.TokenPair {
+	t.Helper()
+	pair, err := tokens.Issue(&models.User{Base: models.Base{ID: id}, Username: "alice"})
+	require.NoError(t, err)
+	return pair
+}
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+	tokens := newTestTokens(t)
+	pair := issueTokens(t, tokens, 7)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{name: "valid access token", authorization: "Bearer " + pair.AccessToken, wantStatus: http.StatusOK},
+		{name: "scheme is case-insensitive", authorization: "bearer " + pair.AccessToken, wantStatus: http.StatusOK},
+		{name: "no header", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "other scheme", authorization: "Basic YWxpY2U6c2VjcmV0", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "malformed token", authorization: "Bearer abc.def.ghi", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token"`},
+		{name: "refresh token", authorization: "Bearer " + pair.RefreshToken, wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := gin.New()
+			r.Use(Auth(tokens))
+			r.GET("/me", func(c *gin.Context) {
+				claims, ok := ClaimsFromContext(c)
+				require.True(t, ok)
+				c.JSON(http.StatusOK, gin.H{"sub": claims.Subject, "username": claims.Username})
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/me", http.NoBody)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantChallenge, w.Header().Get("WWW-Authenticate"))
+			if tt.wantStatus == http.StatusOK {
+				assert.JSONEq(t, `{"sub":"7","username":"alice"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeysAreScopedToTheUser(t *testing.T) {
+	t.Parallel()
+	tokens := newTestTokens(t)
+
+	r := gin.New()
+	r.Use(Auth(tokens), Idempotency(idempotency.NewMemoryStore(), time.Hour))
+	r.POST("/items", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	send := func(id uint) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+issueTokens(t, tokens, id).AccessToken)
+		req.Header.Set("Idempotency-Key", "k")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, send(1))
+	assert.Equal(t, http.StatusCreated, send(1))
+	assert.Equal(t, http.StatusUnprocessableEntity, send(2), "another user's response is not replayed")
+}
+
+func TestRequirePermission(t *testing.T) {
+	t.Parallel()
+	tokens := newTestTokens(t)
+
+	tests := []struct {
+		name       string
+		roles      []string
+		anonymous  bool
+		wantStatus int
+	}{
+		{name: "role grants the permission", roles: []string{auth.RoleEditor}, wantStatus: http.StatusOK},
+		{name: "role lacks the permission", roles: []string{auth.RoleViewer}, wantStatus: http.StatusForbidden},
+		{name: "no roles", wantStatus: http.StatusForbidden},
+		{name: "not authenticated", anonymous: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := gin.New()
+			if !tt.anonymous {
+				r.Use(Auth(tokens))
+			}
+			r.POST("/items", RequirePermission(auth.PermItemsWrite), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			pair, err := tokens.Issue(&models.User{Base: models.Base{ID: 1}, Roles: tt.roles})
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/items", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.JSONEq(t, `{"error":"Permission denied: items:write required"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Parallel()
+	tokens := newTestTokens(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.APIKey{}))
+	keys := auth.NewAPIKeys(models.NewRepository(db))
+	key := &models.APIKey{Name: "import", Scopes: []string{auth.PermItemsRead}}
+	raw, err := keys.Create(context.Background(), key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		method     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "valid key", apiKey: raw, method: http.MethodGet, wantStatus: http.StatusOK, wantBody: `{"sub":"apikey:1","key":1}`},
+		{name: "scope not granted", apiKey: raw, method: http.MethodPost, wantStatus: http.StatusForbidden, wantBody: `{"error":"Permission denied: items:write required"}`},
+		{name: "invalid key", apiKey: raw + "x", method: http.MethodGet, wantStatus: http.StatusUnauthorized, wantBody: `{"error":"Invalid or expired API key"}`},
+		{name: "no key falls through to Auth", method: http.MethodGet, wantStatus: http.StatusUnauthorized, wantBody: `{"error":"Authentication required"}`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := gin.New()
+			r.Use(APIKey(keys), Auth(tokens))
+			handler := func(c *gin.Context) {
+				claims, _ := ClaimsFromContext(c)
+				key, ok := APIKeyFromContext(c)
+				require.True(t, ok)
+				c.JSON(http.StatusOK, gin.H{"sub": claims.Subject, "key": key.ID})
+			}
+			r.GET("/items", RequirePermission(auth.PermItemsRead), handler)
+			r.POST("/items", RequirePermission(auth.PermItemsWrite), handler)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/items", http.NoBody)
+			if tt.apiKey != "" {
+				req.Header.Set(auth.APIKeyHeader, tt.apiKey)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
@@ -23,8 +23,9 @@ func TestCORSMiddleware(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
-		assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
-		assert.Equal(t, "Content-Type, Content-Length, Accept-Encoding, Authorization, X-Request-ID", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, Content-Length, Accept-Encoding, Authorization, X-Request-ID, If-Match, If-None-Match, Idempotency-Key, X-API-Key", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "X-Request-ID, X-Total-Count, Link, ETag, Idempotent-Replayed", w.Header().Get("Access-Control-Expose-Headers"))
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
@@ -132,7 +133,7 @@ func TestCORSMiddleware(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
-		assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
 		assert.Equal(t, http.StatusNoContent, w.Code)
 	})
 }
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"backend/internal/idempotency"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyLockTimeout is how long a key stays reserved by a request
+	// that never completes, e.g. because its server instance stopped.
+	idempotencyLockTimeout = time.Minute
+)
+
+// bodyRecorder keeps a copy of the response body as it is written.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes POST requests sent with an Idempotency-Key header safe to
+// retry. The first request with a key is processed and its response stored
+// for ttl; retries with the same key and request get that response again,
+// marked with Idempotent-Replayed: true. Reusing a key for a different
+// request, or by another authenticated user, is rejected with 422, and a
+// retry while the first request is still being processed with 409. Server
+// errors are not stored, so the request can be retried. Other methods and
+// requests without the header pass through. It must run after Auth, which
+// identifies the user.
+func Idempotency(store idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Store writes must not be cut short by the client going away.
+		ctx := context.WithoutCancel(c.Request.Context())
+		var principal string
+		if claims, ok := ClaimsFromContext(c); ok {
+			principal = claims.Subject
+		}
+		fingerprint := idempotency.Fingerprint(principal, c.Request.Method, c.Request.URL.RequestURI(), body)
+		existing, reserved, err := store.Reserve(ctx, key, fingerprint, idempotencyLockTimeout)
+		if err != nil {
+			slog.Error("Failed to reserve idempotency key", "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+					gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case existing.Response == nil:
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict,
+					gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				replay(c, existing.Response)
+			}
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		done := false
+		defer func() {
+			// Also runs when the handler panics, before Recovery responds.
+			if done {
+				return
+			}
+			if err := store.Release(ctx, key, fingerprint); err != nil {
+				slog.Error("Failed to release idempotency key", "error", err)
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		done = true
+		resp := idempotency.Response{
+			Status: status,
+			Header: idempotency.ReplayHeader(recorder.Header()),
+			Body:   recorder.body.Bytes(),
+		}
+		if err := store.Complete(ctx, key, fingerprint, resp, ttl); err != nil {
+			// The key stays reserved until idempotencyLockTimeout, so retries
+			// get 409 rather than being applied again.
+			slog.Error("Failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+// replay writes a stored response.
+func replay(c *gin.Context, resp *idempotency.Response) {
+	for name, values := range resp.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Writer.WriteHeader(resp.Status)
+	if _, err := c.Writer.Write(resp.Body); err != nil {
+		slog.Error("Failed to write replayed response", "error", err)
+	}
+	c.Abort()
+}
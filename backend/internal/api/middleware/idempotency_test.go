@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/internal/idempotency"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is an idempotency.Store whose backend is down.
+type failingStore struct{}
+
+func (failingStore) Reserve(context.Context, string, string, time.Duration) (*idempotency.Record, bool, error) {
+	return nil, false, errors.New("store unavailable")
+}
+
+func (failingStore) Complete(context.Context, string, string, idempotency.Response, time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func (failingStore) Release(context.Context, string, string) error {
+	return errors.New("store unavailable")
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		method, key, body string
+		wantStatus        int
+		wantBody          string
+		wantReplayed      bool
+	}
+
+	tests := []struct {
+		name      string
+		status    int // response status of the handler; it panics when -1
+		store     idempotency.Store
+		requests  []request
+		wantCalls int32
+	}{
+		{
+			name:   "a retry replays the stored response",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: "POST", key: "k", body: `{"name":"a"}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`},
+				{method: "POST", key: "k", body: `{"name":"a"}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "client errors are replayed too",
+			status: http.StatusBadRequest,
+			requests: []request{
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusBadRequest},
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusBadRequest, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "reusing a key for another body",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: "POST", key: "k", body: `{"name":"a"}`, wantStatus: http.StatusCreated},
+				{method: "POST", key: "k", body: `{"name":"b"}`, wantStatus: http.StatusUnprocessableEntity},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "requests without a key are not deduplicated",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: "POST", body: `{"name":"a"}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`},
+				{method: "POST", body: `{"name":"a"}`, wantStatus: http.StatusCreated, wantBody: `{"call":2}`},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "other methods are not deduplicated",
+			status: http.StatusOK,
+			requests: []request{
+				{method: "PUT", key: "k", body: `{}`, wantStatus: http.StatusOK},
+				{method: "PUT", key: "k", body: `{}`, wantStatus: http.StatusOK},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "server errors are not stored",
+			status: http.StatusInternalServerError,
+			requests: []request{
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusInternalServerError},
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusInternalServerError},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "a panic releases the key",
+			status: -1,
+			requests: []request{
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusInternalServerError},
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusInternalServerError},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "an overlong key",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: "POST", key: strings.Repeat("k", 256), body: `{}`, wantStatus: http.StatusBadRequest},
+			},
+		},
+		{
+			name:   "an unavailable store",
+			status: http.StatusCreated,
+			store:  failingStore{},
+			requests: []request{
+				{method: "POST", key: "k", body: `{}`, wantStatus: http.StatusServiceUnavailable},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := tt.store
+			if store == nil {
+				store = idempotency.NewMemoryStore()
+			}
+			var calls atomic.Int32
+			handler := func(c *gin.Context) {
+				n := calls.Add(1)
+				if tt.status < 0 {
+					panic("handler failed")
+				}
+				c.Header("Location", "/items/1")
+				c.Header("X-Request-ID", "req")
+				c.JSON(tt.status, gin.H{"call": n})
+			}
+			r := gin.New()
+			r.Use(Recovery(), Idempotency(store, time.Hour))
+			r.Any("/items", handler)
+
+			for i, req := range tt.requests {
+				w := httptest.NewRecorder()
+				httpReq := httptest.NewRequest(req.method, "/items", strings.NewReader(req.body))
+				if req.key != "" {
+					httpReq.Header.Set("Idempotency-Key", req.key)
+				}
+				r.ServeHTTP(w, httpReq)
+
+				require.Equal(t, req.wantStatus, w.Code, "request %d: %s", i, w.Body.String())
+				if req.wantBody != "" {
+					assert.JSONEq(t, req.wantBody, w.Body.String(), "request %d", i)
+				}
+				if req.wantReplayed {
+					assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+					assert.Equal(t, "/items/1", w.Header().Get("Location"))
+					assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+					assert.Empty(t, w.Header().Get("X-Request-ID"), "per-exchange headers are not replayed")
+				} else {
+					assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+				}
+			}
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestIdempotencyConcurrentRetry(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := gin.New()
+	r.Use(Idempotency(idempotency.NewMemoryStore(), time.Hour))
+	r.POST("/items", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "k")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- send() }()
+	<-started
+
+	w := send()
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-first).Code)
+	w = send()
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+}
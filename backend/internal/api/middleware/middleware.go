@@ -42,8 +42,9 @@ func CORS(allowedOrigins string) gin.HandlerFunc {
 			// If there is no Origin header, treat this as a non-CORS request:
 			// allow it through without setting Access-Control-Allow-Origin.
 		}
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, Authorization, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, Authorization, X-Request-ID, If-Match, If-None-Match, Idempotency-Key, X-API-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Total-Count, Link, ETag, Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -3,10 +3,17 @@ package routes
 import (
 	"backend/internal/api/handlers"
 	"backend/internal/api/middleware"
+	"backend/internal/auth"
 	"backend/internal/config"
+	"backend/internal/database"
 	"backend/internal/health"
+	"backend/internal/idempotency"
 	"backend/internal/models"
+	"backend/internal/outbox"
 	"backend/internal/websocket"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,8 +21,9 @@ import (
 
 // SetupRoutes configures all the routes for our application.
 // healthChecker is injected from main so the readiness endpoint reflects real dependency health.
+// tokens authenticates API requests; it is nil when authentication is disabled.
 // Returns the rate limiter so the caller can stop it during shutdown.
-func SetupRoutes(router *gin.Engine, repository models.Repository, healthChecker *health.HealthChecker, cfg *config.Config, hub *websocket.Hub) *handlers.RateLimiter {
+func SetupRoutes(router *gin.Engine, store models.Store, healthChecker *health.HealthChecker, cfg *config.Config, hub *websocket.Hub, tokens *auth.Service) *handlers.RateLimiter {
 	// Add middleware
 	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger())
@@ -25,6 +33,13 @@ func SetupRoutes(router *gin.Engine, repository models.Repository, healthChecker
 
 	// WebSocket endpoint (top-level, outside rate limiter — connections are long-lived)
 	wsHandler := handlers.NewWebSocketHandler(hub, cfg.CORS.AllowedOrigins)
+	wsHandler.SetCompression(cfg.WebSocket.Compression)
+	if tokens != nil {
+		wsHandler.SetTokens(tokens)
+		// Topics carry the same data as the read endpoints of their collection.
+		hub.RequirePermission("items", auth.PermItemsRead)
+		hub.RequirePermission("users", auth.PermUsersRead)
+	}
 	router.GET("/ws", wsHandler.HandleWebSocket)
 
 	// Health check endpoints
@@ -38,24 +53,135 @@ func SetupRoutes(router *gin.Engine, repository models.Repository, healthChecker
 	// Rate limiter for API routes
 	rateLimiter := handlers.NewRateLimiter(100, time.Minute)
 
-	// API v1 routes
+	// API v1 routes. API keys are checked before the rate limiter so that
+	// each key is limited separately, by its own limit if it has one. Keys
+	// that fail verification count against the client IP instead, and once
+	// an IP has too many failures its keys are no longer looked up.
 	v1 := router.Group("/api/v1")
-	v1.Use(rateLimiter.RateLimit())
+	if tokens != nil {
+		v1.Use(rateLimiter.LimitFailures(http.StatusUnauthorized))
+		v1.Use(middleware.APIKey(auth.NewAPIKeys(store)))
+		v1.Use(rateLimiter.RateLimitBy(func(c *gin.Context) (string, int) {
+			if key, ok := middleware.APIKeyFromContext(c); ok {
+				return "key:" + strconv.FormatUint(uint64(key.ID), 10), key.RateLimit
+			}
+			return c.ClientIP(), 0
+		}))
+	} else {
+		v1.Use(rateLimiter.RateLimit())
+	}
 	{
 		// Ping endpoint
 		v1.GET("/ping", handlers.Ping)
 
+		// Auth endpoints
+		if tokens != nil && tokens.CanIssue() {
+			authHandler := handlers.NewAuthHandler(store, tokens)
+			v1.POST("/auth/login", authHandler.Login)
+			v1.POST("/auth/refresh", authHandler.Refresh)
+		}
+	}
+
+	// Authenticated API v1 routes
+	api := v1.Group("")
+	if tokens != nil {
+		api.Use(middleware.Auth(tokens))
+	}
+	var idempotencyStore idempotency.Store
+	if cfg.Idempotency.TTL > 0 {
+		idempotencyStore = database.NewIdempotencyStore(cfg, store)
+		api.Use(middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL))
+	}
+	// requirePermission restricts a route to tokens whose roles grant
+	// permission; without authentication there are no roles to check.
+	requirePermission := func(permission string) gin.HandlerFunc {
+		if tokens == nil {
+			return func(*gin.Context) {}
+		}
+		return middleware.RequirePermission(permission)
+	}
+	{
 		// Items endpoints
-		itemsHandler := handlers.NewHandlerWithHub(repository, hub)
-		items := v1.Group("/items")
+		itemsHandler := handlers.NewHandlerWithHub(store, hub)
+		itemsHandler.SetRequireIfMatch(cfg.Preconditions.RequireIfMatch)
+		if outboxer, ok := store.(models.Outboxer); ok && cfg.Outbox.Enabled {
+			// Change events are recorded with their changes and delivered
+			// from the outbox until the hub shuts down.
+			dispatcher := outbox.NewDispatcher(outboxer, hub)
+			dispatcher.SetInterval(cfg.Outbox.PollInterval)
+			dispatcher.SetRetention(cfg.Outbox.Retention)
+			go dispatcher.Run(hub.Done())
+			itemsHandler.SetOutbox(dispatcher)
+		}
+		// WebSocket clients call the same item mutations, limited by the
+		// hub's call rate limit and, like REST requests, safe to retry with
+		// an idempotency key.
+		rpcHandler := handlers.NewRPCHandler(itemsHandler)
+		if idempotencyStore != nil {
+			rpcHandler.SetIdempotency(idempotencyStore, cfg.Idempotency.TTL)
+		}
+		hub.SetDispatcher(rpcHandler)
+		items := api.Group("/items")
 		{
-			items.GET("", itemsHandler.GetItems)
-			items.GET("/:id", itemsHandler.GetItem)
-			items.POST("", itemsHandler.CreateItem)
-			items.PUT("/:id", itemsHandler.UpdateItem)
-			items.DELETE("/:id", itemsHandler.DeleteItem)
+			items.GET("", requirePermission(auth.PermItemsRead), itemsHandler.GetItems)
+			items.GET("/:id", requirePermission(auth.PermItemsRead), itemsHandler.GetItem)
+			items.POST("", requirePermission(auth.PermItemsWrite), itemsHandler.CreateItem)
+			items.PUT("/:id", requirePermission(auth.PermItemsWrite), itemsHandler.UpdateItem)
+			items.PATCH("/:id", requirePermission(auth.PermItemsWrite), itemsHandler.PatchItem)
+			items.DELETE("/:id", requirePermission(auth.PermItemsWrite), itemsHandler.DeleteItem)
+			items.POST("/:id/restore", requirePermission(auth.PermItemsWrite), itemsHandler.RestoreItem)
+		}
+		api.POST("/items:method", requirePermission(auth.PermItemsWrite), customMethods(map[string]gin.HandlerFunc{
+			"batch": itemsHandler.BatchItems,
+		}))
+
+		// Users endpoints
+		users := api.Group("/users")
+		{
+			users.GET("", requirePermission(auth.PermUsersRead), itemsHandler.GetUsers)
+			users.GET("/:id", requirePermission(auth.PermUsersRead), itemsHandler.GetUser)
+			users.POST("", requirePermission(auth.PermUsersWrite), itemsHandler.CreateUser)
+			users.PUT("/:id", requirePermission(auth.PermUsersWrite), itemsHandler.UpdateUser)
+			users.DELETE("/:id", requirePermission(auth.PermUsersWrite), itemsHandler.DeleteUser)
+		}
+
+		// Server-Sent Events, for clients that cannot open a WebSocket, and
+		// who is viewing which resource. Topic permissions are checked by
+		// the hub, as for /ws.
+		api.GET("/events", handlers.NewEventsHandler(hub).StreamEvents)
+		api.GET("/presence", wsHandler.GetPresence)
+
+		// Admin endpoints
+		adminHandler := handlers.NewAdminHandler(store, cfg.SoftDelete.Retention)
+		admin := api.Group("/admin")
+		{
+			admin.POST("/purge", requirePermission(auth.PermDataPurge), adminHandler.PurgeDeleted)
+			admin.GET("/roles", requirePermission(auth.PermRolesWrite), adminHandler.ListRoles)
+			admin.PUT("/users/:id/roles", requirePermission(auth.PermRolesWrite), adminHandler.SetUserRoles)
+
+			apiKeyHandler := handlers.NewAPIKeyHandler(store)
+			admin.POST("/api-keys", requirePermission(auth.PermAPIKeysWrite), apiKeyHandler.CreateAPIKey)
+			admin.GET("/api-keys", requirePermission(auth.PermAPIKeysWrite), apiKeyHandler.ListAPIKeys)
+			admin.DELETE("/api-keys/:id", requirePermission(auth.PermAPIKeysWrite), apiKeyHandler.RevokeAPIKey)
+
+			admin.GET("/ws/clients", requirePermission(auth.PermConnectionsRead), wsHandler.ListClients)
 		}
 	}
 
 	return rateLimiter
 }
+
+// customMethods serves custom methods on a collection, such as
+// POST /api/v1/items:batch. gin reads ":method" in a path as a parameter, so
+// the methods of a collection share one route and are dispatched by name.
+func customMethods(methods map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := strings.CutPrefix(c.Param("method"), ":")
+		handler, found := methods[name]
+		if !ok || !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		handler(c)
+	}
+}
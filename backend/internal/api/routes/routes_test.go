@@ -1,18 +1,27 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"backend/internal/api/handlers"
+	"backend/internal/auth"
 	"backend/internal/config"
 	"backend/internal/health"
+	"backend/internal/models"
 	"backend/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 func TestSetupRoutes(t *testing.T) {
@@ -40,7 +49,7 @@ func TestSetupRoutes(t *testing.T) {
 	defer hub.Shutdown()
 
 	// Setup routes
-	rl := SetupRoutes(router, mockRepo, healthChecker, cfg, hub)
+	rl := SetupRoutes(router, mockRepo, healthChecker, cfg, hub, nil)
 	defer rl.Stop()
 
 	// Test cases
@@ -65,12 +74,26 @@ func TestSetupRoutes(t *testing.T) {
 			expectedCode: 200,
 			expectedBody: map[string]string{"message": "pong"},
 		},
+		{
+			name:         "Batch custom method",
+			route:        "/api/v1/items:batch",
+			method:       "POST",
+			expectedCode: 400,
+			expectedBody: map[string]string{"error": "Invalid request format"},
+		},
+		{
+			name:         "Unknown custom method",
+			route:        "/api/v1/items:merge",
+			method:       "POST",
+			expectedCode: 404,
+			expectedBody: map[string]string{"error": "Not found"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			req, _ := http.NewRequest(tt.method, tt.route, nil)
+			req, _ := http.NewRequest(tt.method, tt.route, http.NoBody)
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedCode, w.Code)
@@ -84,3 +107,162 @@ func TestSetupRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestSetupRoutesWithAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	mockRepo := handlers.NewMockRepository()
+	for _, username := range []string{"alice", "root"} {
+		user := &models.User{Username: username, Email: username + "@example.com"}
+		require.NoError(t, user.SetPassword("correct horse"))
+		require.NoError(t, mockRepo.Create(context.Background(), user))
+	}
+
+	healthChecker := health.New()
+	healthChecker.SetReady(true)
+
+	cfg := &config.Config{
+		CORS: config.CORSConfig{AllowedOrigins: "*"},
+		Auth: config.AuthConfig{
+			Enabled:         true,
+			Algorithm:       "HS256",
+			Secret:          "0123456789abcdef0123456789abcdef",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+			BootstrapAdmins: "root",
+		},
+	}
+	tokens, err := auth.NewService(cfg.Auth)
+	require.NoError(t, err)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	rl := SetupRoutes(router, mockRepo, healthChecker, cfg, hub, tokens)
+	defer rl.Stop()
+
+	serve := func(method, route, body, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, route, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("GET", "/health", "", "").Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/ping", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/api/v1/items", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("POST", "/api/v1/items:batch", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/ws", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/api/v1/events?topics=items", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/api/v1/presence?resource=items:1", "", "").Code)
+
+	login := func(username string) auth.TokenPair {
+		w := serve("POST", "/api/v1/auth/login", `{"username":"`+username+`","password":"correct horse"}`, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var pair auth.TokenPair
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+		return pair
+	}
+	alice, root := login("alice"), login("root")
+	item := `{"name":"Widget","price":1.5}`
+
+	// Without roles alice is a viewer.
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/items", "", alice.AccessToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/api/v1/items", "", alice.RefreshToken).Code)
+	assert.Equal(t, http.StatusForbidden, serve("POST", "/api/v1/items", item, alice.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, serve("PUT", "/api/v1/admin/users/1/roles", `{"roles":["admin"]}`, alice.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, serve("GET", "/api/v1/events?topics=orders", "", alice.AccessToken).Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/presence?resource=items:1", "", alice.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, serve("GET", "/api/v1/presence?resource=orders:1", "", alice.AccessToken).Code)
+
+	// The bootstrap admin makes her an editor, which applies once she refreshes.
+	assert.Equal(t, http.StatusOK, serve("PUT", "/api/v1/admin/users/1/roles", `{"roles":["editor"]}`, root.AccessToken).Code)
+	w := serve("POST", "/api/v1/auth/refresh", `{"refresh_token":"`+alice.RefreshToken+`"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &alice))
+	assert.Equal(t, http.StatusCreated, serve("POST", "/api/v1/items", item, alice.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, serve("POST", "/api/v1/admin/purge", "", alice.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, serve("GET", "/api/v1/admin/ws/clients", "", alice.AccessToken).Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/admin/ws/clients", "", root.AccessToken).Code)
+}
+
+func TestSetupRoutesWithAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Item{}, &models.APIKey{}))
+	store := models.NewRepository(db)
+	root := &models.User{Username: "root", Email: "root@example.com"}
+	require.NoError(t, root.SetPassword("correct horse"))
+	require.NoError(t, store.Create(context.Background(), root))
+
+	healthChecker := health.New()
+	healthChecker.SetReady(true)
+	cfg := &config.Config{
+		CORS: config.CORSConfig{AllowedOrigins: "*"},
+		Auth: config.AuthConfig{
+			Enabled:         true,
+			Algorithm:       "HS256",
+			Secret:          "0123456789abcdef0123456789abcdef",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+			BootstrapAdmins: "root",
+		},
+	}
+	tokens, err := auth.NewService(cfg.Auth)
+	require.NoError(t, err)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	router := gin.New()
+	rl := SetupRoutes(router, store, healthChecker, cfg, hub, tokens)
+	defer rl.Stop()
+
+	serve := func(method, route, body string, header ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, route, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("POST", "/api/v1/auth/login", `{"username":"root","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var pair auth.TokenPair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+	bearer := "Bearer " + pair.AccessToken
+
+	w = serve("POST", "/api/v1/admin/api-keys", `{"name":"import","scopes":["items:read"],"rate_limit":3}`, "Authorization", bearer)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created handlers.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// The key reads items but cannot write them or manage keys.
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/items", "", auth.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusForbidden, serve("POST", "/api/v1/items", `{"name":"Widget","price":1}`, auth.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusForbidden, serve("GET", "/api/v1/admin/api-keys", "", auth.APIKeyHeader, created.Key).Code)
+	// Its own limit of 3 requests is used up, while the admin's is not.
+	assert.Equal(t, http.StatusTooManyRequests, serve("GET", "/api/v1/items", "", auth.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/items", "", "Authorization", bearer).Code)
+
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/api/v1/admin/api-keys/1", "", "Authorization", bearer).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/api/v1/items", "", auth.APIKeyHeader, created.Key).Code)
+
+	// Invalid keys count against the client IP, the revoked one included,
+	// until it may not try more.
+	for i := 1; i < 100; i++ {
+		require.Equal(t, http.StatusUnauthorized, serve("GET", "/api/v1/items", "", auth.APIKeyHeader, fmt.Sprintf("guess-%d", i)).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve("GET", "/api/v1/items", "", auth.APIKeyHeader, "guess-100").Code)
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+)
+
+// APIKeyHeader is the request header that carries an API key.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyScheme starts every API key, so leaked keys are easy to recognise.
+// A key reads "ak_<prefix>_<secret>"; the prefix is stored in clear to find
+// the key, the whole key only as a SHA-256 hash.
+const apiKeyScheme = "ak_"
+
+// lastUsedResolution is how stale an API key's LastUsedAt may become before a
+// request updates it, so busy keys do not cause a write per request.
+const lastUsedResolution = time.Minute
+
+// ErrInvalidAPIKey is returned by Verify for keys that are unknown, revoked
+// or expired.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeys creates and verifies API keys stored in a models.Store.
+type APIKeys struct {
+	keys models.Repository[models.APIKey]
+	now  func() time.Time
+}
+
+// NewAPIKeys creates an APIKeys backed by store.
+func NewAPIKeys(store models.Store) *APIKeys {
+	return &APIKeys{
+		keys: models.NewTypedRepository[models.APIKey](store),
+		now:  time.Now,
+	}
+}
+
+// Create generates a key for key's name and scopes, stores key with the
+// generated key's hash and returns the generated key. It cannot be recovered
+// afterwards.
+func (a *APIKeys) Create(ctx context.Context, key *models.APIKey) (string, error) {
+	prefix, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+	raw := apiKeyScheme + prefix + "_" + secret
+
+	key.Prefix = prefix
+	key.KeyHash = hashAPIKey(raw)
+	if err := a.keys.Create(ctx, key); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Verify returns the stored key matching raw. Using a key records when it was
+// last used.
+func (a *APIKeys) Verify(ctx context.Context, raw string) (*models.APIKey, error) {
+	rest, ok := strings.CutPrefix(raw, apiKeyScheme)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	keys, err := a.keys.List(ctx, models.Filter{Field: "prefix", Op: "exact", Value: prefix})
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrInvalidAPIKey
+	}
+	key := &keys[0]
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(raw))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := a.now().UTC()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		key.LastUsedAt = &now
+		// Patch rather than Update, so that a key revoked meanwhile stays
+		// revoked. A failed write only loses the timestamp.
+		if err := a.keys.Patch(ctx, key, "last_used_at"); err != nil {
+			slog.Warn("Failed to record API key use", "api_key_id", key.ID, "error", err)
+		}
+	}
+	return key, nil
+}
+
+// APIKeyClaims returns the claims a request authenticated with key acts
+// under. Their subject is "apikey:<id>" and their scopes are the key's.
+func APIKeyClaims(key *models.APIKey) *Claims {
+	claims := &Claims{
+		Username: key.Name,
+		Scopes:   key.Scopes,
+		TokenUse: AccessToken,
+	}
+	claims.Subject = "apikey:" + strconv.FormatUint(uint64(key.ID), 10)
+	return claims
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+	return encode(b), nil
+}
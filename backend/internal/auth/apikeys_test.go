@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupAPIKeys(t *testing.T) (*APIKeys, models.Repository[models.APIKey]) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.APIKey{}))
+	store := models.NewRepository(db)
+	return NewAPIKeys(store), models.NewTypedRepository[models.APIKey](store)
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		key     models.APIKey
+		mutate  func(t *testing.T, repo models.Repository[models.APIKey], key *models.APIKey, raw string) string
+		wantErr bool
+	}{
+		{
+			name: "valid key",
+			key:  models.APIKey{Name: "import", Scopes: []string{PermItemsRead}},
+		},
+		{
+			name: "wrong secret",
+			key:  models.APIKey{Name: "import", Scopes: []string{PermItemsRead}},
+			mutate: func(t *testing.T, _ models.Repository[models.APIKey], _ *models.APIKey, raw string) string {
+				return raw[:len(raw)-4] + "AAAA"
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown prefix",
+			key:  models.APIKey{Name: "import", Scopes: []string{PermItemsRead}},
+			mutate: func(t *testing.T, _ models.Repository[models.APIKey], key *models.APIKey, raw string) string {
+				return strings.Replace(raw, key.Prefix, "0000000000000000", 1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "not an API key",
+			key:  models.APIKey{Name: "import", Scopes: []string{PermItemsRead}},
+			mutate: func(t *testing.T, _ models.Repository[models.APIKey], _ *models.APIKey, _ string) string {
+				return "secret"
+			},
+			wantErr: true,
+		},
+		{
+			name:    "expired key",
+			key:     models.APIKey{Name: "import", Scopes: []string{PermItemsRead}, ExpiresAt: &past},
+			wantErr: true,
+		},
+		{
+			name: "revoked key",
+			key:  models.APIKey{Name: "import", Scopes: []string{PermItemsRead}},
+			mutate: func(t *testing.T, repo models.Repository[models.APIKey], key *models.APIKey, raw string) string {
+				require.NoError(t, repo.Delete(ctx, key))
+				return raw
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			keys, repo := setupAPIKeys(t)
+
+			key := tt.key
+			raw, err := keys.Create(ctx, &key)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(raw, "ak_"+key.Prefix+"_"))
+			assert.NotContains(t, key.KeyHash, raw)
+			if tt.mutate != nil {
+				raw = tt.mutate(t, repo, &key, raw)
+			}
+
+			got, err := keys.Verify(ctx, raw)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAPIKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, got.ID)
+
+			stored, err := repo.FindByID(ctx, key.ID)
+			require.NoError(t, err)
+			require.NotNil(t, stored.LastUsedAt, "use is recorded")
+		})
+	}
+}
+
+func TestAPIKeysRecordUseOncePerResolution(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	keys, repo := setupAPIKeys(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	keys.now = func() time.Time { return now }
+
+	key := &models.APIKey{Name: "import", Scopes: []string{PermItemsRead}}
+	raw, err := keys.Create(ctx, key)
+	require.NoError(t, err)
+
+	lastUsed := func() time.Time {
+		stored, err := repo.FindByID(ctx, key.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.LastUsedAt)
+		return stored.LastUsedAt.UTC()
+	}
+
+	_, err = keys.Verify(ctx, raw)
+	require.NoError(t, err)
+	first := now
+	assert.True(t, first.Equal(lastUsed()))
+
+	now = now.Add(lastUsedResolution / 2)
+	_, err = keys.Verify(ctx, raw)
+	require.NoError(t, err)
+	assert.True(t, first.Equal(lastUsed()), "not rewritten within the resolution")
+
+	now = now.Add(lastUsedResolution)
+	_, err = keys.Verify(ctx, raw)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(lastUsed()))
+}
+
+func TestAPIKeyClaims(t *testing.T) {
+	t.Parallel()
+	claims := APIKeyClaims(&models.APIKey{Base: models.Base{ID: 3}, Name: "import", Scopes: []string{PermItemsWrite}})
+
+	assert.Equal(t, "apikey:3", claims.Subject)
+	assert.True(t, claims.HasPermission(PermItemsWrite))
+	assert.False(t, claims.HasPermission(PermItemsRead), "scopes replace the default role")
+	_, err := claims.UserID()
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+// Package auth issues and verifies the JWT bearer tokens that authenticate
+// API requests. Tokens are signed with HS256 or RS256; besides its own signing
+// key, a Service accepts the keys of a local JWKS file.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token uses, carried in the token_use claim so a refresh token cannot be
+// presented as an access token or the other way round.
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+// ErrCannotIssue is returned by Issue when the Service only has verification
+// keys.
+var ErrCannotIssue = errors.New("no signing key configured")
+
+// Claims are the claims of the tokens a Service issues. The subject is the
+// user ID. Requests authenticated with an API key carry claims too; see
+// APIKeyClaims.
+type Claims struct {
+	jwt.RegisteredClaims
+	Username string   `json:"username,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	// Scopes, when set, are the only permissions granted, whatever the roles.
+	Scopes   []string `json:"scopes,omitempty"`
+	TokenUse string   `json:"token_use"`
+}
+
+// UserID returns the user ID in the subject claim.
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid subject %q", c.Subject)
+	}
+	return uint(id), nil
+}
+
+// TokenPair is the response to a login or refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int    `json:"expires_in" example:"900"` // Access token lifetime in seconds
+}
+
+// Service issues and verifies tokens.
+type Service struct {
+	method     jwt.SigningMethod
+	signingKey interface{} // nil when tokens cannot be issued
+	keyID      string
+	keys       []verificationKey
+
+	issuer     string
+	audience   string
+	admins     map[string]bool
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+// NewService creates a Service from cfg, reading its key files.
+func NewService(cfg config.AuthConfig) (*Service, error) {
+	s := &Service{
+		keyID:      cfg.KeyID,
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		accessTTL:  cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+		admins:     make(map[string]bool),
+		now:        time.Now,
+	}
+	for _, username := range strings.Split(cfg.BootstrapAdmins, ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			s.admins[username] = true
+		}
+	}
+
+	switch cfg.Algorithm {
+	case "HS256":
+		s.method = jwt.SigningMethodHS256
+		if cfg.Secret != "" {
+			s.signingKey = []byte(cfg.Secret)
+			s.keys = append(s.keys, verificationKey{id: cfg.KeyID, key: []byte(cfg.Secret)})
+		}
+	case "RS256":
+		s.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			key, err := loadRSAPrivateKey(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			s.signingKey = key
+			s.keys = append(s.keys, verificationKey{id: cfg.KeyID, key: &key.PublicKey})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, keys...)
+	}
+	if len(s.keys) == 0 {
+		return nil, errors.New("no verification keys configured")
+	}
+	return s, nil
+}
+
+// CanIssue reports whether the Service has a signing key.
+func (s *Service) CanIssue() bool {
+	return s.signingKey != nil
+}
+
+// Issue creates an access and a refresh token for user, carrying the user's
+// roles. Bootstrap admins are given the admin role in addition.
+func (s *Service) Issue(user *models.User) (*TokenPair, error) {
+	if !s.CanIssue() {
+		return nil, ErrCannotIssue
+	}
+
+	access, err := s.sign(user, AccessToken, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.sign(user, RefreshToken, s.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}, nil
+}
+
+func (s *Service) sign(user *models.User, use string, ttl time.Duration) (string, error) {
+	now := s.now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Username: user.Username,
+		Roles:    s.roles(user),
+		TokenUse: use,
+	}
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
+
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.keyID != "" {
+		token.Header["kid"] = s.keyID
+	}
+	signed, err := token.SignedString(s.signingKey)
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+	return signed, nil
+}
+
+func (s *Service) roles(user *models.User) []string {
+	roles := slices.Clone(user.Roles)
+	if s.admins[user.Username] && !slices.Contains(roles, RoleAdmin) {
+		roles = append(roles, RoleAdmin)
+	}
+	return roles
+}
+
+// Verify parses token, checks its signature, lifetime, issuer and audience,
+// and that it is meant for use, and returns its claims.
+func (s *Service) Verify(token, use string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if s.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(s.issuer))
+	}
+	if s.audience != "" {
+		opts = append(opts, jwt.WithAudience(s.audience))
+	}
+
+	var claims Claims
+	if _, err := jwt.ParseWithClaims(token, &claims, s.keyFunc, opts...); err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != use {
+		return nil, fmt.Errorf("%s token used as %s token", claims.TokenUse, use)
+	}
+	return &claims, nil
+}
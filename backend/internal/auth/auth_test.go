@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func authConfig(alg string) config.AuthConfig {
+	return config.AuthConfig{
+		Enabled:         true,
+		Algorithm:       alg,
+		Issuer:          "backend-api",
+		Audience:        "backend",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}
+}
+
+// externalToken signs claims for the service's issuer and audience with key.
+func externalToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, mutate func(*Claims)) string {
+	t.Helper()
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "backend-api",
+			Audience:  jwt.ClaimStrings{"backend"},
+			Subject:   "7",
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		TokenUse: AccessToken,
+	}
+	if mutate != nil {
+		mutate(&claims)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestService(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := writeFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	}))
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "external", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(otherKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(otherKey.E)).Bytes()),
+		},
+		{"kty": "EC", "kid": "ignored", "crv": "P-256"},
+	}})
+	require.NoError(t, err)
+	jwksFile := writeFile(t, "jwks.json", jwks)
+
+	hs256 := authConfig("HS256")
+	hs256.Secret = testSecret
+	rs256 := authConfig("RS256")
+	rs256.PrivateKeyFile = keyFile
+	rs256.KeyID = "local"
+	rs256.JWKSFile = jwksFile
+	jwksOnly := authConfig("RS256")
+	jwksOnly.JWKSFile = jwksFile
+
+	user := &models.User{Base: models.Base{ID: 7}, Username: "alice"}
+
+	tests := []struct {
+		name    string
+		cfg     config.AuthConfig
+		token   func(t *testing.T, s *Service) string
+		use     string
+		wantErr bool
+	}{
+		{
+			name: "HS256 access token",
+			cfg:  hs256,
+			use:  AccessToken,
+		},
+		{
+			name: "RS256 refresh token",
+			cfg:  rs256,
+			use:  RefreshToken,
+		},
+		{
+			name: "token signed by a JWKS key",
+			cfg:  jwksOnly,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodRS256, "external", otherKey, nil)
+			},
+			use: AccessToken,
+		},
+		{
+			name: "refresh token used as access token",
+			cfg:  hs256,
+			token: func(t *testing.T, s *Service) string {
+				pair, err := s.Issue(user)
+				require.NoError(t, err)
+				return pair.RefreshToken
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "expired token",
+			cfg:  hs256,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodHS256, "", []byte(testSecret), func(c *Claims) {
+					c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				})
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "token without expiry",
+			cfg:  hs256,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodHS256, "", []byte(testSecret), func(c *Claims) {
+					c.ExpiresAt = nil
+				})
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "wrong audience",
+			cfg:  hs256,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodHS256, "", []byte(testSecret), func(c *Claims) {
+					c.Audience = jwt.ClaimStrings{"other"}
+				})
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			cfg:  hs256,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodHS256, "", []byte(testSecret), func(c *Claims) {
+					c.Issuer = "other"
+				})
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "unknown key",
+			cfg:  rs256,
+			token: func(t *testing.T, s *Service) string {
+				unknown, err := rsa.GenerateKey(rand.Reader, 2048)
+				require.NoError(t, err)
+				return externalToken(t, jwt.SigningMethodRS256, "", unknown, nil)
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "kid naming another key",
+			cfg:  rs256,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodRS256, "external", rsaKey, nil)
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "HS256 token for an RSA key",
+			cfg:  rs256,
+			token: func(t *testing.T, s *Service) string {
+				der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+				require.NoError(t, err)
+				return externalToken(t, jwt.SigningMethodHS256, "", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil)
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+		{
+			name: "unsigned token",
+			cfg:  hs256,
+			token: func(t *testing.T, s *Service) string {
+				return externalToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, nil)
+			},
+			use:     AccessToken,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := NewService(tt.cfg)
+			require.NoError(t, err)
+
+			var token string
+			if tt.token != nil {
+				token = tt.token(t, s)
+			} else {
+				pair, err := s.Issue(user)
+				require.NoError(t, err)
+				assert.Equal(t, "Bearer", pair.TokenType)
+				assert.Equal(t, 900, pair.ExpiresIn)
+				token = pair.AccessToken
+				if tt.use == RefreshToken {
+					token = pair.RefreshToken
+				}
+			}
+
+			claims, err := s.Verify(token, tt.use)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			id, err := claims.UserID()
+			require.NoError(t, err)
+			assert.Equal(t, uint(7), id)
+			assert.Equal(t, tt.use, claims.TokenUse)
+		})
+	}
+
+	t.Run("verification-only service cannot issue", func(t *testing.T) {
+		t.Parallel()
+		s, err := NewService(jwksOnly)
+		require.NoError(t, err)
+		assert.False(t, s.CanIssue())
+		_, err = s.Issue(user)
+		assert.ErrorIs(t, err, ErrCannotIssue)
+	})
+
+	t.Run("unreadable key files", func(t *testing.T) {
+		t.Parallel()
+		cfg := authConfig("RS256")
+		cfg.PrivateKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+		_, err := NewService(cfg)
+		assert.Error(t, err)
+
+		cfg = authConfig("RS256")
+		cfg.JWKSFile = writeFile(t, "empty.json", []byte(`{"keys":[]}`))
+		_, err = NewService(cfg)
+		assert.ErrorContains(t, err, "no usable signing keys")
+	})
+}
+
+func TestHasPermission(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{name: "no roles can read", permission: PermItemsRead, want: true},
+		{name: "no roles cannot write", permission: PermItemsWrite, want: false},
+		{name: "viewer cannot write", roles: []string{RoleViewer}, permission: PermItemsWrite, want: false},
+		{name: "editor writes items", roles: []string{RoleEditor}, permission: PermItemsWrite, want: true},
+		{name: "editor cannot manage users", roles: []string{RoleEditor}, permission: PermUsersWrite, want: false},
+		{name: "admin assigns roles", roles: []string{RoleViewer, RoleAdmin}, permission: PermRolesWrite, want: true},
+		{name: "unknown role grants nothing", roles: []string{"root"}, permission: PermItemsRead, want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, HasPermission(tt.roles, tt.permission))
+		})
+	}
+}
+
+func TestIssueCarriesRoles(t *testing.T) {
+	t.Parallel()
+
+	cfg := authConfig("HS256")
+	cfg.Secret = testSecret
+	cfg.BootstrapAdmins = "root, alice"
+	s, err := NewService(cfg)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		user *models.User
+		want []string
+	}{
+		{user: &models.User{Username: "bob", Roles: []string{RoleEditor}}, want: []string{RoleEditor}},
+		{user: &models.User{Username: "alice", Roles: []string{RoleEditor}}, want: []string{RoleEditor, RoleAdmin}},
+		{user: &models.User{Username: "root", Roles: []string{RoleAdmin}}, want: []string{RoleAdmin}},
+	} {
+		pair, err := s.Issue(tt.user)
+		require.NoError(t, err)
+		claims, err := s.Verify(pair.AccessToken, AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, claims.Roles, tt.user.Username)
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verificationKey is a key accepted when verifying tokens: an HMAC secret
+// ([]byte) or an RSA public key (*rsa.PublicKey). id is its "kid", empty for
+// a key that tokens need not name.
+type verificationKey struct {
+	id  string
+	key interface{}
+}
+
+// keyFunc selects the keys that may have signed token: those of the type its
+// algorithm uses, restricted to the key it names in its "kid" header if any.
+// Matching key types to the algorithm prevents an RSA public key from being
+// used as an HMAC secret.
+func (s *Service) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	var set jwt.VerificationKeySet
+	for _, k := range s.keys {
+		if kid != "" && k.id != "" && k.id != kid {
+			continue
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if _, ok := k.key.([]byte); !ok {
+				continue
+			}
+		case *jwt.SigningMethodRSA:
+			if _, ok := k.key.(*rsa.PublicKey); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, k.key)
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("no %s key for kid %q", token.Method.Alg(), kid)
+	}
+	return set, nil
+}
+
+// loadRSAPrivateKey reads a PEM-encoded PKCS #1 or PKCS #8 RSA private key.
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// jwk is the subset of a JSON Web Key (RFC 7517) used for RSA ("RSA") and
+// symmetric ("oct") signing keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// loadJWKS reads the signing keys of a JSON Web Key Set file. Keys of other
+// types or uses are skipped.
+func loadJWKS(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS %s: %w", path, err)
+	}
+
+	var keys []verificationKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS %s: key %d: %w", path, i, err)
+		}
+		if key != nil {
+			keys = append(keys, verificationKey{id: k.Kid, key: key})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s has no usable signing keys", path)
+	}
+	return keys, nil
+}
+
+// key decodes the key material, or returns nil for an unsupported key type.
+func (k *jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "oct":
+		if k.Alg != "" && k.Alg != "HS256" {
+			return nil, nil
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("invalid k")
+		}
+		return secret, nil
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return nil, nil
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("invalid n")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid e")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"slices"
+	"sort"
+)
+
+// Permissions checked by the API routes.
+const (
+	PermItemsRead  = "items:read"
+	PermItemsWrite = "items:write"
+	PermUsersRead  = "users:read"
+	PermUsersWrite = "users:write"
+	PermRolesWrite = "roles:write"
+	PermDataPurge  = "data:purge"
+
+	PermAPIKeysWrite = "apikeys:write"
+	// PermConnectionsRead lists the WebSocket clients connected to an
+	// instance.
+	PermConnectionsRead = "connections:read"
+)
+
+// permissions lists every permission, in the order of the constants above.
+var permissions = []string{
+	PermItemsRead, PermItemsWrite, PermUsersRead, PermUsersWrite,
+	PermRolesWrite, PermDataPurge, PermAPIKeysWrite, PermConnectionsRead,
+}
+
+// Roles assignable to users.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// DefaultRole is the role of users who have none assigned.
+const DefaultRole = RoleViewer
+
+// rolePermissions defines the permissions each role grants. Roles are
+// resolved to permissions when a request is authorized, so changes here apply
+// to tokens already issued.
+var rolePermissions = map[string][]string{
+	RoleViewer: {PermItemsRead, PermUsersRead},
+	RoleEditor: {PermItemsRead, PermItemsWrite, PermUsersRead},
+	RoleAdmin:  slices.Clone(permissions),
+}
+
+// Role describes a role and the permissions it grants.
+type Role struct {
+	Name        string   `json:"name" example:"editor"`
+	Permissions []string `json:"permissions"`
+}
+
+// Roles returns every role, sorted by name.
+func Roles() []Role {
+	roles := make([]Role, 0, len(rolePermissions))
+	for name, perms := range rolePermissions {
+		roles = append(roles, Role{Name: name, Permissions: slices.Clone(perms)})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// IsRole reports whether name is a defined role.
+func IsRole(name string) bool {
+	_, ok := rolePermissions[name]
+	return ok
+}
+
+// IsPermission reports whether name is a defined permission.
+func IsPermission(name string) bool {
+	return slices.Contains(permissions, name)
+}
+
+// HasPermission reports whether roles grant permission. No roles means
+// DefaultRole; unknown roles grant nothing.
+func HasPermission(roles []string, permission string) bool {
+	if len(roles) == 0 {
+		roles = []string{DefaultRole}
+	}
+	for _, role := range roles {
+		if slices.Contains(rolePermissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the claims grant permission: their scopes if
+// they have any, otherwise their roles.
+func (c *Claims) HasPermission(permission string) bool {
+	if c.Scopes != nil {
+		return slices.Contains(c.Scopes, permission)
+	}
+	return HasPermission(c.Roles, permission)
+}
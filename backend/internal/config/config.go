@@ -25,6 +25,33 @@ const (
 	defaultWriteTimeout    = time.Duration(0)
 	defaultIdleTimeout     = 30 * time.Second
 	defaultShutdownTimeout = 30 * time.Second
+	// defaultSoftDeleteRetention is how long soft-deleted records are kept
+	// before the admin purge endpoint removes them permanently.
+	defaultSoftDeleteRetention = 30 * 24 * time.Hour
+	// defaultIdempotencyTTL is how long responses to requests sent with an
+	// Idempotency-Key are replayed to retries.
+	defaultIdempotencyTTL = 24 * time.Hour
+	// defaultOutboxPollInterval is how often the outbox is checked for
+	// events not yet delivered, and defaultOutboxRetention how long
+	// delivered events are kept.
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxRetention    = 24 * time.Hour
+	// defaultWebSocketHistorySize is how many events the WebSocket hub
+	// buffers for clients resuming after a reconnect.
+	defaultWebSocketHistorySize int32 = 1024
+	// defaultWebSocketMaxMessageSize is the largest message, in bytes, a
+	// WebSocket client may send.
+	defaultWebSocketMaxMessageSize int32 = 64 << 10
+	// defaultWebSocketCallRateLimit is how many calls a WebSocket client may
+	// make per minute, as many as the requests of a REST client.
+	defaultWebSocketCallRateLimit int32 = 100
+	// defaultAccessTokenTTL and defaultRefreshTokenTTL are the lifetimes of
+	// the tokens issued by /api/v1/auth/login and /api/v1/auth/refresh.
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	// minJWTSecretLength is the minimum HS256 secret length, the size of the
+	// SHA-256 output.
+	minJWTSecretLength = 32
 )
 
 // CORSConfig holds CORS configuration
@@ -40,10 +67,16 @@ type Config struct {
 	Database DatabaseConfig
 	Server   ServerConfig
 	// Then string and simple field structs
-	App        AppConfig
-	AzureTable AzureTableConfig
-	CORS       CORSConfig
-	Logging    LogConfig
+	App           AppConfig
+	Auth          AuthConfig
+	AzureTable    AzureTableConfig
+	CORS          CORSConfig
+	Idempotency   IdempotencyConfig
+	Logging       LogConfig
+	Outbox        OutboxConfig
+	Preconditions PreconditionConfig
+	SoftDelete    SoftDeleteConfig
+	WebSocket     WebSocketConfig
 }
 
 // AppConfig holds application-wide configuration
@@ -104,6 +137,101 @@ type ServerConfig struct {
 	Port string
 }
 
+// SoftDeleteConfig holds soft-delete retention configuration
+type SoftDeleteConfig struct {
+	// Retention is the minimum age of a soft-deleted record before it may be purged.
+	Retention time.Duration
+}
+
+// IdempotencyConfig holds Idempotency-Key configuration
+type IdempotencyConfig struct {
+	// Store is where idempotency keys are kept: "database" (the default; the
+	// configured MySQL or Azure Table backend, shared by every instance) or
+	// "memory" (per instance, lost on restart).
+	Store string
+	// TTL is how long a response is replayed to retries of its request.
+	// 0 disables Idempotency-Key handling.
+	TTL time.Duration
+}
+
+// OutboxConfig holds configuration of the outbox of change events
+type OutboxConfig struct {
+	// Enabled records change events in the database, in the same
+	// transaction as the changes, and delivers them to WebSocket clients
+	// from there, so that none is lost. Otherwise events are published
+	// directly, and dropped if the hub is overloaded or the process exits.
+	Enabled bool
+	// PollInterval is how often the outbox is checked for events not yet
+	// delivered, such as those recorded before a restart.
+	PollInterval time.Duration
+	// Retention is how long delivered events are kept. 0 keeps them forever.
+	Retention time.Duration
+}
+
+// WebSocketConfig holds WebSocket hub configuration
+type WebSocketConfig struct {
+	// Backplane relays WebSocket events between instances: "none" (the
+	// default; clients only get the events of the instance they are
+	// connected to) or "redis".
+	Backplane string
+	// RedisURL locates the Redis server of the "redis" backplane, and
+	// BackplaneChannel is the pub/sub channel every instance shares.
+	RedisURL         string
+	BackplaneChannel string
+	// HistorySize is how many of the latest events are buffered for clients
+	// that reconnect with the seq of the last event they received. 0 makes
+	// them all reload their state instead.
+	HistorySize int32
+	// MaxMessageSize is the largest message, in bytes, a client may send,
+	// such as a call with an item. Clients sending more are disconnected. 0
+	// removes the limit.
+	MaxMessageSize int32
+	// CallRateLimit is how many calls a client may make per minute over its
+	// connection; more fail with 429. 0 removes the limit.
+	CallRateLimit int32
+	// Backpressure is what happens to clients that do not read their events
+	// as fast as they are published, unless they choose otherwise:
+	// "disconnect" (the default), "drop-oldest" or "coalesce".
+	Backpressure string
+	// Compression negotiates permessage-deflate with clients that offer it.
+	Compression bool
+}
+
+// AuthConfig holds JWT authentication configuration
+type AuthConfig struct {
+	// Enabled requires a bearer token on /api/v1 routes other than ping and
+	// the login and refresh endpoints.
+	Enabled bool
+	// Algorithm is the token signing algorithm: "HS256" or "RS256".
+	Algorithm string
+	// Secret is the HS256 key.
+	Secret string
+	// PrivateKeyFile is a PEM-encoded RSA private key used to sign RS256
+	// tokens, and KeyID the "kid" header of the tokens it signs.
+	PrivateKeyFile string
+	KeyID          string
+	// JWKSFile is a local JSON Web Key Set of additional keys accepted when
+	// verifying tokens, e.g. the public keys of another issuer.
+	JWKSFile string
+	// Issuer and Audience are set on issued tokens and required of verified ones.
+	Issuer   string
+	Audience string
+	// AccessTokenTTL and RefreshTokenTTL are the lifetimes of issued tokens.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// BootstrapAdmins is a comma-separated list of usernames whose tokens
+	// always carry the admin role, so that roles can be assigned before any
+	// user has been made an admin.
+	BootstrapAdmins string
+}
+
+// PreconditionConfig holds conditional request configuration
+type PreconditionConfig struct {
+	// RequireIfMatch rejects item writes without an If-Match header with
+	// 428 Precondition Required, so clients cannot skip optimistic locking.
+	RequireIfMatch bool
+}
+
 // LogConfig holds logging configuration
 type LogConfig struct {
 	Level string
@@ -130,6 +258,28 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("server config: %w", err)
 	}
 
+	if err := c.SoftDelete.Validate(); err != nil {
+		return fmt.Errorf("soft delete config: %w", err)
+	}
+
+	if err := c.Idempotency.Validate(); err != nil {
+		return fmt.Errorf("idempotency config: %w", err)
+	}
+
+	if err := c.Outbox.Validate(); err != nil {
+		return fmt.Errorf("outbox config: %w", err)
+	}
+
+	if err := c.WebSocket.Validate(); err != nil {
+		return fmt.Errorf("websocket config: %w", err)
+	}
+
+	if c.Auth.Enabled {
+		if err := c.Auth.Validate(); err != nil {
+			return fmt.Errorf("auth config: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -219,6 +369,108 @@ func (c *ServerConfig) Validate() error {
 	return nil
 }
 
+func (c *SoftDeleteConfig) Validate() error {
+	if c.Retention < 0 {
+		return errors.New("retention must be non-negative")
+	}
+
+	return nil
+}
+
+func (c *IdempotencyConfig) Validate() error {
+	switch c.Store {
+	case "", "database", "memory":
+	default:
+		return errors.New(`store must be "database" or "memory"`)
+	}
+
+	// A TTL of 0 is valid — it disables Idempotency-Key handling.
+	if c.TTL < 0 {
+		return errors.New("ttl must be non-negative (0 to disable)")
+	}
+
+	return nil
+}
+
+func (c *OutboxConfig) Validate() error {
+	if c.Enabled && c.PollInterval <= 0 {
+		return errors.New("poll interval must be positive")
+	}
+
+	// A retention of 0 is valid — it keeps delivered events forever.
+	if c.Retention < 0 {
+		return errors.New("retention must be non-negative (0 to keep events)")
+	}
+
+	return nil
+}
+
+func (c *WebSocketConfig) Validate() error {
+	if c.HistorySize < 0 {
+		return errors.New("history size must be non-negative (0 to disable)")
+	}
+
+	if c.MaxMessageSize < 0 {
+		return errors.New("max message size must be non-negative (0 for no limit)")
+	}
+
+	if c.CallRateLimit < 0 {
+		return errors.New("call rate limit must be non-negative (0 for no limit)")
+	}
+
+	switch c.Backpressure {
+	case "", "disconnect", "drop-oldest", "coalesce":
+	default:
+		return errors.New(`backpressure must be "disconnect", "drop-oldest" or "coalesce"`)
+	}
+
+	switch c.Backplane {
+	case "", "none":
+		return nil
+	case "redis":
+	default:
+		return errors.New(`backplane must be "none" or "redis"`)
+	}
+
+	if c.RedisURL == "" {
+		return errors.New("redis url is required for the redis backplane")
+	}
+
+	if c.BackplaneChannel == "" {
+		return errors.New("backplane channel is required")
+	}
+
+	return nil
+}
+
+func (c *AuthConfig) Validate() error {
+	switch c.Algorithm {
+	case "HS256":
+		if c.Secret == "" && c.JWKSFile == "" {
+			return errors.New("secret or JWKS file is required for HS256")
+		}
+		if c.Secret != "" && len(c.Secret) < minJWTSecretLength {
+			return fmt.Errorf("secret must be at least %d bytes", minJWTSecretLength)
+		}
+	case "RS256":
+		if c.PrivateKeyFile == "" && c.JWKSFile == "" {
+			return errors.New("private key file or JWKS file is required for RS256")
+		}
+	default:
+		return errors.New(`algorithm must be "HS256" or "RS256"`)
+	}
+
+	if c.AccessTokenTTL <= 0 {
+		return errors.New("access token ttl must be positive")
+	}
+
+	if c.RefreshTokenTTL <= 0 {
+		return errors.New("refresh token ttl must be positive")
+	}
+
+	return nil
+}
+
 // DSN returns the database connection string
 func (c *DatabaseConfig) DSN() string {
 	// Use a builder for better performance and readability
@@ -300,6 +552,44 @@ func LoadConfig() (*Config, error) {
 			Level: getEnv("LOG_LEVEL", "info"),
 			File:  getEnv("LOG_FILE", ""),
 		},
+		Idempotency: IdempotencyConfig{
+			Store: getEnv("IDEMPOTENCY_STORE", "database"),
+			TTL:   getEnvDuration("IDEMPOTENCY_TTL", defaultIdempotencyTTL),
+		},
+		Auth: AuthConfig{
+			Enabled:         getEnvBool("AUTH_ENABLED", false),
+			Algorithm:       getEnv("AUTH_JWT_ALGORITHM", "HS256"),
+			Secret:          getEnv("AUTH_JWT_SECRET", ""),
+			PrivateKeyFile:  getEnv("AUTH_JWT_PRIVATE_KEY_FILE", ""),
+			KeyID:           getEnv("AUTH_JWT_KEY_ID", ""),
+			JWKSFile:        getEnv("AUTH_JWKS_FILE", ""),
+			Issuer:          getEnv("AUTH_JWT_ISSUER", "backend-api"),
+			Audience:        getEnv("AUTH_JWT_AUDIENCE", ""),
+			AccessTokenTTL:  getEnvDuration("AUTH_ACCESS_TOKEN_TTL", defaultAccessTokenTTL),
+			RefreshTokenTTL: getEnvDuration("AUTH_REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+			BootstrapAdmins: getEnv("AUTH_BOOTSTRAP_ADMINS", ""),
+		},
+		Outbox: OutboxConfig{
+			Enabled:      getEnvBool("OUTBOX_ENABLED", true),
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", defaultOutboxPollInterval),
+			Retention:    getEnvDuration("OUTBOX_RETENTION", defaultOutboxRetention),
+		},
+		Preconditions: PreconditionConfig{
+			RequireIfMatch: getEnvBool("REQUIRE_IF_MATCH", false),
+		},
+		SoftDelete: SoftDeleteConfig{
+			Retention: getEnvDuration("SOFT_DELETE_RETENTION", defaultSoftDeleteRetention),
+		},
+		WebSocket: WebSocketConfig{
+			Backplane:        getEnv("WEBSOCKET_BACKPLANE", "none"),
+			RedisURL:         getEnv("WEBSOCKET_REDIS_URL", "redis://localhost:6379/0"),
+			BackplaneChannel: getEnv("WEBSOCKET_BACKPLANE_CHANNEL", "websocket"),
+			HistorySize:      getEnvInt32("WEBSOCKET_HISTORY_SIZE", defaultWebSocketHistorySize),
+			MaxMessageSize:   getEnvInt32("WEBSOCKET_MAX_MESSAGE_SIZE", defaultWebSocketMaxMessageSize),
+			CallRateLimit:    getEnvInt32("WEBSOCKET_CALL_RATE_LIMIT", defaultWebSocketCallRateLimit),
+			Backpressure:     getEnv("WEBSOCKET_BACKPRESSURE", "disconnect"),
+			Compression:      getEnvBool("WEBSOCKET_COMPRESSION", true),
+		},
 	}
 
 	// Validate the configuration
@@ -18,24 +18,44 @@ func TestLoadConfig(t *testing.T) {
 	t.Run("With environment variables", func(t *testing.T) {
 		// Set test environment variables
 		envVars := map[string]string{
-			"APP_NAME":                 "testapp",
-			"GO_ENV":                   "testing",
-			"APP_DEBUG":                "true",
-			"DB_HOST":                  "testhost",
-			"DB_PORT":                  "3306",
-			"DB_USER":                  "testuser",
-			"DB_PASSWORD":              "testpass",
-			"DB_NAME":                  "testdb",
-			"SERVER_HOST":              "localhost",
-			"SERVER_PORT":              "3000",
-			"LOG_LEVEL":                "debug",
-			"LOG_FILE":                 "test.log",
-			"USE_AZURE_TABLE":          "true",
-			"USE_AZURITE":              "true",
-			"AZURE_TABLE_ACCOUNT_NAME": "devstoreaccount1",
-			"AZURE_TABLE_ACCOUNT_KEY":  "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==",
-			"AZURE_TABLE_ENDPOINT":     "127.0.0.1:10002",
-			"AZURE_TABLE_NAME":         "testitems",
+			"APP_NAME":                   "testapp",
+			"GO_ENV":                     "testing",
+			"APP_DEBUG":                  "true",
+			"DB_HOST":                    "testhost",
+			"DB_PORT":                    "3306",
+			"DB_USER":                    "testuser",
+			"DB_PASSWORD":                "testpass",
+			"DB_NAME":                    "testdb",
+			"SERVER_HOST":                "localhost",
+			"SERVER_PORT":                "3000",
+			"LOG_LEVEL":                  "debug",
+			"LOG_FILE":                   "test.log",
+			"USE_AZURE_TABLE":            "true",
+			"USE_AZURITE":                "true",
+			"AZURE_TABLE_ACCOUNT_NAME":   "devstoreaccount1",
+			"AZURE_TABLE_ACCOUNT_KEY":    "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==",
+			"AZURE_TABLE_ENDPOINT":       "127.0.0.1:10002",
+			"AZURE_TABLE_NAME":           "testitems",
+			"SOFT_DELETE_RETENTION":      "48h",
+			"REQUIRE_IF_MATCH":           "true",
+			"IDEMPOTENCY_STORE":          "memory",
+			"IDEMPOTENCY_TTL":            "1h",
+			"OUTBOX_ENABLED":             "false",
+			"OUTBOX_POLL_INTERVAL":       "5s",
+			"OUTBOX_RETENTION":           "0s",
+			"AUTH_ENABLED":               "true",
+			"AUTH_JWT_ALGORITHM":         "HS256",
+			"AUTH_JWT_SECRET":            "0123456789abcdef0123456789abcdef",
+			"AUTH_JWT_AUDIENCE":          "testapp",
+			"AUTH_ACCESS_TOKEN_TTL":      "5m",
+			"AUTH_BOOTSTRAP_ADMINS":      "root",
+			"WEBSOCKET_BACKPLANE":        "redis",
+			"WEBSOCKET_REDIS_URL":        "redis://cache:6379/1",
+			"WEBSOCKET_HISTORY_SIZE":     "0",
+			"WEBSOCKET_MAX_MESSAGE_SIZE": "1024",
+			"WEBSOCKET_CALL_RATE_LIMIT":  "10",
+			"WEBSOCKET_BACKPRESSURE":     "coalesce",
+			"WEBSOCKET_COMPRESSION":      "false",
 		}
 
 		// Set environment variables
@@ -80,6 +100,41 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==", config.AzureTable.AccountKey)
 		assert.Equal(t, "127.0.0.1:10002", config.AzureTable.Endpoint)
 		assert.Equal(t, "testitems", config.AzureTable.TableName)
+
+		// Check soft delete config
+		assert.Equal(t, 48*time.Hour, config.SoftDelete.Retention)
+
+		// Check precondition config
+		assert.True(t, config.Preconditions.RequireIfMatch)
+
+		// Check idempotency config
+		assert.Equal(t, "memory", config.Idempotency.Store)
+		assert.Equal(t, time.Hour, config.Idempotency.TTL)
+
+		// Check outbox config
+		assert.False(t, config.Outbox.Enabled)
+		assert.Equal(t, 5*time.Second, config.Outbox.PollInterval)
+		assert.Zero(t, config.Outbox.Retention)
+
+		// Check auth config
+		assert.True(t, config.Auth.Enabled)
+		assert.Equal(t, "HS256", config.Auth.Algorithm)
+		assert.Equal(t, "0123456789abcdef0123456789abcdef", config.Auth.Secret)
+		assert.Equal(t, "backend-api", config.Auth.Issuer)
+		assert.Equal(t, "testapp", config.Auth.Audience)
+		assert.Equal(t, 5*time.Minute, config.Auth.AccessTokenTTL)
+		assert.Equal(t, 7*24*time.Hour, config.Auth.RefreshTokenTTL)
+		assert.Equal(t, "root", config.Auth.BootstrapAdmins)
+
+		// Check websocket config
+		assert.Equal(t, "redis", config.WebSocket.Backplane)
+		assert.Equal(t, "redis://cache:6379/1", config.WebSocket.RedisURL)
+		assert.Equal(t, "websocket", config.WebSocket.BackplaneChannel)
+		assert.Equal(t, int32(0), config.WebSocket.HistorySize)
+		assert.Equal(t, int32(1024), config.WebSocket.MaxMessageSize)
+		assert.Equal(t, int32(10), config.WebSocket.CallRateLimit)
+		assert.Equal(t, "coalesce", config.WebSocket.Backpressure)
+		assert.False(t, config.WebSocket.Compression)
 	})
 
 	// Test with default values
@@ -94,6 +149,13 @@ func TestLoadConfig(t *testing.T) {
 			"USE_AZURE_TABLE", "USE_AZURITE",
 			"AZURE_TABLE_ACCOUNT_NAME", "AZURE_TABLE_ACCOUNT_KEY",
 			"AZURE_TABLE_ENDPOINT", "AZURE_TABLE_NAME",
+			"SOFT_DELETE_RETENTION", "REQUIRE_IF_MATCH",
+			"IDEMPOTENCY_STORE", "IDEMPOTENCY_TTL",
+			"OUTBOX_ENABLED", "OUTBOX_POLL_INTERVAL", "OUTBOX_RETENTION",
+			"AUTH_ENABLED", "AUTH_JWT_ALGORITHM", "AUTH_JWT_SECRET", "AUTH_JWT_ISSUER", "AUTH_JWT_AUDIENCE",
+			"AUTH_ACCESS_TOKEN_TTL", "AUTH_REFRESH_TOKEN_TTL", "AUTH_BOOTSTRAP_ADMINS",
+			"WEBSOCKET_BACKPLANE", "WEBSOCKET_REDIS_URL", "WEBSOCKET_BACKPLANE_CHANNEL", "WEBSOCKET_HISTORY_SIZE",
+			"WEBSOCKET_MAX_MESSAGE_SIZE", "WEBSOCKET_CALL_RATE_LIMIT", "WEBSOCKET_BACKPRESSURE", "WEBSOCKET_COMPRESSION",
 		}
 		for _, v := range vars {
 			os.Unsetenv(v)
@@ -135,6 +197,34 @@ func TestLoadConfig(t *testing.T) {
 		assert.Empty(t, config.AzureTable.AccountKey)
 		assert.Empty(t, config.AzureTable.Endpoint)
 		assert.Equal(t, "items", config.AzureTable.TableName)
+
+		// Check default soft delete config
+		assert.Equal(t, 30*24*time.Hour, config.SoftDelete.Retention)
+
+		// Check default precondition config
+		assert.False(t, config.Preconditions.RequireIfMatch)
+
+		// Check default idempotency config
+		assert.Equal(t, "database", config.Idempotency.Store)
+		assert.Equal(t, 24*time.Hour, config.Idempotency.TTL)
+
+		// Check default outbox config
+		assert.True(t, config.Outbox.Enabled)
+		assert.Equal(t, time.Second, config.Outbox.PollInterval)
+		assert.Equal(t, 24*time.Hour, config.Outbox.Retention)
+
+		// Check default auth config
+		assert.False(t, config.Auth.Enabled)
+		assert.Equal(t, "HS256", config.Auth.Algorithm)
+		assert.Equal(t, 15*time.Minute, config.Auth.AccessTokenTTL)
+
+		// Check default websocket config
+		assert.Equal(t, "none", config.WebSocket.Backplane)
+		assert.Equal(t, int32(1024), config.WebSocket.HistorySize)
+		assert.Equal(t, int32(65536), config.WebSocket.MaxMessageSize)
+		assert.Equal(t, int32(100), config.WebSocket.CallRateLimit)
+		assert.Equal(t, "disconnect", config.WebSocket.Backpressure)
+		assert.True(t, config.WebSocket.Compression)
 	})
 }
 
@@ -281,6 +371,171 @@ func TestConfigValidate(t *testing.T) {
 		assert.Contains(t, err.Error(), "server config")
 	})
 
+	t.Run("negative soft delete retention", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.Config{
+			App: config.AppConfig{
+				Name:        "myapp",
+				Environment: "production",
+			},
+			Database: config.DatabaseConfig{
+				Host:            "localhost",
+				Port:            "3306",
+				User:            "user",
+				DBName:          "dbname",
+				MaxOpenConns:    10,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: 1 * time.Minute,
+			},
+			Server: config.ServerConfig{
+				Port:        "8080",
+				ReadTimeout: 5 * time.Second,
+				IdleTimeout: 30 * time.Second,
+			},
+			SoftDelete: config.SoftDeleteConfig{Retention: -time.Hour},
+		}
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "soft delete config")
+	})
+
+	t.Run("unknown idempotency store", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.Config{
+			App: config.AppConfig{
+				Name:        "myapp",
+				Environment: "production",
+			},
+			Database: config.DatabaseConfig{
+				Host:            "localhost",
+				Port:            "3306",
+				User:            "user",
+				DBName:          "dbname",
+				MaxOpenConns:    10,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: 1 * time.Minute,
+			},
+			Server: config.ServerConfig{
+				Port:        "8080",
+				ReadTimeout: 5 * time.Second,
+				IdleTimeout: 30 * time.Second,
+			},
+			Idempotency: config.IdempotencyConfig{Store: "redis", TTL: time.Hour},
+		}
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "idempotency config")
+	})
+
+	t.Run("invalid outbox config", func(t *testing.T) {
+		t.Parallel()
+		outboxes := map[string]config.OutboxConfig{
+			"no poll interval":   {Enabled: true, Retention: time.Hour},
+			"negative retention": {Enabled: true, PollInterval: time.Second, Retention: -time.Hour},
+		}
+		for name, outbox := range outboxes {
+			cfg := &config.Config{
+				App: config.AppConfig{
+					Name:        "myapp",
+					Environment: "production",
+				},
+				Database: config.DatabaseConfig{
+					Host:            "localhost",
+					Port:            "3306",
+					User:            "user",
+					DBName:          "dbname",
+					MaxOpenConns:    10,
+					MaxIdleConns:    5,
+					ConnMaxLifetime: 1 * time.Minute,
+				},
+				Server: config.ServerConfig{
+					Port:        "8080",
+					ReadTimeout: 5 * time.Second,
+					IdleTimeout: 30 * time.Second,
+				},
+				Outbox: outbox,
+			}
+			err := cfg.Validate()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "outbox config", name)
+		}
+	})
+
+	t.Run("invalid websocket config", func(t *testing.T) {
+		t.Parallel()
+		websockets := map[string]config.WebSocketConfig{
+			"unknown backplane":  {Backplane: "nats", RedisURL: "redis://localhost:6379", BackplaneChannel: "websocket"},
+			"no redis url":       {Backplane: "redis", BackplaneChannel: "websocket"},
+			"no channel":         {Backplane: "redis", RedisURL: "redis://localhost:6379"},
+			"negative history":   {Backplane: "none", HistorySize: -1},
+			"negative max size":  {Backplane: "none", MaxMessageSize: -1},
+			"negative call rate": {Backplane: "none", CallRateLimit: -1},
+			"unknown policy":     {Backplane: "none", Backpressure: "block"},
+		}
+		for name, ws := range websockets {
+			cfg := &config.Config{
+				App: config.AppConfig{
+					Name:        "myapp",
+					Environment: "production",
+				},
+				Database: config.DatabaseConfig{
+					Host:            "localhost",
+					Port:            "3306",
+					User:            "user",
+					DBName:          "dbname",
+					MaxOpenConns:    10,
+					MaxIdleConns:    5,
+					ConnMaxLifetime: 1 * time.Minute,
+				},
+				Server: config.ServerConfig{
+					Port:        "8080",
+					ReadTimeout: 5 * time.Second,
+					IdleTimeout: 30 * time.Second,
+				},
+				WebSocket: ws,
+			}
+			err := cfg.Validate()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "websocket config", name)
+		}
+	})
+
+	t.Run("invalid auth config", func(t *testing.T) {
+		t.Parallel()
+		auths := map[string]config.AuthConfig{
+			"unknown algorithm": {Enabled: true, Algorithm: "none", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour},
+			"short secret":      {Enabled: true, Algorithm: "HS256", Secret: "short", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour},
+			"no RS256 key":      {Enabled: true, Algorithm: "RS256", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour},
+			"no access ttl":     {Enabled: true, Algorithm: "RS256", JWKSFile: "jwks.json", RefreshTokenTTL: time.Hour},
+		}
+		for name, auth := range auths {
+			cfg := &config.Config{
+				App: config.AppConfig{
+					Name:        "myapp",
+					Environment: "production",
+				},
+				Database: config.DatabaseConfig{
+					Host:            "localhost",
+					Port:            "3306",
+					User:            "user",
+					DBName:          "dbname",
+					MaxOpenConns:    10,
+					MaxIdleConns:    5,
+					ConnMaxLifetime: 1 * time.Minute,
+				},
+				Server: config.ServerConfig{
+					Port:        "8080",
+					ReadTimeout: 5 * time.Second,
+					IdleTimeout: 30 * time.Second,
+				},
+				Auth: auth,
+			}
+			err := cfg.Validate()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "auth config", name)
+		}
+	})
+
 	t.Run("zero WriteTimeout passes validation", func(t *testing.T) {
 		t.Parallel()
 		cfg := &config.Config{
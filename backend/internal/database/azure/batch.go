@@ -0,0 +1,244 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"backend/internal/models"
+	"backend/pkg/dberrors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
+)
+
+// maxTransactionActions is the most entities Table Storage accepts in one
+// entity-group transaction.
+const maxTransactionActions = 100
+
+// pendingWrite is an entity write that has been validated and encoded but not
+// yet sent, so the same preparation serves single writes and transactions.
+type pendingWrite struct {
+	codec  *modelCodec
+	value  reflect.Value
+	rowKey string
+	action aztables.TransactionAction
+
+	// undo reverts the in-memory changes made while preparing, if the write
+	// is not applied. onApplied records changes only known once it is.
+	undo      func()
+	onApplied func()
+}
+
+func (w *pendingWrite) partitionKey() string {
+	return w.codec.info.Name
+}
+
+func (w *pendingWrite) rollback() {
+	if w.undo != nil {
+		w.undo()
+	}
+}
+
+func (w *pendingWrite) applied() {
+	if w.onApplied != nil {
+		w.onApplied()
+	}
+}
+
+// encodeEntity serialises v as the JSON body of a Table Storage entity. When
+// columns is not nil only their properties, the keys and the properties the
+// repository maintains (UpdatedAt and Version) are included, so that a merge
+// leaves the other stored properties untouched.
+func encodeEntity(codec *modelCodec, v reflect.Value, id uint, columns []string) ([]byte, error) {
+	props, err := codec.encode(v, id)
+	if err != nil {
+		return nil, dberrors.NewDatabaseError("marshal", err)
+	}
+	if columns != nil {
+		keep := []string{"PartitionKey", "RowKey", "UpdatedAt", "Version"}
+		for _, c := range columns {
+			if f, ok := codec.byColumn[c]; ok {
+				keep = append(keep, f.property, f.property+"@odata.type")
+			}
+		}
+		subset := make(map[string]interface{}, len(keep))
+		for _, k := range keep {
+			if value, ok := props[k]; ok {
+				subset[k] = value
+			}
+		}
+		props = subset
+	}
+	entityBytes, err := json.Marshal(props)
+	if err != nil {
+		return nil, dberrors.NewDatabaseError("marshal", err)
+	}
+	return entityBytes, nil
+}
+
+// Batch implements models.Batcher. Every op is prepared first — validated,
+// version- and uniqueness-checked and encoded — so an invalid op fails the
+// batch before anything is written. The writes are then submitted as
+// entity-group transactions, which Table Storage limits to one partition and
+// 100 entities. Each transaction is atomic but a batch spanning several is
+// not: when a later transaction fails, the *models.BatchError lists the ops
+// already committed in Applied. Within WithEvents, the batch must be in one
+// partition and its last transaction is submitted by WithEvents with the
+// events, so that they are recorded only if the whole batch is committed.
+func (r *TableRepository) Batch(ctx context.Context, ops []models.BatchOp) error {
+	writes := make([]*pendingWrite, 0, len(ops))
+	fail := func(index int, applied []int, err error) error {
+		done := make(map[int]bool, len(applied))
+		for _, i := range applied {
+			done[i] = true
+		}
+		for i := len(writes) - 1; i >= 0; i-- {
+			if !done[i] {
+				writes[i].rollback()
+			}
+		}
+		return &models.BatchError{Index: index, Applied: applied, Err: err}
+	}
+
+	// A transaction may touch each entity once, and unique columns must
+	// also be unique among the entities written by the batch itself.
+	rows := make(map[string]bool, len(ops))
+	uniqueValues := make(map[string]string)
+	for i, op := range ops {
+		var (
+			w   *pendingWrite
+			err error
+		)
+		switch op.Action {
+		case models.BatchCreate:
+			w, err = r.prepareCreate(ctx, op.Entity)
+		case models.BatchUpdate:
+			w, err = r.prepareUpdate(ctx, op.Entity, nil)
+		case models.BatchDelete:
+			w, err = r.prepareDelete(ctx, op.Entity)
+		default:
+			err = dberrors.NewDatabaseError("batch",
+				fmt.Errorf("%w: unknown batch action %q", dberrors.ErrValidation, op.Action))
+		}
+		if err != nil {
+			return fail(i, nil, err)
+		}
+		writes = append(writes, w)
+
+		row := w.partitionKey() + "/" + w.rowKey
+		if rows[row] {
+			return fail(i, nil, dberrors.NewDatabaseError("batch",
+				fmt.Errorf("%w: entity %s appears more than once", dberrors.ErrValidation, w.rowKey)))
+		}
+		rows[row] = true
+		if op.Action == models.BatchDelete {
+			continue
+		}
+		for _, f := range w.codec.uniqueFields() {
+			key := fmt.Sprintf("%s/%s/%v", w.partitionKey(), f.property, w.value.FieldByIndex(f.index).Interface())
+			if owner, ok := uniqueValues[key]; ok && owner != w.rowKey {
+				return fail(i, nil, dberrors.NewDatabaseError("batch", dberrors.ErrDuplicateKey))
+			}
+			uniqueValues[key] = w.rowKey
+		}
+	}
+
+	// Group the writes by partition, keeping their relative order.
+	var partitions []string
+	groups := make(map[string][]int)
+	for i, w := range writes {
+		pk := w.partitionKey()
+		if _, ok := groups[pk]; !ok {
+			partitions = append(partitions, pk)
+		}
+		groups[pk] = append(groups[pk], i)
+	}
+	var chunks [][]int
+	for _, pk := range partitions {
+		chunks = append(chunks, chunkIndexes(groups[pk], maxTransactionActions)...)
+	}
+
+	// Within WithEvents, the last transaction is left for it to submit with
+	// the events, so it must have room for them: the batch is rejected before
+	// anything is written when it spans several partitions or when the writes
+	// already made with tx leave no room.
+	tx, withEvents := r.client.(*txClient)
+	if withEvents && len(writes) > 0 {
+		room := maxTransactionActions - len(tx.actions) - 1
+		switch {
+		case len(partitions) > 1:
+			return fail(-1, nil, dberrors.NewDatabaseError("batch",
+				fmt.Errorf("%w: a batch with events spans several partitions", dberrors.ErrValidation)))
+		case room < 1:
+			return fail(-1, nil, dberrors.NewDatabaseError("batch",
+				fmt.Errorf("%w: the batch and its events do not fit in one transaction", dberrors.ErrValidation)))
+		}
+		indexes := groups[partitions[0]]
+		last := indexes[max(len(indexes)-room, 0):]
+		chunks = append(chunkIndexes(indexes[:len(indexes)-len(last)], maxTransactionActions), last)
+	}
+
+	var applied []int
+	for n, chunk := range chunks {
+		actions := make([]aztables.TransactionAction, len(chunk))
+		for j, i := range chunk {
+			actions[j] = writes[i].action
+		}
+		// The service names the failed action only in the error message, so
+		// it is only known for single-action transactions.
+		index := -1
+		if len(chunk) == 1 {
+			index = chunk[0]
+		}
+		if withEvents && n == len(chunks)-1 {
+			tx.actions = append(tx.actions, actions...)
+			tx.settle = func(err error) error {
+				if err != nil {
+					return fail(index, applied, err)
+				}
+				for _, i := range chunk {
+					writes[i].applied()
+				}
+				return nil
+			}
+			return nil
+		}
+		if _, err := r.client.SubmitTransaction(ctx, actions, nil); err != nil {
+			return fail(index, applied, transactionError("batch", err))
+		}
+		for _, i := range chunk {
+			writes[i].applied()
+		}
+		applied = append(applied, chunk...)
+	}
+	return nil
+}
+
+// chunkIndexes splits indexes into chunks of at most size, in order.
+func chunkIndexes(indexes []int, size int) [][]int {
+	var chunks [][]int
+	for start := 0; start < len(indexes); start += size {
+		chunks = append(chunks, indexes[start:min(start+size, len(indexes))])
+	}
+	return chunks
+}
+
+// transactionError maps the error of a rejected entity-group transaction to
+// the errors the single-entity operations return, with op as the context.
+func transactionError(op string, err error) error {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		switch {
+		case respErr.ErrorCode == "EntityAlreadyExists":
+			return dberrors.NewDatabaseError(op, dberrors.ErrDuplicateKey)
+		case respErr.StatusCode == 412 || respErr.ErrorCode == "UpdateConditionNotSatisfied":
+			return dberrors.NewDatabaseError(op, errors.New("version mismatch"))
+		case respErr.StatusCode == 404 || respErr.ErrorCode == "ResourceNotFound":
+			return dberrors.NewDatabaseError(op, dberrors.ErrNotFound)
+		}
+	}
+	return dberrors.NewDatabaseError(op, err)
+}
@@ -0,0 +1,301 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// fieldCodec describes how one struct field is stored as a Table Storage property.
+type fieldCodec struct {
+	// index is the path passed to reflect.Value.FieldByIndex (embedded structs
+	// such as models.Base are flattened).
+	index []int
+
+	// property is the Table Storage property name: the Go field name, which
+	// matches the PascalCase layout used before entities were mapped generically.
+	property string
+
+	// column is the SQL column name (e.g. "created_at"), used to resolve the
+	// field names accepted by models.Filter.
+	column string
+
+	typ    reflect.Type
+	unique bool
+}
+
+// modelCodec maps a registered model to and from Table Storage entities.
+// The primary key is stored in the RowKey and the model's registered name is
+// used as the PartitionKey, so every model can share the same table.
+type modelCodec struct {
+	info     models.ModelInfo
+	fields   []fieldCodec
+	byColumn map[string]*fieldCodec
+}
+
+// codecs caches one modelCodec per model type.
+var codecs sync.Map
+
+// codecForType returns the codec for a registered struct type.
+func codecForType(t reflect.Type) (*modelCodec, error) {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*modelCodec), nil
+	}
+
+	info, err := models.LookupModel(reflect.New(t).Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	naming := schema.NamingStrategy{}
+	c := &modelCodec{info: info, byColumn: make(map[string]*fieldCodec)}
+	var walk func(t reflect.Type, prefix []int)
+	walk = func(t reflect.Type, prefix []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			index := append(append([]int{}, prefix...), i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+				walk(f.Type, index)
+				continue
+			}
+			gormTag := f.Tag.Get("gorm")
+			if f.Name == "ID" || gormTag == "-" {
+				continue
+			}
+			c.fields = append(c.fields, fieldCodec{
+				index:    index,
+				property: f.Name,
+				column:   naming.ColumnName("", f.Name),
+				typ:      f.Type,
+				unique:   hasGormOption(gormTag, "unique") || hasGormOption(gormTag, "uniqueIndex"),
+			})
+		}
+	}
+	walk(t, nil)
+
+	for i := range c.fields {
+		c.byColumn[c.fields[i].column] = &c.fields[i]
+	}
+
+	actual, _ := codecs.LoadOrStore(t, c)
+	return actual.(*modelCodec), nil
+}
+
+// hasGormOption reports whether a gorm struct tag contains the given option,
+// with or without a value (e.g. "uniqueIndex" or "uniqueIndex:idx_name").
+func hasGormOption(tag, option string) bool {
+	for _, part := range strings.Split(tag, ";") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if strings.EqualFold(name, option) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueFields returns the fields backed by a unique index in SQL.
+func (c *modelCodec) uniqueFields() []*fieldCodec {
+	var result []*fieldCodec
+	for i := range c.fields {
+		if c.fields[i].unique {
+			result = append(result, &c.fields[i])
+		}
+	}
+	return result
+}
+
+// encode converts a model struct value into a Table Storage entity.
+func (c *modelCodec) encode(v reflect.Value, id uint) (map[string]interface{}, error) {
+	entity := map[string]interface{}{
+		"PartitionKey": c.info.Name,
+		"RowKey":       strconv.FormatUint(uint64(id), 10),
+	}
+	for i := range c.fields {
+		f := &c.fields[i]
+		value, ok, err := encodeValue(v.FieldByIndex(f.index))
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", f.property, err)
+		}
+		if ok {
+			entity[f.property] = value
+			if isFloatKind(f.typ) {
+				// Without the annotation Table Storage infers Edm.Int32 for
+				// whole numbers, and OData comparisons with Edm.Double
+				// literals would no longer match them.
+				entity[f.property+"@odata.type"] = "Edm.Double"
+			}
+		}
+	}
+	return entity, nil
+}
+
+// isFloatKind reports whether t (or the type it points to) is a float type.
+func isFloatKind(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// encodeValue converts a field value into a JSON-serialisable property value.
+// The second return value is false when the property should be omitted.
+func encodeValue(fv reflect.Value) (interface{}, bool, error) {
+	switch {
+	case fv.Type() == timeType:
+		return fv.Interface().(time.Time).UTC().Format(time.RFC3339), true, nil
+	case fv.Type() == deletedAtType:
+		// The soft-delete tombstone: present only while the entity is deleted.
+		d := fv.Interface().(gorm.DeletedAt)
+		if !d.Valid {
+			return nil, false, nil
+		}
+		return d.Time.UTC().Format(time.RFC3339), true, nil
+	case fv.Kind() == reflect.Ptr:
+		if fv.IsNil() {
+			return nil, false, nil
+		}
+		return encodeValue(fv.Elem())
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), true, nil
+	case reflect.Bool:
+		return fv.Bool(), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int(), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fv.Uint(), true, nil
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true, nil
+	default:
+		// Slices, maps and nested structs have no native Table Storage type;
+		// store them as JSON strings.
+		b, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil, false, err
+		}
+		return string(b), true, nil
+	}
+}
+
+// decode populates the model struct value v from a Table Storage entity.
+// Missing properties leave the zero value; properties of the wrong type are
+// reported as errors.
+func (c *modelCodec) decode(data map[string]interface{}, v reflect.Value) error {
+	for i := range c.fields {
+		f := &c.fields[i]
+		raw, ok := data[f.property]
+		if !ok || raw == nil {
+			continue
+		}
+		if err := decodeValue(raw, v.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("missing or invalid %s field: %w", f.property, err)
+		}
+	}
+	return nil
+}
+
+// decodeValue assigns a JSON-decoded property value to a struct field.
+func decodeValue(raw interface{}, fv reflect.Value) error {
+	if fv.Kind() == reflect.Ptr {
+		elem := reflect.New(fv.Type().Elem())
+		if err := decodeValue(raw, elem.Elem()); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	if fv.Type() == timeType || fv.Type() == deletedAtType {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected time string, got %T", raw)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		if fv.Type() == deletedAtType {
+			fv.Set(reflect.ValueOf(gorm.DeletedAt{Time: t, Valid: true}))
+		} else {
+			fv.Set(reflect.ValueOf(t))
+		}
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", raw)
+		}
+		fv.SetString(s)
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("expected bool, got %T", raw)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toFloat(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toFloat(raw)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("negative value %v for unsigned field", n)
+		}
+		fv.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, err := toFloat(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected JSON string, got %T", raw)
+		}
+		return json.Unmarshal([]byte(s), fv.Addr().Interface())
+	}
+	return nil
+}
+
+// toFloat converts a JSON number to float64. Table Storage returns Edm.Int64
+// values as strings, so numeric strings are accepted too.
+func toFloat(raw interface{}) (float64, error) {
+	switch n := raw.(type) {
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	default:
+		return 0, fmt.Errorf("expected number, got %T", raw)
+	}
+}
@@ -135,7 +135,7 @@ func TestTableRepository_InvalidData(t *testing.T) {
 		// Test with wrong type
 		err = repo.Create(ctx, "string")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "entity must be *models.Item")
+		assert.Contains(t, err.Error(), "entity must be a pointer to a registered model")
 	})
 
 	t.Run("Invalid inputs for FindByID", func(t *testing.T) {
@@ -148,7 +148,7 @@ func TestTableRepository_InvalidData(t *testing.T) {
 		// Test with wrong type
 		err = repo.FindByID(ctx, 1, "string")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "dest must be *models.Item")
+		assert.Contains(t, err.Error(), "dest must be a pointer to a registered model")
 	})
 
 	t.Run("Invalid inputs for Update", func(t *testing.T) {
@@ -161,7 +161,7 @@ func TestTableRepository_InvalidData(t *testing.T) {
 		// Test with wrong type
 		err = repo.Update(ctx, "string")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "entity must be *models.Item")
+		assert.Contains(t, err.Error(), "entity must be a pointer to a registered model")
 	})
 
 	t.Run("Invalid inputs for Delete", func(t *testing.T) {
@@ -174,7 +174,7 @@ func TestTableRepository_InvalidData(t *testing.T) {
 		// Test with wrong type
 		err = repo.Delete(ctx, "string")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "entity must be *models.Item")
+		assert.Contains(t, err.Error(), "entity must be a pointer to a registered model")
 	})
 
 	t.Run("Invalid inputs for List", func(t *testing.T) {
@@ -187,6 +187,6 @@ func TestTableRepository_InvalidData(t *testing.T) {
 		// Test with wrong type
 		err = repo.List(ctx, "string")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "dest must be *[]models.Item")
+		assert.Contains(t, err.Error(), "dest must be a pointer to a slice of a registered model")
 	})
 }
@@ -0,0 +1,78 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+
+	"backend/internal/models"
+)
+
+// splitConjuncts flattens the top-level ANDs of an expression, so each part
+// can be pushed down to Table Storage or evaluated in memory on its own.
+func splitConjuncts(e models.Expr) []models.Expr {
+	if and, ok := e.(models.And); ok {
+		return append(splitConjuncts(and.Left), splitConjuncts(and.Right)...)
+	}
+	return []models.Expr{e}
+}
+
+// odataExpr translates a bound expression into an OData filter. It reports
+// false when part of the expression has no Table Storage equivalent (isnull,
+// which cannot match a missing property, and the text operators, which the
+// service does not support); such expressions are evaluated in memory instead.
+func odataExpr(codec *modelCodec, e models.Expr) (string, bool, error) {
+	switch e := e.(type) {
+	case models.And:
+		return odataJoin(codec, e.Left, "and", e.Right)
+	case models.Or:
+		return odataJoin(codec, e.Left, "or", e.Right)
+	case models.Not:
+		inner, ok, err := odataExpr(codec, e.Expr)
+		if !ok || err != nil {
+			return "", ok, err
+		}
+		return "not (" + inner + ")", true, nil
+	case models.Comparison:
+		field, ok := codec.byColumn[e.Field]
+		if !ok {
+			return "", false, fmt.Errorf("invalid filter field: %q", e.Field)
+		}
+		literals := make([]string, len(e.Values))
+		for i, v := range e.Values {
+			literal, err := odataLiteral(field, v)
+			if err != nil {
+				return "", false, err
+			}
+			literals[i] = literal
+		}
+		switch e.Op {
+		case models.OpEq, models.OpNe, models.OpGt, models.OpGe, models.OpLt, models.OpLe:
+			return fmt.Sprintf("%s %s %s", field.property, e.Op, literals[0]), true, nil
+		case models.OpIn:
+			clauses := make([]string, len(literals))
+			for i, literal := range literals {
+				clauses[i] = fmt.Sprintf("%s eq %s", field.property, literal)
+			}
+			return "(" + strings.Join(clauses, " or ") + ")", true, nil
+		case models.OpBetween:
+			return fmt.Sprintf("(%s ge %s and %s le %s)", field.property, literals[0], field.property, literals[1]), true, nil
+		default:
+			return "", false, nil
+		}
+	default:
+		return "", false, fmt.Errorf("unsupported filter expression %T", e)
+	}
+}
+
+// odataJoin translates both operands and joins them with a logical operator.
+func odataJoin(codec *modelCodec, left models.Expr, op string, right models.Expr) (string, bool, error) {
+	l, ok, err := odataExpr(codec, left)
+	if !ok || err != nil {
+		return "", ok, err
+	}
+	r, ok, err := odataExpr(codec, right)
+	if !ok || err != nil {
+		return "", ok, err
+	}
+	return "(" + l + " " + op + " " + r + ")", true, nil
+}
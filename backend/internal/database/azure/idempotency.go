@@ -0,0 +1,290 @@
+package azure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/idempotency"
+	"backend/pkg/dberrors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
+)
+
+const (
+	// idempotencyPartition holds idempotency keys in the table shared with the
+	// models, whose partitions are named after them.
+	idempotencyPartition = "_idempotency"
+
+	// maxBinaryProperty is the size limit of an Edm.Binary property. Response
+	// bodies are split over Body0, Body1, ... properties of at most this size.
+	maxBinaryProperty = 64 * 1024
+
+	// maxBodyChunks keeps an entity under the 1 MiB entity size limit.
+	maxBodyChunks = 15
+
+	// idempotencySweepInterval is how often expired keys are deleted.
+	idempotencySweepInterval = time.Minute
+)
+
+// IdempotencyStore is an idempotency.Store in the repository's table, shared
+// by every server instance using it. Row keys are hashes of the idempotency
+// keys, which may contain characters row keys cannot.
+type IdempotencyStore struct {
+	client AzureTableClient
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewIdempotencyStore creates an IdempotencyStore in repo's table.
+func NewIdempotencyStore(repo *TableRepository) *IdempotencyStore {
+	return &IdempotencyStore{client: repo.client}
+}
+
+// idempotencyEntity is a stored key. Status is 0 while the request that
+// reserved the key is in flight.
+type idempotencyEntity struct {
+	etag        azcore.ETag
+	key         string
+	fingerprint string
+	expiresAt   time.Time
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+func idempotencyRowKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *idempotencyEntity) marshal() ([]byte, error) {
+	props := map[string]interface{}{
+		"PartitionKey": idempotencyPartition,
+		"RowKey":       idempotencyRowKey(e.key),
+		"Key":          e.key,
+		"Fingerprint":  e.fingerprint,
+		"ExpiresAt":    e.expiresAt.UTC().Format(time.RFC3339),
+		"Status":       e.status,
+	}
+	if e.status != 0 {
+		header, err := json.Marshal(e.header)
+		if err != nil {
+			return nil, err
+		}
+		props["Header"] = string(header)
+		chunks := (len(e.body) + maxBinaryProperty - 1) / maxBinaryProperty
+		if chunks > maxBodyChunks {
+			return nil, fmt.Errorf("response body of %d bytes is too large to store", len(e.body))
+		}
+		props["BodyChunks"] = chunks
+		for i := 0; i < chunks; i++ {
+			chunk := e.body[i*maxBinaryProperty : min((i+1)*maxBinaryProperty, len(e.body))]
+			name := fmt.Sprintf("Body%d", i)
+			props[name] = base64.StdEncoding.EncodeToString(chunk)
+			props[name+"@odata.type"] = "Edm.Binary"
+		}
+	}
+	return json.Marshal(props)
+}
+
+func unmarshalIdempotencyEntity(data []byte, etag azcore.ETag) (*idempotencyEntity, error) {
+	var props map[string]interface{}
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, err
+	}
+	e := &idempotencyEntity{etag: etag}
+	e.key, _ = props["Key"].(string)
+	e.fingerprint, _ = props["Fingerprint"].(string)
+	expiresAt, _ := props["ExpiresAt"].(string)
+	var err error
+	if e.expiresAt, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+		return nil, fmt.Errorf("invalid ExpiresAt: %w", err)
+	}
+	if n, err := toFloat(props["Status"]); err == nil {
+		e.status = int(n)
+	}
+	if e.status == 0 {
+		return e, nil
+	}
+	if header, ok := props["Header"].(string); ok && header != "" {
+		if err := json.Unmarshal([]byte(header), &e.header); err != nil {
+			return nil, fmt.Errorf("invalid Header: %w", err)
+		}
+	}
+	chunks, _ := toFloat(props["BodyChunks"])
+	for i := 0; i < int(chunks); i++ {
+		encoded, _ := props[fmt.Sprintf("Body%d", i)].(string)
+		chunk, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Body%d: %w", i, err)
+		}
+		e.body = append(e.body, chunk...)
+	}
+	return e, nil
+}
+
+func (e *idempotencyEntity) record() *idempotency.Record {
+	rec := &idempotency.Record{Key: e.key, Fingerprint: e.fingerprint, ExpiresAt: e.expiresAt}
+	if e.status != 0 {
+		rec.Response = &idempotency.Response{Status: e.status, Header: e.header, Body: e.body}
+	}
+	return rec
+}
+
+// get loads the entity of key, or returns nil if there is none.
+func (s *IdempotencyStore) get(ctx context.Context, op, key string) (*idempotencyEntity, error) {
+	resp, err := s.client.GetEntity(ctx, idempotencyPartition, idempotencyRowKey(key), nil)
+	if IsNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dberrors.NewDatabaseError(op, err)
+	}
+	e, err := unmarshalIdempotencyEntity(resp.Value, resp.ETag)
+	if err != nil {
+		return nil, dberrors.NewDatabaseError("unmarshal", err)
+	}
+	return e, nil
+}
+
+// Reserve implements idempotency.Store. Adding the entity fails if the key
+// exists, which makes the reservation atomic; an expired entity is deleted,
+// conditional on its ETag, and the add retried.
+func (s *IdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*idempotency.Record, bool, error) {
+	now := time.Now()
+	s.sweep(ctx, now)
+
+	entity, err := (&idempotencyEntity{key: key, fingerprint: fingerprint, expiresAt: now.Add(ttl)}).marshal()
+	if err != nil {
+		return nil, false, dberrors.NewDatabaseError("marshal", err)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := s.client.AddEntity(ctx, entity, nil)
+		if err == nil {
+			return nil, true, nil
+		}
+		if !IsEntityExistsError(err) {
+			return nil, false, dberrors.NewDatabaseError("reserve", err)
+		}
+
+		existing, err := s.get(ctx, "reserve", key)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing == nil {
+			continue // released since the add
+		}
+		if now.Before(existing.expiresAt) {
+			return existing.record(), false, nil
+		}
+		if err := s.delete(ctx, key, existing.etag); err != nil {
+			return nil, false, dberrors.NewDatabaseError("reserve", err)
+		}
+	}
+	return nil, false, dberrors.NewDatabaseError("reserve", errors.New("idempotency key is contended"))
+}
+
+// Complete implements idempotency.Store.
+func (s *IdempotencyStore) Complete(ctx context.Context, key, fingerprint string, resp idempotency.Response, ttl time.Duration) error {
+	existing, err := s.get(ctx, "complete", key)
+	if err != nil || existing == nil || existing.fingerprint != fingerprint {
+		return err
+	}
+
+	existing.status = resp.Status
+	existing.header = resp.Header
+	existing.body = resp.Body
+	existing.expiresAt = time.Now().Add(ttl)
+	entity, err := existing.marshal()
+	if err != nil {
+		return dberrors.NewDatabaseError("marshal", err)
+	}
+	_, err = s.client.UpdateEntity(ctx, entity, &aztables.UpdateEntityOptions{
+		IfMatch:    &existing.etag,
+		UpdateMode: aztables.UpdateModeReplace,
+	})
+	if err != nil && !isConditionFailed(err) {
+		return dberrors.NewDatabaseError("complete", err)
+	}
+	return nil
+}
+
+// Release implements idempotency.Store.
+func (s *IdempotencyStore) Release(ctx context.Context, key, fingerprint string) error {
+	existing, err := s.get(ctx, "release", key)
+	if err != nil || existing == nil || existing.fingerprint != fingerprint || existing.status != 0 {
+		return err
+	}
+	if err := s.delete(ctx, key, existing.etag); err != nil {
+		return dberrors.NewDatabaseError("release", err)
+	}
+	return nil
+}
+
+// delete removes the entity of key if it still has etag.
+func (s *IdempotencyStore) delete(ctx context.Context, key string, etag azcore.ETag) error {
+	_, err := s.client.DeleteEntity(ctx, idempotencyPartition, idempotencyRowKey(key),
+		&aztables.DeleteEntityOptions{IfMatch: &etag})
+	if err != nil && !IsNotFoundError(err) && !isConditionFailed(err) {
+		return err
+	}
+	return nil
+}
+
+// sweep deletes expired keys, at most once per idempotencySweepInterval per
+// instance. ExpiresAt is stored in a fixed-width format, so it compares as a
+// string.
+func (s *IdempotencyStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	due := now.Sub(s.lastSweep) >= idempotencySweepInterval
+	if due {
+		s.lastSweep = now
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+
+	filter := fmt.Sprintf("PartitionKey eq '%s' and ExpiresAt le '%s'",
+		idempotencyPartition, now.UTC().Format(time.RFC3339))
+	selected := "RowKey"
+	pager := s.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{Filter: &filter, Select: &selected})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			slog.Warn("Failed to list expired idempotency keys", "error", err)
+			return
+		}
+		for _, raw := range page.Entities {
+			var row struct {
+				RowKey string `json:"RowKey"`
+				ETag   string `json:"odata.etag"`
+			}
+			if err := json.Unmarshal(raw, &row); err != nil || row.ETag == "" {
+				continue
+			}
+			etag := azcore.ETag(row.ETag)
+			_, err := s.client.DeleteEntity(ctx, idempotencyPartition, row.RowKey, &aztables.DeleteEntityOptions{IfMatch: &etag})
+			if err != nil && !IsNotFoundError(err) && !isConditionFailed(err) {
+				slog.Warn("Failed to delete expired idempotency key", "error", err)
+			}
+		}
+	}
+}
+
+// isConditionFailed reports whether err is a failed If-Match condition.
+func isConditionFailed(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusPreconditionFailed
+}
@@ -0,0 +1,170 @@
+package azure_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/internal/database/azure"
+	"backend/internal/idempotency"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTable is an in-memory table honouring the conditions of the entity
+// operations, for stores that depend on them.
+type fakeTable struct {
+	mu       sync.Mutex
+	entities map[string][]byte
+	etags    map[string]azcore.ETag
+	version  int
+}
+
+func newFakeTable() *fakeTable {
+	return &fakeTable{entities: map[string][]byte{}, etags: map[string]azcore.ETag{}}
+}
+
+func (f *fakeTable) keyOf(entity []byte) string {
+	var keys struct{ PartitionKey, RowKey string }
+	_ = json.Unmarshal(entity, &keys)
+	return keys.PartitionKey + "/" + keys.RowKey
+}
+
+func (f *fakeTable) put(key string, entity []byte) {
+	f.version++
+	f.entities[key] = entity
+	f.etags[key] = azcore.ETag(fmt.Sprintf("W/\"%d\"", f.version))
+}
+
+func (f *fakeTable) client() *mockClient {
+	return &mockClient{
+		pager: &testPager{},
+		addEntity: func(ctx context.Context, entity []byte, options *aztables.AddEntityOptions) (aztables.AddEntityResponse, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			key := f.keyOf(entity)
+			if _, ok := f.entities[key]; ok {
+				return aztables.AddEntityResponse{}, &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "EntityAlreadyExists"}
+			}
+			f.put(key, entity)
+			return aztables.AddEntityResponse{}, nil
+		},
+		getEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			key := partitionKey + "/" + rowKey
+			entity, ok := f.entities[key]
+			if !ok {
+				return aztables.GetEntityResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound}
+			}
+			return aztables.GetEntityResponse{Value: entity, ETag: f.etags[key]}, nil
+		},
+		updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			key := f.keyOf(entity)
+			if options.IfMatch != nil && *options.IfMatch != f.etags[key] {
+				return aztables.UpdateEntityResponse{}, &azcore.ResponseError{StatusCode: http.StatusPreconditionFailed}
+			}
+			f.put(key, entity)
+			return aztables.UpdateEntityResponse{}, nil
+		},
+		deleteEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			key := partitionKey + "/" + rowKey
+			if _, ok := f.entities[key]; !ok {
+				return aztables.DeleteEntityResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound}
+			}
+			if options != nil && options.IfMatch != nil && *options.IfMatch != f.etags[key] {
+				return aztables.DeleteEntityResponse{}, &azcore.ResponseError{StatusCode: http.StatusPreconditionFailed}
+			}
+			delete(f.entities, key)
+			return aztables.DeleteEntityResponse{}, nil
+		},
+	}
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	newStore := func() (*azure.IdempotencyStore, *fakeTable) {
+		table := newFakeTable()
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(table.client())
+		return azure.NewIdempotencyStore(repo), table
+	}
+
+	t.Run("replays a completed response", func(t *testing.T) {
+		t.Parallel()
+		store, table := newStore()
+
+		key := "client/key #1?"
+		_, reserved, err := store.Reserve(ctx, key, "fp", time.Minute)
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		existing, reserved, err := store.Reserve(ctx, key, "fp", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+		assert.Nil(t, existing.Response, "the first request is still in flight")
+
+		body := bytes.Repeat([]byte("x"), 150*1024)
+		resp := idempotency.Response{Status: http.StatusCreated, Header: http.Header{"Location": {"/api/v1/items/1"}}, Body: body}
+		require.NoError(t, store.Complete(ctx, key, "fp", resp, time.Hour))
+		for k, entity := range table.entities {
+			assert.NotContains(t, k, "#", "row keys are hashed")
+			assert.Contains(t, string(entity), `"Body2@odata.type":"Edm.Binary"`, "large bodies are split")
+		}
+
+		existing, reserved, err = store.Reserve(ctx, key, "other", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+		assert.Equal(t, key, existing.Key)
+		assert.Equal(t, "fp", existing.Fingerprint)
+		require.NotNil(t, existing.Response)
+		assert.Equal(t, resp, *existing.Response)
+	})
+
+	t.Run("expired and released keys can be reserved again", func(t *testing.T) {
+		t.Parallel()
+		store, _ := newStore()
+
+		_, reserved, err := store.Reserve(ctx, "expired", "fp", -time.Second)
+		require.NoError(t, err)
+		require.True(t, reserved)
+		_, reserved, err = store.Reserve(ctx, "expired", "fp", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+
+		_, _, err = store.Reserve(ctx, "released", "fp", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, store.Release(ctx, "released", "other"))
+		_, reserved, err = store.Reserve(ctx, "released", "fp", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, reserved, "only the reserving request can release")
+		require.NoError(t, store.Release(ctx, "released", "fp"))
+		_, reserved, err = store.Reserve(ctx, "released", "fp", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+	})
+
+	t.Run("rejects bodies over the entity size limit", func(t *testing.T) {
+		t.Parallel()
+		store, _ := newStore()
+
+		_, _, err := store.Reserve(ctx, "k", "fp", time.Minute)
+		require.NoError(t, err)
+		err = store.Complete(ctx, "k", "fp", idempotency.Response{Status: http.StatusOK, Body: make([]byte, 2<<20)}, time.Hour)
+		assert.ErrorContains(t, err, "too large")
+	})
+}
@@ -19,4 +19,5 @@ type AzureTableClient interface {
 	UpdateEntity(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error)
 	DeleteEntity(ctx context.Context, partitionKey, rowKey string, options *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error)
 	NewListEntitiesPager(options *aztables.ListEntitiesOptions) ListEntitiesPager
+	SubmitTransaction(ctx context.Context, transactionActions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error)
 }
@@ -0,0 +1,194 @@
+package azure
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// odataOps maps the comparison operators accepted by odataQuery.where to
+// OData. models.Filter ops ("exact", ">=", "<=") and the OData names
+// themselves are both accepted.
+var odataOps = map[string]string{
+	"exact": "eq", "eq": "eq",
+	"ne": "ne",
+	"gt": "gt",
+	">=": "ge", "ge": "ge",
+	"lt": "lt",
+	"<=": "le", "le": "le",
+}
+
+// odataQuery builds a Table Storage filter. Property names only ever come
+// from a model codec and values are type-checked against the property and
+// formatted as literals, so no input can change the structure of the query.
+// Clauses are joined with "and".
+type odataQuery struct {
+	clauses []string
+}
+
+// newPartitionQuery starts a query restricted to one partition.
+func newPartitionQuery(partitionKey string) *odataQuery {
+	return &odataQuery{clauses: []string{"PartitionKey eq " + quoteODataString(partitionKey)}}
+}
+
+// where adds a comparison of field with value.
+func (q *odataQuery) where(field *fieldCodec, op string, value interface{}) error {
+	clause, err := odataComparison(field, op, value)
+	if err != nil {
+		return err
+	}
+	q.clauses = append(q.clauses, clause)
+	return nil
+}
+
+// whereAny adds the disjunction of already-built clauses.
+func (q *odataQuery) whereAny(clauses []string) {
+	if len(clauses) > 0 {
+		q.clauses = append(q.clauses, "("+strings.Join(clauses, " or ")+")")
+	}
+}
+
+// whereExpr adds a clause produced by odataExpr.
+func (q *odataQuery) whereExpr(clause string) {
+	q.clauses = append(q.clauses, "("+clause+")")
+}
+
+func (q *odataQuery) String() string {
+	return strings.Join(q.clauses, " and ")
+}
+
+// odataComparison formats "<Property> <op> <literal>".
+func odataComparison(field *fieldCodec, op string, value interface{}) (string, error) {
+	odataOp, ok := odataOps[op]
+	if !ok {
+		return "", fmt.Errorf("unsupported operator %q for %s", op, field.column)
+	}
+	literal, err := odataLiteral(field, value)
+	if err != nil {
+		return "", err
+	}
+	return field.property + " " + odataOp + " " + literal, nil
+}
+
+// odataLiteral formats value as an OData literal matching how encodeValue
+// stores the field. It fails when the value's type does not fit the field,
+// rather than producing a literal Table Storage would compare by a different
+// type (and silently match nothing).
+func odataLiteral(field *fieldCodec, value interface{}) (string, error) {
+	t := field.typ
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return "", fmt.Errorf("%s: null cannot be compared; Table Storage omits null properties", field.column)
+	}
+	mismatch := func() error {
+		return fmt.Errorf("%s: cannot compare %s field with %T value", field.column, t, value)
+	}
+
+	switch {
+	case t == timeType || t == deletedAtType:
+		var ts time.Time
+		switch v := rv.Interface().(type) {
+		case time.Time:
+			ts = v
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("%s: invalid RFC 3339 timestamp %q", field.column, v)
+			}
+			ts = parsed
+		default:
+			return "", mismatch()
+		}
+		// Timestamps are stored as UTC RFC 3339 strings, which sort chronologically.
+		return quoteODataString(ts.UTC().Format(time.RFC3339)), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if rv.Kind() != reflect.String {
+			return "", mismatch()
+		}
+		return quoteODataString(rv.String()), nil
+	case reflect.Bool:
+		if rv.Kind() != reflect.Bool {
+			return "", mismatch()
+		}
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := integerValue(rv)
+		if !ok {
+			return "", mismatch()
+		}
+		if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64 && n < 0 {
+			return "", fmt.Errorf("%s: negative value %d for unsigned field", field.column, n)
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			// Edm.Int64 literals carry an L suffix.
+			return strconv.FormatInt(n, 10) + "L", nil
+		}
+		return strconv.FormatInt(n, 10), nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		default:
+			n, ok := integerValue(rv)
+			if !ok {
+				return "", mismatch()
+			}
+			f = float64(n)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("%s: %v cannot be compared", field.column, f)
+		}
+		// A literal without a decimal point is an Edm.Int32, which Table
+		// Storage does not compare with Edm.Double properties.
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	default:
+		return "", fmt.Errorf("%s: %s fields cannot be filtered", field.column, t)
+	}
+}
+
+// integerValue returns the value of an integer, or of a float holding a whole
+// number (as JSON numbers decode), as an int64.
+func integerValue(rv reflect.Value) (int64, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	default:
+		return 0, false
+	}
+}
+
+// quoteODataString escapes a value for use inside a single-quoted OData
+// string literal by doubling single quotes, which is the only escape OData
+// string literals have.
+func quoteODataString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,288 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+	"backend/pkg/dberrors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
+)
+
+// outboxRowPrefix starts the row keys of outbox events. Events are stored in
+// the partition of the entities whose changes they describe, so that both
+// are written in one entity-group transaction; "~" sorts after the digits of
+// entity row keys, and List skips these rows.
+const outboxRowPrefix = "~outbox-"
+
+// outboxRowRange restricts a query to outbox rows: "." follows the "-" ending
+// outboxRowPrefix.
+const outboxRowRange = "RowKey gt '~outbox-' and RowKey lt '~outbox.'"
+
+// isOutboxRow reports whether rowKey is the row key of an outbox event.
+func isOutboxRow(rowKey string) bool {
+	return strings.HasPrefix(rowKey, outboxRowPrefix)
+}
+
+// outboxPartition returns the partition holding the events of topic: its
+// collection, which is the registered name of the model it is about.
+func outboxPartition(topic string) string {
+	collection, _, _ := strings.Cut(topic, ":")
+	return collection
+}
+
+// txClient buffers the entity writes made through it as the actions of one
+// entity-group transaction, for WithEvents to submit. Reads go straight to the
+// table, and so do the transactions Batch submits, except its last, which it
+// adds to actions.
+type txClient struct {
+	AzureTableClient
+	actions []aztables.TransactionAction
+
+	// settle, when set by Batch, is given the outcome of the transaction, or
+	// the error that prevented it, and returns the error to report.
+	settle func(err error) error
+}
+
+func (c *txClient) AddEntity(_ context.Context, entity []byte, _ *aztables.AddEntityOptions) (aztables.AddEntityResponse, error) {
+	c.actions = append(c.actions, aztables.TransactionAction{ActionType: aztables.TransactionTypeAdd, Entity: entity})
+	return aztables.AddEntityResponse{}, nil
+}
+
+func (c *txClient) UpdateEntity(_ context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+	action := aztables.TransactionAction{ActionType: aztables.TransactionTypeUpdateMerge, Entity: entity}
+	if options != nil {
+		action.IfMatch = options.IfMatch
+		if options.UpdateMode == aztables.UpdateModeReplace {
+			action.ActionType = aztables.TransactionTypeUpdateReplace
+		}
+	}
+	c.actions = append(c.actions, action)
+	return aztables.UpdateEntityResponse{}, nil
+}
+
+func (c *txClient) DeleteEntity(_ context.Context, partitionKey, rowKey string, options *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error) {
+	keys, err := json.Marshal(map[string]string{"PartitionKey": partitionKey, "RowKey": rowKey})
+	if err != nil {
+		return aztables.DeleteEntityResponse{}, err
+	}
+	action := aztables.TransactionAction{ActionType: aztables.TransactionTypeDelete, Entity: keys}
+	if options != nil {
+		action.IfMatch = options.IfMatch
+	}
+	c.actions = append(c.actions, action)
+	return aztables.DeleteEntityResponse{}, nil
+}
+
+// WithEvents implements models.Outboxer. fn's writes are buffered and
+// submitted with the events in one entity-group transaction, so they must
+// all be in one partition, that of the events' collection, and number at
+// most 100 with the events; otherwise nothing is written and ErrValidation
+// is returned. A Batch needing several transactions commits all but its last
+// one while fn runs, and its last one is submitted with the events; if that
+// fails, the *models.BatchError lists the ops already committed.
+func (r *TableRepository) WithEvents(ctx context.Context, fn func(tx models.Store) ([]models.OutboxEvent, error)) error {
+	tx := &txClient{AzureTableClient: r.client}
+	events, err := fn(&TableRepository{client: tx, tableName: r.tableName})
+	if err == nil {
+		err = r.submitWithEvents(ctx, tx.actions, events)
+	}
+	if tx.settle != nil {
+		return tx.settle(err)
+	}
+	return err
+}
+
+// submitWithEvents submits actions and the rows of events in one transaction.
+func (r *TableRepository) submitWithEvents(ctx context.Context, actions []aztables.TransactionAction, events []models.OutboxEvent) error {
+	for _, event := range events {
+		entity, err := marshalOutboxEvent(event)
+		if err != nil {
+			return dberrors.NewDatabaseError("outbox", err)
+		}
+		actions = append(actions, aztables.TransactionAction{ActionType: aztables.TransactionTypeAdd, Entity: entity})
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	if len(actions) > maxTransactionActions {
+		return dberrors.NewDatabaseError("outbox",
+			fmt.Errorf("%w: %d writes do not fit in one transaction", dberrors.ErrValidation, len(actions)))
+	}
+	partition := actionPartition(actions[0])
+	for _, action := range actions[1:] {
+		if actionPartition(action) != partition {
+			return dberrors.NewDatabaseError("outbox",
+				fmt.Errorf("%w: changes and events span several partitions", dberrors.ErrValidation))
+		}
+	}
+
+	if _, err := r.client.SubmitTransaction(ctx, actions, nil); err != nil {
+		return transactionError("outbox", err)
+	}
+	return nil
+}
+
+// actionPartition returns the partition key of the entity action writes.
+func actionPartition(action aztables.TransactionAction) string {
+	var keys struct{ PartitionKey string }
+	_ = json.Unmarshal(action.Entity, &keys)
+	return keys.PartitionKey
+}
+
+// marshalOutboxEvent encodes e as an entity. Like idempotent responses, the
+// message is split over Message0, Message1, ... properties.
+func marshalOutboxEvent(e models.OutboxEvent) ([]byte, error) {
+	chunks := (len(e.Message) + maxBinaryProperty - 1) / maxBinaryProperty
+	if chunks > maxBodyChunks {
+		return nil, fmt.Errorf("event of %d bytes is too large to store", len(e.Message))
+	}
+	props := map[string]interface{}{
+		"PartitionKey":  outboxPartition(e.Topic),
+		"RowKey":        outboxRowPrefix + e.EventID,
+		"Topic":         e.Topic,
+		"CreatedAt":     e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"Sent":          false,
+		"MessageChunks": chunks,
+	}
+	for i := 0; i < chunks; i++ {
+		chunk := e.Message[i*maxBinaryProperty : min((i+1)*maxBinaryProperty, len(e.Message))]
+		name := fmt.Sprintf("Message%d", i)
+		props[name] = base64.StdEncoding.EncodeToString(chunk)
+		props[name+"@odata.type"] = "Edm.Binary"
+	}
+	return json.Marshal(props)
+}
+
+func unmarshalOutboxEvent(data []byte) (models.OutboxEvent, error) {
+	var props map[string]interface{}
+	if err := json.Unmarshal(data, &props); err != nil {
+		return models.OutboxEvent{}, err
+	}
+	rowKey, _ := props["RowKey"].(string)
+	e := models.OutboxEvent{EventID: strings.TrimPrefix(rowKey, outboxRowPrefix)}
+	e.Topic, _ = props["Topic"].(string)
+	createdAt, _ := props["CreatedAt"].(string)
+	e.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	chunks, _ := toFloat(props["MessageChunks"])
+	for i := 0; i < int(chunks); i++ {
+		encoded, _ := props[fmt.Sprintf("Message%d", i)].(string)
+		chunk, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return models.OutboxEvent{}, fmt.Errorf("invalid Message%d: %w", i, err)
+		}
+		e.Message = append(e.Message, chunk...)
+	}
+	return e, nil
+}
+
+// PendingEvents implements models.Outboxer. Events are read from the
+// partition of every registered model, and ordered by EventID, which starts
+// with their creation time.
+func (r *TableRepository) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	var events []models.OutboxEvent
+	for _, info := range models.RegisteredModels() {
+		// Rows are returned in row key order, so each partition's oldest
+		// events come first.
+		filter := newPartitionQuery(info.Name).String() + " and " + outboxRowRange + " and Sent eq false"
+		top := int32(limit)
+		pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{Filter: &filter, Top: &top})
+		read := 0
+		for read < limit && pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, dberrors.NewDatabaseError("outbox", err)
+			}
+			for _, raw := range page.Entities {
+				event, err := unmarshalOutboxEvent(raw)
+				if err != nil {
+					return nil, dberrors.NewDatabaseError("unmarshal", err)
+				}
+				events = append(events, event)
+				read++
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].EventID < events[j].EventID })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// MarkEventsSent implements models.Outboxer. The events of each partition
+// are marked in transactions of up to 100.
+func (r *TableRepository) MarkEventsSent(ctx context.Context, events []models.OutboxEvent) error {
+	sentAt := time.Now().UTC().Format(time.RFC3339)
+	var partitions []string
+	groups := make(map[string][]aztables.TransactionAction)
+	for _, event := range events {
+		pk := outboxPartition(event.Topic)
+		entity, err := json.Marshal(map[string]interface{}{
+			"PartitionKey": pk,
+			"RowKey":       outboxRowPrefix + event.EventID,
+			"Sent":         true,
+			"SentAt":       sentAt,
+		})
+		if err != nil {
+			return dberrors.NewDatabaseError("marshal", err)
+		}
+		if _, ok := groups[pk]; !ok {
+			partitions = append(partitions, pk)
+		}
+		groups[pk] = append(groups[pk], aztables.TransactionAction{ActionType: aztables.TransactionTypeUpdateMerge, Entity: entity})
+	}
+
+	for _, pk := range partitions {
+		actions := groups[pk]
+		for start := 0; start < len(actions); start += maxTransactionActions {
+			chunk := actions[start:min(start+maxTransactionActions, len(actions))]
+			if _, err := r.client.SubmitTransaction(ctx, chunk, nil); err != nil {
+				return transactionError("outbox", err)
+			}
+		}
+	}
+	return nil
+}
+
+// PurgeSentEvents implements models.Outboxer. SentAt is stored in a
+// fixed-width format, so it compares as a string.
+func (r *TableRepository) PurgeSentEvents(ctx context.Context, sentBefore time.Time) (int64, error) {
+	var purged int64
+	for _, info := range models.RegisteredModels() {
+		filter := fmt.Sprintf("%s and %s and Sent eq true and SentAt lt '%s'",
+			newPartitionQuery(info.Name), outboxRowRange, sentBefore.UTC().Format(time.RFC3339))
+		selected := "RowKey"
+		pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{Filter: &filter, Select: &selected})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return purged, dberrors.NewDatabaseError("outbox", err)
+			}
+			for _, raw := range page.Entities {
+				var row struct{ RowKey string }
+				if err := json.Unmarshal(raw, &row); err != nil {
+					return purged, dberrors.NewDatabaseError("unmarshal", err)
+				}
+				_, err := r.client.DeleteEntity(ctx, info.Name, row.RowKey, nil)
+				if err != nil {
+					if IsNotFoundError(err) {
+						continue // Already purged by another instance
+					}
+					return purged, dberrors.NewDatabaseError("outbox", err)
+				}
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
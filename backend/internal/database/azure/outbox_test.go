@@ -0,0 +1,325 @@
+package azure_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/database/azure"
+	"backend/internal/models"
+	"backend/pkg/dberrors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listingClient is a mockClient whose queries are answered by list.
+type listingClient struct {
+	*mockClient
+	list func(options *aztables.ListEntitiesOptions) azure.ListEntitiesPager
+}
+
+func (c *listingClient) NewListEntitiesPager(options *aztables.ListEntitiesOptions) azure.ListEntitiesPager {
+	return c.list(options)
+}
+
+func outboxRow(partition, eventID, topic string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"PartitionKey":  partition,
+		"RowKey":        "~outbox-" + eventID,
+		"Topic":         topic,
+		"Message0":      base64.StdEncoding.EncodeToString([]byte(`{"type":"item.created"}`)),
+		"MessageChunks": 1,
+		"CreatedAt":     "2024-01-01T00:00:00Z",
+		"Sent":          false,
+	})
+	return b
+}
+
+func TestTableRepository_WithEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	liveItem := func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+		return aztables.GetEntityResponse{
+			ETag:  azcore.ETag("etag-" + rowKey),
+			Value: []byte(`{"PartitionKey":"items","RowKey":"` + rowKey + `","Name":"old","Price":1,"Version":1}`),
+		}, nil
+	}
+	event := func(topic string) models.OutboxEvent {
+		return models.OutboxEvent{EventID: models.NewEventID(), Topic: topic, Message: []byte(`{}`), CreatedAt: time.Now()}
+	}
+
+	t.Run("changes and events are submitted in one transaction", func(t *testing.T) {
+		t.Parallel()
+
+		var transactions [][]aztables.TransactionAction
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager:     &testPager{},
+			getEntity: liveItem,
+			submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+				transactions = append(transactions, actions)
+				return aztables.TransactionResponse{}, nil
+			},
+		})
+
+		created := &models.Item{Name: "new", Price: 1}
+		updated := &models.Item{Base: models.Base{ID: 7}, Name: "renamed", Price: 2, Version: 1}
+		e := event("items:7")
+		err := repo.WithEvents(ctx, func(tx models.Store) ([]models.OutboxEvent, error) {
+			if err := tx.Create(ctx, created); err != nil {
+				return nil, err
+			}
+			if err := tx.Update(ctx, updated); err != nil {
+				return nil, err
+			}
+			if err := tx.Delete(ctx, &models.Item{Base: models.Base{ID: 8}}); err != nil {
+				return nil, err
+			}
+			return []models.OutboxEvent{e}, nil
+		})
+		require.NoError(t, err)
+
+		require.Len(t, transactions, 1)
+		actions := transactions[0]
+		require.Len(t, actions, 4)
+		assert.Equal(t, aztables.TransactionTypeAdd, actions[0].ActionType)
+		assert.Equal(t, aztables.TransactionTypeUpdateMerge, actions[1].ActionType)
+		assert.Equal(t, azcore.ETag("etag-7"), *actions[1].IfMatch)
+		assert.Contains(t, string(actions[2].Entity), `"DeletedAt"`)
+		assert.Equal(t, aztables.TransactionTypeAdd, actions[3].ActionType)
+		var row map[string]interface{}
+		require.NoError(t, json.Unmarshal(actions[3].Entity, &row))
+		assert.Equal(t, "items", row["PartitionKey"])
+		assert.Equal(t, "~outbox-"+e.EventID, row["RowKey"])
+		assert.Equal(t, "items:7", row["Topic"])
+		assert.Equal(t, false, row["Sent"])
+	})
+
+	t.Run("nothing is written when the transaction cannot be made", func(t *testing.T) {
+		t.Parallel()
+
+		failure := errors.New("failure")
+		tests := []struct {
+			name    string
+			fn      func(tx models.Store) ([]models.OutboxEvent, error)
+			wantErr error
+		}{
+			{
+				name: "fn fails",
+				fn: func(tx models.Store) ([]models.OutboxEvent, error) {
+					require.NoError(t, tx.Create(ctx, &models.Item{Name: "new", Price: 1}))
+					return nil, failure
+				},
+				wantErr: failure,
+			},
+			{
+				name: "several partitions",
+				fn: func(tx models.Store) ([]models.OutboxEvent, error) {
+					require.NoError(t, tx.Create(ctx, &models.Item{Name: "new", Price: 1}))
+					return []models.OutboxEvent{event("users:1")}, nil
+				},
+				wantErr: dberrors.ErrValidation,
+			},
+			{
+				name: "too many writes",
+				fn: func(tx models.Store) ([]models.OutboxEvent, error) {
+					for i := 0; i < 100; i++ {
+						require.NoError(t, tx.Create(ctx, &models.Item{Name: "new", Price: 1}))
+					}
+					return []models.OutboxEvent{event("items")}, nil
+				},
+				wantErr: dberrors.ErrValidation,
+			},
+		}
+
+		for _, tt := range tests {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				repo := azure.NewTestTableRepository("testtable")
+				repo.SetTestClient(&mockClient{pager: &testPager{}})
+				assert.ErrorIs(t, repo.WithEvents(ctx, tt.fn), tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("a batch's last transaction is submitted with the events", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name string
+			// failWith fails the transaction with the events, when set.
+			failWith error
+		}{
+			{name: "committed"},
+			{name: "event write fails", failWith: &azcore.ResponseError{StatusCode: 500}},
+		}
+
+		for _, tt := range tests {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				var transactions [][]aztables.TransactionAction
+				repo := azure.NewTestTableRepository("testtable")
+				repo.SetTestClient(&mockClient{
+					pager: &testPager{},
+					submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+						transactions = append(transactions, actions)
+						if len(transactions) == 2 && tt.failWith != nil {
+							return aztables.TransactionResponse{}, tt.failWith
+						}
+						return aztables.TransactionResponse{}, nil
+					},
+				})
+
+				ops := make([]models.BatchOp, 150)
+				for i := range ops {
+					ops[i] = models.BatchOp{Action: models.BatchCreate, Entity: &models.Item{Name: "new", Price: 1}}
+				}
+				e := event("items")
+				err := repo.WithEvents(ctx, func(tx models.Store) ([]models.OutboxEvent, error) {
+					if err := tx.(models.Batcher).Batch(ctx, ops); err != nil {
+						return nil, err
+					}
+					return []models.OutboxEvent{e}, nil
+				})
+
+				require.Len(t, transactions, 2)
+				assert.Len(t, transactions[0], 51)
+				require.Len(t, transactions[1], 100, "the last transaction keeps room for the event")
+				assert.Contains(t, string(transactions[1][99].Entity), "~outbox-"+e.EventID)
+				if tt.failWith == nil {
+					require.NoError(t, err)
+					return
+				}
+				var batchErr *models.BatchError
+				require.ErrorAs(t, err, &batchErr, "the ops of the first transaction stay applied")
+				assert.Len(t, batchErr.Applied, 51)
+				assert.Equal(t, 50, batchErr.Applied[50])
+			})
+		}
+	})
+
+	t.Run("a batch with events is rejected before anything is written", func(t *testing.T) {
+		t.Parallel()
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager: &testPager{},
+			submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+				t.Error("nothing is submitted")
+				return aztables.TransactionResponse{}, nil
+			},
+		})
+
+		err := repo.WithEvents(ctx, func(tx models.Store) ([]models.OutboxEvent, error) {
+			for i := 0; i < 99; i++ {
+				require.NoError(t, tx.Create(ctx, &models.Item{Name: "new", Price: 1}))
+			}
+			ops := []models.BatchOp{{Action: models.BatchCreate, Entity: &models.Item{Name: "new", Price: 1}}}
+			return nil, tx.(models.Batcher).Batch(ctx, ops)
+		})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+
+	t.Run("a rejected transaction reports the conflict", func(t *testing.T) {
+		t.Parallel()
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager:     &testPager{},
+			getEntity: liveItem,
+			submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+				return aztables.TransactionResponse{}, &azcore.ResponseError{StatusCode: 412}
+			},
+		})
+
+		err := repo.WithEvents(ctx, func(tx models.Store) ([]models.OutboxEvent, error) {
+			return []models.OutboxEvent{event("items:7")}, tx.Update(ctx, &models.Item{Base: models.Base{ID: 7}, Name: "x", Price: 1, Version: 1})
+		})
+		assert.ErrorContains(t, err, "version mismatch")
+	})
+}
+
+func TestTableRepository_OutboxDelivery(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	rows := map[string][][]byte{
+		"items": {outboxRow("items", "0002", "items:1"), outboxRow("items", "0003", "items:2")},
+		"users": {outboxRow("users", "0001", "users:1")},
+	}
+	var filters []string
+	var transactions [][]aztables.TransactionAction
+	var deleted []string
+	repo := azure.NewTestTableRepository("testtable")
+	repo.SetTestClient(&listingClient{
+		mockClient: &mockClient{
+			submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+				transactions = append(transactions, actions)
+				return aztables.TransactionResponse{}, nil
+			},
+			deleteEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error) {
+				deleted = append(deleted, partitionKey+"/"+rowKey)
+				return aztables.DeleteEntityResponse{}, nil
+			},
+		},
+		list: func(options *aztables.ListEntitiesOptions) azure.ListEntitiesPager {
+			filters = append(filters, *options.Filter)
+			partition := strings.Trim(strings.TrimPrefix(strings.SplitN(*options.Filter, " and ", 2)[0], "PartitionKey eq "), "'")
+			return &testPager{pages: rows[partition]}
+		},
+	})
+
+	events, err := repo.PendingEvents(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "0001", events[0].EventID, "events of every partition are ordered by ID")
+	assert.Equal(t, "users:1", events[0].Topic)
+	assert.Equal(t, `{"type":"item.created"}`, string(events[0].Message))
+	assert.Equal(t, "0002", events[1].EventID)
+	for _, filter := range filters {
+		assert.Contains(t, filter, "RowKey gt '~outbox-' and RowKey lt '~outbox.' and Sent eq false")
+	}
+
+	require.NoError(t, repo.MarkEventsSent(ctx, events))
+	require.Len(t, transactions, 2, "events are marked a partition at a time")
+	var marked map[string]interface{}
+	require.NoError(t, json.Unmarshal(transactions[0][0].Entity, &marked))
+	assert.Equal(t, "users", marked["PartitionKey"])
+	assert.Equal(t, "~outbox-0001", marked["RowKey"])
+	assert.Equal(t, true, marked["Sent"])
+	assert.Equal(t, aztables.TransactionTypeUpdateMerge, transactions[0][0].ActionType)
+
+	filters = nil
+	purged, err := repo.PurgeSentEvents(ctx, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	assert.Contains(t, deleted, "users/~outbox-0001")
+	for _, filter := range filters {
+		assert.Contains(t, filter, "Sent eq true and SentAt lt '2024-01-02T00:00:00Z'")
+	}
+}
+
+func TestTableRepository_ListSkipsOutboxEvents(t *testing.T) {
+	t.Parallel()
+
+	repo := azure.NewTestTableRepository("testtable")
+	repo.SetTestClient(&mockClient{pager: &testPager{pages: [][]byte{
+		[]byte(`{"PartitionKey":"items","RowKey":"1","Name":"item1","Price":10.5}`),
+		outboxRow("items", "0001", "items:1"),
+	}}})
+
+	var items []models.Item
+	require.NoError(t, repo.List(context.Background(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "item1", items[0].Name)
+}
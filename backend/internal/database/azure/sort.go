@@ -0,0 +1,109 @@
+package azure
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"backend/internal/models"
+	"backend/pkg/dberrors"
+
+	"gorm.io/gorm"
+)
+
+// resolveSort maps the fields of a Sort condition to the model's field codecs.
+// A nil entry stands for the primary key, which is stored in the RowKey.
+func resolveSort(codec *modelCodec, order models.Sort) ([]*fieldCodec, error) {
+	fields := make([]*fieldCodec, len(order))
+	for i, f := range order {
+		if !models.Sortable(f.Field, codec.info.FilterFields) {
+			return nil, fmt.Errorf("%w: invalid sort field: %q", dberrors.ErrValidation, f.Field)
+		}
+		if f.Field == "id" {
+			continue
+		}
+		field, ok := codec.byColumn[f.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid sort field: %q", dberrors.ErrValidation, f.Field)
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+// sortRows orders a slice of model structs in memory, since Table Storage
+// always returns entities in PartitionKey/RowKey order. fields comes from
+// resolveSort. The sort is stable and rows that compare equal on every field
+// are ordered by primary key, as the GORM repository does.
+func sortRows(rows reflect.Value, order models.Sort, fields []*fieldCodec) {
+	key := func(row reflect.Value, field *fieldCodec) reflect.Value {
+		if field == nil {
+			return reflect.ValueOf(row.Addr().Interface().(models.Entity).GetID())
+		}
+		return row.FieldByIndex(field.index)
+	}
+	sort.SliceStable(rows.Interface(), func(i, j int) bool {
+		a, b := rows.Index(i), rows.Index(j)
+		for k, f := range order {
+			if c := compareValues(key(a, fields[k]), key(b, fields[k])); c != 0 {
+				if f.Desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return compareValues(key(a, nil), key(b, nil)) < 0
+	})
+}
+
+// compareValues compares two field values of the same type, returning -1, 0
+// or +1. Nil pointers and unset soft-delete timestamps sort first, as NULL
+// does in ascending SQL order on SQLite and MySQL.
+func compareValues(a, b reflect.Value) int {
+	switch {
+	case a.Kind() == reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		return compareValues(a.Elem(), b.Elem())
+	case a.Type() == timeType:
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	case a.Type() == deletedAtType:
+		da, db := a.Interface().(gorm.DeletedAt), b.Interface().(gorm.DeletedAt)
+		if !da.Valid || !db.Valid {
+			return cmp.Compare(boolRank(da.Valid), boolRank(db.Valid))
+		}
+		return da.Time.Compare(db.Time)
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return cmp.Compare(boolRank(a.Bool()), boolRank(b.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	default:
+		// Complex types are stored as JSON strings; order them the same way.
+		return cmp.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+	}
+}
+
+// boolRank orders false before true.
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
+	"gorm.io/gorm"
 )
 
 // nextID generates a collision-resistant numeric ID using a cryptographically
@@ -30,7 +32,8 @@ func nextID() (uint, error) {
 	return uint(rb.Uint64()), nil
 }
 
-// TableRepository implements the Repository interface for Azure Table Storage
+// TableRepository implements the models.Store interface for Azure Table Storage.
+// Every registered model shares one table, partitioned by its registered name.
 type TableRepository struct {
 	client    AzureTableClient
 	tableName string
@@ -102,68 +105,102 @@ func NewTestTableRepository(tableName string) *TableRepository {
 	}
 }
 
-// Create implements the Repository interface
+// modelFor resolves the codec and addressable struct value for a pointer to a
+// registered model. op is used as the error context.
+func modelFor(op string, entity interface{}) (*modelCodec, reflect.Value, error) {
+	v := reflect.ValueOf(entity)
+	if entity == nil || v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, reflect.Value{}, dberrors.NewDatabaseError("type_assertion",
+			fmt.Errorf("%s must be a pointer to a registered model, got %T", op, entity))
+	}
+	codec, err := codecForType(v.Elem().Type())
+	if err != nil {
+		return nil, reflect.Value{}, dberrors.NewDatabaseError("type_assertion",
+			fmt.Errorf("%s must be a pointer to a registered model: %w", op, err))
+	}
+	return codec, v.Elem(), nil
+}
+
+// Create implements the Store interface for any registered model.
 func (r *TableRepository) Create(ctx context.Context, entity interface{}) error {
-	item, ok := entity.(*models.Item)
-	if !ok {
-		return dberrors.NewDatabaseError("type_assertion", errors.New("entity must be *models.Item"))
+	w, err := r.prepareCreate(ctx, entity)
+	if err != nil {
+		return err
 	}
 
-	// Initialize version for new entities (consistent with GORM default:1 and MockRepository)
-	if item.Version == 0 {
-		item.Version = 1
+	_, err = r.client.AddEntity(ctx, w.action.Entity, nil)
+	if err != nil {
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.ErrorCode == "EntityAlreadyExists" {
+			return dberrors.NewDatabaseError("create", dberrors.ErrDuplicateKey)
+		}
+		return dberrors.NewDatabaseError("create", err)
 	}
 
-	// Create Azure Table entity
-	now := time.Now().UTC()
+	return nil
+}
+
+// prepareCreate validates a new entity, assigns its ID, version and
+// timestamps, and encodes the insert.
+func (r *TableRepository) prepareCreate(ctx context.Context, entity interface{}) (*pendingWrite, error) {
+	codec, v, err := modelFor("entity", entity)
+	if err != nil {
+		return nil, err
+	}
+
+	if val, ok := entity.(models.Validator); ok {
+		if err := val.Validate(); err != nil {
+			return nil, dberrors.NewDatabaseError("validate",
+				fmt.Errorf("%w: %s", dberrors.ErrValidation, err.Error()))
+		}
+	}
+
+	// Initialize version for new entities (consistent with GORM default:1 and MockRepository)
+	if ver, ok := entity.(models.Versionable); ok && ver.GetVersion() == 0 {
+		ver.SetVersion(1)
+	}
 
 	// Generate a numeric ID (Azure Table Storage has no auto-increment)
-	if item.ID == 0 {
+	e := entity.(models.Entity)
+	if e.GetID() == 0 {
 		id, err := nextID()
 		if err != nil {
-			return dberrors.NewDatabaseError("create", err)
+			return nil, dberrors.NewDatabaseError("create", err)
 		}
-		item.ID = id
+		e.SetID(id)
 	}
 
-	entityJSON := map[string]interface{}{
-		"PartitionKey": "items",
-		"RowKey":       strconv.FormatUint(uint64(item.ID), 10),
-		"Name":         item.Name,
-		"Price":        item.Price,
-		"Version":      item.Version,
-		"CreatedAt":    now.Format(time.RFC3339),
-		"UpdatedAt":    now.Format(time.RFC3339),
+	// Table Storage has no secondary unique indexes, so columns declared
+	// unique in SQL are checked with a query before the insert.
+	if err := r.ensureUnique(ctx, "create", codec, v, e.GetID()); err != nil {
+		return nil, err
 	}
 
-	entityBytes, err := json.Marshal(entityJSON)
-	if err != nil {
-		return dberrors.NewDatabaseError("marshal", err)
-	}
+	now := time.Now().UTC()
+	setTimestamps(v, now, now)
 
-	_, err = r.client.AddEntity(ctx, entityBytes, nil)
+	entityBytes, err := encodeEntity(codec, v, e.GetID(), nil)
 	if err != nil {
-		var respErr *azcore.ResponseError
-		if errors.As(err, &respErr) && respErr.ErrorCode == "EntityAlreadyExists" {
-			return dberrors.NewDatabaseError("create", dberrors.ErrDuplicateKey)
-		}
-		return dberrors.NewDatabaseError("create", err)
+		return nil, err
 	}
-
-	item.CreatedAt = now
-	item.UpdatedAt = now
-	return nil
+	return &pendingWrite{
+		codec:  codec,
+		value:  v,
+		rowKey: strconv.FormatUint(uint64(e.GetID()), 10),
+		action: aztables.TransactionAction{ActionType: aztables.TransactionTypeAdd, Entity: entityBytes},
+	}, nil
 }
 
-// FindByID implements the Repository interface
-func (r *TableRepository) FindByID(ctx context.Context, id uint, dest interface{}) error {
-	item, ok := dest.(*models.Item)
-	if !ok {
-		return dberrors.NewDatabaseError("type_assertion", fmt.Errorf("dest must be *models.Item"))
+// FindByID implements the Store interface for any registered model.
+// Tombstoned entities are reported as not found unless conditions include
+// models.IncludeDeleted.
+func (r *TableRepository) FindByID(ctx context.Context, id uint, dest interface{}, conditions ...interface{}) error {
+	codec, v, err := modelFor("dest", dest)
+	if err != nil {
+		return err
 	}
 
-	// Get the entity
-	result, err := r.client.GetEntity(ctx, "items", strconv.FormatUint(uint64(id), 10), nil)
+	result, err := r.client.GetEntity(ctx, codec.info.Name, strconv.FormatUint(uint64(id), 10), nil)
 	if err != nil {
 		var respErr *azcore.ResponseError
 		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
@@ -172,126 +209,135 @@ func (r *TableRepository) FindByID(ctx context.Context, id uint, dest interface{
 		return dberrors.NewDatabaseError("find", err)
 	}
 
-	// Parse the entity
 	var entityData map[string]interface{}
 	if err := json.Unmarshal(result.Value, &entityData); err != nil {
 		return dberrors.NewDatabaseError("unmarshal", err)
 	}
 
-	// Map entity to item
-	item.ID = id
-
-	name, ok := entityData["Name"].(string)
-	if !ok {
-		return dberrors.NewDatabaseError("unmarshal", fmt.Errorf("missing or invalid Name field"))
+	if isTombstoned(entityData) && !models.IncludesDeleted(conditions) {
+		return dberrors.NewDatabaseError("find", dberrors.ErrNotFound)
 	}
-	item.Name = name
 
-	price, ok := entityData["Price"].(float64)
-	if !ok {
-		return dberrors.NewDatabaseError("unmarshal", fmt.Errorf("missing or invalid Price field"))
+	v.Set(reflect.Zero(v.Type()))
+	if err := codec.decode(entityData, v); err != nil {
+		return dberrors.NewDatabaseError("unmarshal", err)
 	}
-	item.Price = price
+	dest.(models.Entity).SetID(id)
+	defaultVersion(dest)
 
-	// Default to version 1 when the Version field is missing or invalid to
-	// keep optimistic-lock semantics consistent with the GORM repository.
-	item.Version = 1
-	if v, ok := entityData["Version"]; ok {
-		if vf, ok := v.(float64); ok && vf > 0 {
-			item.Version = uint(vf)
-		}
+	return nil
+}
+
+// Update implements the Store interface for any registered model.
+// For models that implement Versionable (e.g. Item), optimistic locking is enforced:
+// the entity is fetched first to compare versions, and the ETag from the GET response
+// is passed to UpdateEntity so Azure Table Storage rejects stale writes.
+func (r *TableRepository) Update(ctx context.Context, entity interface{}) error {
+	w, err := r.prepareUpdate(ctx, entity, nil)
+	if err != nil {
+		return err
 	}
+	return r.submitUpdate(ctx, "update", w)
+}
 
-	createdAtStr, ok := entityData["CreatedAt"].(string)
-	if !ok {
-		return dberrors.NewDatabaseError("unmarshal", fmt.Errorf("missing or invalid CreatedAt field"))
+// Patch implements models.Patcher. Only the properties of columns (with
+// UpdatedAt and Version) are sent, and Table Storage merges them into the
+// stored entity, so concurrent writes to other properties are preserved.
+// Optimistic locking works as for Update.
+func (r *TableRepository) Patch(ctx context.Context, entity interface{}, columns ...string) error {
+	if err := models.PatchColumns(entity, columns); err != nil {
+		return dberrors.NewDatabaseError("patch", err)
 	}
-	createdAt, err := time.Parse(time.RFC3339, createdAtStr)
+	w, err := r.prepareUpdate(ctx, entity, columns)
 	if err != nil {
-		return dberrors.NewDatabaseError("parse_time", err)
+		return err
 	}
-	item.CreatedAt = createdAt
+	return r.submitUpdate(ctx, "patch", w)
+}
 
-	updatedAtStr, ok := entityData["UpdatedAt"].(string)
-	if !ok {
-		return dberrors.NewDatabaseError("unmarshal", fmt.Errorf("missing or invalid UpdatedAt field"))
-	}
-	updatedAt, err := time.Parse(time.RFC3339, updatedAtStr)
+// submitUpdate merges a prepared update into the stored entity.
+func (r *TableRepository) submitUpdate(ctx context.Context, op string, w *pendingWrite) error {
+	_, err := r.client.UpdateEntity(ctx, w.action.Entity, &aztables.UpdateEntityOptions{
+		IfMatch:    w.action.IfMatch,
+		UpdateMode: aztables.UpdateModeMerge,
+	})
 	if err != nil {
-		return dberrors.NewDatabaseError("parse_time", err)
+		w.rollback()
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == 412 {
+			// Precondition failed — concurrent modification
+			return dberrors.NewDatabaseError(op, errors.New("version mismatch"))
+		}
+		return dberrors.NewDatabaseError(op, err)
 	}
-	item.UpdatedAt = updatedAt
 
 	return nil
 }
 
-// Update implements the Repository interface.
-// For models that implement Versionable (e.g. Item), optimistic locking is enforced:
-// the entity is fetched first to compare versions, and the ETag from the GET response
-// is passed to UpdateEntity so Azure Table Storage rejects stale writes.
-func (r *TableRepository) Update(ctx context.Context, entity interface{}) error {
-	item, ok := entity.(*models.Item)
-	if !ok {
-		return dberrors.NewDatabaseError("type_assertion", fmt.Errorf("entity must be *models.Item"))
+// prepareUpdate checks that entity exists and, if Versionable, that its
+// version matches the stored one, then bumps the version and UpdatedAt and
+// encodes a merge conditional on the stored ETag. columns restricts the
+// merged properties as for encodeEntity.
+func (r *TableRepository) prepareUpdate(ctx context.Context, entity interface{}, columns []string) (*pendingWrite, error) {
+	codec, v, err := modelFor("entity", entity)
+	if err != nil {
+		return nil, err
 	}
 
-	if item.ID == 0 {
-		return dberrors.NewDatabaseError("update", dberrors.ErrValidation)
+	e := entity.(models.Entity)
+	if e.GetID() == 0 {
+		return nil, dberrors.NewDatabaseError("update", dberrors.ErrValidation)
+	}
+
+	if val, ok := entity.(models.Validator); ok {
+		if err := val.Validate(); err != nil {
+			return nil, dberrors.NewDatabaseError("validate",
+				fmt.Errorf("%w: %s", dberrors.ErrValidation, err.Error()))
+		}
 	}
 
 	// Fetch existing entity (also validates existence)
-	existing, err := r.client.GetEntity(ctx, "items", strconv.FormatUint(uint64(item.ID), 10), nil)
+	rowKey := strconv.FormatUint(uint64(e.GetID()), 10)
+	existing, err := r.client.GetEntity(ctx, codec.info.Name, rowKey, nil)
 	if err != nil {
 		var respErr *azcore.ResponseError
 		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
-			return dberrors.NewDatabaseError("update", dberrors.ErrNotFound)
+			return nil, dberrors.NewDatabaseError("update", dberrors.ErrNotFound)
 		}
-		return dberrors.NewDatabaseError("find", err)
+		return nil, dberrors.NewDatabaseError("find", err)
 	}
 
 	// Parse existing entity once for version checking and CreatedAt preservation.
 	var existingData map[string]interface{}
 	if err := json.Unmarshal(existing.Value, &existingData); err != nil {
-		return dberrors.NewDatabaseError("unmarshal", err)
+		return nil, dberrors.NewDatabaseError("unmarshal", err)
+	}
+	if isTombstoned(existingData) {
+		return nil, dberrors.NewDatabaseError("update", dberrors.ErrNotFound)
 	}
 
 	// Optimistic locking: compare version if the entity is Versionable
-	if ver, ok := entity.(models.Versionable); ok {
+	ver, versioned := entity.(models.Versionable)
+	if versioned {
 		currentVersion := ver.GetVersion()
-
-		// Default stored version to 1 for legacy rows that predate versioning,
-		// consistent with the model default and FindByID behavior.
-		storedVersionUint := uint(1)
-
-		if storedVersion, ok := existingData["Version"]; ok {
-			switch v := storedVersion.(type) {
-			case float64:
-				// Only treat strictly positive values as valid stored versions.
-				// Non-positive values leave the default of 1, matching FindByID.
-				if v > 0 {
-					storedVersionUint = uint(v)
-				}
-			case json.Number:
-				if n, err := v.Int64(); err == nil && n > 0 {
-					storedVersionUint = uint(n)
-				}
-			}
-		}
-
-		if currentVersion != storedVersionUint {
-			return dberrors.NewDatabaseError("update", errors.New("version mismatch"))
+		if currentVersion != storedVersion(existingData) {
+			return nil, dberrors.NewDatabaseError("update", errors.New("version mismatch"))
 		}
 
 		// Increment version for the update
 		ver.SetVersion(currentVersion + 1)
 	}
 
-	// Create Azure Table entity
-	now := time.Now().UTC()
+	if err := r.ensureUnique(ctx, "update", codec, v, e.GetID()); err != nil {
+		if versioned {
+			ver.SetVersion(ver.GetVersion() - 1) // Roll back
+		}
+		return nil, err
+	}
 
 	// Preserve the stored CreatedAt so callers that skip FindByID before
 	// updating don't accidentally clobber it with a zero time.
-	createdAt := item.CreatedAt
+	createdAt := v.FieldByName("CreatedAt").Interface().(time.Time)
 	if createdAt.IsZero() {
 		if caStr, ok := existingData["CreatedAt"].(string); ok {
 			if parsed, parseErr := time.Parse(time.RFC3339, caStr); parseErr == nil {
@@ -299,130 +345,351 @@ func (r *TableRepository) Update(ctx context.Context, entity interface{}) error
 			}
 		}
 	}
-
-	entityJson := map[string]interface{}{
-		"PartitionKey": "items",
-		"RowKey":       strconv.FormatUint(uint64(item.ID), 10),
-		"Name":         item.Name,
-		"Price":        item.Price,
-		"Version":      item.Version,
-		"CreatedAt":    createdAt.Format(time.RFC3339),
-		"UpdatedAt":    now.Format(time.RFC3339),
+	now := time.Now().UTC()
+	previousUpdatedAt := v.FieldByName("UpdatedAt").Interface().(time.Time)
+	setTimestamps(v, createdAt, now)
+	undo := func() {
+		setTimestamps(v, createdAt, previousUpdatedAt)
+		if versioned {
+			ver.SetVersion(ver.GetVersion() - 1)
+		}
 	}
 
-	entityBytes, err := json.Marshal(entityJson)
+	entityBytes, err := encodeEntity(codec, v, e.GetID(), columns)
 	if err != nil {
-		return dberrors.NewDatabaseError("marshal", err)
+		undo()
+		return nil, err
+	}
+	return &pendingWrite{
+		codec:  codec,
+		value:  v,
+		rowKey: rowKey,
+		action: aztables.TransactionAction{
+			ActionType: aztables.TransactionTypeUpdateMerge,
+			Entity:     entityBytes,
+			IfMatch:    &existing.ETag,
+		},
+		undo: undo,
+	}, nil
+}
+
+// Delete implements the Store interface for any registered model. It is a
+// soft delete: a DeletedAt tombstone property is merged into the entity, which
+// hides it from FindByID and List until it is restored or purged.
+func (r *TableRepository) Delete(ctx context.Context, entity interface{}) error {
+	w, err := r.prepareDelete(ctx, entity)
+	if err != nil {
+		return err
 	}
 
-	// Use ETag from the GET response for conditional update
-	updateOpts := &aztables.UpdateEntityOptions{
-		IfMatch:    &existing.ETag,
+	_, err = r.client.UpdateEntity(ctx, w.action.Entity, &aztables.UpdateEntityOptions{
+		IfMatch:    w.action.IfMatch,
 		UpdateMode: aztables.UpdateModeMerge,
-	}
-	_, err = r.client.UpdateEntity(ctx, entityBytes, updateOpts)
+	})
 	if err != nil {
 		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
+			return dberrors.NewDatabaseError("delete", dberrors.ErrNotFound)
+		}
 		if errors.As(err, &respErr) && respErr.StatusCode == 412 {
-			// Precondition failed — concurrent modification
-			if ver, ok := entity.(models.Versionable); ok {
-				ver.SetVersion(ver.GetVersion() - 1) // Roll back
-			}
-			return dberrors.NewDatabaseError("update", errors.New("version mismatch"))
+			return dberrors.NewDatabaseError("delete", errors.New("version mismatch"))
 		}
-		return dberrors.NewDatabaseError("update", err)
+		return dberrors.NewDatabaseError("delete", err)
 	}
 
-	item.UpdatedAt = now
+	w.applied()
 	return nil
 }
 
-// Delete implements the Repository interface
-func (r *TableRepository) Delete(ctx context.Context, entity interface{}) error {
-	item, ok := entity.(*models.Item)
-	if !ok {
-		return dberrors.NewDatabaseError("type_assertion", fmt.Errorf("entity must be *models.Item"))
+// prepareDelete checks that entity exists and is live, and encodes the merge
+// of its tombstone conditional on the stored ETag.
+func (r *TableRepository) prepareDelete(ctx context.Context, entity interface{}) (*pendingWrite, error) {
+	codec, v, err := modelFor("entity", entity)
+	if err != nil {
+		return nil, err
 	}
 
-	if item.ID == 0 {
-		return dberrors.NewDatabaseError("delete", dberrors.ErrValidation)
+	id := entity.(models.Entity).GetID()
+	if id == 0 {
+		return nil, dberrors.NewDatabaseError("delete", dberrors.ErrValidation)
 	}
+	rowKey := strconv.FormatUint(uint64(id), 10)
 
-	_, err := r.client.DeleteEntity(ctx, "items", strconv.FormatUint(uint64(item.ID), 10), nil)
+	existing, err := r.client.GetEntity(ctx, codec.info.Name, rowKey, nil)
 	if err != nil {
 		var respErr *azcore.ResponseError
 		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
-			return dberrors.NewDatabaseError("delete", dberrors.ErrNotFound)
+			return nil, dberrors.NewDatabaseError("delete", dberrors.ErrNotFound)
 		}
-		return dberrors.NewDatabaseError("delete", err)
+		return nil, dberrors.NewDatabaseError("delete", err)
 	}
 
-	return nil
+	var existingData map[string]interface{}
+	if err := json.Unmarshal(existing.Value, &existingData); err != nil {
+		return nil, dberrors.NewDatabaseError("unmarshal", err)
+	}
+	if isTombstoned(existingData) {
+		return nil, dberrors.NewDatabaseError("delete", dberrors.ErrNotFound)
+	}
+	if ver, ok := entity.(models.Versionable); ok && ver.GetVersion() > 0 && ver.GetVersion() != storedVersion(existingData) {
+		return nil, dberrors.NewDatabaseError("delete", errors.New("version mismatch"))
+	}
+
+	now := time.Now().UTC()
+	tombstone, err := json.Marshal(map[string]interface{}{
+		"PartitionKey": codec.info.Name,
+		"RowKey":       rowKey,
+		"DeletedAt":    now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, dberrors.NewDatabaseError("marshal", err)
+	}
+
+	return &pendingWrite{
+		codec:  codec,
+		value:  v,
+		rowKey: rowKey,
+		action: aztables.TransactionAction{
+			ActionType: aztables.TransactionTypeUpdateMerge,
+			Entity:     tombstone,
+			IfMatch:    &existing.ETag,
+		},
+		onApplied: func() {
+			v.FieldByName("DeletedAt").Set(reflect.ValueOf(gorm.DeletedAt{Time: now, Valid: true}))
+		},
+	}, nil
 }
 
-// List implements the Repository interface
+// Restore implements the Store interface for any registered model. The entity
+// is rewritten in replace mode, because merge mode cannot remove the DeletedAt
+// tombstone property. Restoring a live entity is a no-op and reports false.
+func (r *TableRepository) Restore(ctx context.Context, entity interface{}) (bool, error) {
+	codec, v, err := modelFor("entity", entity)
+	if err != nil {
+		return false, err
+	}
+
+	id := entity.(models.Entity).GetID()
+	if id == 0 {
+		return false, dberrors.NewDatabaseError("restore", dberrors.ErrValidation)
+	}
+
+	existing, err := r.client.GetEntity(ctx, codec.info.Name, strconv.FormatUint(uint64(id), 10), nil)
+	if err != nil {
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
+			return false, dberrors.NewDatabaseError("restore", dberrors.ErrNotFound)
+		}
+		return false, dberrors.NewDatabaseError("restore", err)
+	}
+
+	var existingData map[string]interface{}
+	if err := json.Unmarshal(existing.Value, &existingData); err != nil {
+		return false, dberrors.NewDatabaseError("unmarshal", err)
+	}
+
+	v.Set(reflect.Zero(v.Type()))
+	if err := codec.decode(existingData, v); err != nil {
+		return false, dberrors.NewDatabaseError("unmarshal", err)
+	}
+	entity.(models.Entity).SetID(id)
+	defaultVersion(entity)
+
+	if !isTombstoned(existingData) {
+		return false, nil
+	}
+
+	v.FieldByName("DeletedAt").Set(reflect.ValueOf(gorm.DeletedAt{}))
+	v.FieldByName("UpdatedAt").Set(reflect.ValueOf(time.Now().UTC()))
+	if ver, ok := entity.(models.Versionable); ok {
+		ver.SetVersion(ver.GetVersion() + 1)
+	}
+
+	entityBytes, err := encodeEntity(codec, v, id, nil)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.client.UpdateEntity(ctx, entityBytes, &aztables.UpdateEntityOptions{
+		IfMatch:    &existing.ETag,
+		UpdateMode: aztables.UpdateModeReplace,
+	})
+	if err != nil {
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == 412 {
+			return false, dberrors.NewDatabaseError("restore", errors.New("version mismatch"))
+		}
+		return false, dberrors.NewDatabaseError("restore", err)
+	}
+	return true, nil
+}
+
+// Purge implements the Store interface for any registered model. Tombstones
+// are stored as UTC RFC 3339 strings, so comparing them as strings in the
+// OData filter orders them chronologically.
+func (r *TableRepository) Purge(ctx context.Context, model interface{}, deletedBefore time.Time) (int64, error) {
+	codec, _, err := modelFor("model", model)
+	if err != nil {
+		return 0, err
+	}
+
+	query := newPartitionQuery(codec.info.Name)
+	if err := query.where(codec.byColumn["deleted_at"], "lt", deletedBefore); err != nil {
+		return 0, dberrors.NewDatabaseError("purge", err)
+	}
+	filter := query.String()
+	pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{Filter: &filter})
+
+	var purged int64
+	for pager.More() {
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			return purged, dberrors.NewDatabaseError("purge", err)
+		}
+		for _, entityBytes := range response.Entities {
+			var entityData map[string]interface{}
+			if err := json.Unmarshal(entityBytes, &entityData); err != nil {
+				return purged, dberrors.NewDatabaseError("unmarshal", err)
+			}
+			rowKey, _ := entityData["RowKey"].(string)
+			_, err := r.client.DeleteEntity(ctx, codec.info.Name, rowKey, nil)
+			if err != nil {
+				var respErr *azcore.ResponseError
+				if errors.As(err, &respErr) && respErr.StatusCode == 404 {
+					continue // Already purged by a concurrent caller
+				}
+				return purged, dberrors.NewDatabaseError("purge", err)
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// containsFilter is a case-insensitive substring match evaluated in memory,
+// since OData has no portable "contains" operator for Table Storage.
+type containsFilter struct {
+	field  *fieldCodec
+	needle string
+}
+
+// List implements the Store interface for any registered model. dest must be
+// a pointer to a slice of the model type. The top-level AND terms of a
+// models.Expr condition are sent to Table Storage as OData where possible and
+// evaluated in memory otherwise.
+//
+// With a *models.Cursor condition only one page is read, using Table Storage
+// continuation tokens, so the cost does not grow with the partition size.
+// Otherwise the whole partition is scanned, then sorted and paginated in
+// memory; a *models.Count condition is answered from the same scan. Counting
+// and sorting are not supported together with a cursor, since both need every
+// matching entity and would defeat the single-page read.
 func (r *TableRepository) List(ctx context.Context, dest interface{}, conditions ...interface{}) error {
-	items, ok := dest.(*[]models.Item)
-	if !ok {
-		return dberrors.NewDatabaseError("type_assertion", fmt.Errorf("dest must be *[]models.Item"))
+	dv := reflect.ValueOf(dest)
+	if dest == nil || dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Slice ||
+		dv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return dberrors.NewDatabaseError("type_assertion",
+			fmt.Errorf("dest must be a pointer to a slice of a registered model, got %T", dest))
+	}
+	sliceType := dv.Elem().Type()
+	codec, err := codecForType(sliceType.Elem())
+	if err != nil {
+		return dberrors.NewDatabaseError("type_assertion",
+			fmt.Errorf("dest must be a pointer to a slice of a registered model: %w", err))
 	}
 
 	// Process conditions
 	var (
-		result             []models.Item
-		pagination         *models.Pagination
-		nameContainsFilter string
+		pagination     *models.Pagination
+		cursor         *models.Cursor
+		count          *models.Count
+		order          models.Sort
+		orderFields    []*fieldCodec
+		contains       []containsFilter
+		inMemory       []models.Expr
+		includeDeleted bool
 	)
 
 	// Base filter for partition key
-	filterParts := []string{"PartitionKey eq 'items'"}
+	query := newPartitionQuery(codec.info.Name)
 
 	// Build filters from conditions
 	for _, condition := range conditions {
 		switch cond := condition.(type) {
 		case models.Filter:
-			switch cond.Field {
-			case "name":
-				name := cond.Value.(string)
-				if cond.Op == "exact" {
-					filterParts = append(filterParts, fmt.Sprintf("Name eq '%s'", name))
-				} else {
-					nameContainsFilter = strings.ToLower(name)
+			field, ok := codec.byColumn[cond.Field]
+			if !ok || !codec.info.FilterFields[cond.Field] {
+				return dberrors.NewDatabaseError("list", fmt.Errorf("%w: invalid filter field: %q", dberrors.ErrValidation, cond.Field))
+			}
+			switch cond.Op {
+			case "", "contains":
+				needle, ok := cond.Value.(string)
+				if !ok {
+					return dberrors.NewDatabaseError("list",
+						fmt.Errorf("%w: filter %q: contains needs a string, got %T", dberrors.ErrValidation, cond.Field, cond.Value))
+				}
+				contains = append(contains, containsFilter{field: field, needle: strings.ToLower(needle)})
+			default:
+				if err := query.where(field, cond.Op, cond.Value); err != nil {
+					return dberrors.NewDatabaseError("list", fmt.Errorf("%w: filter %w", dberrors.ErrValidation, err))
+				}
+			}
+		case models.Expr:
+			bound, err := models.BindExpr(cond, sliceType.Elem(), codec.info.FilterFields)
+			if err != nil {
+				return dberrors.NewDatabaseError("list", err)
+			}
+			for _, conjunct := range splitConjuncts(bound) {
+				odata, ok, err := odataExpr(codec, conjunct)
+				if err != nil {
+					return dberrors.NewDatabaseError("list", err)
 				}
-			case "price":
-				price := cond.Value.(float64)
-				if cond.Op == ">=" {
-					filterParts = append(filterParts, fmt.Sprintf("Price ge %f", price))
-				} else if cond.Op == "<=" {
-					filterParts = append(filterParts, fmt.Sprintf("Price le %f", price))
+				if ok {
+					query.whereExpr(odata)
+				} else {
+					inMemory = append(inMemory, conjunct)
 				}
 			}
 		case models.Pagination:
 			pagination = &cond
+		case *models.Cursor:
+			cursor = cond
+		case *models.Count:
+			count = cond
+		case models.Sort:
+			fields, err := resolveSort(codec, cond)
+			if err != nil {
+				return dberrors.NewDatabaseError("list", err)
+			}
+			order, orderFields = cond, fields
+		case models.IncludeDeleted:
+			includeDeleted = true
 		}
 	}
 
-	// Combine all filter parts with AND
-	filter := strings.Join(filterParts, " and ")
-
-	// Get pager for table query
-	pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{
-		Filter: &filter,
-	})
-
-	// Fetch and process all entities
-	for pager.More() {
-		response, err := pager.NextPage(ctx)
-		if err != nil {
-			return dberrors.NewDatabaseError("list", err)
-		}
+	filter := query.String()
 
-		for _, entityBytes := range response.Entities {
+	// decodeRows appends the entities of one response page that pass the
+	// in-memory filters to result.
+	result := reflect.MakeSlice(sliceType, 0, 0)
+	decodeRows := func(entities [][]byte) error {
+		for _, entityBytes := range entities {
 			var entityData map[string]interface{}
 			if err := json.Unmarshal(entityBytes, &entityData); err != nil {
 				return dberrors.NewDatabaseError("unmarshal", err)
 			}
 
+			// Outbox events share the partition; see WithEvents.
+			if rowKey, _ := entityData["RowKey"].(string); isOutboxRow(rowKey) {
+				continue
+			}
+
+			// Table Storage cannot filter on a missing property, so
+			// tombstones are skipped here rather than in the query.
+			if isTombstoned(entityData) && !includeDeleted {
+				continue
+			}
+
 			rowKey, ok := entityData["RowKey"].(string)
 			if !ok || rowKey == "" {
 				return dberrors.NewDatabaseError("list", fmt.Errorf("entity missing or invalid RowKey"))
@@ -432,85 +699,239 @@ func (r *TableRepository) List(ctx context.Context, dest interface{}, conditions
 				return dberrors.NewDatabaseError("list", fmt.Errorf("invalid RowKey %q: %w", rowKey, err))
 			}
 
-			createdAtStr, ok := entityData["CreatedAt"].(string)
-			if !ok || createdAtStr == "" {
-				return dberrors.NewDatabaseError("list", fmt.Errorf("entity missing or invalid CreatedAt"))
-			}
-			createdAt, err := time.Parse(time.RFC3339, createdAtStr)
-			if err != nil {
-				return dberrors.NewDatabaseError("list", fmt.Errorf("invalid CreatedAt %q: %w", createdAtStr, err))
+			ptr := reflect.New(sliceType.Elem())
+			if err := codec.decode(entityData, ptr.Elem()); err != nil {
+				return dberrors.NewDatabaseError("list", err)
 			}
+			ptr.Interface().(models.Entity).SetID(uint(id))
+			defaultVersion(ptr.Interface())
 
-			updatedAtStr, ok := entityData["UpdatedAt"].(string)
-			if !ok || updatedAtStr == "" {
-				return dberrors.NewDatabaseError("list", fmt.Errorf("entity missing or invalid UpdatedAt"))
+			// Apply contains filters and untranslatable expressions in memory
+			if !matchesContains(ptr.Elem(), contains) {
+				continue
 			}
-			updatedAt, err := time.Parse(time.RFC3339, updatedAtStr)
-			if err != nil {
-				return dberrors.NewDatabaseError("list", fmt.Errorf("invalid UpdatedAt %q: %w", updatedAtStr, err))
-			}
-
-			name, ok := entityData["Name"].(string)
-			if !ok {
-				return dberrors.NewDatabaseError("list", fmt.Errorf("entity missing or invalid Name"))
+			matched := true
+			for _, e := range inMemory {
+				if matched, err = models.MatchExpr(e, ptr.Interface()); err != nil {
+					return dberrors.NewDatabaseError("list", err)
+				}
+				if !matched {
+					break
+				}
 			}
-
-			price, ok := entityData["Price"].(float64)
-			if !ok {
-				return dberrors.NewDatabaseError("list", fmt.Errorf("entity missing or invalid Price"))
+			if !matched {
+				continue
 			}
 
-			item := models.Item{
-				Base: models.Base{
-					ID:        uint(id),
-					CreatedAt: createdAt,
-					UpdatedAt: updatedAt,
-				},
-				Name:  name,
-				Price: price,
-			}
+			result = reflect.Append(result, ptr.Elem())
+		}
+		return nil
+	}
 
-			// Populate Version if present; default to 1 when absent or invalid
-			if v, ok := entityData["Version"]; ok {
-				if vf, ok := v.(float64); ok {
-					item.Version = uint(vf)
-				} else {
-					item.Version = 1
-				}
-			} else {
-				item.Version = 1
-			}
+	if cursor != nil {
+		if count != nil {
+			return dberrors.NewDatabaseError("list",
+				fmt.Errorf("%w: total count is not supported with cursor pagination", dberrors.ErrValidation))
+		}
+		if len(order) > 0 {
+			return dberrors.NewDatabaseError("list",
+				fmt.Errorf("%w: sort is not supported with cursor pagination", dberrors.ErrValidation))
+		}
+		if err := r.listPage(ctx, filter, cursor, func(entities [][]byte) (int, error) {
+			before := result.Len()
+			err := decodeRows(entities)
+			return result.Len() - before, err
+		}); err != nil {
+			return err
+		}
+		dv.Elem().Set(result)
+		return nil
+	}
 
-			// Apply name contains filter if specified
-			if nameContainsFilter != "" {
-				if !strings.Contains(strings.ToLower(item.Name), nameContainsFilter) {
-					continue
-				}
-			}
+	// Get pager for table query
+	pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{
+		Filter: &filter,
+	})
 
-			result = append(result, item)
+	// Fetch and process all entities
+	for pager.More() {
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			return dberrors.NewDatabaseError("list", err)
+		}
+		if err := decodeRows(response.Entities); err != nil {
+			return err
 		}
 	}
 
+	if count != nil {
+		count.Total = int64(result.Len())
+	}
+	if len(order) > 0 {
+		sortRows(result, order, orderFields)
+	}
+
 	// Apply pagination after all filtering
 	if pagination != nil {
 		start := pagination.Offset
-		if start >= len(result) {
-			*items = []models.Item{}
+		if start > result.Len() {
+			start = result.Len()
+		}
+		end := start + pagination.Limit
+		if end > result.Len() {
+			end = result.Len()
+		}
+		result = result.Slice(start, end)
+	}
+
+	dv.Elem().Set(result)
+	return nil
+}
+
+// continuationToken is the decoded form of a Table Storage cursor token.
+type continuationToken struct {
+	PartitionKey string `json:"pk"`
+	RowKey       string `json:"rk"`
+}
+
+// listPage reads up to cursor.Limit matching entities starting at cursor.Token
+// and stores the continuation for the following page in cursor.Next. accept
+// decodes one response page and reports how many of its entities matched the
+// in-memory filters. Because entities can be skipped after they are fetched,
+// the remaining count is re-requested with $top until the page is full or the
+// partition is exhausted; the continuation therefore never points into the
+// middle of a page that was partially consumed.
+func (r *TableRepository) listPage(ctx context.Context, filter string, cursor *models.Cursor, accept func([][]byte) (int, error)) error {
+	if cursor.Limit <= 0 {
+		return dberrors.NewDatabaseError("list", fmt.Errorf("%w: cursor limit must be positive", dberrors.ErrValidation))
+	}
+
+	var nextPK, nextRK *string
+	if cursor.Token != "" {
+		var token continuationToken
+		if err := models.DecodeCursor(cursor.Token, &token); err != nil || token.PartitionKey == "" {
+			return dberrors.NewDatabaseError("list", fmt.Errorf("%w: invalid cursor", dberrors.ErrValidation))
+		}
+		nextPK, nextRK = &token.PartitionKey, &token.RowKey
+	}
+
+	for remaining := cursor.Limit; remaining > 0; {
+		// Only the continuation of the last response read points past it.
+		cursor.Next = ""
+		top := int32(remaining)
+		pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{
+			Filter:           &filter,
+			Top:              &top,
+			NextPartitionKey: nextPK,
+			NextRowKey:       nextRK,
+		})
+		if !pager.More() {
 			return nil
 		}
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			return dberrors.NewDatabaseError("list", err)
+		}
+		matched, err := accept(response.Entities)
+		if err != nil {
+			return err
+		}
+		remaining -= matched
 
-		end := start + pagination.Limit
-		if end > len(result) {
-			end = len(result)
+		if response.NextPartitionKey == nil || *response.NextPartitionKey == "" {
+			return nil
 		}
-		result = result[start:end]
+		nextPK, nextRK = response.NextPartitionKey, response.NextRowKey
+		token := continuationToken{PartitionKey: *nextPK}
+		if nextRK != nil {
+			token.RowKey = *nextRK
+		}
+		cursor.Next = models.EncodeCursor(token)
 	}
+	return nil
+}
+
+// ensureUnique returns ErrDuplicateKey if another entity in the partition
+// already holds the value of any field declared unique on the model.
+func (r *TableRepository) ensureUnique(ctx context.Context, op string, codec *modelCodec, v reflect.Value, id uint) error {
+	var clauses []string
+	for _, f := range codec.uniqueFields() {
+		clause, err := odataComparison(f, "eq", v.FieldByIndex(f.index).Interface())
+		if err != nil {
+			return dberrors.NewDatabaseError(op, err)
+		}
+		clauses = append(clauses, clause)
+	}
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	query := newPartitionQuery(codec.info.Name)
+	query.whereAny(clauses)
+	filter := query.String()
+	pager := r.client.NewListEntitiesPager(&aztables.ListEntitiesOptions{Filter: &filter})
 
-	*items = result
+	ownKey := strconv.FormatUint(uint64(id), 10)
+	for pager.More() {
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			return dberrors.NewDatabaseError(op, err)
+		}
+		for _, entityBytes := range response.Entities {
+			var entityData map[string]interface{}
+			if err := json.Unmarshal(entityBytes, &entityData); err != nil {
+				return dberrors.NewDatabaseError("unmarshal", err)
+			}
+			if rowKey, _ := entityData["RowKey"].(string); rowKey != ownKey {
+				return dberrors.NewDatabaseError(op, dberrors.ErrDuplicateKey)
+			}
+		}
+	}
 	return nil
 }
 
+// matchesContains reports whether v satisfies every in-memory contains filter.
+func matchesContains(v reflect.Value, filters []containsFilter) bool {
+	for _, f := range filters {
+		s := fmt.Sprint(v.FieldByIndex(f.field.index).Interface())
+		if !strings.Contains(strings.ToLower(s), f.needle) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTombstoned reports whether a stored entity carries a DeletedAt tombstone.
+func isTombstoned(entityData map[string]interface{}) bool {
+	deletedAt, ok := entityData["DeletedAt"].(string)
+	return ok && deletedAt != ""
+}
+
+// storedVersion returns the Version property of a stored entity. Legacy rows
+// that predate versioning, and non-positive values, count as version 1,
+// consistent with the model default and FindByID behavior.
+func storedVersion(entityData map[string]interface{}) uint {
+	if n, err := toFloat(entityData["Version"]); err == nil && n > 0 {
+		return uint(n)
+	}
+	return 1
+}
+
+// setTimestamps assigns the CreatedAt and UpdatedAt fields promoted from models.Base.
+func setTimestamps(v reflect.Value, createdAt, updatedAt time.Time) {
+	v.FieldByName("CreatedAt").Set(reflect.ValueOf(createdAt))
+	v.FieldByName("UpdatedAt").Set(reflect.ValueOf(updatedAt))
+}
+
+// defaultVersion sets the version of Versionable entities to 1 when the stored
+// value is missing or invalid, keeping optimistic-lock semantics consistent
+// with the GORM repository.
+func defaultVersion(entity interface{}) {
+	if ver, ok := entity.(models.Versionable); ok && ver.GetVersion() == 0 {
+		ver.SetVersion(1)
+	}
+}
+
 // Ping implements the Repository interface
 func (r *TableRepository) Ping(ctx context.Context) error {
 	// List tables to check connectivity
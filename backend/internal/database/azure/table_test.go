@@ -2,13 +2,19 @@ package azure_test
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"testing"
+	"time"
 
 	"backend/internal/database/azure"
 	"backend/internal/models"
+	"backend/pkg/dberrors"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/aztables"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type testPager struct {
@@ -45,6 +51,7 @@ type mockClient struct {
 	getEntity    func(context.Context, string, string, *aztables.GetEntityOptions) (aztables.GetEntityResponse, error)
 	updateEntity func(context.Context, []byte, *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error)
 	deleteEntity func(context.Context, string, string, *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error)
+	submit       func(context.Context, []aztables.TransactionAction, *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error)
 	pager        *testPager
 }
 
@@ -68,6 +75,10 @@ func (m *mockClient) NewListEntitiesPager(options *aztables.ListEntitiesOptions)
 	return m.pager
 }
 
+func (m *mockClient) SubmitTransaction(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+	return m.submit(ctx, actions, options)
+}
+
 func TestTableClientOperations(t *testing.T) {
 	t.Parallel()
 
@@ -192,9 +203,18 @@ func TestTableClientOperations(t *testing.T) {
 	t.Run("can delete an entity", func(t *testing.T) {
 		t.Parallel()
 
+		var tombstone map[string]interface{}
+		var updateMode aztables.UpdateMode
 		mockClient := &mockClient{
-			deleteEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error) {
-				return aztables.DeleteEntityResponse{}, nil
+			getEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+				return aztables.GetEntityResponse{
+					Value: []byte(`{"PartitionKey":"items","RowKey":"1","Name":"test","Price":10.5,"Version":1}`),
+				}, nil
+			},
+			updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+				updateMode = options.UpdateMode
+				assert.NoError(t, json.Unmarshal(entity, &tombstone))
+				return aztables.UpdateEntityResponse{}, nil
 			},
 		}
 
@@ -208,5 +228,881 @@ func TestTableClientOperations(t *testing.T) {
 		item.ID = 1
 		err := repo.Delete(context.Background(), item)
 		assert.NoError(t, err)
+		assert.Equal(t, aztables.UpdateModeMerge, updateMode, "soft delete must not rewrite other properties")
+		assert.NotEmpty(t, tombstone["DeletedAt"])
+		assert.True(t, item.DeletedAt.Valid)
+	})
+
+	t.Run("can create and retrieve a user", func(t *testing.T) {
+		t.Parallel()
+
+		var stored []byte
+		mockClient := &mockClient{
+			pager: &testPager{},
+			addEntity: func(ctx context.Context, entity []byte, options *aztables.AddEntityOptions) (aztables.AddEntityResponse, error) {
+				stored = entity
+				return aztables.AddEntityResponse{}, nil
+			},
+			getEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+				assert.Equal(t, "users", partitionKey)
+				return aztables.GetEntityResponse{Value: stored}, nil
+			},
+		}
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(mockClient)
+
+		user := &models.User{Username: "alice", Email: "alice@example.com", Name: "Alice"}
+		assert.NoError(t, repo.Create(context.Background(), user))
+		assert.NotZero(t, user.ID)
+		assert.Contains(t, string(stored), `"PartitionKey":"users"`)
+
+		var retrieved models.User
+		assert.NoError(t, repo.FindByID(context.Background(), user.ID, &retrieved))
+		assert.Equal(t, "alice", retrieved.Username)
+		assert.Equal(t, "alice@example.com", retrieved.Email)
+		assert.Equal(t, "Alice", retrieved.Name)
+	})
+
+	t.Run("create user rejects duplicate username or email", func(t *testing.T) {
+		t.Parallel()
+
+		var gotFilter string
+		mockClient := &mockClient{
+			pager: &testPager{
+				pages: [][]byte{
+					[]byte(`{"PartitionKey":"users","RowKey":"7","Username":"alice","Email":"alice@example.com","CreatedAt":"2021-01-01T00:00:00Z","UpdatedAt":"2021-01-01T00:00:00Z"}`),
+				},
+			},
+		}
+		listingClient := &filterCapturingClient{mockClient: mockClient, filter: &gotFilter}
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(listingClient)
+
+		user := &models.User{Username: "o'brien", Email: "alice@example.com"}
+		err := repo.Create(context.Background(), user)
+		assert.ErrorIs(t, err, dberrors.ErrDuplicateKey)
+		assert.Contains(t, gotFilter, "Username eq 'o''brien'", "quotes in literals must be escaped")
+	})
+}
+
+// filterCapturingClient records the OData filter passed to NewListEntitiesPager.
+type filterCapturingClient struct {
+	*mockClient
+	filter *string
+}
+
+func (c *filterCapturingClient) NewListEntitiesPager(options *aztables.ListEntitiesOptions) azure.ListEntitiesPager {
+	if options != nil && options.Filter != nil {
+		*c.filter = *options.Filter
+	}
+	return c.mockClient.NewListEntitiesPager(options)
+}
+
+// gadget is registered only by these tests to check that the table repository
+// maps any registered model, not just the built-in ones.
+type gadget struct {
+	models.Base
+	Serial   string `gorm:"uniqueIndex"`
+	Weight   float64
+	Count    int
+	Active   bool
+	Tags     []string `gorm:"serializer:json"`
+	Internal string   `gorm:"-"`
+}
+
+func init() {
+	models.RegisterModel[gadget]("gadgets", "serial", "count", "active")
+}
+
+func TestTableRepository_RegisteredModel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-trips every supported field type", func(t *testing.T) {
+		t.Parallel()
+
+		var stored []byte
+		mockClient := &mockClient{
+			pager: &testPager{},
+			addEntity: func(ctx context.Context, entity []byte, options *aztables.AddEntityOptions) (aztables.AddEntityResponse, error) {
+				stored = entity
+				return aztables.AddEntityResponse{}, nil
+			},
+			getEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+				assert.Equal(t, "gadgets", partitionKey)
+				return aztables.GetEntityResponse{Value: stored}, nil
+			},
+		}
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(mockClient)
+
+		g := &gadget{Serial: "g-1", Weight: 1.5, Count: 7, Active: true, Tags: []string{"a", "b"}, Internal: "secret"}
+		assert.NoError(t, repo.Create(context.Background(), g))
+		assert.NotZero(t, g.ID)
+		assert.NotContains(t, string(stored), "secret", "fields tagged gorm:\"-\" must not be stored")
+		assert.Contains(t, string(stored), `"Weight@odata.type":"Edm.Double"`, "whole floats must not be stored as Edm.Int32")
+
+		var got gadget
+		assert.NoError(t, repo.FindByID(context.Background(), g.ID, &got))
+		assert.Equal(t, g.ID, got.ID)
+		assert.Equal(t, "g-1", got.Serial)
+		assert.InDelta(t, 1.5, got.Weight, 0.001)
+		assert.Equal(t, 7, got.Count)
+		assert.True(t, got.Active)
+		assert.Equal(t, []string{"a", "b"}, got.Tags)
+		assert.False(t, got.CreatedAt.IsZero())
+	})
+
+	t.Run("list filters on registered columns only", func(t *testing.T) {
+		t.Parallel()
+
+		var gotFilter string
+		mockClient := &mockClient{
+			pager: &testPager{
+				pages: [][]byte{
+					[]byte(`{"PartitionKey":"gadgets","RowKey":"1","Serial":"g-1","Count":3}`),
+				},
+			},
+		}
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&filterCapturingClient{mockClient: mockClient, filter: &gotFilter})
+
+		var gadgets []gadget
+		err := repo.List(context.Background(), &gadgets, models.Filter{Field: "count", Op: ">=", Value: 2})
+		assert.NoError(t, err)
+		assert.Len(t, gadgets, 1)
+		assert.Equal(t, uint(1), gadgets[0].ID)
+		assert.Equal(t, "PartitionKey eq 'gadgets' and Count ge 2", gotFilter)
+
+		err = repo.List(context.Background(), &gadgets, models.Filter{Field: "weight", Op: "exact", Value: 1.0})
+		assert.ErrorContains(t, err, "invalid filter field")
+	})
+}
+
+func TestTableRepository_SoftDelete(t *testing.T) {
+	t.Parallel()
+
+	const (
+		live    = `{"PartitionKey":"items","RowKey":"1","Name":"live","Price":1,"Version":1}`
+		deleted = `{"PartitionKey":"items","RowKey":"2","Name":"deleted","Price":2,"Version":3,"DeletedAt":"2021-01-01T00:00:00Z"}`
+	)
+
+	getByRowKey := func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+		if rowKey == "2" {
+			return aztables.GetEntityResponse{Value: []byte(deleted)}, nil
+		}
+		return aztables.GetEntityResponse{Value: []byte(live)}, nil
+	}
+
+	t.Run("find hides tombstoned entities by default", func(t *testing.T) {
+		t.Parallel()
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{getEntity: getByRowKey})
+
+		var item models.Item
+		err := repo.FindByID(context.Background(), 2, &item)
+		assert.ErrorIs(t, err, dberrors.ErrNotFound)
+
+		err = repo.FindByID(context.Background(), 2, &item, models.IncludeDeleted{})
+		assert.NoError(t, err)
+		assert.Equal(t, "deleted", item.Name)
+		assert.True(t, item.DeletedAt.Valid)
+	})
+
+	t.Run("list skips tombstoned entities by default", func(t *testing.T) {
+		t.Parallel()
+
+		newRepo := func() *azure.TableRepository {
+			repo := azure.NewTestTableRepository("testtable")
+			repo.SetTestClient(&mockClient{pager: &testPager{pages: [][]byte{[]byte(live), []byte(deleted)}}})
+			return repo
+		}
+
+		var items []models.Item
+		assert.NoError(t, newRepo().List(context.Background(), &items))
+		assert.Len(t, items, 1)
+		assert.Equal(t, "live", items[0].Name)
+
+		assert.NoError(t, newRepo().List(context.Background(), &items, models.IncludeDeleted{}))
+		assert.Len(t, items, 2)
+	})
+
+	t.Run("delete and update of a tombstoned entity report not found", func(t *testing.T) {
+		t.Parallel()
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{getEntity: getByRowKey})
+
+		item := &models.Item{Base: models.Base{ID: 2}, Name: "x", Price: 1, Version: 3}
+		assert.ErrorIs(t, repo.Delete(context.Background(), item), dberrors.ErrNotFound)
+		assert.ErrorIs(t, repo.Update(context.Background(), item), dberrors.ErrNotFound)
+	})
+
+	t.Run("delete with a stale version is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		deletes := 0
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			getEntity: getByRowKey,
+			updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+				deletes++
+				return aztables.UpdateEntityResponse{}, nil
+			},
+		})
+
+		err := repo.Delete(context.Background(), &models.Item{Base: models.Base{ID: 1}, Version: 2})
+		assert.ErrorContains(t, err, "version mismatch")
+		assert.Zero(t, deletes)
+
+		assert.NoError(t, repo.Delete(context.Background(), &models.Item{Base: models.Base{ID: 1}, Version: 1}))
+		assert.NoError(t, repo.Delete(context.Background(), &models.Item{Base: models.Base{ID: 1}}))
+		assert.Equal(t, 2, deletes)
+	})
+
+	t.Run("restore replaces the entity without the tombstone", func(t *testing.T) {
+		t.Parallel()
+
+		var written map[string]interface{}
+		var updateMode aztables.UpdateMode
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			getEntity: getByRowKey,
+			updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+				updateMode = options.UpdateMode
+				assert.NoError(t, json.Unmarshal(entity, &written))
+				return aztables.UpdateEntityResponse{}, nil
+			},
+		})
+
+		item := &models.Item{Base: models.Base{ID: 2}}
+		restored, err := repo.Restore(context.Background(), item)
+		assert.NoError(t, err)
+		assert.True(t, restored)
+		assert.Equal(t, aztables.UpdateModeReplace, updateMode)
+		assert.NotContains(t, written, "DeletedAt")
+		assert.Equal(t, "deleted", item.Name)
+		assert.Equal(t, uint(4), item.Version, "restore is a modification and bumps the version")
+		assert.False(t, item.DeletedAt.Valid)
+	})
+
+	t.Run("restoring a live entity writes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			getEntity: getByRowKey,
+			updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+				t.Error("a live entity was rewritten")
+				return aztables.UpdateEntityResponse{}, nil
+			},
+		})
+
+		item := &models.Item{Base: models.Base{ID: 1}}
+		restored, err := repo.Restore(context.Background(), item)
+		assert.NoError(t, err)
+		assert.False(t, restored)
+		assert.Equal(t, "live", item.Name)
 	})
+
+	t.Run("purge deletes tombstones older than the cutoff", func(t *testing.T) {
+		t.Parallel()
+
+		var gotFilter string
+		var purgedKeys []string
+		base := &mockClient{
+			pager: &testPager{pages: [][]byte{[]byte(deleted)}},
+			deleteEntity: func(ctx context.Context, partitionKey, rowKey string, options *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error) {
+				purgedKeys = append(purgedKeys, partitionKey+"/"+rowKey)
+				return aztables.DeleteEntityResponse{}, nil
+			},
+		}
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&filterCapturingClient{mockClient: base, filter: &gotFilter})
+
+		cutoff := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		n, err := repo.Purge(context.Background(), &models.Item{}, cutoff)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.Equal(t, []string{"items/2"}, purgedKeys)
+		assert.Equal(t, "PartitionKey eq 'items' and DeletedAt lt '2022-01-01T00:00:00Z'", gotFilter)
+	})
+}
+
+// continuationClient serves scripted query pages keyed by the continuation
+// row key, like Table Storage does when a query is resumed.
+type continuationClient struct {
+	mockClient
+	pages map[string]aztables.ListEntitiesResponse // keyed by NextRowKey ("" for the first page)
+	tops  []int32
+}
+
+func (c *continuationClient) NewListEntitiesPager(options *aztables.ListEntitiesOptions) azure.ListEntitiesPager {
+	key := ""
+	if options.NextRowKey != nil {
+		key = *options.NextRowKey
+	}
+	if options.Top != nil {
+		c.tops = append(c.tops, *options.Top)
+	}
+	return &responsePager{response: c.pages[key]}
+}
+
+// responsePager returns a single response page.
+type responsePager struct {
+	response aztables.ListEntitiesResponse
+	done     bool
+}
+
+func (p *responsePager) More() bool { return !p.done }
+
+func (p *responsePager) NextPage(ctx context.Context) (aztables.ListEntitiesResponse, error) {
+	p.done = true
+	return p.response, nil
+}
+
+func TestTableRepository_CursorPagination(t *testing.T) {
+	t.Parallel()
+
+	entity := func(id string, deleted bool) []byte {
+		if deleted {
+			return []byte(`{"PartitionKey":"items","RowKey":"` + id + `","Name":"item` + id + `","DeletedAt":"2021-01-01T00:00:00Z"}`)
+		}
+		return []byte(`{"PartitionKey":"items","RowKey":"` + id + `","Name":"item` + id + `"}`)
+	}
+	continuation := func(rk string) (*string, *string) {
+		pk := "items"
+		return &pk, &rk
+	}
+
+	newClient := func() *continuationClient {
+		pk3, rk3 := continuation("3")
+		pk5, rk5 := continuation("5")
+		return &continuationClient{pages: map[string]aztables.ListEntitiesResponse{
+			"":  {Entities: [][]byte{entity("1", false), entity("2", true)}, NextPartitionKey: pk3, NextRowKey: rk3},
+			"3": {Entities: [][]byte{entity("3", false), entity("4", false)}, NextPartitionKey: pk5, NextRowKey: rk5},
+			"5": {Entities: [][]byte{entity("5", false)}},
+		}}
+	}
+
+	t.Run("pages follow continuation tokens and refill skipped rows", func(t *testing.T) {
+		t.Parallel()
+		client := newClient()
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(client)
+
+		var items []models.Item
+		cursor := &models.Cursor{Limit: 2}
+		assert.NoError(t, repo.List(context.Background(), &items, cursor))
+		assert.Equal(t, []string{"item1", "item3"}, []string{items[0].Name, items[1].Name})
+		assert.Equal(t, []int32{2, 1}, client.tops, "the tombstoned row must be replaced with a smaller $top")
+		assert.NotEmpty(t, cursor.Next)
+
+		cursor = &models.Cursor{Token: cursor.Next, Limit: 2}
+		assert.NoError(t, repo.List(context.Background(), &items, cursor))
+		assert.Len(t, items, 1)
+		assert.Equal(t, "item5", items[0].Name)
+		assert.Empty(t, cursor.Next, "no continuation after the last page")
+	})
+
+	t.Run("a page ending with the last response has no next cursor", func(t *testing.T) {
+		t.Parallel()
+		client := newClient()
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(client)
+
+		var items []models.Item
+		cursor := &models.Cursor{Limit: 10}
+		assert.NoError(t, repo.List(context.Background(), &items, cursor))
+		assert.Len(t, items, 4)
+		assert.Equal(t, []int32{10, 9, 7}, client.tops)
+		assert.Empty(t, cursor.Next, "the continuation of an earlier response must not be kept")
+	})
+
+	t.Run("invalid cursor is a validation error", func(t *testing.T) {
+		t.Parallel()
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(newClient())
+
+		var items []models.Item
+		err := repo.List(context.Background(), &items, &models.Cursor{Token: "%%%", Limit: 2})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+}
+
+func TestTableRepository_Count(t *testing.T) {
+	t.Parallel()
+
+	t.Run("total comes from the same scan as the page", func(t *testing.T) {
+		t.Parallel()
+		// testPager is exhausted after one pass, so a second scan for the
+		// count would report zero.
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{pager: &testPager{pages: [][]byte{
+			[]byte(`{"PartitionKey":"items","RowKey":"1","Name":"apple","Price":1}`),
+			[]byte(`{"PartitionKey":"items","RowKey":"2","Name":"banana","Price":2}`),
+			[]byte(`{"PartitionKey":"items","RowKey":"3","Name":"apricot","Price":3}`),
+			[]byte(`{"PartitionKey":"items","RowKey":"4","Name":"avocado","Price":4,"DeletedAt":"2021-01-01T00:00:00Z"}`),
+		}}})
+
+		var items []models.Item
+		count := &models.Count{}
+		err := repo.List(context.Background(), &items,
+			models.Filter{Field: "name", Op: "contains", Value: "ap"},
+			models.Pagination{Limit: 1, Offset: 1},
+			count,
+		)
+		assert.NoError(t, err)
+		assert.Len(t, items, 1)
+		assert.Equal(t, "apricot", items[0].Name)
+		assert.Equal(t, int64(2), count.Total, "tombstones and filtered rows are not counted")
+	})
+
+	t.Run("count with a cursor is a validation error", func(t *testing.T) {
+		t.Parallel()
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{pager: &testPager{}})
+
+		var items []models.Item
+		err := repo.List(context.Background(), &items, &models.Cursor{Limit: 2}, &models.Count{})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+}
+
+func TestTableRepository_Sort(t *testing.T) {
+	t.Parallel()
+
+	// RowKeys come back in lexical order, so "10" precedes "2".
+	rows := [][]byte{
+		[]byte(`{"PartitionKey":"items","RowKey":"10","Name":"cherry","Price":2}`),
+		[]byte(`{"PartitionKey":"items","RowKey":"2","Name":"apple","Price":2}`),
+		[]byte(`{"PartitionKey":"items","RowKey":"3","Name":"banana","Price":5}`),
+		[]byte(`{"PartitionKey":"items","RowKey":"4","Name":"apple","Price":1}`),
+	}
+
+	tests := []struct {
+		name    string
+		order   models.Sort
+		wantIDs []uint
+		wantErr bool
+	}{
+		{name: "price descending, ties by id", order: models.Sort{{Field: "price", Desc: true}}, wantIDs: []uint{3, 2, 10, 4}},
+		{name: "name then price descending", order: models.Sort{{Field: "name"}, {Field: "price", Desc: true}}, wantIDs: []uint{2, 4, 3, 10}},
+		{name: "id is numeric, not lexical", order: models.Sort{{Field: "id"}}, wantIDs: []uint{2, 3, 4, 10}},
+		{name: "field not whitelisted", order: models.Sort{{Field: "description"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			repo := azure.NewTestTableRepository("testtable")
+			repo.SetTestClient(&mockClient{pager: &testPager{pages: rows}})
+
+			var items []models.Item
+			err := repo.List(context.Background(), &items, tt.order)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, dberrors.ErrValidation)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]uint, len(items))
+			for i, item := range items {
+				ids[i] = item.ID
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+
+	t.Run("sort is applied before pagination", func(t *testing.T) {
+		t.Parallel()
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{pager: &testPager{pages: rows}})
+
+		var items []models.Item
+		err := repo.List(context.Background(), &items, models.Sort{{Field: "price"}}, models.Pagination{Limit: 1})
+		assert.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, uint(4), items[0].ID)
+	})
+}
+
+func TestTableRepository_FilterExpr(t *testing.T) {
+	t.Parallel()
+
+	rows := [][]byte{
+		[]byte(`{"PartitionKey":"items","RowKey":"1","Name":"Foo bar","Price":12}`),
+		[]byte(`{"PartitionKey":"items","RowKey":"2","Name":"barista","Price":15}`),
+		[]byte(`{"PartitionKey":"items","RowKey":"3","Name":"other","Price":20}`),
+	}
+
+	tests := []struct {
+		name       string
+		filter     string
+		wantFilter string
+		wantIDs    []uint
+		wantErr    bool
+	}{
+		{
+			name:       "comparisons are pushed down",
+			filter:     `price gt 10 and name in ("a", "o'b") and price between 1 and 2.5`,
+			wantFilter: "PartitionKey eq 'items' and (Price gt 10.0) and ((Name eq 'a' or Name eq 'o''b')) and ((Price ge 1.0 and Price le 2.5))",
+			wantIDs:    []uint{1, 2, 3}, // the mock pager does not evaluate the filter
+		},
+		{
+			name:       "text operators are evaluated in memory",
+			filter:     `price gt 10 and (name contains "FOO" or name startswith "bar")`,
+			wantFilter: "PartitionKey eq 'items' and (Price gt 10.0)",
+			wantIDs:    []uint{1, 2},
+		},
+		{
+			name:       "not over a pushed-down comparison",
+			filter:     `not (price eq 20)`,
+			wantFilter: "PartitionKey eq 'items' and (not (Price eq 20.0))",
+			wantIDs:    []uint{1, 2, 3},
+		},
+		{
+			name:    "field not whitelisted",
+			filter:  `version eq 1`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var gotFilter string
+			repo := azure.NewTestTableRepository("testtable")
+			repo.SetTestClient(&filterCapturingClient{mockClient: &mockClient{pager: &testPager{pages: rows}}, filter: &gotFilter})
+
+			expr, err := models.ParseFilter(tt.filter)
+			require.NoError(t, err)
+			var items []models.Item
+			err = repo.List(context.Background(), &items, expr)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, dberrors.ErrValidation)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFilter, gotFilter)
+			ids := make([]uint, len(items))
+			for i, item := range items {
+				ids[i] = item.ID
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestTableRepository_ODataFilterSafety(t *testing.T) {
+	t.Parallel()
+
+	name := "o'brien"
+
+	tests := []struct {
+		name       string
+		dest       interface{}
+		condition  models.Filter
+		wantFilter string
+		wantErr    bool
+	}{
+		{
+			name:       "quote cannot close the literal",
+			dest:       &[]models.Item{},
+			condition:  models.Filter{Field: "name", Op: "exact", Value: "x' or PartitionKey ne 'items"},
+			wantFilter: "PartitionKey eq 'items' and Name eq 'x'' or PartitionKey ne ''items'",
+		},
+		{
+			name:       "tautology stays inside the literal",
+			dest:       &[]models.Item{},
+			condition:  models.Filter{Field: "name", Op: "exact", Value: "' or 1 eq 1 or Name eq '"},
+			wantFilter: "PartitionKey eq 'items' and Name eq ''' or 1 eq 1 or Name eq '''",
+		},
+		{
+			name:       "pointer values are dereferenced",
+			dest:       &[]models.Item{},
+			condition:  models.Filter{Field: "name", Op: "gt", Value: &name},
+			wantFilter: "PartitionKey eq 'items' and Name gt 'o''brien'",
+		},
+		{
+			name:       "whole number for a double field gets a decimal point",
+			dest:       &[]models.Item{},
+			condition:  models.Filter{Field: "price", Op: ">=", Value: 10},
+			wantFilter: "PartitionKey eq 'items' and Price ge 10.0",
+		},
+		{
+			name:       "large integers are Edm.Int64 literals",
+			dest:       &[]gadget{},
+			condition:  models.Filter{Field: "count", Op: "exact", Value: 3e10},
+			wantFilter: "PartitionKey eq 'gadgets' and Count eq 30000000000L",
+		},
+		{
+			name:       "booleans",
+			dest:       &[]gadget{},
+			condition:  models.Filter{Field: "active", Op: "ne", Value: true},
+			wantFilter: "PartitionKey eq 'gadgets' and Active ne true",
+		},
+		{name: "string for a double field", dest: &[]models.Item{}, condition: models.Filter{Field: "price", Op: "exact", Value: "10 or true"}, wantErr: true},
+		{name: "number for a string field", dest: &[]models.Item{}, condition: models.Filter{Field: "name", Op: "exact", Value: 5}, wantErr: true},
+		{name: "fraction for an integer field", dest: &[]gadget{}, condition: models.Filter{Field: "count", Op: "exact", Value: 1.5}, wantErr: true},
+		{name: "string for a bool field", dest: &[]gadget{}, condition: models.Filter{Field: "active", Op: "exact", Value: "true"}, wantErr: true},
+		{name: "NaN", dest: &[]models.Item{}, condition: models.Filter{Field: "price", Op: ">=", Value: math.NaN()}, wantErr: true},
+		{name: "nil value", dest: &[]models.Item{}, condition: models.Filter{Field: "name", Op: "exact", Value: nil}, wantErr: true},
+		{name: "unknown operator", dest: &[]models.Item{}, condition: models.Filter{Field: "name", Op: "like", Value: "x"}, wantErr: true},
+		{name: "operator injection", dest: &[]models.Item{}, condition: models.Filter{Field: "name", Op: "eq 'a' or Name eq", Value: "x"}, wantErr: true},
+		{name: "field injection", dest: &[]models.Item{}, condition: models.Filter{Field: "name eq 'a' or Name", Op: "exact", Value: "x"}, wantErr: true},
+		{name: "contains with a non-string", dest: &[]models.Item{}, condition: models.Filter{Field: "name", Value: 5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var gotFilter string
+			repo := azure.NewTestTableRepository("testtable")
+			repo.SetTestClient(&filterCapturingClient{mockClient: &mockClient{pager: &testPager{}}, filter: &gotFilter})
+
+			err := repo.List(context.Background(), tt.dest, tt.condition)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, dberrors.ErrValidation)
+				assert.Empty(t, gotFilter, "no query may be sent")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFilter, gotFilter)
+		})
+	}
+}
+
+func TestTableRepository_Batch(t *testing.T) {
+	t.Parallel()
+
+	// liveItem answers GetEntity with a live item at version 1.
+	liveItem := func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+		return aztables.GetEntityResponse{
+			ETag:  azcore.ETag("etag-" + rowKey),
+			Value: []byte(`{"PartitionKey":"items","RowKey":"` + rowKey + `","Name":"old","Price":1,"Version":1}`),
+		}, nil
+	}
+
+	t.Run("writes are grouped into transactions of 100", func(t *testing.T) {
+		t.Parallel()
+
+		var transactions [][]aztables.TransactionAction
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager:     &testPager{},
+			getEntity: liveItem,
+			submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+				transactions = append(transactions, actions)
+				return aztables.TransactionResponse{}, nil
+			},
+		})
+
+		var ops []models.BatchOp
+		for i := 0; i < 150; i++ {
+			ops = append(ops, models.BatchOp{Action: models.BatchCreate, Entity: &models.Item{Name: "new", Price: 1}})
+		}
+		updated := &models.Item{Base: models.Base{ID: 7}, Name: "renamed", Price: 2, Version: 1}
+		deleted := &models.Item{Base: models.Base{ID: 8}}
+		ops = append(ops,
+			models.BatchOp{Action: models.BatchUpdate, Entity: updated},
+			models.BatchOp{Action: models.BatchDelete, Entity: deleted},
+		)
+
+		require.NoError(t, repo.Batch(context.Background(), ops))
+		require.Len(t, transactions, 2)
+		assert.Len(t, transactions[0], 100)
+		require.Len(t, transactions[1], 52)
+		assert.Equal(t, aztables.TransactionTypeAdd, transactions[0][0].ActionType)
+
+		update, del := transactions[1][50], transactions[1][51]
+		assert.Equal(t, aztables.TransactionTypeUpdateMerge, update.ActionType)
+		assert.Equal(t, azcore.ETag("etag-7"), *update.IfMatch)
+		assert.Contains(t, string(update.Entity), `"Name":"renamed"`)
+		assert.Equal(t, uint(2), updated.Version)
+		assert.Equal(t, azcore.ETag("etag-8"), *del.IfMatch)
+		assert.Contains(t, string(del.Entity), `"DeletedAt"`)
+		assert.True(t, deleted.DeletedAt.Valid)
+		for _, op := range ops[:150] {
+			assert.NotZero(t, op.Entity.(*models.Item).ID)
+		}
+	})
+
+	t.Run("a failed transaction reports the ops already applied", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager:     &testPager{},
+			getEntity: liveItem,
+			submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+				calls++
+				if calls == 2 {
+					return aztables.TransactionResponse{}, &azcore.ResponseError{StatusCode: 412}
+				}
+				return aztables.TransactionResponse{}, nil
+			},
+		})
+
+		var ops []models.BatchOp
+		for i := 0; i < 100; i++ {
+			ops = append(ops, models.BatchOp{Action: models.BatchCreate, Entity: &models.Item{Name: "new", Price: 1}})
+		}
+		updated := &models.Item{Base: models.Base{ID: 7}, Name: "renamed", Price: 2, Version: 1}
+		ops = append(ops, models.BatchOp{Action: models.BatchUpdate, Entity: updated})
+
+		err := repo.Batch(context.Background(), ops)
+		var batchErr *models.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Equal(t, 100, batchErr.Index, "a single-action transaction identifies the failed op")
+		assert.Len(t, batchErr.Applied, 100)
+		assert.ErrorContains(t, err, "version mismatch")
+		assert.Equal(t, uint(1), updated.Version, "the version bump is rolled back")
+	})
+
+	t.Run("invalid ops fail before anything is written", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name      string
+			ops       []models.BatchOp
+			wantIndex int
+			wantErr   error
+		}{
+			{
+				name: "stale version",
+				ops: []models.BatchOp{
+					{Action: models.BatchCreate, Entity: &models.Item{Name: "new", Price: 1}},
+					{Action: models.BatchUpdate, Entity: &models.Item{Base: models.Base{ID: 7}, Name: "x", Price: 1, Version: 3}},
+				},
+				wantIndex: 1,
+			},
+			{
+				name: "same entity twice",
+				ops: []models.BatchOp{
+					{Action: models.BatchDelete, Entity: &models.Item{Base: models.Base{ID: 7}}},
+					{Action: models.BatchUpdate, Entity: &models.Item{Base: models.Base{ID: 7}, Name: "x", Price: 1, Version: 1}},
+				},
+				wantIndex: 1,
+				wantErr:   dberrors.ErrValidation,
+			},
+			{
+				name: "unique value repeated within the batch",
+				ops: []models.BatchOp{
+					{Action: models.BatchCreate, Entity: &gadget{Serial: "s-1"}},
+					{Action: models.BatchCreate, Entity: &gadget{Serial: "s-1"}},
+				},
+				wantIndex: 1,
+				wantErr:   dberrors.ErrDuplicateKey,
+			},
+			{
+				name:      "unknown action",
+				ops:       []models.BatchOp{{Action: "upsert", Entity: &models.Item{Name: "x", Price: 1}}},
+				wantIndex: 0,
+				wantErr:   dberrors.ErrValidation,
+			},
+		}
+
+		for _, tt := range tests {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				repo := azure.NewTestTableRepository("testtable")
+				repo.SetTestClient(&mockClient{
+					pager:     &testPager{},
+					getEntity: liveItem,
+					submit: func(ctx context.Context, actions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+						t.Error("no transaction may be submitted")
+						return aztables.TransactionResponse{}, nil
+					},
+				})
+
+				err := repo.Batch(context.Background(), tt.ops)
+				var batchErr *models.BatchError
+				require.ErrorAs(t, err, &batchErr)
+				assert.Equal(t, tt.wantIndex, batchErr.Index)
+				assert.Empty(t, batchErr.Applied)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+			})
+		}
+	})
+}
+
+func TestTableRepository_Patch(t *testing.T) {
+	t.Parallel()
+
+	getItem := func(ctx context.Context, partitionKey, rowKey string, options *aztables.GetEntityOptions) (aztables.GetEntityResponse, error) {
+		return aztables.GetEntityResponse{
+			ETag:  azcore.ETag("etag-1"),
+			Value: []byte(`{"PartitionKey":"items","RowKey":"7","Name":"old","Price":1,"Version":1}`),
+		}, nil
+	}
+
+	t.Run("merges only the patched properties", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			body []byte
+			opts *aztables.UpdateEntityOptions
+		)
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager:     &testPager{},
+			getEntity: getItem,
+			updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+				body, opts = entity, options
+				return aztables.UpdateEntityResponse{}, nil
+			},
+		})
+
+		item := &models.Item{Base: models.Base{ID: 7}, Name: "ignored", Price: 3, Version: 1}
+		require.NoError(t, repo.Patch(context.Background(), item, "price"))
+		assert.Equal(t, uint(2), item.Version)
+
+		var props map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &props))
+		assert.ElementsMatch(t,
+			[]string{"PartitionKey", "RowKey", "Price", "Price@odata.type", "UpdatedAt", "Version"},
+			keys(props))
+		assert.Equal(t, aztables.UpdateModeMerge, opts.UpdateMode)
+		assert.Equal(t, azcore.ETag("etag-1"), *opts.IfMatch)
+	})
+
+	t.Run("rejects stale versions and invalid columns", func(t *testing.T) {
+		t.Parallel()
+
+		repo := azure.NewTestTableRepository("testtable")
+		repo.SetTestClient(&mockClient{
+			pager:     &testPager{},
+			getEntity: getItem,
+			updateEntity: func(ctx context.Context, entity []byte, options *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error) {
+				t.Error("nothing should be written")
+				return aztables.UpdateEntityResponse{}, nil
+			},
+		})
+
+		stale := &models.Item{Base: models.Base{ID: 7}, Name: "x", Price: 3, Version: 4}
+		err := repo.Patch(context.Background(), stale, "price")
+		assert.ErrorContains(t, err, "version mismatch")
+		assert.Equal(t, uint(4), stale.Version)
+
+		err = repo.Patch(context.Background(), &models.Item{Base: models.Base{ID: 7}, Version: 1}, "version")
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+}
+
+func keys(m map[string]interface{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
 }
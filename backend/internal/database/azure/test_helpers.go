@@ -37,11 +37,12 @@ func (p *testPager) NextPage(ctx context.Context) (aztables.ListEntitiesResponse
 
 // testClient implements azure.AzureTableClient for testing
 type testClient struct {
-	addEntity    func(context.Context, []byte, *aztables.AddEntityOptions) (aztables.AddEntityResponse, error)
-	getEntity    func(context.Context, string, string, *aztables.GetEntityOptions) (aztables.GetEntityResponse, error)
-	updateEntity func(context.Context, []byte, *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error)
-	deleteEntity func(context.Context, string, string, *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error)
-	pager       *testPager
+	addEntity         func(context.Context, []byte, *aztables.AddEntityOptions) (aztables.AddEntityResponse, error)
+	getEntity         func(context.Context, string, string, *aztables.GetEntityOptions) (aztables.GetEntityResponse, error)
+	updateEntity      func(context.Context, []byte, *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error)
+	deleteEntity      func(context.Context, string, string, *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error)
+	submitTransaction func(context.Context, []aztables.TransactionAction, *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error)
+	pager             *testPager
 }
 
 func (m *testClient) AddEntity(ctx context.Context, entity []byte, options *aztables.AddEntityOptions) (aztables.AddEntityResponse, error) {
@@ -78,3 +79,10 @@ func (m *testClient) NewListEntitiesPager(options *aztables.ListEntitiesOptions)
 	}
 	return &testPager{} // Return empty pager by default
 }
+
+func (m *testClient) SubmitTransaction(ctx context.Context, transactionActions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+	if m.submitTransaction != nil {
+		return m.submitTransaction(ctx, transactionActions, options)
+	}
+	return aztables.TransactionResponse{}, nil
+}
@@ -49,6 +49,7 @@ type mockTableClient struct {
 	updateEntityFn         func(context.Context, []byte, *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error)
 	deleteEntityFn         func(context.Context, string, string, *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error)
 	newListEntitiesPagerFn func(*aztables.ListEntitiesOptions) azure.ListEntitiesPager
+	submitTransactionFn    func(context.Context, []aztables.TransactionAction, *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error)
 }
 
 // Verify interface compliance at compile time
@@ -114,3 +115,11 @@ func (m *mockTableClient) NewListEntitiesPager(options *aztables.ListEntitiesOpt
 	}
 	return &mockTablePager{}
 }
+
+// SubmitTransaction implements azure.AzureTableClient
+func (m *mockTableClient) SubmitTransaction(ctx context.Context, transactionActions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+	if m.submitTransactionFn != nil {
+		return m.submitTransactionFn(ctx, transactionActions, options)
+	}
+	return aztables.TransactionResponse{}, nil
+}
@@ -55,6 +55,7 @@ type MockTableClient struct {
 	updateEntityFn         func(context.Context, []byte, *aztables.UpdateEntityOptions) (aztables.UpdateEntityResponse, error)
 	deleteEntityFn         func(context.Context, string, string, *aztables.DeleteEntityOptions) (aztables.DeleteEntityResponse, error)
 	newListEntitiesPagerFn func(*aztables.ListEntitiesOptions) azure.ListEntitiesPager
+	submitTransactionFn    func(context.Context, []aztables.TransactionAction, *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error)
 }
 
 // Verify interface compliance at compile time
@@ -120,3 +121,11 @@ func (m *MockTableClient) NewListEntitiesPager(options *aztables.ListEntitiesOpt
 	}
 	return &MockTablePager{}
 }
+
+// SubmitTransaction implements azure.AzureTableClient
+func (m *MockTableClient) SubmitTransaction(ctx context.Context, transactionActions []aztables.TransactionAction, options *aztables.SubmitTransactionOptions) (aztables.TransactionResponse, error) {
+	if m.submitTransactionFn != nil {
+		return m.submitTransactionFn(ctx, transactionActions, options)
+	}
+	return aztables.TransactionResponse{}, nil
+}
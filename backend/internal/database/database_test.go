@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"testing"
+	"time"
 
 	"backend/internal/models"
 
@@ -241,3 +242,137 @@ func TestItemCRUD(t *testing.T) {
 	})
 
 }
+
+func TestUserRepository(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		second  models.User
+		wantErr error
+	}{
+		{
+			name:    "distinct user is created",
+			second:  models.User{Username: "bob", Email: "bob@example.com"},
+			wantErr: nil,
+		},
+		{
+			name:    "duplicate username is rejected",
+			second:  models.User{Username: "alice", Email: "other@example.com"},
+			wantErr: ErrDuplicateKey,
+		},
+		{
+			name:    "duplicate email is rejected",
+			second:  models.User{Username: "other", Email: "alice@example.com"},
+			wantErr: ErrDuplicateKey,
+		},
+		{
+			name:    "invalid email is rejected",
+			second:  models.User{Username: "carol", Email: "invalid"},
+			wantErr: ErrValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			db := setupTestDB(t)
+			require.NoError(t, db.AutoMigrate())
+			repo := models.NewRepository(db.DB)
+			ctx := context.Background()
+
+			require.NoError(t, repo.Create(ctx, &models.User{Username: "alice", Email: "alice@example.com"}))
+
+			second := tt.second
+			err := repo.Create(ctx, &second)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				var users []models.User
+				require.NoError(t, repo.List(ctx, &users, models.Filter{Field: "username", Op: "exact", Value: second.Username}))
+				assert.Len(t, users, 1)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (models.Store, *models.Item) {
+		t.Helper()
+		db := setupTestDB(t)
+		require.NoError(t, db.AutoMigrate())
+		repo := models.NewRepository(db.DB)
+		item := &models.Item{Name: "Soft", Price: 5}
+		require.NoError(t, repo.Create(ctx, item))
+		require.NoError(t, repo.Delete(ctx, item))
+		return repo, item
+	}
+
+	t.Run("deleted rows are hidden unless requested", func(t *testing.T) {
+		t.Parallel()
+		repo, item := setup(t)
+
+		var found models.Item
+		assert.ErrorIs(t, repo.FindByID(ctx, item.ID, &found), ErrNotFound)
+		require.NoError(t, repo.FindByID(ctx, item.ID, &found, models.IncludeDeleted{}))
+		assert.True(t, found.DeletedAt.Valid)
+
+		var items []models.Item
+		require.NoError(t, repo.List(ctx, &items))
+		assert.Empty(t, items)
+		require.NoError(t, repo.List(ctx, &items, models.IncludeDeleted{}))
+		assert.Len(t, items, 1)
+	})
+
+	t.Run("deleting twice reports not found", func(t *testing.T) {
+		t.Parallel()
+		repo, item := setup(t)
+
+		assert.ErrorIs(t, repo.Delete(ctx, item), ErrNotFound)
+	})
+
+	t.Run("restore makes the row visible and bumps the version", func(t *testing.T) {
+		t.Parallel()
+		repo, item := setup(t)
+
+		restored := &models.Item{Base: models.Base{ID: item.ID}}
+		ok, err := repo.Restore(ctx, restored)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, restored.DeletedAt.Valid)
+		assert.Equal(t, "Soft", restored.Name)
+		assert.Equal(t, uint(2), restored.Version)
+
+		var found models.Item
+		require.NoError(t, repo.FindByID(ctx, item.ID, &found))
+
+		ok, err = repo.Restore(ctx, restored)
+		require.NoError(t, err)
+		assert.False(t, ok, "restoring a live row is a no-op")
+		assert.Equal(t, uint(2), restored.Version)
+
+		_, err = repo.Restore(ctx, &models.Item{Base: models.Base{ID: 999}})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("purge removes only rows deleted before the cutoff", func(t *testing.T) {
+		t.Parallel()
+		repo, item := setup(t)
+
+		n, err := repo.Purge(ctx, &models.Item{}, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		n, err = repo.Purge(ctx, &models.Item{}, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		var found models.Item
+		assert.ErrorIs(t, repo.FindByID(ctx, item.ID, &found, models.IncludeDeleted{}), ErrNotFound)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 
 	"backend/internal/database/schema"
+	"backend/internal/idempotency"
 	"backend/internal/models"
 
 	"gorm.io/gorm"
@@ -90,6 +91,66 @@ func (d *Database) AutoMigrate() error {
 		},
 	})
 
+	migrator.AddMigration(schema.Migration{
+		Version:     "20231201000004",
+		Name:        "create_idempotency_keys",
+		Description: "Create the table of Idempotency-Key responses",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&idempotency.SQLRecord{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&idempotency.SQLRecord{})
+		},
+	})
+
+	migrator.AddMigration(schema.Migration{
+		Version:     "20231201000005",
+		Name:        "add_user_password_hash",
+		Description: "Add the password hash column used by login",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.User{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&models.User{}, "password_hash")
+		},
+	})
+
+	migrator.AddMigration(schema.Migration{
+		Version:     "20231201000006",
+		Name:        "add_user_roles",
+		Description: "Add the roles column used for access control",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.User{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&models.User{}, "roles")
+		},
+	})
+
+	migrator.AddMigration(schema.Migration{
+		Version:     "20231201000007",
+		Name:        "create_api_keys",
+		Description: "Create the API key table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.APIKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.APIKey{})
+		},
+	})
+
+	migrator.AddMigration(schema.Migration{
+		Version:     "20231201000008",
+		Name:        "create_outbox_events",
+		Description: "Create the outbox of change events",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.OutboxEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.OutboxEvent{})
+		},
+	})
+
 	// Run migrations
 	if err := migrator.MigrateUp(); err != nil {
 		return err
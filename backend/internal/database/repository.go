@@ -6,11 +6,12 @@ import (
 
 	"backend/internal/config"
 	"backend/internal/database/azure"
+	"backend/internal/idempotency"
 	"backend/internal/models"
 )
 
 // NewRepository creates a new repository based on the configuration
-func NewRepository(cfg *config.Config) (models.Repository, error) {
+func NewRepository(cfg *config.Config) (models.Store, error) {
 	if cfg.AzureTable.UseAzureTable {
 		slog.Info("Using Azure Table Storage as repository")
 		return azure.NewTableRepository(
@@ -39,3 +40,23 @@ func NewRepository(cfg *config.Config) (models.Repository, error) {
 
 	return models.NewRepository(db.DB), nil
 }
+
+// NewIdempotencyStore creates the idempotency key store selected by cfg. The
+// "database" store keeps keys next to store's data: in an idempotency_keys
+// table for MySQL, or in a partition of the Azure table. Stores that can hold
+// neither fall back to memory.
+func NewIdempotencyStore(cfg *config.Config, store models.Store) idempotency.Store {
+	if cfg.Idempotency.Store == "memory" {
+		return idempotency.NewMemoryStore()
+	}
+
+	switch s := store.(type) {
+	case *azure.TableRepository:
+		return azure.NewIdempotencyStore(s)
+	case *models.GenericRepository:
+		return idempotency.NewSQLStore(s.DB())
+	default:
+		slog.Warn("Repository cannot store idempotency keys; keeping them in memory", "repository", fmt.Sprintf("%T", store))
+		return idempotency.NewMemoryStore()
+	}
+}
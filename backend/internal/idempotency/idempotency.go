@@ -0,0 +1,75 @@
+// Package idempotency stores the responses of requests sent with an
+// Idempotency-Key header, so that a retried request is answered with the
+// original response instead of being applied twice.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Response is a stored response, replayed to retries of its request.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the state of an idempotency key.
+type Record struct {
+	Key string
+	// Fingerprint identifies the request that reserved the key; a retry must
+	// carry the same fingerprint.
+	Fingerprint string
+	ExpiresAt   time.Time
+	// Response is nil while the request that reserved the key is in flight.
+	Response *Response
+}
+
+// Store persists idempotency records. Reserve must be atomic across every
+// server instance sharing the store, so that only one of several concurrent
+// requests with the same key is processed. Records past their ExpiresAt are
+// treated as absent.
+type Store interface {
+	// Reserve claims key for the request identified by fingerprint until ttl
+	// elapses. If another unexpired record holds the key, it is returned and
+	// reserved is false.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (existing *Record, reserved bool, err error)
+
+	// Complete stores the response of the request that reserved key, to be
+	// replayed until ttl elapses. It does nothing if the key is now held by a
+	// different request.
+	Complete(ctx context.Context, key, fingerprint string, resp Response, ttl time.Duration) error
+
+	// Release drops the reservation of key by the request identified by
+	// fingerprint, so that a retry is processed afresh.
+	Release(ctx context.Context, key, fingerprint string) error
+}
+
+// Fingerprint identifies a request by its method, URI and body, and the
+// authenticated principal sending it ("" if anonymous), so that a key reused
+// by another user does not replay a response meant for the first.
+func Fingerprint(principal, method, requestURI string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(principal + "\n" + method + " " + requestURI + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayedHeaders are the response headers stored for replay. Others, such
+// as X-Request-ID, describe the original exchange rather than its result.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Link", "X-Total-Count"}
+
+// ReplayHeader returns the subset of header that is stored with a response.
+func ReplayHeader(header http.Header) http.Header {
+	out := make(http.Header)
+	for _, name := range replayedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			out[name] = append([]string(nil), values...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops expired records.
+const sweepInterval = time.Minute
+
+// MemoryStore is a Store for a single server instance. Records do not
+// survive a restart and are not shared with other instances.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*Record
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record), now: time.Now}
+}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(_ context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if rec, ok := s.records[key]; ok && now.Before(rec.ExpiresAt) {
+		existing := *rec
+		return &existing, false, nil
+	}
+	s.records[key] = &Record{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// Complete implements Store.
+func (s *MemoryStore) Complete(_ context.Context, key, fingerprint string, resp Response, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && rec.Fingerprint == fingerprint {
+		rec.Response = &resp
+		rec.ExpiresAt = s.now().Add(ttl)
+	}
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(_ context.Context, key, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && rec.Fingerprint == fingerprint && rec.Response == nil {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// sweep drops expired records, at most once per sweepInterval. The caller
+// must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, rec := range s.records {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend/pkg/dberrors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQLRecord is the row of an idempotency key in the idempotency_keys table.
+// Status is 0 while the request that reserved the key is in flight.
+type SQLRecord struct {
+	Key         string `gorm:"column:idempotency_key;primaryKey;size:255"`
+	Fingerprint string `gorm:"size:64;not null"`
+	Status      int    `gorm:"not null;default:0"`
+	Header      string `gorm:"type:text"`
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index;not null"`
+}
+
+// TableName implements gorm's Tabler.
+func (SQLRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+func (r *SQLRecord) record() (*Record, error) {
+	rec := &Record{Key: r.Key, Fingerprint: r.Fingerprint, ExpiresAt: r.ExpiresAt}
+	if r.Status == 0 {
+		return rec, nil
+	}
+	rec.Response = &Response{Status: r.Status, Body: r.Body}
+	if r.Header != "" {
+		if err := json.Unmarshal([]byte(r.Header), &rec.Response.Header); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// SQLStore is a Store backed by the idempotency_keys table, shared by every
+// server instance using the database. The table is created by the database
+// migrations.
+type SQLStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewSQLStore creates a SQLStore using db.
+func NewSQLStore(db *gorm.DB) *SQLStore {
+	return &SQLStore{db: db, now: time.Now}
+}
+
+// Reserve implements Store. The primary key makes the insert atomic; an
+// expired row holding the key is deleted and the insert retried.
+func (s *SQLStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	now := s.now()
+	s.sweep(ctx, now)
+
+	db := s.db.WithContext(ctx)
+	for attempt := 0; attempt < 2; attempt++ {
+		row := SQLRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if result.Error != nil {
+			return nil, false, dberrors.NewDatabaseError("reserve", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return nil, true, nil
+		}
+
+		var existing SQLRecord
+		err := db.Where("idempotency_key = ?", key).Take(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // released since the insert
+		}
+		if err != nil {
+			return nil, false, dberrors.NewDatabaseError("reserve", err)
+		}
+		if now.Before(existing.ExpiresAt) {
+			rec, err := existing.record()
+			if err != nil {
+				return nil, false, dberrors.NewDatabaseError("reserve", err)
+			}
+			return rec, false, nil
+		}
+		err = db.Where("idempotency_key = ? AND expires_at <= ?", key, now).Delete(&SQLRecord{}).Error
+		if err != nil {
+			return nil, false, dberrors.NewDatabaseError("reserve", err)
+		}
+	}
+	return nil, false, dberrors.NewDatabaseError("reserve", errors.New("idempotency key is contended"))
+}
+
+// Complete implements Store.
+func (s *SQLStore) Complete(ctx context.Context, key, fingerprint string, resp Response, ttl time.Duration) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return dberrors.NewDatabaseError("complete", err)
+	}
+	err = s.db.WithContext(ctx).Model(&SQLRecord{}).
+		Where("idempotency_key = ? AND fingerprint = ?", key, fingerprint).
+		Updates(map[string]interface{}{
+			"status":     resp.Status,
+			"header":     string(header),
+			"body":       resp.Body,
+			"expires_at": s.now().Add(ttl),
+		}).Error
+	if err != nil {
+		return dberrors.NewDatabaseError("complete", err)
+	}
+	return nil
+}
+
+// Release implements Store.
+func (s *SQLStore) Release(ctx context.Context, key, fingerprint string) error {
+	err := s.db.WithContext(ctx).
+		Where("idempotency_key = ? AND fingerprint = ? AND status = 0", key, fingerprint).
+		Delete(&SQLRecord{}).Error
+	if err != nil {
+		return dberrors.NewDatabaseError("release", err)
+	}
+	return nil
+}
+
+// sweep deletes expired rows, at most once per sweepInterval per instance.
+func (s *SQLStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	due := now.Sub(s.lastSweep) >= sweepInterval
+	if due {
+		s.lastSweep = now
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&SQLRecord{}).Error; err != nil {
+		slog.Warn("Failed to delete expired idempotency keys", "error", err)
+	}
+}
@@ -0,0 +1,186 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testClock is a manually advanced clock shared by a store under test.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestStore creates the named Store implementation on clock.
+func newTestStore(t *testing.T, name string, clock *testClock) Store {
+	t.Helper()
+
+	if name == "memory" {
+		store := NewMemoryStore()
+		store.now = clock.Now
+		return store
+	}
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // every connection would get its own in-memory database
+	require.NoError(t, db.AutoMigrate(&SQLRecord{}))
+	store := NewSQLStore(db)
+	store.now = clock.Now
+	return store
+}
+
+func TestStores(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	resp := Response{
+		Status: http.StatusCreated,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   []byte(`{"id":1}`),
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store Store, clock *testClock)
+	}{
+		{
+			name: "a completed key is replayed until it expires",
+			run: func(t *testing.T, store Store, clock *testClock) {
+				_, reserved, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				require.True(t, reserved)
+				require.NoError(t, store.Complete(ctx, "k", "fp", resp, time.Hour))
+
+				clock.Advance(30 * time.Minute)
+				existing, reserved, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				assert.False(t, reserved)
+				require.NotNil(t, existing.Response)
+				assert.Equal(t, "fp", existing.Fingerprint)
+				assert.Equal(t, resp, *existing.Response)
+
+				clock.Advance(31 * time.Minute)
+				_, reserved, err = store.Reserve(ctx, "k", "other", time.Minute)
+				require.NoError(t, err)
+				assert.True(t, reserved, "an expired key can be reused")
+			},
+		},
+		{
+			name: "an in-flight key is reported without a response",
+			run: func(t *testing.T, store Store, clock *testClock) {
+				_, reserved, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				require.True(t, reserved)
+
+				existing, reserved, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				assert.False(t, reserved)
+				assert.Nil(t, existing.Response)
+
+				clock.Advance(2 * time.Minute)
+				_, reserved, err = store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				assert.True(t, reserved, "an abandoned reservation lapses")
+			},
+		},
+		{
+			name: "release frees the key for a retry",
+			run: func(t *testing.T, store Store, clock *testClock) {
+				_, _, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				require.NoError(t, store.Release(ctx, "k", "other"))
+				_, reserved, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				assert.False(t, reserved, "only the reserving request can release")
+
+				require.NoError(t, store.Release(ctx, "k", "fp"))
+				_, reserved, err = store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				assert.True(t, reserved)
+			},
+		},
+		{
+			name: "complete ignores a key held by another request",
+			run: func(t *testing.T, store Store, clock *testClock) {
+				_, _, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				require.NoError(t, store.Complete(ctx, "k", "other", resp, time.Hour))
+
+				existing, _, err := store.Reserve(ctx, "k", "fp", time.Minute)
+				require.NoError(t, err)
+				assert.Nil(t, existing.Response)
+			},
+		},
+		{
+			name: "only one concurrent request reserves a key",
+			run: func(t *testing.T, store Store, clock *testClock) {
+				var (
+					wg       sync.WaitGroup
+					mu       sync.Mutex
+					reserved int
+				)
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, ok, err := store.Reserve(ctx, "k", "fp", time.Minute)
+						assert.NoError(t, err)
+						if ok {
+							mu.Lock()
+							reserved++
+							mu.Unlock()
+						}
+					}()
+				}
+				wg.Wait()
+				assert.Equal(t, 1, reserved)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			for _, name := range []string{"memory", "sql"} {
+				t.Run(name, func(t *testing.T) {
+					clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+					tt.run(t, newTestStore(t, name, clock), clock)
+				})
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	base := Fingerprint("", "POST", "/api/v1/items", []byte(`{"name":"a"}`))
+	assert.Len(t, base, 64)
+	assert.Equal(t, base, Fingerprint("", "POST", "/api/v1/items", []byte(`{"name":"a"}`)))
+	assert.NotEqual(t, base, Fingerprint("", "POST", "/api/v1/items", []byte(`{"name":"b"}`)))
+	assert.NotEqual(t, base, Fingerprint("", "POST", "/api/v1/users", []byte(`{"name":"a"}`)))
+	assert.NotEqual(t, base, Fingerprint("7", "POST", "/api/v1/items", []byte(`{"name":"a"}`)))
+}
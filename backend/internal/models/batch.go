@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"backend/pkg/dberrors"
+
+	"gorm.io/gorm"
+)
+
+// BatchAction is the kind of write performed by a BatchOp.
+type BatchAction string
+
+const (
+	BatchCreate BatchAction = "create"
+	BatchUpdate BatchAction = "update"
+	BatchDelete BatchAction = "delete"
+)
+
+// BatchOp is one write of a batch. Entity is a pointer to a registered model,
+// handled as by the Store method of the same name: IDs, versions and
+// timestamps are set on it as the write is prepared.
+type BatchOp struct {
+	Action BatchAction
+	Entity interface{}
+}
+
+// Batcher is implemented by stores that can apply several writes as a unit.
+// Stores that do not implement it cannot serve batch requests.
+type Batcher interface {
+	// Batch applies ops in order. If any op fails, the returned error is a
+	// *BatchError and, for stores that apply batches atomically, none of the
+	// ops are applied.
+	Batch(ctx context.Context, ops []BatchOp) error
+}
+
+// BatchError reports which operation of a batch failed.
+type BatchError struct {
+	// Index is the position of the failed op, or -1 when the store cannot tell
+	// which op of a failed transaction was rejected.
+	Index int
+
+	// Applied lists the ops committed before the failure. It is always empty
+	// for atomic stores; Azure Table Storage commits each entity-group
+	// transaction separately, so earlier groups may have been applied.
+	Applied []int
+
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("batch failed: %v", e.Err)
+	}
+	return fmt.Sprintf("batch operation %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch implements Batcher. All ops run in one database transaction, so they
+// are applied together or not at all.
+func (r *GenericRepository) Batch(ctx context.Context, ops []BatchOp) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := &GenericRepository{db: tx, allowedFilterFields: r.allowedFilterFields}
+		for i, op := range ops {
+			var err error
+			switch op.Action {
+			case BatchCreate:
+				err = repo.Create(ctx, op.Entity)
+			case BatchUpdate:
+				err = repo.Update(ctx, op.Entity)
+			case BatchDelete:
+				err = repo.Delete(ctx, op.Entity)
+			default:
+				err = dberrors.NewDatabaseError("batch",
+					fmt.Errorf("%w: unknown batch action %q", dberrors.ErrValidation, op.Action))
+			}
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+}
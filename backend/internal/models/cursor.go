@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for tokens it did not produce.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor requests keyset pagination from Store.List. Pass a pointer so List
+// can report where the following page starts:
+//
+//	cur := &models.Cursor{Token: token, Limit: 20}
+//	err := store.List(ctx, &items, cur)
+//	// cur.Next is empty on the last page
+//
+// Tokens are opaque to clients and only valid for the backend that issued
+// them. A Cursor takes precedence over Pagination.
+type Cursor struct {
+	// Token is the Next value of the previous page; empty for the first page.
+	Token string
+	// Limit is the maximum number of records to return. It must be positive.
+	Limit int
+	// Next is set by List to the token of the following page, or "" when
+	// there are no more records.
+	Next string
+}
+
+// keysetCursor is the token payload used by GenericRepository.
+type keysetCursor struct {
+	ID uint `json:"id"`
+}
+
+// EncodeCursor serialises a backend-specific position into an opaque,
+// URL-safe token.
+func EncodeCursor(position interface{}) string {
+	b, err := json.Marshal(position)
+	if err != nil {
+		// Positions are plain structs of strings and integers.
+		panic("models: EncodeCursor: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor into position.
+func DecodeCursor(token string, position interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
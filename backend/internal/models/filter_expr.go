@@ -0,0 +1,324 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/pkg/dberrors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Expr is a boolean filter expression, usually produced by ParseFilter. Any
+// Expr can be passed to Store.List as a condition; it is combined with the
+// other conditions using AND.
+type Expr interface {
+	expr()
+}
+
+// And matches records matching both Left and Right.
+type And struct {
+	Left, Right Expr
+}
+
+// Or matches records matching Left, Right or both.
+type Or struct {
+	Left, Right Expr
+}
+
+// Not matches records that do not match Expr.
+type Not struct {
+	Expr Expr
+}
+
+// CompareOp is the operator of a Comparison.
+type CompareOp string
+
+// Comparison operators. OpIn takes one or more values, OpBetween exactly two
+// (inclusive bounds), OpIsNull none and the others exactly one.
+const (
+	OpEq         CompareOp = "eq"
+	OpNe         CompareOp = "ne"
+	OpGt         CompareOp = "gt"
+	OpGe         CompareOp = "ge"
+	OpLt         CompareOp = "lt"
+	OpLe         CompareOp = "le"
+	OpContains   CompareOp = "contains"
+	OpStartsWith CompareOp = "startswith"
+	OpEndsWith   CompareOp = "endswith"
+	OpIn         CompareOp = "in"
+	OpBetween    CompareOp = "between"
+	OpIsNull     CompareOp = "isnull"
+)
+
+// Comparison tests one field (by column name) against literal values.
+// Before BindExpr, values are the string, float64 and bool literals produced
+// by ParseFilter; afterwards they have the Go type of the field (string,
+// int64, uint64, float64, bool or time.Time).
+type Comparison struct {
+	Field  string
+	Op     CompareOp
+	Values []interface{}
+}
+
+func (And) expr()        {}
+func (Or) expr()         {}
+func (Not) expr()        {}
+func (Comparison) expr() {}
+
+// BindExpr checks expr against a model and returns a copy whose values are
+// converted to the types of the fields they are compared with. Every field
+// must be in allowed, and every value must be convertible: numbers for
+// numeric fields (whole numbers for integer fields), strings for text fields,
+// RFC 3339 strings for timestamps and booleans for bool fields. The text
+// operators (contains, startswith, endswith) only apply to text fields.
+// Errors wrap dberrors.ErrValidation.
+func BindExpr(expr Expr, model reflect.Type, allowed map[string]bool) (Expr, error) {
+	columns := columnsOf(model)
+	var bind func(Expr) (Expr, error)
+	bind = func(e Expr) (Expr, error) {
+		switch e := e.(type) {
+		case And:
+			left, err := bind(e.Left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := bind(e.Right)
+			if err != nil {
+				return nil, err
+			}
+			return And{Left: left, Right: right}, nil
+		case Or:
+			left, err := bind(e.Left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := bind(e.Right)
+			if err != nil {
+				return nil, err
+			}
+			return Or{Left: left, Right: right}, nil
+		case Not:
+			inner, err := bind(e.Expr)
+			if err != nil {
+				return nil, err
+			}
+			return Not{Expr: inner}, nil
+		case Comparison:
+			return bindComparison(e, columns, allowed)
+		default:
+			return nil, fmt.Errorf("%w: unsupported filter expression %T", dberrors.ErrValidation, e)
+		}
+	}
+	return bind(expr)
+}
+
+func bindComparison(c Comparison, columns map[string]reflect.StructField, allowed map[string]bool) (Expr, error) {
+	field, ok := columns[c.Field]
+	if !ok || !allowed[c.Field] {
+		return nil, fmt.Errorf("%w: invalid filter field: %q", dberrors.ErrValidation, c.Field)
+	}
+
+	want := 1
+	switch c.Op {
+	case OpIn:
+		want = len(c.Values)
+		if want == 0 {
+			return nil, fmt.Errorf("%w: %s %s needs at least one value", dberrors.ErrValidation, c.Field, c.Op)
+		}
+	case OpBetween:
+		want = 2
+	case OpIsNull:
+		want = 0
+	case OpContains, OpStartsWith, OpEndsWith:
+		if fieldKind(field.Type) != reflect.String {
+			return nil, fmt.Errorf("%w: %s only applies to text fields, not %q", dberrors.ErrValidation, c.Op, c.Field)
+		}
+	case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe:
+	default:
+		return nil, fmt.Errorf("%w: unknown filter operator %q", dberrors.ErrValidation, c.Op)
+	}
+	if len(c.Values) != want {
+		return nil, fmt.Errorf("%w: %s %s takes %d value(s), got %d", dberrors.ErrValidation, c.Field, c.Op, want, len(c.Values))
+	}
+
+	bound := Comparison{Field: c.Field, Op: c.Op, Values: make([]interface{}, len(c.Values))}
+	for i, v := range c.Values {
+		converted, err := convertLiteral(v, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%w: filter %q: %s", dberrors.ErrValidation, c.Field, err.Error())
+		}
+		bound.Values[i] = converted
+	}
+	return bound, nil
+}
+
+// fieldKind returns the kind of a field type, looking through pointers.
+func fieldKind(t reflect.Type) reflect.Kind {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind()
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// convertLiteral converts a parsed literal to the Go type used for a field.
+func convertLiteral(v interface{}, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t == deletedAtType {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an RFC 3339 timestamp string, got %T", v)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", s)
+		}
+		return ts.UTC(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected a string, got %T", v)
+	case reflect.Bool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected true or false, got %T", v)
+	case reflect.Float32, reflect.Float64:
+		if n, ok := v.(float64); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %T", v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || n < math.MinInt64 || n > math.MaxInt64 {
+			return nil, fmt.Errorf("expected a whole number, got %v", v)
+		}
+		return int64(n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || n < 0 || n > math.MaxUint64 {
+			return nil, fmt.Errorf("expected a non-negative whole number, got %v", v)
+		}
+		return uint64(n), nil
+	default:
+		return nil, fmt.Errorf("field type %s cannot be filtered", t)
+	}
+}
+
+// columnCache holds the column name to struct field mapping per model type.
+var columnCache sync.Map
+
+// columnsOf maps the column names of a model struct type (as GORM names them)
+// to its fields, including those promoted from embedded structs such as Base.
+// The Index of each returned field is relative to the model type.
+func columnsOf(t reflect.Type) map[string]reflect.StructField {
+	if cached, ok := columnCache.Load(t); ok {
+		return cached.(map[string]reflect.StructField)
+	}
+	naming := schema.NamingStrategy{}
+	columns := make(map[string]reflect.StructField)
+	var walk func(t reflect.Type, prefix []int)
+	walk = func(t reflect.Type, prefix []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Tag.Get("gorm") == "-" {
+				continue
+			}
+			f.Index = append(append([]int{}, prefix...), i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != timeType && f.Type != deletedAtType {
+				walk(f.Type, f.Index)
+				continue
+			}
+			columns[naming.ColumnName("", f.Name)] = f
+		}
+	}
+	walk(t, nil)
+	actual, _ := columnCache.LoadOrStore(t, columns)
+	return actual.(map[string]reflect.StructField)
+}
+
+// likeEscaper escapes LIKE wildcards using '!' as the escape character, which
+// unlike the backslash means the same thing in SQLite and MySQL string literals.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// exprSQL translates a bound expression into a SQL condition with positional
+// parameters. Field names have been checked against the model's columns and
+// filter whitelist by BindExpr, so they are safe to interpolate.
+func exprSQL(e Expr) (string, []interface{}, error) {
+	switch e := e.(type) {
+	case And:
+		return joinSQL(e.Left, "AND", e.Right)
+	case Or:
+		return joinSQL(e.Left, "OR", e.Right)
+	case Not:
+		inner, args, err := exprSQL(e.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	case Comparison:
+		switch e.Op {
+		case OpEq:
+			return e.Field + " = ?", e.Values, nil
+		case OpNe:
+			return e.Field + " <> ?", e.Values, nil
+		case OpGt:
+			return e.Field + " > ?", e.Values, nil
+		case OpGe:
+			return e.Field + " >= ?", e.Values, nil
+		case OpLt:
+			return e.Field + " < ?", e.Values, nil
+		case OpLe:
+			return e.Field + " <= ?", e.Values, nil
+		case OpContains, OpStartsWith, OpEndsWith:
+			s, ok := e.Values[0].(string)
+			if !ok {
+				break
+			}
+			pattern := likeEscaper.Replace(s)
+			if e.Op != OpStartsWith {
+				pattern = "%" + pattern
+			}
+			if e.Op != OpEndsWith {
+				pattern += "%"
+			}
+			return e.Field + " LIKE ? ESCAPE '!'", []interface{}{pattern}, nil
+		case OpIn:
+			return e.Field + " IN ?", []interface{}{e.Values}, nil
+		case OpBetween:
+			return e.Field + " BETWEEN ? AND ?", e.Values, nil
+		case OpIsNull:
+			return e.Field + " IS NULL", nil, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: unsupported filter expression %#v", dberrors.ErrValidation, e)
+}
+
+// joinSQL translates both operands and joins them with a logical operator.
+func joinSQL(left Expr, op string, right Expr) (string, []interface{}, error) {
+	lsql, largs, err := exprSQL(left)
+	if err != nil {
+		return "", nil, err
+	}
+	rsql, rargs, err := exprSQL(right)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + lsql + " " + op + " " + rsql + ")", append(append([]interface{}{}, largs...), rargs...), nil
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MatchExpr evaluates a bound expression (see BindExpr) against a model
+// struct or a pointer to one, for stores that cannot evaluate it themselves.
+// Text operators are case-insensitive, like LIKE under the default SQLite and
+// MySQL collations; equality and ordering are exact.
+func MatchExpr(e Expr, entity interface{}) (bool, error) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return false, fmt.Errorf("cannot match filter against %T", entity)
+	}
+	return matchExpr(e, v, columnsOf(v.Type()))
+}
+
+func matchExpr(e Expr, v reflect.Value, columns map[string]reflect.StructField) (bool, error) {
+	switch e := e.(type) {
+	case And:
+		ok, err := matchExpr(e.Left, v, columns)
+		if err != nil || !ok {
+			return false, err
+		}
+		return matchExpr(e.Right, v, columns)
+	case Or:
+		ok, err := matchExpr(e.Left, v, columns)
+		if err != nil || ok {
+			return ok, err
+		}
+		return matchExpr(e.Right, v, columns)
+	case Not:
+		ok, err := matchExpr(e.Expr, v, columns)
+		return !ok, err
+	case Comparison:
+		field, ok := columns[e.Field]
+		if !ok {
+			return false, fmt.Errorf("unknown filter field %q", e.Field)
+		}
+		return matchComparison(e, fieldValue(v.FieldByIndex(field.Index)))
+	default:
+		return false, fmt.Errorf("unsupported filter expression %T", e)
+	}
+}
+
+// fieldValue returns the comparable value of a field, or nil for NULL: a nil
+// pointer or an unset soft-delete timestamp.
+func fieldValue(fv reflect.Value) interface{} {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	switch {
+	case fv.Type() == deletedAtType:
+		d := fv.Interface().(gorm.DeletedAt)
+		if !d.Valid {
+			return nil
+		}
+		return d.Time
+	case fv.Type() == timeType:
+		return fv.Interface()
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return fv.Float()
+	case reflect.String:
+		return fv.String()
+	case reflect.Bool:
+		return fv.Bool()
+	default:
+		return fv.Interface()
+	}
+}
+
+func matchComparison(c Comparison, value interface{}) (bool, error) {
+	if c.Op == OpIsNull {
+		return value == nil, nil
+	}
+	if value == nil {
+		// As in SQL, NULL satisfies no comparison.
+		return false, nil
+	}
+
+	switch c.Op {
+	case OpContains, OpStartsWith, OpEndsWith:
+		s, ok1 := value.(string)
+		needle, ok2 := c.Values[0].(string)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("%s needs text operands", c.Op)
+		}
+		s, needle = strings.ToLower(s), strings.ToLower(needle)
+		switch c.Op {
+		case OpContains:
+			return strings.Contains(s, needle), nil
+		case OpStartsWith:
+			return strings.HasPrefix(s, needle), nil
+		default:
+			return strings.HasSuffix(s, needle), nil
+		}
+	case OpIn:
+		for _, candidate := range c.Values {
+			n, err := compareLiteral(value, candidate)
+			if err != nil {
+				return false, err
+			}
+			if n == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case OpBetween:
+		low, err := compareLiteral(value, c.Values[0])
+		if err != nil {
+			return false, err
+		}
+		high, err := compareLiteral(value, c.Values[1])
+		if err != nil {
+			return false, err
+		}
+		return low >= 0 && high <= 0, nil
+	}
+
+	n, err := compareLiteral(value, c.Values[0])
+	if err != nil {
+		return false, err
+	}
+	switch c.Op {
+	case OpEq:
+		return n == 0, nil
+	case OpNe:
+		return n != 0, nil
+	case OpGt:
+		return n > 0, nil
+	case OpGe:
+		return n >= 0, nil
+	case OpLt:
+		return n < 0, nil
+	case OpLe:
+		return n <= 0, nil
+	default:
+		return false, fmt.Errorf("unknown filter operator %q", c.Op)
+	}
+}
+
+// compareLiteral compares a field value from fieldValue with a bound literal,
+// returning -1, 0 or +1.
+func compareLiteral(value, literal interface{}) (int, error) {
+	switch v := value.(type) {
+	case string:
+		if l, ok := literal.(string); ok {
+			return cmp.Compare(v, l), nil
+		}
+	case bool:
+		if l, ok := literal.(bool); ok {
+			switch {
+			case v == l:
+				return 0, nil
+			case l:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	case time.Time:
+		if l, ok := literal.(time.Time); ok {
+			return v.Compare(l), nil
+		}
+	case int64, uint64, float64:
+		a, aok := toFloat64(v)
+		b, bok := toFloat64(literal)
+		if aok && bok {
+			return cmp.Compare(a, b), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", value, literal)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
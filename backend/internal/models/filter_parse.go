@@ -0,0 +1,322 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits on parsed filter expressions, so a single query parameter cannot make
+// the parser or the generated query arbitrarily expensive.
+const (
+	maxFilterLength = 2048
+	maxFilterDepth  = 32
+	maxInValues     = 100
+)
+
+// ParseFilter parses a filter expression such as
+//
+//	price gt 10 and (name contains "foo" or name startswith "bar")
+//
+// The grammar, with case-insensitive keywords, is:
+//
+//	expr       = term { "or" term }
+//	term       = factor { "and" factor }
+//	factor     = "not" factor | "(" expr ")" | comparison
+//	comparison = field op value
+//	           | field "in" "(" value { "," value } ")"
+//	           | field "between" value "and" value
+//	           | field "isnull"
+//	op         = "eq" | "ne" | "gt" | "ge" | "lt" | "le"
+//	           | "contains" | "startswith" | "endswith"
+//	value      = string | number | "true" | "false"
+//
+// Strings are double- or single-quoted; a quote is escaped by doubling it or
+// with a backslash. Field names are not checked here; see BindExpr.
+func ParseFilter(s string) (Expr, error) {
+	if len(s) > maxFilterLength {
+		return nil, fmt.Errorf("filter longer than %d characters", maxFilterLength)
+	}
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string // identifier or keyword text, or the unquoted string value
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// is reports whether t is the given keyword.
+func (t token) is(keyword string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, keyword)
+}
+
+func lexFilter(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			value, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at position %d", err, i)
+			}
+			tokens = append(tokens, token{kind: tokString, text: value, pos: i})
+			i += n
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(s) && (s[i] == '.' || s[i] == 'e' || s[i] == 'E' || (s[i] >= '0' && s[i] <= '9') ||
+				((s[i] == '-' || s[i] == '+') && (s[i-1] == 'e' || s[i-1] == 'E'))) {
+				i++
+			}
+			n, err := strconv.ParseFloat(s[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", s[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: s[start:i], num: n, pos: start})
+		case isIdentByte(c) && !(c >= '0' && c <= '9'):
+			start := i
+			for i < len(s) && isIdentByte(s[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: s[start:i], pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+// isIdentByte reports whether c may appear in a field name or keyword.
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// lexString reads a quoted string at the start of s and returns its value and
+// the number of bytes consumed.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == quote && i+1 < len(s) && s[i+1] == quote:
+			i++
+			b.WriteByte(quote)
+		case c == quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() token { return p.tokens[p.pos] }
+
+func (p *filterParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("or") {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseFactor(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("and") {
+		p.next()
+		right, err := p.parseFactor(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseFactor(depth int) (Expr, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("filter nested deeper than %d levels", maxFilterDepth)
+	}
+	tok := p.peek()
+	switch {
+	case tok.is("not"):
+		p.next()
+		inner, err := p.parseFactor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: inner}, nil
+	case tok.kind == tokLParen:
+		p.next()
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\" at position %d, got %s", closing.pos, closing)
+		}
+		return inner, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *filterParser) parseComparison() (Expr, error) {
+	field := p.next()
+	if field.kind != tokIdent || isFilterKeyword(field.text) {
+		return nil, fmt.Errorf("expected field name at position %d, got %s", field.pos, field)
+	}
+	opTok := p.next()
+	if opTok.kind != tokIdent {
+		return nil, fmt.Errorf("expected operator at position %d, got %s", opTok.pos, opTok)
+	}
+	cmp := Comparison{Field: field.text, Op: CompareOp(strings.ToLower(opTok.text))}
+
+	switch cmp.Op {
+	case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe, OpContains, OpStartsWith, OpEndsWith:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []interface{}{value}
+	case OpIn:
+		if open := p.next(); open.kind != tokLParen {
+			return nil, fmt.Errorf("expected \"(\" after in at position %d, got %s", open.pos, open)
+		}
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			cmp.Values = append(cmp.Values, value)
+			if len(cmp.Values) > maxInValues {
+				return nil, fmt.Errorf("in list longer than %d values", maxInValues)
+			}
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return nil, fmt.Errorf("expected \",\" or \")\" at position %d, got %s", sep.pos, sep)
+			}
+		}
+	case OpBetween:
+		low, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if and := p.next(); !and.is("and") {
+			return nil, fmt.Errorf("expected and in between at position %d, got %s", and.pos, and)
+		}
+		high, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []interface{}{low, high}
+	case OpIsNull:
+	default:
+		return nil, fmt.Errorf("unknown operator %s at position %d", opTok, opTok.pos)
+	}
+	return cmp, nil
+}
+
+func (p *filterParser) parseValue() (interface{}, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokString:
+		return tok.text, nil
+	case tok.kind == tokNumber:
+		return tok.num, nil
+	case tok.is("true"):
+		return true, nil
+	case tok.is("false"):
+		return false, nil
+	default:
+		return nil, fmt.Errorf("expected value at position %d, got %s", tok.pos, tok)
+	}
+}
+
+// isFilterKeyword reports whether s is reserved and cannot be a field name.
+func isFilterKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "true", "false":
+		return true
+	}
+	return false
+}
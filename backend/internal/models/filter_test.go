@@ -0,0 +1,179 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/pkg/dberrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    Expr
+		wantErr string
+	}{
+		{
+			name:  "single comparison",
+			input: "price gt 10",
+			want:  Comparison{Field: "price", Op: OpGt, Values: []interface{}{10.0}},
+		},
+		{
+			name:  "and binds tighter than or",
+			input: `price gt 10 and name contains "foo" or name startswith 'bar'`,
+			want: Or{
+				Left: And{
+					Left:  Comparison{Field: "price", Op: OpGt, Values: []interface{}{10.0}},
+					Right: Comparison{Field: "name", Op: OpContains, Values: []interface{}{"foo"}},
+				},
+				Right: Comparison{Field: "name", Op: OpStartsWith, Values: []interface{}{"bar"}},
+			},
+		},
+		{
+			name:  "parentheses and case-insensitive keywords",
+			input: `price GT 10 AND (name contains "foo" OR name startswith "bar")`,
+			want: And{
+				Left: Comparison{Field: "price", Op: OpGt, Values: []interface{}{10.0}},
+				Right: Or{
+					Left:  Comparison{Field: "name", Op: OpContains, Values: []interface{}{"foo"}},
+					Right: Comparison{Field: "name", Op: OpStartsWith, Values: []interface{}{"bar"}},
+				},
+			},
+		},
+		{
+			name:  "in, between, isnull and not",
+			input: `name in ("a", "b") and price between -1.5 and 2e3 and not name isnull`,
+			want: And{
+				Left: And{
+					Left:  Comparison{Field: "name", Op: OpIn, Values: []interface{}{"a", "b"}},
+					Right: Comparison{Field: "price", Op: OpBetween, Values: []interface{}{-1.5, 2000.0}},
+				},
+				Right: Not{Expr: Comparison{Field: "name", Op: OpIsNull}},
+			},
+		},
+		{
+			name:  "escaped quotes",
+			input: `name eq "say \"hi\"" or name eq 'o''brien'`,
+			want: Or{
+				Left:  Comparison{Field: "name", Op: OpEq, Values: []interface{}{`say "hi"`}},
+				Right: Comparison{Field: "name", Op: OpEq, Values: []interface{}{"o'brien"}},
+			},
+		},
+		{name: "boolean literal", input: "active ne false", want: Comparison{Field: "active", Op: OpNe, Values: []interface{}{false}}},
+		{name: "unknown operator", input: "price like 10", wantErr: "unknown operator"},
+		{name: "missing value", input: "price gt", wantErr: "expected value"},
+		{name: "bare identifier value", input: "name eq foo", wantErr: "expected value"},
+		{name: "unbalanced parenthesis", input: "(price gt 1", wantErr: `expected ")"`},
+		{name: "trailing tokens", input: "price gt 1 price", wantErr: "unexpected"},
+		{name: "unterminated string", input: `name eq "abc`, wantErr: "unterminated string"},
+		{name: "keyword as field", input: "and eq 1", wantErr: "expected field name"},
+		{name: "invalid character", input: "price gt 1; drop table items", wantErr: "unexpected character"},
+		{name: "empty in list", input: "name in ()", wantErr: "expected value"},
+		{name: "between without and", input: "price between 1 or 2", wantErr: "expected and"},
+		{name: "too deep", input: strings.Repeat("(", 40) + "price gt 1" + strings.Repeat(")", 40), wantErr: "nested deeper"},
+		{name: "too long", input: "name eq \"" + strings.Repeat("a", maxFilterLength) + "\"", wantErr: "longer than"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseFilter(tt.input)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBindExpr(t *testing.T) {
+	t.Parallel()
+
+	allowed := map[string]bool{"name": true, "price": true, "version": true, "created_at": true}
+	itemType := reflect.TypeOf(Item{})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    Expr
+		wantErr bool
+	}{
+		{name: "float field", input: "price ge 2", want: Comparison{Field: "price", Op: OpGe, Values: []interface{}{2.0}}},
+		{name: "unsigned field", input: "version eq 3", want: Comparison{Field: "version", Op: OpEq, Values: []interface{}{uint64(3)}}},
+		{
+			name:  "timestamp field",
+			input: `created_at lt "2025-01-02T03:04:05+01:00"`,
+			want:  Comparison{Field: "created_at", Op: OpLt, Values: []interface{}{time.Date(2025, 1, 2, 2, 4, 5, 0, time.UTC)}},
+		},
+		{name: "field not whitelisted", input: "id eq 1", wantErr: true},
+		{name: "unknown field", input: "colour eq 'red'", wantErr: true},
+		{name: "string for number", input: "price eq '10'", wantErr: true},
+		{name: "fraction for integer", input: "version eq 1.5", wantErr: true},
+		{name: "negative for unsigned", input: "version eq -1", wantErr: true},
+		{name: "text operator on number", input: "price contains 1", wantErr: true},
+		{name: "bad timestamp", input: "created_at gt 'yesterday'", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			expr, err := ParseFilter(tt.input)
+			require.NoError(t, err)
+			got, err := BindExpr(expr, itemType, allowed)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, dberrors.ErrValidation)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchExpr(t *testing.T) {
+	t.Parallel()
+
+	allowed := map[string]bool{"name": true, "price": true, "deleted_at": true}
+	item := Item{Name: "Blue Widget", Price: 12.5}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{filter: "price gt 10", want: true},
+		{filter: "price between 12.5 and 20", want: true},
+		{filter: "price in (1, 2)", want: false},
+		{filter: `name eq "blue widget"`, want: false},
+		{filter: `name contains "WIDGET"`, want: true},
+		{filter: `name startswith "blue" and name endswith "get"`, want: true},
+		{filter: `not (name ne "Blue Widget") or price lt 0`, want: true},
+		{filter: "deleted_at isnull", want: true},
+		{filter: `deleted_at lt "2030-01-01T00:00:00Z"`, want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.filter, func(t *testing.T) {
+			t.Parallel()
+			expr, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			bound, err := BindExpr(expr, reflect.TypeOf(item), allowed)
+			require.NoError(t, err)
+			got, err := MatchExpr(bound, &item)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -4,20 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
 	"backend/pkg/dberrors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Base model with common fields
 type Base struct {
-	ID        uint       `gorm:"primarykey" json:"id" example:"1" description:"@Description Unique identifier"`
-	CreatedAt time.Time  `json:"created_at" example:"2025-06-02T10:00:00Z" description:"@Description Creation timestamp"`
-	UpdatedAt time.Time  `json:"updated_at" example:"2025-06-02T10:00:00Z" description:"@Description Last update timestamp"`
-	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty" format:"date-time" description:"@Description Soft delete timestamp"`
+	ID        uint           `gorm:"primarykey" json:"id" example:"1" description:"@Description Unique identifier"`
+	CreatedAt time.Time      `json:"created_at" example:"2025-06-02T10:00:00Z" description:"@Description Creation timestamp"`
+	UpdatedAt time.Time      `json:"updated_at" example:"2025-06-02T10:00:00Z" description:"@Description Last update timestamp"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string" format:"date-time" description:"@Description Soft delete timestamp (null while the record is live)"`
 }
 
 // Item represents a basic item in the system
@@ -34,6 +36,31 @@ type User struct {
 	Username string `gorm:"size:255;not null;unique" json:"username"`
 	Email    string `gorm:"size:255;not null;unique" json:"email"`
 	Name     string `gorm:"size:255" json:"name"`
+	// PasswordHash is the bcrypt hash of the user's password, empty for users
+	// who cannot log in. It is never serialised to clients.
+	PasswordHash string `gorm:"size:255" json:"-"`
+	// Roles name the roles granting the user permissions; see package auth.
+	// They are assigned through the admin endpoints, not by the user.
+	Roles []string `gorm:"serializer:json;size:1024" json:"roles"`
+}
+
+// APIKey is a credential for callers that cannot log in interactively, such
+// as batch jobs. Only a hash of the key is stored; Prefix identifies the key
+// in listings and lookups. Revoking a key soft-deletes it.
+type APIKey struct {
+	Base
+	Name    string `gorm:"size:255;not null" json:"name"`
+	Prefix  string `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	KeyHash string `gorm:"size:64;not null" json:"-"`
+	// UserID is the user who created the key.
+	UserID uint `gorm:"index" json:"user_id"`
+	// Scopes are the permissions the key grants; see package auth.
+	Scopes []string `gorm:"serializer:json;size:1024" json:"scopes"`
+	// RateLimit overrides the requests allowed per rate-limit window for the
+	// key; 0 uses the default limit.
+	RateLimit  int        `json:"rate_limit"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
 }
 
 // Validator is an interface for model validation
@@ -53,37 +80,44 @@ func (i *Item) GetVersion() uint { return i.Version }
 // SetVersion implements Versionable for Item.
 func (i *Item) SetVersion(v uint) { i.Version = v }
 
-// Repository defines the interface for database operations.
-type Repository interface {
+// Store defines the untyped interface for database operations shared by all
+// registered models. Application code should prefer the type-safe
+// Repository[T] returned by NewTypedRepository, which wraps a Store.
+//
+// Delete is a soft delete: the record is hidden from FindByID and List until
+// it is restored, unless IncludeDeleted is passed as a condition. Like Update,
+// it fails with a version mismatch when a Versionable entity's non-zero
+// version is stale; a zero version deletes unconditionally. Restore reports
+// whether the record was deleted, since restoring a live record is a no-op.
+// Purge permanently removes records soft-deleted before the given time.
+type Store interface {
 	Create(ctx context.Context, entity interface{}) error
-	FindByID(ctx context.Context, id uint, dest interface{}) error
+	FindByID(ctx context.Context, id uint, dest interface{}, conditions ...interface{}) error
 	Update(ctx context.Context, entity interface{}) error
 	Delete(ctx context.Context, entity interface{}) error
+	Restore(ctx context.Context, entity interface{}) (bool, error)
+	Purge(ctx context.Context, model interface{}, deletedBefore time.Time) (int64, error)
 	List(ctx context.Context, dest interface{}, conditions ...interface{}) error
 	Ping(ctx context.Context) error
 	Close() error
 }
 
-// GenericRepository implements the Repository interface
+// GenericRepository implements the Store interface on top of GORM for any
+// registered model.
 type GenericRepository struct {
 	db                  *gorm.DB
 	allowedFilterFields map[string]bool
 }
 
-// NewRepository creates a new GenericRepository with filter fields for the Item
-// entity ("name", "price"). For other entity types, use NewRepositoryWithFilterFields.
-func NewRepository(db *gorm.DB) Repository {
-	return &GenericRepository{
-		db: db,
-		allowedFilterFields: map[string]bool{
-			"name":  true,
-			"price": true,
-		},
-	}
+// NewRepository creates a new GenericRepository. Filter fields are whitelisted
+// per model from the registry (see RegisterModel).
+func NewRepository(db *gorm.DB) Store {
+	return &GenericRepository{db: db}
 }
 
-// NewRepositoryWithFilterFields creates a GenericRepository with a custom filter field whitelist.
-func NewRepositoryWithFilterFields(db *gorm.DB, fields []string) Repository {
+// NewRepositoryWithFilterFields creates a GenericRepository with a custom filter
+// field whitelist that applies to every model, overriding the registry.
+func NewRepositoryWithFilterFields(db *gorm.DB, fields []string) Store {
 	allowed := make(map[string]bool, len(fields))
 	for _, f := range fields {
 		allowed[f] = true
@@ -91,6 +125,12 @@ func NewRepositoryWithFilterFields(db *gorm.DB, fields []string) Repository {
 	return &GenericRepository{db: db, allowedFilterFields: allowed}
 }
 
+// DB returns the connection the repository uses, for components that keep
+// their own tables in the same database.
+func (r *GenericRepository) DB() *gorm.DB {
+	return r.db
+}
+
 // Ping checks if the database is reachable
 func (r *GenericRepository) Ping(ctx context.Context) error {
 	sqlDB, err := r.db.DB()
@@ -123,8 +163,12 @@ func (r *GenericRepository) Create(ctx context.Context, entity interface{}) erro
 	return nil
 }
 
-func (r *GenericRepository) FindByID(ctx context.Context, id uint, dest interface{}) error {
-	if err := r.db.WithContext(ctx).First(dest, id).Error; err != nil {
+func (r *GenericRepository) FindByID(ctx context.Context, id uint, dest interface{}, conditions ...interface{}) error {
+	query := r.db.WithContext(ctx)
+	if IncludesDeleted(conditions) {
+		query = query.Unscoped()
+	}
+	if err := query.First(dest, id).Error; err != nil {
 		return r.handleError("find", err)
 	}
 	return nil
@@ -172,23 +216,109 @@ func (r *GenericRepository) Update(ctx context.Context, entity interface{}) erro
 	return nil
 }
 
+// Delete soft-deletes entity by setting its deleted_at column. Deleting a
+// record that is already soft-deleted returns ErrNotFound. A Versionable
+// entity with a non-zero version is only deleted if the stored version still
+// matches.
 func (r *GenericRepository) Delete(ctx context.Context, entity interface{}) error {
-	result := r.db.WithContext(ctx).Delete(entity)
+	query := r.db.WithContext(ctx)
+	ver, versioned := entity.(Versionable)
+	conditional := versioned && ver.GetVersion() > 0
+	if conditional {
+		query = query.Where("version = ?", ver.GetVersion())
+	}
+
+	result := query.Delete(entity)
 	if result.Error != nil {
 		return r.handleError("delete", result.Error)
 	}
 	if result.RowsAffected == 0 {
+		if conditional {
+			// Tell a stale version apart from a missing record.
+			var live int64
+			err := r.db.WithContext(ctx).Model(entity).
+				Where("id = ?", entity.(Entity).GetID()).Count(&live).Error
+			if err != nil {
+				return r.handleError("delete", err)
+			}
+			if live > 0 {
+				return dberrors.NewDatabaseError("delete", errors.New("version mismatch"))
+			}
+		}
 		return dberrors.NewDatabaseError("delete", dberrors.ErrNotFound)
 	}
 	return nil
 }
 
+// Restore clears the deleted_at column of a soft-deleted entity and reloads it
+// into entity. Versionable entities get a new version, since restoring is a
+// modification clients may need to detect. Restoring a live record is a no-op
+// and reports false.
+func (r *GenericRepository) Restore(ctx context.Context, entity interface{}) (bool, error) {
+	e, ok := entity.(Entity)
+	if !ok || e.GetID() == 0 {
+		return false, dberrors.NewDatabaseError("restore", dberrors.ErrValidation)
+	}
+
+	updates := map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()}
+	if _, ok := entity.(Versionable); ok {
+		updates["version"] = gorm.Expr("version + 1")
+	}
+
+	// Each statement starts from a fresh Unscoped session so the
+	// "deleted_at IS NOT NULL" condition does not leak into the reload.
+	db := r.db.WithContext(ctx)
+	result := db.Unscoped().Model(entity).Where("deleted_at IS NOT NULL").Updates(updates)
+	if result.Error != nil {
+		return false, r.handleError("restore", result.Error)
+	}
+	if err := db.Unscoped().First(entity, e.GetID()).Error; err != nil {
+		return false, r.handleError("restore", err)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Purge permanently deletes records of model's type that were soft-deleted
+// before deletedBefore and returns how many were removed.
+func (r *GenericRepository) Purge(ctx context.Context, model interface{}, deletedBefore time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Delete(model)
+	if result.Error != nil {
+		return 0, r.handleError("purge", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
 func (r *GenericRepository) List(ctx context.Context, dest interface{}, conditions ...interface{}) error {
+	// Registration is only needed for the filter whitelist and expressions,
+	// so the whitelist override works with unregistered models.
+	info, lookupErr := LookupModel(dest)
+	allowed := r.allowedFilterFields
+	if allowed == nil {
+		if lookupErr != nil {
+			return dberrors.NewDatabaseError("list", lookupErr)
+		}
+		allowed = info.FilterFields
+	}
+
+	var (
+		cursor     *Cursor
+		count      *Count
+		pagination *Pagination
+		sort       Sort
+	)
 	query := r.db.WithContext(ctx)
 	for _, cond := range conditions {
 		switch c := cond.(type) {
+		case *Cursor:
+			cursor = c
+		case *Count:
+			count = c
+		case IncludeDeleted:
+			query = query.Unscoped()
 		case Filter:
-			if !r.allowedFilterFields[c.Field] {
+			if !allowed[c.Field] {
 				return dberrors.NewDatabaseError("list",
 					fmt.Errorf("invalid filter field: %q", c.Field))
 			}
@@ -208,13 +338,58 @@ func (r *GenericRepository) List(ctx context.Context, dest interface{}, conditio
 				escaped := strings.NewReplacer("%", "\\%", "_", "\\_").Replace(fmt.Sprint(c.Value))
 				query = query.Where(fmt.Sprintf("%s LIKE ?", c.Field), "%"+escaped+"%")
 			}
-		case Pagination:
-			if c.Limit > 0 {
-				query = query.Limit(c.Limit)
+		case Expr:
+			if lookupErr != nil {
+				return dberrors.NewDatabaseError("list", lookupErr)
 			}
-			if c.Offset > 0 {
-				query = query.Offset(c.Offset)
+			bound, err := BindExpr(c, info.Type, allowed)
+			if err != nil {
+				return dberrors.NewDatabaseError("list", err)
 			}
+			sql, args, err := exprSQL(bound)
+			if err != nil {
+				return dberrors.NewDatabaseError("list", err)
+			}
+			query = query.Where(sql, args...)
+		case Pagination:
+			pagination = &c
+		case Sort:
+			for _, f := range c {
+				if !Sortable(f.Field, allowed) {
+					return dberrors.NewDatabaseError("list",
+						fmt.Errorf("%w: invalid sort field: %q", dberrors.ErrValidation, f.Field))
+				}
+			}
+			sort = c
+		}
+	}
+
+	// Count on a copy of the filtered query, before limit and offset apply.
+	if count != nil {
+		if err := query.Session(&gorm.Session{}).Model(dest).Count(&count.Total).Error; err != nil {
+			return r.handleError("count", err)
+		}
+	}
+	if cursor != nil {
+		if len(sort) > 0 {
+			return dberrors.NewDatabaseError("list",
+				fmt.Errorf("%w: sort is not supported with cursor pagination", dberrors.ErrValidation))
+		}
+		return r.listPage(query, dest, cursor)
+	}
+	if len(sort) > 0 {
+		// Column names are whitelisted above and quoted by the dialect.
+		for _, f := range sort {
+			query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: f.Field}, Desc: f.Desc})
+		}
+		query = query.Order("id")
+	}
+	if pagination != nil {
+		if pagination.Limit > 0 {
+			query = query.Limit(pagination.Limit)
+		}
+		if pagination.Offset > 0 {
+			query = query.Offset(pagination.Offset)
 		}
 	}
 	if err := query.Find(dest).Error; err != nil {
@@ -223,6 +398,35 @@ func (r *GenericRepository) List(ctx context.Context, dest interface{}, conditio
 	return nil
 }
 
+// listPage runs query as a keyset page ordered by primary key. One extra row
+// is fetched to learn whether another page follows without a COUNT query.
+// Offset and limit from a Pagination condition are overridden.
+func (r *GenericRepository) listPage(query *gorm.DB, dest interface{}, cursor *Cursor) error {
+	if cursor.Limit <= 0 {
+		return dberrors.NewDatabaseError("list", fmt.Errorf("%w: cursor limit must be positive", dberrors.ErrValidation))
+	}
+	if cursor.Token != "" {
+		var position keysetCursor
+		if err := DecodeCursor(cursor.Token, &position); err != nil {
+			return dberrors.NewDatabaseError("list", fmt.Errorf("%w: %s", dberrors.ErrValidation, err.Error()))
+		}
+		query = query.Where("id > ?", position.ID)
+	}
+
+	if err := query.Order("id").Offset(-1).Limit(cursor.Limit + 1).Find(dest).Error; err != nil {
+		return r.handleError("list", err)
+	}
+
+	cursor.Next = ""
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > cursor.Limit {
+		rows.Set(rows.Slice(0, cursor.Limit))
+		last := rows.Index(cursor.Limit - 1).Addr().Interface().(Entity)
+		cursor.Next = EncodeCursor(keysetCursor{ID: last.GetID()})
+	}
+	return nil
+}
+
 // handleError translates database errors into our custom error types
 func (r *GenericRepository) handleError(op string, err error) error {
 	if err == nil {
@@ -232,7 +436,9 @@ func (r *GenericRepository) handleError(op string, err error) error {
 	switch {
 	case err == gorm.ErrRecordNotFound:
 		return dberrors.NewDatabaseError(op, dberrors.ErrNotFound)
-	case strings.Contains(err.Error(), "Duplicate entry"):
+	case strings.Contains(err.Error(), "Duplicate entry"),
+		strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		// MySQL reports "Duplicate entry", SQLite "UNIQUE constraint failed".
 		return dberrors.NewDatabaseError(op, dberrors.ErrDuplicateKey)
 	case strings.Contains(err.Error(), "validation failed"):
 		return dberrors.NewDatabaseError(op, dberrors.ErrValidation)
@@ -248,8 +454,29 @@ type Filter struct {
 	Value interface{} `json:"value"`
 }
 
+// Count asks List to also report how many records match the other
+// conditions, ignoring Pagination and Cursor. Pass a pointer so List can set
+// Total.
+type Count struct {
+	Total int64
+}
+
 // Pagination represents pagination parameters for queries
 type Pagination struct {
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
 }
+
+// IncludeDeleted is a query condition for FindByID and List that also returns
+// soft-deleted records.
+type IncludeDeleted struct{}
+
+// IncludesDeleted reports whether conditions contain IncludeDeleted.
+func IncludesDeleted(conditions []interface{}) bool {
+	for _, c := range conditions {
+		if _, ok := c.(IncludeDeleted); ok {
+			return true
+		}
+	}
+	return false
+}
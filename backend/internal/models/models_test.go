@@ -27,3 +27,37 @@ func TestModelValidation(t *testing.T) {
 		assert.False(t, model.UpdatedAt.IsZero())
 	})
 }
+
+func TestUserPassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "valid password", password: "correct horse"},
+		{name: "too short", password: "short", wantErr: ErrPasswordTooShort},
+		{name: "too long", password: string(make([]byte, 73)), wantErr: ErrPasswordTooLong},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var user User
+			err := user.SetPassword(tt.password)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, user.PasswordHash)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotEqual(t, tt.password, user.PasswordHash)
+			assert.True(t, user.CheckPassword(tt.password))
+			assert.False(t, user.CheckPassword(tt.password+"!"))
+		})
+	}
+
+	assert.False(t, (&User{}).CheckPassword(""), "users without a password cannot log in")
+}
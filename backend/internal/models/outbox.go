@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxEvent is a change event in a store's outbox. It is recorded in the
+// same transaction as the change it describes, then delivered by an outbox
+// dispatcher at least once.
+type OutboxEvent struct {
+	// Seq orders the events of the SQL outbox. Table Storage orders them by
+	// EventID.
+	Seq uint64 `gorm:"primaryKey;autoIncrement"`
+	// EventID identifies the event, so that consumers can drop the
+	// duplicates of redelivered events; see NewEventID.
+	EventID string `gorm:"size:32;uniqueIndex;not null"`
+	// Topic is the hub topic the event is published to. Its collection names
+	// the partition holding the event in Table Storage.
+	Topic string `gorm:"size:128;not null"`
+	// Message is the event as published, a serialised websocket.Message.
+	Message   []byte    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	// SentAt is when the event was delivered, or nil while it is pending.
+	SentAt *time.Time `gorm:"index"`
+}
+
+// TableName implements gorm's Tabler.
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// NewEventID returns a new OutboxEvent.EventID: 32 hex digits, the first 16
+// of which are the current time, so that IDs sort in creation order.
+func NewEventID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(b))
+}
+
+// Outboxer is implemented by stores with an outbox, which record change
+// events in the same transaction as the changes they describe. Stores that
+// do not implement it cannot guarantee the delivery of change events.
+type Outboxer interface {
+	// WithEvents calls fn with a store to make changes through, and commits
+	// them together with the events fn returns: either all are committed or
+	// none is. Reads through the store may not see the changes made.
+	WithEvents(ctx context.Context, fn func(tx Store) ([]OutboxEvent, error)) error
+
+	// PendingEvents returns up to limit events not yet marked as sent,
+	// oldest first.
+	PendingEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+
+	// MarkEventsSent marks events, returned by PendingEvents, as delivered.
+	MarkEventsSent(ctx context.Context, events []OutboxEvent) error
+
+	// PurgeSentEvents deletes the events delivered before sentBefore and
+	// returns how many were deleted.
+	PurgeSentEvents(ctx context.Context, sentBefore time.Time) (int64, error)
+}
+
+// WithEvents implements Outboxer. fn's changes and the events are written
+// in one database transaction, to the outbox_events table.
+func (r *GenericRepository) WithEvents(ctx context.Context, fn func(tx Store) ([]OutboxEvent, error)) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		events, err := fn(&GenericRepository{db: tx, allowedFilterFields: r.allowedFilterFields})
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		if err := tx.Create(&events).Error; err != nil {
+			return r.handleError("outbox", err)
+		}
+		return nil
+	})
+}
+
+// PendingEvents implements Outboxer.
+func (r *GenericRepository) PendingEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := r.db.WithContext(ctx).Where("sent_at IS NULL").Order("seq").Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, r.handleError("outbox", err)
+	}
+	return events, nil
+}
+
+// MarkEventsSent implements Outboxer.
+func (r *GenericRepository) MarkEventsSent(ctx context.Context, events []OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	seqs := make([]uint64, len(events))
+	for i, e := range events {
+		seqs[i] = e.Seq
+	}
+	err := r.db.WithContext(ctx).Model(&OutboxEvent{}).Where("seq IN ?", seqs).
+		Update("sent_at", time.Now().UTC()).Error
+	return r.handleError("outbox", err)
+}
+
+// PurgeSentEvents implements Outboxer.
+func (r *GenericRepository) PurgeSentEvents(ctx context.Context, sentBefore time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("sent_at < ?", sentBefore).Delete(&OutboxEvent{})
+	if result.Error != nil {
+		return 0, r.handleError("outbox", result.Error)
+	}
+	return result.RowsAffected, nil
+}
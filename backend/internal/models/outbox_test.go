@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/pkg/dberrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupOutboxRepo(t *testing.T) *GenericRepository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&widget{}, &OutboxEvent{}))
+	return NewRepository(db).(*GenericRepository)
+}
+
+func outboxEvent(topic string) OutboxEvent {
+	return OutboxEvent{EventID: NewEventID(), Topic: topic, Message: []byte(`{}`), CreatedAt: time.Now().UTC()}
+}
+
+func TestNewEventID(t *testing.T) {
+	t.Parallel()
+	a, b := NewEventID(), NewEventID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+	assert.LessOrEqual(t, a[:16], b[:16], "IDs sort in creation order")
+}
+
+func TestGenericRepository_WithEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("commits changes with their events", func(t *testing.T) {
+		t.Parallel()
+		repo := setupOutboxRepo(t)
+
+		first, second := outboxEvent("widgets:1"), outboxEvent("widgets")
+		err := repo.WithEvents(ctx, func(tx Store) ([]OutboxEvent, error) {
+			if err := tx.Create(ctx, &widget{Label: "a"}); err != nil {
+				return nil, err
+			}
+			return []OutboxEvent{first, second}, nil
+		})
+		require.NoError(t, err)
+
+		var widgets []widget
+		require.NoError(t, repo.List(ctx, &widgets))
+		assert.Len(t, widgets, 1)
+		pending, err := repo.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, first.EventID, pending[0].EventID)
+		assert.Equal(t, second.EventID, pending[1].EventID)
+		assert.Less(t, pending[0].Seq, pending[1].Seq)
+		assert.Nil(t, pending[0].SentAt)
+	})
+
+	t.Run("rolls back changes when fn fails", func(t *testing.T) {
+		t.Parallel()
+		repo := setupOutboxRepo(t)
+
+		failure := errors.New("failure")
+		err := repo.WithEvents(ctx, func(tx Store) ([]OutboxEvent, error) {
+			require.NoError(t, tx.Create(ctx, &widget{Label: "a"}))
+			return []OutboxEvent{outboxEvent("widgets")}, failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		var widgets []widget
+		require.NoError(t, repo.List(ctx, &widgets))
+		assert.Empty(t, widgets)
+		pending, err := repo.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("rolls back changes when events cannot be recorded", func(t *testing.T) {
+		t.Parallel()
+		repo := setupOutboxRepo(t)
+
+		event := outboxEvent("widgets")
+		err := repo.WithEvents(ctx, func(tx Store) ([]OutboxEvent, error) {
+			require.NoError(t, tx.Create(ctx, &widget{Label: "a"}))
+			return []OutboxEvent{event, event}, nil
+		})
+		assert.ErrorIs(t, err, dberrors.ErrDuplicateKey)
+
+		var widgets []widget
+		require.NoError(t, repo.List(ctx, &widgets))
+		assert.Empty(t, widgets)
+	})
+}
+
+func TestGenericRepository_OutboxDelivery(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := setupOutboxRepo(t)
+
+	var events []OutboxEvent
+	for i := 0; i < 3; i++ {
+		events = append(events, outboxEvent("widgets"))
+	}
+	require.NoError(t, repo.WithEvents(ctx, func(Store) ([]OutboxEvent, error) { return events, nil }))
+
+	pending, err := repo.PendingEvents(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, events[0].EventID, pending[0].EventID)
+	require.NoError(t, repo.MarkEventsSent(ctx, pending))
+	require.NoError(t, repo.MarkEventsSent(ctx, nil))
+
+	pending, err = repo.PendingEvents(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, events[2].EventID, pending[0].EventID)
+
+	deleted, err := repo.PurgeSentEvents(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, deleted, "recently sent events are kept")
+	deleted, err = repo.PurgeSentEvents(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted, "pending events are kept")
+	pending, err = repo.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+}
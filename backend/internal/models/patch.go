@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"backend/pkg/dberrors"
+)
+
+// Patcher is implemented by stores that can write a subset of an entity's
+// columns, leaving the others as stored. Like Update, Patch enforces
+// optimistic locking for Versionable entities and bumps their version.
+type Patcher interface {
+	Patch(ctx context.Context, entity interface{}, columns ...string) error
+}
+
+// storeManagedColumns are maintained by the stores and cannot be patched.
+var storeManagedColumns = map[string]bool{
+	"id": true, "created_at": true, "updated_at": true, "deleted_at": true, "version": true,
+}
+
+// PatchColumns checks that columns name patchable columns of entity's model:
+// columns that exist and are not maintained by the store.
+func PatchColumns(entity interface{}, columns []string) error {
+	t := reflect.TypeOf(entity)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: cannot patch %T", dberrors.ErrValidation, entity)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("%w: no columns to patch", dberrors.ErrValidation)
+	}
+	known := columnsOf(t)
+	for _, c := range columns {
+		if _, ok := known[c]; !ok || storeManagedColumns[c] {
+			return fmt.Errorf("%w: column %q cannot be patched", dberrors.ErrValidation, c)
+		}
+	}
+	return nil
+}
+
+// Patch implements Patcher. Only columns, updated_at and, for Versionable
+// entities, version are written.
+func (r *GenericRepository) Patch(ctx context.Context, entity interface{}, columns ...string) error {
+	if err := PatchColumns(entity, columns); err != nil {
+		return dberrors.NewDatabaseError("patch", err)
+	}
+	if v, ok := entity.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return dberrors.NewDatabaseError("validate",
+				fmt.Errorf("%w: %s", dberrors.ErrValidation, err.Error()))
+		}
+	}
+
+	selected := append(append([]string{}, columns...), "updated_at")
+	query := r.db.WithContext(ctx).Model(entity)
+	ver, versioned := entity.(Versionable)
+	var currentVersion uint
+	if versioned {
+		currentVersion = ver.GetVersion()
+		ver.SetVersion(currentVersion + 1)
+		query = query.Where("version = ?", currentVersion)
+		selected = append(selected, "version")
+	}
+
+	result := query.Select(selected).Updates(entity)
+	if result.Error != nil {
+		if versioned {
+			ver.SetVersion(currentVersion)
+		}
+		return r.handleError("patch", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if versioned {
+			ver.SetVersion(currentVersion)
+			return dberrors.NewDatabaseError("patch", errors.New("version mismatch"))
+		}
+		return dberrors.NewDatabaseError("patch", dberrors.ErrNotFound)
+	}
+	return nil
+}
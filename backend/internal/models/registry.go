@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Entity is implemented by every persistable model through the embedded Base.
+// Repositories use it to read and assign primary keys without knowing the
+// concrete model type.
+type Entity interface {
+	GetID() uint
+	SetID(id uint)
+}
+
+// GetID implements Entity.
+func (b *Base) GetID() uint { return b.ID }
+
+// SetID implements Entity.
+func (b *Base) SetID(id uint) { b.ID = id }
+
+// ModelInfo describes a model registered with RegisterModel.
+type ModelInfo struct {
+	// Type is the struct type of the model (e.g. models.Item, not *models.Item).
+	Type reflect.Type
+
+	// FilterFields whitelists the column names that may be used in Filter conditions.
+	FilterFields map[string]bool
+
+	// Name identifies the model in storage: the SQL table name and the Azure
+	// Table Storage partition key.
+	Name string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[reflect.Type]ModelInfo)
+)
+
+func init() {
+	RegisterModel[Item]("items", "name", "price")
+	RegisterModel[User]("users", "username", "email", "name")
+	RegisterModel[APIKey]("api_keys", "prefix", "name", "user_id")
+}
+
+// RegisterModel makes T available to every repository backend under the given
+// storage name. filterFields lists the column names clients may filter on.
+// T must be a struct type embedding Base. Registering the same type twice
+// replaces the previous registration.
+func RegisterModel[T any](name string, filterFields ...string) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("models: RegisterModel requires a struct type, got %s", t))
+	}
+	if _, ok := reflect.New(t).Interface().(Entity); !ok {
+		panic(fmt.Sprintf("models: %s must embed models.Base", t))
+	}
+
+	fields := make(map[string]bool, len(filterFields))
+	for _, f := range filterFields {
+		fields[f] = true
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[t] = ModelInfo{Type: t, Name: name, FilterFields: fields}
+}
+
+// RegisteredModels returns every registered model, ordered by name.
+func RegisteredModels() []ModelInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	result := make([]ModelInfo, 0, len(registry))
+	for _, info := range registry {
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// LookupModel returns the registration for the model behind v. v may be a
+// model value, a pointer to one, or a (pointer to a) slice of models, so the
+// dest arguments of Store methods can be passed directly.
+func LookupModel(v interface{}) (ModelInfo, error) {
+	if v == nil {
+		return ModelInfo{}, fmt.Errorf("unregistered model type: <nil>")
+	}
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	info, ok := registry[t]
+	if !ok {
+		return ModelInfo{}, fmt.Errorf("unregistered model type: %s", t)
+	}
+	return info, nil
+}
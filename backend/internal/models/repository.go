@@ -0,0 +1,107 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/pkg/dberrors"
+)
+
+// Repository is the type-safe data access API for a single registered model.
+// It is backed by a Store, so the same implementation works for the GORM and
+// Azure Table Storage backends without type assertions at call sites.
+type Repository[T any] interface {
+	Create(ctx context.Context, entity *T) error
+	FindByID(ctx context.Context, id uint, conditions ...interface{}) (*T, error)
+	Update(ctx context.Context, entity *T) error
+	// Patch writes only the given columns of entity (see Patcher). Stores
+	// that cannot write a subset of columns fall back to Update.
+	Patch(ctx context.Context, entity *T, columns ...string) error
+	Delete(ctx context.Context, entity *T) error
+	Restore(ctx context.Context, entity *T) (bool, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	List(ctx context.Context, conditions ...interface{}) ([]T, error)
+	// Batch applies ops, whose entities must all be *T, through the store's
+	// Batcher implementation.
+	Batch(ctx context.Context, ops []BatchOp) error
+}
+
+// typedRepository adapts an untyped Store to Repository[T].
+type typedRepository[T any] struct {
+	store Store
+}
+
+// NewTypedRepository returns a Repository[T] backed by store. It panics if T
+// has not been registered with RegisterModel, since that is a programming
+// error that would otherwise only surface on the first query.
+func NewTypedRepository[T any](store Store) Repository[T] {
+	if _, err := LookupModel((*T)(nil)); err != nil {
+		panic(fmt.Sprintf("models: NewTypedRepository: %v", err))
+	}
+	return &typedRepository[T]{store: store}
+}
+
+func (r *typedRepository[T]) Create(ctx context.Context, entity *T) error {
+	return r.store.Create(ctx, entity)
+}
+
+func (r *typedRepository[T]) FindByID(ctx context.Context, id uint, conditions ...interface{}) (*T, error) {
+	var dest T
+	if err := r.store.FindByID(ctx, id, &dest, conditions...); err != nil {
+		return nil, err
+	}
+	return &dest, nil
+}
+
+func (r *typedRepository[T]) Update(ctx context.Context, entity *T) error {
+	return r.store.Update(ctx, entity)
+}
+
+func (r *typedRepository[T]) Patch(ctx context.Context, entity *T, columns ...string) error {
+	if patcher, ok := r.store.(Patcher); ok {
+		return patcher.Patch(ctx, entity, columns...)
+	}
+	if err := PatchColumns(entity, columns); err != nil {
+		return dberrors.NewDatabaseError("patch", err)
+	}
+	return r.store.Update(ctx, entity)
+}
+
+func (r *typedRepository[T]) Delete(ctx context.Context, entity *T) error {
+	return r.store.Delete(ctx, entity)
+}
+
+func (r *typedRepository[T]) Restore(ctx context.Context, entity *T) (bool, error) {
+	return r.store.Restore(ctx, entity)
+}
+
+func (r *typedRepository[T]) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return r.store.Purge(ctx, new(T), deletedBefore)
+}
+
+func (r *typedRepository[T]) List(ctx context.Context, conditions ...interface{}) ([]T, error) {
+	dest := []T{}
+	if err := r.store.List(ctx, &dest, conditions...); err != nil {
+		return nil, err
+	}
+	if dest == nil {
+		dest = []T{}
+	}
+	return dest, nil
+}
+
+func (r *typedRepository[T]) Batch(ctx context.Context, ops []BatchOp) error {
+	batcher, ok := r.store.(Batcher)
+	if !ok {
+		return dberrors.NewDatabaseError("batch", errors.New("store does not support batches"))
+	}
+	for i, op := range ops {
+		if _, ok := op.Entity.(*T); !ok {
+			return &BatchError{Index: i, Err: dberrors.NewDatabaseError("batch",
+				fmt.Errorf("%w: batch entity must be %T, got %T", dberrors.ErrValidation, (*T)(nil), op.Entity))}
+		}
+	}
+	return batcher.Batch(ctx, ops)
+}
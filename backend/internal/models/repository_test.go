@@ -0,0 +1,404 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"backend/pkg/dberrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// widget is a model registered only by these tests, to check that new models
+// work with the typed repository without touching the Store implementations.
+type widget struct {
+	Base
+	Label string `gorm:"size:64;uniqueIndex" json:"label"`
+	Color string `gorm:"size:32" json:"color"`
+	Stock int    `json:"stock"`
+}
+
+func init() {
+	RegisterModel[widget]("widgets", "label", "color")
+}
+
+type unregistered struct {
+	Base
+}
+
+func setupTypedRepo(t *testing.T) Repository[widget] {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&widget{}))
+	return NewTypedRepository[widget](NewRepository(db))
+}
+
+func TestLookupModel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		wantName string
+		wantErr  bool
+	}{
+		{name: "pointer", value: &Item{}, wantName: "items"},
+		{name: "value", value: User{}, wantName: "users"},
+		{name: "pointer to slice", value: &[]widget{}, wantName: "widgets"},
+		{name: "unregistered", value: &unregistered{}, wantErr: true},
+		{name: "nil", value: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			info, err := LookupModel(tt.value)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "unregistered model type")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, info.Name)
+		})
+	}
+}
+
+func TestRegisterModelRejectsInvalidTypes(t *testing.T) {
+	t.Parallel()
+
+	assert.Panics(t, func() { RegisterModel[string]("strings") })
+	assert.Panics(t, func() { RegisterModel[struct{ Name string }]("anonymous") })
+	assert.Panics(t, func() { NewTypedRepository[unregistered](nil) })
+}
+
+func TestTypedRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("create, find, update and delete", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		w := &widget{Label: "sprocket", Color: "red", Stock: 3}
+		require.NoError(t, repo.Create(ctx, w))
+		require.NotZero(t, w.ID)
+
+		found, err := repo.FindByID(ctx, w.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "sprocket", found.Label)
+		assert.Equal(t, 3, found.Stock)
+
+		found.Stock = 5
+		require.NoError(t, repo.Update(ctx, found))
+		found, err = repo.FindByID(ctx, w.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, found.Stock)
+
+		require.NoError(t, repo.Delete(ctx, found))
+		_, err = repo.FindByID(ctx, w.ID)
+		assert.ErrorIs(t, err, dberrors.ErrNotFound)
+	})
+
+	t.Run("unique index is reported as duplicate key", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		require.NoError(t, repo.Create(ctx, &widget{Label: "sprocket"}))
+		err := repo.Create(ctx, &widget{Label: "sprocket"})
+		assert.ErrorIs(t, err, dberrors.ErrDuplicateKey)
+	})
+
+	t.Run("list uses the registered filter fields", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		for _, w := range []*widget{
+			{Label: "a", Color: "red"},
+			{Label: "b", Color: "blue"},
+			{Label: "c", Color: "red"},
+		} {
+			require.NoError(t, repo.Create(ctx, w))
+		}
+
+		all, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		red, err := repo.List(ctx, Filter{Field: "color", Op: "exact", Value: "red"})
+		require.NoError(t, err)
+		assert.Len(t, red, 2)
+
+		_, err = repo.List(ctx, Filter{Field: "stock", Op: "exact", Value: 1})
+		assert.ErrorContains(t, err, "invalid filter field")
+	})
+
+	t.Run("cursor pages through every row once", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		for _, label := range []string{"a", "b", "c", "d", "e"} {
+			require.NoError(t, repo.Create(ctx, &widget{Label: label, Color: "red"}))
+		}
+
+		var labels []string
+		cursor := &Cursor{Limit: 2}
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 5, "cursor did not terminate")
+			page, err := repo.List(ctx, Filter{Field: "color", Op: "exact", Value: "red"}, cursor)
+			require.NoError(t, err)
+			for _, w := range page {
+				labels = append(labels, w.Label)
+			}
+			if cursor.Next == "" {
+				break
+			}
+			cursor = &Cursor{Token: cursor.Next, Limit: 2}
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, labels)
+	})
+
+	t.Run("count ignores pagination but honours filters", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		for _, w := range []*widget{
+			{Label: "a", Color: "red"},
+			{Label: "b", Color: "blue"},
+			{Label: "c", Color: "red"},
+			{Label: "d", Color: "red"},
+		} {
+			require.NoError(t, repo.Create(ctx, w))
+		}
+
+		count := &Count{}
+		page, err := repo.List(ctx,
+			Filter{Field: "color", Op: "exact", Value: "red"},
+			Pagination{Limit: 1, Offset: 1},
+			count,
+		)
+		require.NoError(t, err)
+		require.Len(t, page, 1)
+		assert.Equal(t, "c", page[0].Label)
+		assert.Equal(t, int64(3), count.Total)
+	})
+
+	t.Run("sort orders by whitelisted fields with id as tie-breaker", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		for _, w := range []*widget{
+			{Label: "a", Color: "red"},
+			{Label: "b", Color: "blue"},
+			{Label: "c", Color: "red"},
+			{Label: "d", Color: "blue"},
+		} {
+			require.NoError(t, repo.Create(ctx, w))
+		}
+
+		got, err := repo.List(ctx, Sort{{Field: "color", Desc: true}}, Pagination{Limit: 3})
+		require.NoError(t, err)
+		labels := make([]string, len(got))
+		for i, w := range got {
+			labels[i] = w.Label
+		}
+		assert.Equal(t, []string{"a", "c", "b"}, labels)
+
+		_, err = repo.List(ctx, Sort{{Field: "stock"}})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+
+		_, err = repo.List(ctx, Sort{{Field: "label"}}, &Cursor{Limit: 2})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+
+	t.Run("filter expressions are translated to SQL", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		for _, w := range []*widget{
+			{Label: "apple", Color: "red"},
+			{Label: "banana", Color: "yellow"},
+			{Label: "cherry", Color: "red"},
+			{Label: "50%_off", Color: "green"},
+			{Label: "500 off", Color: "green"},
+		} {
+			require.NoError(t, repo.Create(ctx, w))
+		}
+
+		tests := []struct {
+			filter string
+			want   []string
+		}{
+			{filter: `color eq "red"`, want: []string{"apple", "cherry"}},
+			{filter: `color ne "red" and label startswith "b"`, want: []string{"banana"}},
+			{filter: `color in ("yellow", "green") and not label contains "off"`, want: []string{"banana"}},
+			{filter: `label between "b" and "c" or label endswith "RRY"`, want: []string{"banana", "cherry"}},
+			{filter: `label contains "%_"`, want: []string{"50%_off"}},
+			{filter: `label isnull`, want: []string{}},
+		}
+		for _, tt := range tests {
+			expr, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			got, err := repo.List(ctx, expr)
+			require.NoError(t, err, tt.filter)
+			labels := make([]string, len(got))
+			for i, w := range got {
+				labels[i] = w.Label
+			}
+			assert.Equal(t, tt.want, labels, tt.filter)
+		}
+
+		expr, err := ParseFilter("stock gt 1")
+		require.NoError(t, err)
+		_, err = repo.List(ctx, expr)
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+
+	t.Run("batch is applied in one transaction", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		a := &widget{Label: "a", Stock: 1}
+		b := &widget{Label: "b"}
+		require.NoError(t, repo.Create(ctx, a))
+		require.NoError(t, repo.Create(ctx, b))
+
+		a.Stock = 9
+		c := &widget{Label: "c"}
+		require.NoError(t, repo.Batch(ctx, []BatchOp{
+			{Action: BatchCreate, Entity: c},
+			{Action: BatchUpdate, Entity: a},
+			{Action: BatchDelete, Entity: b},
+		}))
+		assert.NotZero(t, c.ID)
+		got, err := repo.List(ctx, Sort{{Field: "label"}})
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, "a", got[0].Label)
+		assert.Equal(t, 9, got[0].Stock)
+		assert.Equal(t, "c", got[1].Label)
+
+		err = repo.Batch(ctx, []BatchOp{
+			{Action: BatchCreate, Entity: &widget{Label: "d"}},
+			{Action: BatchCreate, Entity: &widget{Label: "c"}},
+		})
+		var batchErr *BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Equal(t, 1, batchErr.Index)
+		assert.Empty(t, batchErr.Applied)
+		assert.ErrorIs(t, err, dberrors.ErrDuplicateKey)
+		got, err = repo.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, got, 2, "the first create must be rolled back")
+
+		err = repo.Batch(ctx, []BatchOp{{Action: BatchCreate, Entity: &Item{Name: "x"}}})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+
+	t.Run("patch writes only the given columns", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		w := &widget{Label: "a", Color: "red", Stock: 1}
+		require.NoError(t, repo.Create(ctx, w))
+
+		stale := *w
+		stale.Color = "blue"
+		stale.Stock = 7
+		w.Label = "b"
+		require.NoError(t, repo.Update(ctx, w))
+
+		require.NoError(t, repo.Patch(ctx, &stale, "stock"))
+		got, err := repo.FindByID(ctx, w.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b", got.Label, "columns changed by others are kept")
+		assert.Equal(t, "red", got.Color, "columns not named are not written")
+		assert.Equal(t, 7, got.Stock)
+
+		for _, columns := range [][]string{nil, {"version"}, {"id"}, {"weight"}} {
+			err = repo.Patch(ctx, got, columns...)
+			assert.ErrorIs(t, err, dberrors.ErrValidation, columns)
+		}
+
+		err = repo.Patch(ctx, &widget{Base: Base{ID: 999}}, "stock")
+		assert.ErrorIs(t, err, dberrors.ErrNotFound)
+	})
+
+	t.Run("patch enforces optimistic locking", func(t *testing.T) {
+		t.Parallel()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&Item{}))
+		repo := NewTypedRepository[Item](NewRepository(db))
+
+		item := &Item{Name: "a", Price: 1, Version: 1}
+		require.NoError(t, repo.Create(ctx, item))
+		stale := *item
+
+		item.Price = 2
+		require.NoError(t, repo.Patch(ctx, item, "price"))
+		assert.Equal(t, uint(2), item.Version)
+
+		stale.Name = "b"
+		err = repo.Patch(ctx, &stale, "name")
+		assert.ErrorContains(t, err, "version mismatch")
+		assert.Equal(t, uint(1), stale.Version, "version is restored on failure")
+
+		got, err := repo.FindByID(ctx, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "a", got.Name)
+		assert.Equal(t, 2.0, got.Price)
+		assert.Equal(t, uint(2), got.Version)
+	})
+
+	t.Run("delete with a version is conditional", func(t *testing.T) {
+		t.Parallel()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&Item{}))
+		repo := NewTypedRepository[Item](NewRepository(db))
+
+		item := &Item{Name: "a", Price: 1, Version: 1}
+		require.NoError(t, repo.Create(ctx, item))
+		stale := *item
+		require.NoError(t, repo.Update(ctx, item))
+
+		err = repo.Delete(ctx, &stale)
+		assert.ErrorContains(t, err, "version mismatch")
+		_, err = repo.FindByID(ctx, item.ID)
+		require.NoError(t, err, "a stale delete must not delete")
+
+		require.NoError(t, repo.Delete(ctx, item))
+		err = repo.Delete(ctx, item)
+		assert.ErrorIs(t, err, dberrors.ErrNotFound)
+
+		other := &Item{Name: "b", Price: 1, Version: 1}
+		require.NoError(t, repo.Create(ctx, other))
+		require.NoError(t, repo.Delete(ctx, &Item{Base: Base{ID: other.ID}}), "a zero version deletes unconditionally")
+	})
+
+	t.Run("cursor rejects tampered tokens", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		_, err := repo.List(ctx, &Cursor{Token: "not a cursor!", Limit: 2})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+
+		_, err = repo.List(ctx, &Cursor{Limit: 0})
+		assert.ErrorIs(t, err, dberrors.ErrValidation)
+	})
+
+	t.Run("empty list is not nil", func(t *testing.T) {
+		t.Parallel()
+		repo := setupTypedRepo(t)
+
+		got, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Empty(t, got)
+	})
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortField orders List results by one column.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Sort is a List condition ordering results by each field in turn. Fields
+// are whitelisted like Filter fields; the Base columns id, created_at and
+// updated_at are always sortable. Records that compare equal on every field
+// are ordered by primary key, so pages do not shift between requests.
+type Sort []SortField
+
+// baseSortFields are sortable on every model regardless of the whitelist.
+var baseSortFields = map[string]bool{"id": true, "created_at": true, "updated_at": true}
+
+// ParseSort parses a comma-separated list of column names, each optionally
+// prefixed with "-" for descending order, e.g. "-price,name".
+func ParseSort(s string) (Sort, error) {
+	var sort Sort
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-")}
+		field.Desc = field.Field != part
+		if field.Field == "" {
+			return nil, fmt.Errorf("empty sort field in %q", s)
+		}
+		sort = append(sort, field)
+	}
+	return sort, nil
+}
+
+// Sortable reports whether field may be used in a Sort condition given the
+// filter field whitelist of a model.
+func Sortable(field string, filterFields map[string]bool) bool {
+	return baseSortFields[field] || filterFields[field]
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    Sort
+		wantErr bool
+	}{
+		{name: "single ascending", input: "name", want: Sort{{Field: "name"}}},
+		{name: "descending then ascending", input: "-price,name", want: Sort{{Field: "price", Desc: true}, {Field: "name"}}},
+		{name: "whitespace is trimmed", input: " -price , name ", want: Sort{{Field: "price", Desc: true}, {Field: "name"}}},
+		{name: "empty segment", input: "name,", wantErr: true},
+		{name: "bare minus", input: "-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseSort(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
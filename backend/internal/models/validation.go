@@ -13,6 +13,10 @@ var (
 	ErrEmptyUsername = errors.New("username cannot be empty")
 	ErrInvalidPrice  = errors.New("price must be positive")
 	ErrEmptyItemName = errors.New("item name cannot be empty")
+
+	ErrEmptyAPIKeyName   = errors.New("API key name cannot be empty")
+	ErrInvalidRateLimit  = errors.New("rate limit cannot be negative")
+	ErrEmptyAPIKeyScopes = errors.New("API key needs at least one scope")
 )
 
 // Validate implements model validation
@@ -28,6 +32,23 @@ func (u *User) Validate() error {
 	return nil
 }
 
+// Validate implements model validation
+func (k *APIKey) Validate() error {
+	if k.Name == "" {
+		return ErrEmptyAPIKeyName
+	}
+
+	if len(k.Scopes) == 0 {
+		return ErrEmptyAPIKeyScopes
+	}
+
+	if k.RateLimit < 0 {
+		return ErrInvalidRateLimit
+	}
+
+	return nil
+}
+
 // Validate implements model validation
 func (i *Item) Validate() error {
 	if i.Name == "" {
@@ -0,0 +1,157 @@
+// Package outbox delivers the change events recorded in a store's outbox to
+// the WebSocket hub.
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/internal/models"
+)
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 100
+	defaultRetention = 24 * time.Hour
+	// purgeInterval is how often sent events older than the retention are
+	// deleted.
+	purgeInterval = time.Hour
+)
+
+// Publisher is where a Dispatcher delivers events; *websocket.Hub
+// implements it.
+type Publisher interface {
+	// PublishWait publishes message to topic, waiting for room if needed.
+	PublishWait(ctx context.Context, topic string, message []byte) error
+}
+
+// Dispatcher delivers the pending events of an outbox, oldest first, and
+// marks them as sent. An event is marked only once it is published, so
+// events are delivered at least once: after a crash, those published but
+// not yet marked are published again, with the same EventID for consumers
+// to drop them.
+type Dispatcher struct {
+	store     models.Outboxer
+	hub       Publisher
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+	// wake is signalled by Notify; its buffer of one coalesces the
+	// notifications made while a dispatch is under way.
+	wake chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher delivering the events of store to hub,
+// polling every second and keeping sent events for a day.
+func NewDispatcher(store models.Outboxer, hub Publisher) *Dispatcher {
+	return &Dispatcher{
+		store:     store,
+		hub:       hub,
+		interval:  defaultInterval,
+		batchSize: defaultBatchSize,
+		retention: defaultRetention,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// SetInterval sets how often the outbox is polled for events that were not
+// notified, such as those recorded by another node or before a crash. It
+// must be called before Run; non-positive values are ignored.
+func (d *Dispatcher) SetInterval(interval time.Duration) {
+	if interval > 0 {
+		d.interval = interval
+	}
+}
+
+// SetRetention sets how long sent events are kept before they are purged;
+// 0 keeps them forever. It must be called before Run.
+func (d *Dispatcher) SetRetention(retention time.Duration) {
+	if retention >= 0 {
+		d.retention = retention
+	}
+}
+
+// Notify tells the dispatcher that events were recorded, so that they are
+// delivered without waiting for the next poll. It never blocks.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers events until stop is closed.
+func (d *Dispatcher) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	poll := time.NewTicker(d.interval)
+	defer poll.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+	d.dispatch(ctx)
+	for {
+		select {
+		case <-d.wake:
+			d.dispatch(ctx)
+		case <-poll.C:
+			d.dispatch(ctx)
+		case <-purge.C:
+			d.purge(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch delivers the pending events, a batch at a time. It stops at the
+// first error, leaving the remaining events for the next attempt.
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		events, err := d.store.PendingEvents(ctx, d.batchSize)
+		if err != nil {
+			slog.Error("Failed to read outbox", "error", err)
+			return
+		}
+		sent := 0
+		for _, event := range events {
+			if err := d.hub.PublishWait(ctx, event.Topic, event.Message); err != nil {
+				slog.Warn("Failed to publish outbox event", "event_id", event.EventID, "topic", event.Topic, "error", err)
+				break
+			}
+			sent++
+		}
+		if sent > 0 {
+			if err := d.store.MarkEventsSent(ctx, events[:sent]); err != nil {
+				slog.Error("Failed to mark outbox events sent", "count", sent, "error", err)
+				return
+			}
+		}
+		if sent < len(events) || len(events) < d.batchSize {
+			return
+		}
+	}
+}
+
+// purge deletes the events sent longer ago than the retention.
+func (d *Dispatcher) purge(ctx context.Context) {
+	if d.retention == 0 {
+		return
+	}
+	deleted, err := d.store.PurgeSentEvents(ctx, time.Now().Add(-d.retention))
+	if err != nil {
+		slog.Error("Failed to purge outbox", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Info("Purged sent outbox events", "count", deleted)
+	}
+}
@@ -0,0 +1,147 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recorder is a Publisher recording the topics published to, failing once
+// failAfter of them are.
+type recorder struct {
+	mu        sync.Mutex
+	topics    []string
+	failAfter int
+}
+
+func (r *recorder) PublishWait(_ context.Context, topic string, _ []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failAfter >= 0 && len(r.topics) >= r.failAfter {
+		return errors.New("hub closed")
+	}
+	r.topics = append(r.topics, topic)
+	return nil
+}
+
+func (r *recorder) published() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.topics...)
+}
+
+func setupStore(t *testing.T) models.Outboxer {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Each connection to ":memory:" opens a database of its own.
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.OutboxEvent{}))
+	return models.NewRepository(db).(models.Outboxer)
+}
+
+// record adds an event for each of topics to store's outbox.
+func record(t *testing.T, store models.Outboxer, topics ...string) {
+	t.Helper()
+	err := store.WithEvents(context.Background(), func(models.Store) ([]models.OutboxEvent, error) {
+		var events []models.OutboxEvent
+		for _, topic := range topics {
+			events = append(events, models.OutboxEvent{EventID: models.NewEventID(), Topic: topic, Message: []byte(`{}`), CreatedAt: time.Now()})
+		}
+		return events, nil
+	})
+	require.NoError(t, err)
+}
+
+func pending(t *testing.T, store models.Outboxer) int {
+	t.Helper()
+	events, err := store.PendingEvents(context.Background(), 100)
+	require.NoError(t, err)
+	return len(events)
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		batchSize   int
+		failAfter   int
+		wantTopics  []string
+		wantPending int
+	}{
+		{
+			name:       "delivers every batch in order",
+			batchSize:  2,
+			failAfter:  -1,
+			wantTopics: []string{"items:1", "items:2", "items:3"},
+		},
+		{
+			name:        "leaves events not published pending",
+			batchSize:   2,
+			failAfter:   1,
+			wantTopics:  []string{"items:1"},
+			wantPending: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := setupStore(t)
+			record(t, store, "items:1", "items:2", "items:3")
+			hub := &recorder{failAfter: tt.failAfter}
+			d := NewDispatcher(store, hub)
+			d.batchSize = tt.batchSize
+
+			d.dispatch(ctx)
+			assert.Equal(t, tt.wantTopics, hub.published())
+			assert.Equal(t, tt.wantPending, pending(t, store))
+		})
+	}
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	t.Parallel()
+	store := setupStore(t)
+	record(t, store, "items:1")
+	hub := &recorder{failAfter: -1}
+	d := NewDispatcher(store, hub)
+	d.SetInterval(time.Hour)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		d.Run(stop)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(hub.published()) == 1 }, time.Second, 10*time.Millisecond,
+		"events recorded before Run are delivered")
+
+	record(t, store, "items:2")
+	d.Notify()
+	require.Eventually(t, func() bool { return len(hub.published()) == 2 }, time.Second, 10*time.Millisecond,
+		"notified events are delivered without waiting for the poll")
+	assert.Equal(t, []string{"items:1", "items:2"}, hub.published())
+	assert.Zero(t, pending(t, store))
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after stop was closed")
+	}
+}
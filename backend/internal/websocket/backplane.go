@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"backend/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Envelope is a message relayed between the hubs of several nodes through a
+// Backplane.
+type Envelope struct {
+	// Origin is the node ID of the hub that published the message; a hub
+	// ignores its own envelopes, since it delivered them locally.
+	Origin string `json:"origin"`
+	// Topic is the topic the message was published to, or empty for a
+	// broadcast.
+	Topic   string `json:"topic,omitempty"`
+	Message []byte `json:"message"`
+	// Presence, when set, makes the envelope a snapshot of who is viewing
+	// which resource on Origin's node rather than a message; see
+	// Hub.Presence.
+	Presence *PresenceSnapshot `json:"presence,omitempty"`
+}
+
+// PresenceSnapshot lists the viewers of every resource on a node.
+type PresenceSnapshot struct {
+	Viewers []Presence `json:"viewers"`
+}
+
+// Backplane relays messages between the hubs of every node serving the API,
+// so that clients receive events wherever they were published.
+type Backplane interface {
+	// Publish sends env to every subscribed hub, including the publisher's.
+	Publish(ctx context.Context, env Envelope) error
+
+	// Subscribe returns the envelopes published by any hub from now on. The
+	// channel is closed when ctx is done or the backplane is closed.
+	Subscribe(ctx context.Context) (<-chan Envelope, error)
+
+	// Ping checks that the backplane can be reached.
+	Ping(ctx context.Context) error
+
+	// Close stops every subscription and releases the backplane's resources.
+	Close() error
+}
+
+// NewBackplane creates the backplane selected by cfg, or returns nil if
+// none is configured.
+func NewBackplane(cfg config.WebSocketConfig) (Backplane, error) {
+	switch cfg.Backplane {
+	case "redis":
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("parse redis url: %w", err)
+		}
+		return NewRedisBackplane(redis.NewClient(opts), cfg.BackplaneChannel), nil
+	default:
+		return nil, nil
+	}
+}
+
+// MemoryBackplane is a Backplane between hubs in the same process, for
+// tests.
+type MemoryBackplane struct {
+	mu          sync.Mutex
+	subscribers map[chan Envelope]struct{}
+}
+
+// NewMemoryBackplane creates a MemoryBackplane without subscribers.
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{subscribers: make(map[chan Envelope]struct{})}
+}
+
+// Publish implements Backplane. Subscribers that are not keeping up miss env.
+func (b *MemoryBackplane) Publish(_ context.Context, env Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- env:
+		default:
+			slog.Warn("Backplane subscriber full, message dropped", "topic", env.Topic)
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Backplane.
+func (b *MemoryBackplane) Subscribe(ctx context.Context) (<-chan Envelope, error) {
+	ch := make(chan Envelope, broadcastBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(ch)
+	}()
+	return ch, nil
+}
+
+// Ping implements Backplane.
+func (b *MemoryBackplane) Ping(context.Context) error {
+	return nil
+}
+
+// Close implements Backplane.
+func (b *MemoryBackplane) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	return nil
+}
+
+func (b *MemoryBackplane) unsubscribe(ch chan Envelope) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// subscriberCount returns the number of open subscriptions.
+func (b *MemoryBackplane) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextEnvelope returns the next envelope from ch, failing after a second.
+func nextEnvelope(t *testing.T, ch <-chan Envelope) Envelope {
+	t.Helper()
+	select {
+	case env, ok := <-ch:
+		require.True(t, ok, "subscription closed")
+		return env
+	case <-time.After(time.Second):
+		t.Fatal("no envelope received")
+		return Envelope{}
+	}
+}
+
+func TestBackplanes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// nodes returns two backplanes connected to each other.
+		nodes func(t *testing.T) (Backplane, Backplane)
+	}{
+		{
+			name: "memory",
+			nodes: func(t *testing.T) (Backplane, Backplane) {
+				b := NewMemoryBackplane()
+				return b, b
+			},
+		},
+		{
+			name: "redis",
+			nodes: func(t *testing.T) (Backplane, Backplane) {
+				server := miniredis.RunT(t)
+				node := func() Backplane {
+					b := NewRedisBackplane(redis.NewClient(&redis.Options{Addr: server.Addr()}), "websocket")
+					t.Cleanup(func() { _ = b.Close() })
+					return b
+				}
+				return node(), node()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			first, second := tt.nodes(t)
+			require.NoError(t, first.Ping(ctx))
+
+			firstCh, err := first.Subscribe(ctx)
+			require.NoError(t, err)
+			secondCtx, cancelSecond := context.WithCancel(ctx)
+			secondCh, err := second.Subscribe(secondCtx)
+			require.NoError(t, err)
+
+			env := Envelope{Origin: "node-1", Topic: "items:42", Message: []byte(`{"type":"item.updated","payload":{"id":42}}`)}
+			require.NoError(t, first.Publish(ctx, env))
+			assert.Equal(t, env, nextEnvelope(t, firstCh), "the publisher receives its own envelope")
+			assert.Equal(t, env, nextEnvelope(t, secondCh))
+
+			cancelSecond()
+			select {
+			case _, open := <-secondCh:
+				assert.False(t, open, "cancelling the context ends the subscription")
+			case <-time.After(time.Second):
+				t.Fatal("subscription not closed")
+			}
+
+			broadcast := Envelope{Origin: "node-2", Message: []byte(`{"type":"notice","payload":null}`)}
+			require.NoError(t, second.Publish(ctx, broadcast))
+			assert.Equal(t, broadcast, nextEnvelope(t, firstCh))
+		})
+	}
+}
+
+func TestRedisBackplane_IgnoresMalformedMessages(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := miniredis.RunT(t)
+	b := NewRedisBackplane(redis.NewClient(&redis.Options{Addr: server.Addr()}), "websocket")
+	defer b.Close()
+
+	ch, err := b.Subscribe(ctx)
+	require.NoError(t, err)
+	server.Publish("websocket", "not json")
+	env := Envelope{Origin: "node-1", Topic: "items", Message: []byte(`{}`)}
+	require.NoError(t, b.Publish(ctx, env))
+
+	assert.Equal(t, env, nextEnvelope(t, ch))
+}
+
+func TestHub_Backplane(t *testing.T) {
+	t.Parallel()
+
+	backplane := NewMemoryBackplane()
+	hubs := make([]*Hub, 2)
+	clients := make([]*Client, 2)
+	for i := range hubs {
+		hub := NewHub()
+		hub.SetBackplane(backplane)
+		go hub.Run()
+		defer hub.Shutdown()
+
+		c := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+		require.NoError(t, hub.Register(c))
+		waitForClientCount(t, hub, 1)
+		request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+		hubs[i], clients[i] = hub, c
+	}
+	assert.NotEqual(t, hubs[0].NodeID(), hubs[1].NodeID())
+	assert.Eventually(t, func() bool {
+		return backplane.subscriberCount() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	item := `{"type":"item.updated","payload":{"id":42}}`
+	notice := `{"type":"notice","payload":null}`
+	hubs[0].Publish("items:42", []byte(item))
+	hubs[1].Broadcast([]byte(notice))
+
+	for i, c := range clients {
+		assert.ElementsMatch(t, []string{item, notice}, events(t, c), "client of hub %d gets each message once", i)
+	}
+
+	hubs[0].Shutdown()
+	assert.Eventually(t, func() bool {
+		return backplane.subscriberCount() == 1
+	}, time.Second, 5*time.Millisecond, "a stopped hub unsubscribes")
+}
+
+// flakyBackplane is a MemoryBackplane on which the first failures
+// subscriptions fail.
+type flakyBackplane struct {
+	*MemoryBackplane
+	failures atomic.Int32
+}
+
+func (b *flakyBackplane) Subscribe(ctx context.Context) (<-chan Envelope, error) {
+	if b.failures.Add(-1) >= 0 {
+		return nil, errors.New("connection refused")
+	}
+	return b.MemoryBackplane.Subscribe(ctx)
+}
+
+func TestHub_BackplaneResubscribes(t *testing.T) {
+	t.Parallel()
+
+	backplane := &flakyBackplane{MemoryBackplane: NewMemoryBackplane()}
+	backplane.failures.Store(2)
+	hub := NewHub()
+	hub.backplaneRetry = 5 * time.Millisecond
+	hub.SetBackplane(backplane)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	c := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	require.NoError(t, hub.Register(c))
+	waitForClientCount(t, hub, 1)
+	request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+
+	assert.Eventually(t, func() bool {
+		return backplane.subscriberCount() == 1
+	}, time.Second, 5*time.Millisecond, "failed subscriptions are retried")
+	first := `{"type":"item.updated","payload":{"id":1}}`
+	require.NoError(t, backplane.Publish(context.Background(), Envelope{Origin: "other", Topic: "items:1", Message: []byte(first)}))
+	assert.Equal(t, []string{first}, events(t, c))
+
+	require.NoError(t, backplane.Close())
+	assert.Eventually(t, func() bool {
+		return backplane.subscriberCount() == 1
+	}, time.Second, 5*time.Millisecond, "a closed subscription is renewed")
+	second := `{"type":"item.updated","payload":{"id":2}}`
+	require.NoError(t, backplane.Publish(context.Background(), Envelope{Origin: "other", Topic: "items:2", Message: []byte(second)}))
+	assert.Equal(t, []string{second}, events(t, c))
+}
@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Backpressure is what the hub does with the messages of a client whose
+// send buffer is full, because it reads them slower than they are
+// published.
+type Backpressure string
+
+// Backpressure policies.
+const (
+	// Disconnect drops the client, which reconnects and resumes from the
+	// last event it received. It is the default.
+	Disconnect Backpressure = "disconnect"
+	// DropOldest drops the oldest queued message to make room; the client
+	// can tell from the gap in seqs.
+	DropOldest Backpressure = "drop-oldest"
+	// Coalesce queues the messages that do not fit, keeping only the latest
+	// of each entity topic, such as "items:42", since it carries the
+	// entity's current state. The client is dropped if the queue fills with
+	// other messages.
+	Coalesce Backpressure = "coalesce"
+)
+
+// backlogFlushInterval is how often the Run loop moves the messages queued
+// for coalescing clients into their send buffers.
+const backlogFlushInterval = 50 * time.Millisecond
+
+// ParseBackpressure returns the policy named s.
+func ParseBackpressure(s string) (Backpressure, error) {
+	switch policy := Backpressure(s); policy {
+	case Disconnect, DropOldest, Coalesce:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown backpressure policy %q", s)
+	}
+}
+
+// Stats counts the messages the hub did not deliver as published.
+type Stats struct {
+	// Rejected counts publications dropped because the hub's own queue was
+	// full.
+	Rejected uint64 `json:"rejected"`
+	// Dropped counts messages dropped by DropOldest clients.
+	Dropped uint64 `json:"dropped"`
+	// Coalesced counts messages superseded by a later message of the same
+	// entity for Coalesce clients.
+	Coalesced uint64 `json:"coalesced"`
+	// Disconnected counts clients dropped for not keeping up.
+	Disconnected uint64 `json:"disconnected"`
+}
+
+// hubStats are the counters behind Stats.
+type hubStats struct {
+	rejected, dropped, coalesced, disconnected atomic.Uint64
+}
+
+// ClientInfo describes a connected client.
+type ClientInfo struct {
+	ID string `json:"id"`
+	// Transport is "websocket", or "sse" for streams.
+	Transport    string       `json:"transport"`
+	RemoteAddr   string       `json:"remote_addr"`
+	Username     string       `json:"username,omitempty"`
+	Topics       []string     `json:"topics"`
+	Backpressure Backpressure `json:"backpressure"`
+	Encoding     Encoding     `json:"encoding"`
+	// QueueDepth is the number of messages waiting to be sent to the client.
+	QueueDepth    int       `json:"queue_depth"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	Dropped       uint64    `json:"dropped"`
+	Coalesced     uint64    `json:"coalesced"`
+}
+
+// Stats returns the hub's counters since it was created.
+func (h *Hub) Stats() Stats {
+	return Stats{
+		Rejected:     h.stats.rejected.Load(),
+		Dropped:      h.stats.dropped.Load(),
+		Coalesced:    h.stats.coalesced.Load(),
+		Disconnected: h.stats.disconnected.Load(),
+	}
+}
+
+// Clients describes the connected clients, oldest first.
+func (h *Hub) Clients() []ClientInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	now := time.Now()
+	infos := make([]ClientInfo, 0, len(h.clients))
+	for client := range h.clients {
+		info := ClientInfo{
+			ID:            client.id,
+			Transport:     "websocket",
+			RemoteAddr:    client.remoteAddr,
+			Topics:        client.subscriptions(),
+			Backpressure:  client.backpressure,
+			Encoding:      client.encoding,
+			QueueDepth:    len(client.send) + int(client.backlogSize.Load()),
+			ConnectedAt:   client.connectedAt,
+			UptimeSeconds: int64(now.Sub(client.connectedAt).Seconds()),
+			Dropped:       client.dropped.Load(),
+			Coalesced:     client.coalesced.Load(),
+		}
+		if client.conn == nil {
+			info.Transport = "sse"
+		}
+		if info.Encoding == "" {
+			info.Encoding = JSON
+		}
+		if client.identity != nil {
+			info.Username = client.identity.Username
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].ConnectedAt.Equal(infos[j].ConnectedAt) {
+			return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// enqueue queues pub for client, applying the client's backpressure policy
+// if its send buffer is full. It returns false if the client is to be
+// disconnected. Only the Run loop may call it.
+func (h *Hub) enqueue(client *Client, pub publication) bool {
+	if len(client.backlog) > 0 {
+		// Behind already: later messages must not overtake the backlog.
+		return h.coalesce(client, pub)
+	}
+	select {
+	case client.send <- pub.message:
+		return true
+	default:
+	}
+
+	switch client.backpressure {
+	case DropOldest:
+		// The write pump may take a message meanwhile, making room anyway.
+		select {
+		case <-client.send:
+			client.dropped.Add(1)
+			h.stats.dropped.Add(1)
+		default:
+		}
+		select {
+		case client.send <- pub.message:
+		default:
+			client.dropped.Add(1)
+			h.stats.dropped.Add(1)
+		}
+		return true
+	case Coalesce:
+		return h.coalesce(client, pub)
+	default:
+		return false
+	}
+}
+
+// coalesce adds pub to client's backlog, in place of the queued message of
+// the same entity topic if there is one; presence events are queued as they
+// are. It returns false if the backlog is full. Only the Run loop may call
+// it.
+func (h *Hub) coalesce(client *Client, pub publication) bool {
+	if isResource(pub.topic) && pub.viewer == "" {
+		for i := range client.backlog {
+			if client.backlog[i].topic == pub.topic && client.backlog[i].viewer == "" {
+				client.backlog[i] = pub
+				client.coalesced.Add(1)
+				h.stats.coalesced.Add(1)
+				return true
+			}
+		}
+	}
+	if len(client.backlog) >= cap(client.send) {
+		return false
+	}
+	client.backlog = append(client.backlog, pub)
+	client.backlogSize.Store(int32(len(client.backlog)))
+	h.backlogged[client] = true
+	return true
+}
+
+// flushBacklogs moves the backlogs of coalescing clients into their send
+// buffers as far as they fit. Only the Run loop may call it.
+func (h *Hub) flushBacklogs() {
+	for client := range h.backlogged {
+		n := 0
+	fill:
+		for n < len(client.backlog) {
+			select {
+			case client.send <- client.backlog[n].message:
+				n++
+			default:
+				break fill
+			}
+		}
+		client.backlog = append(client.backlog[:0], client.backlog[n:]...)
+		client.backlogSize.Store(int32(len(client.backlog)))
+		if len(client.backlog) == 0 {
+			client.backlog = nil
+			delete(h.backlogged, client)
+		}
+	}
+}
+
+// disconnectSlow removes clients that did not keep up. Only the Run loop may
+// call it.
+func (h *Hub) disconnectSlow(slow []*Client) {
+	if len(slow) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, client := range slow {
+		slog.Warn("WebSocket client too slow, disconnecting", "client", client.id, "remote_addr", client.remoteAddr)
+		h.stats.disconnected.Add(1)
+		h.leave(client)
+	}
+}
@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entityEvent returns an event about version v of item id.
+func entityEvent(id, v int) string {
+	return fmt.Sprintf(`{"type":"item.updated","payload":{"id":%d,"v":%d}}`, id, v)
+}
+
+func TestHub_Backpressure(t *testing.T) {
+	t.Parallel()
+
+	// Each publication is of version v of item id; the client's send buffer
+	// holds two messages and is not read until all are published.
+	type publication struct{ id, v int }
+	tests := []struct {
+		name          string
+		policy        Backpressure
+		publish       []publication
+		wantEvents    []string
+		wantConnected bool
+		wantStats     Stats
+	}{
+		{
+			name:      "disconnect",
+			policy:    Disconnect,
+			publish:   []publication{{1, 1}, {2, 1}, {3, 1}},
+			wantStats: Stats{Disconnected: 1},
+		},
+		{
+			name:          "drop oldest",
+			policy:        DropOldest,
+			publish:       []publication{{1, 1}, {2, 1}, {3, 1}, {4, 1}},
+			wantEvents:    []string{entityEvent(3, 1), entityEvent(4, 1)},
+			wantConnected: true,
+			wantStats:     Stats{Dropped: 2},
+		},
+		{
+			name:          "coalesce",
+			policy:        Coalesce,
+			publish:       []publication{{1, 1}, {2, 1}, {1, 2}, {3, 1}, {1, 3}, {3, 2}},
+			wantEvents:    []string{entityEvent(1, 1), entityEvent(2, 1), entityEvent(1, 3), entityEvent(3, 2)},
+			wantConnected: true,
+			wantStats:     Stats{Coalesced: 2},
+		},
+		{
+			name:      "coalesce disconnects when the backlog is full",
+			policy:    Coalesce,
+			publish:   []publication{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}},
+			wantStats: Stats{Disconnected: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hub := NewHub()
+			go hub.Run()
+			defer hub.Shutdown()
+
+			c := &Client{hub: hub, send: make(chan []byte, 2), backpressure: tt.policy}
+			require.NoError(t, hub.Register(c))
+			waitForClientCount(t, hub, 1)
+			request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+
+			for _, p := range tt.publish {
+				hub.Publish(Topic("items", uint(p.id)), []byte(entityEvent(p.id, p.v)))
+			}
+			assert.Eventually(t, func() bool {
+				return hub.Stats() == tt.wantStats
+			}, time.Second, 5*time.Millisecond, "stats %+v", hub.Stats())
+
+			if !tt.wantConnected {
+				waitForClientCount(t, hub, 0)
+				return
+			}
+			assert.Equal(t, tt.wantEvents, events(t, c))
+			assert.Equal(t, 1, hub.ClientCount())
+			assert.Equal(t, tt.wantStats.Dropped, c.dropped.Load())
+			assert.Equal(t, tt.wantStats.Coalesced, c.coalesced.Load())
+		})
+	}
+}
+
+func TestHub_Clients(t *testing.T) {
+	t.Parallel()
+	hub := NewHub()
+	hub.SetBackpressure(Coalesce)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	c := newClient(hub, nil, ClientOptions{Identity: &Identity{Subject: "7", Username: "alice"}, RemoteAddr: "192.0.2.1"})
+	require.NoError(t, hub.Register(c))
+	waitForClientCount(t, hub, 1)
+	request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"users:7", "items"}})
+	hub.Publish("items:1", []byte(entityEvent(1, 1)))
+	assert.Eventually(t, func() bool {
+		return len(c.send) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	clients := hub.Clients()
+	require.Len(t, clients, 1)
+	info := clients[0]
+	assert.NotEmpty(t, info.ID)
+	assert.Equal(t, "sse", info.Transport)
+	assert.Equal(t, "192.0.2.1", info.RemoteAddr)
+	assert.Equal(t, "alice", info.Username)
+	assert.Equal(t, []string{"items", "users:7"}, info.Topics)
+	assert.Equal(t, Coalesce, info.Backpressure, "the hub's policy applies")
+	assert.Equal(t, 1, info.QueueDepth)
+	assert.WithinDuration(t, time.Now(), info.ConnectedAt, time.Second)
+}
+
+func TestParseBackpressure(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []Backpressure{Disconnect, DropOldest, Coalesce} {
+		got, err := ParseBackpressure(string(policy))
+		require.NoError(t, err)
+		assert.Equal(t, policy, got)
+	}
+	_, err := ParseBackpressure("block")
+	assert.EqualError(t, err, `unknown backpressure policy "block"`)
+}
@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// callTimeout bounds each call a client makes.
+const callTimeout = 30 * time.Second
+
+// Dispatcher serves the calls clients make over their connection, such as
+// {"id":1,"method":"items.update","params":{...}}.
+type Dispatcher interface {
+	// Call invokes method with params on behalf of identity, which is nil
+	// for unauthenticated clients, and returns its JSON result. A *CallError
+	// is reported to the client as it is, any other error as an internal
+	// error.
+	Call(ctx context.Context, identity *Identity, method string, params json.RawMessage) (json.RawMessage, error)
+}
+
+// CallError is the failure of a call, with the HTTP status the equivalent
+// REST request would have had.
+type CallError struct {
+	Status  int
+	Message string
+}
+
+func (e *CallError) Error() string { return e.Message }
+
+// call invokes msg with the hub's dispatcher and answers the client with its
+// result. Calls are served one at a time, in the order the client sent them.
+func (c *Client) call(msg ClientMessage) {
+	var (
+		result json.RawMessage
+		err    error
+	)
+	dispatcher := c.hub.dispatcher()
+	switch {
+	case len(msg.ID) == 0 || string(msg.ID) == "null":
+		err = &CallError{Status: http.StatusBadRequest, Message: "call has no id"}
+	case !c.allowCall(time.Now()):
+		err = &CallError{Status: http.StatusTooManyRequests, Message: "rate limit exceeded"}
+	case dispatcher == nil:
+		err = &CallError{Status: http.StatusNotImplemented, Message: "calls are not supported"}
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		result, err = dispatcher.Call(ctx, c.identity, msg.Method, msg.Params)
+		cancel()
+	}
+	if reply := callReply(msg.ID, result, err); reply != nil {
+		c.hub.answer(c, reply)
+	}
+}
+
+// allowCall reports whether the client may make a call at now under the
+// hub's call rate limit, and counts the call if so.
+func (c *Client) allowCall(now time.Time) bool {
+	if c.hub.callLimit <= 0 {
+		return true
+	}
+	windowStart := now.Add(-c.hub.callWindow)
+	valid := c.calls[:0]
+	for _, t := range c.calls {
+		if t.After(windowStart) {
+			valid = append(valid, t)
+		}
+	}
+	c.calls = valid
+	if len(valid) >= c.hub.callLimit {
+		return false
+	}
+	c.calls = append(c.calls, now)
+	return true
+}
+
+// callReply returns the message answering the call with id: a result
+// message, or an error message if err is not nil.
+func callReply(id, result json.RawMessage, err error) []byte {
+	var msg Message
+	if err != nil {
+		var callErr *CallError
+		if !errors.As(err, &callErr) {
+			slog.Error("WebSocket call failed", "error", err)
+			callErr = &CallError{Status: http.StatusInternalServerError, Message: "Internal server error"}
+		}
+		msg, err = NewMessage(ErrorMessage, ErrorPayload{Error: callErr.Message, Status: callErr.Status})
+	} else {
+		msg, err = NewMessage(ResultMessage, result)
+	}
+	if err != nil {
+		slog.Error("Failed to create WebSocket call reply", "error", err)
+		return nil
+	}
+	msg.ID = id
+	b, err := msg.Bytes()
+	if err != nil {
+		slog.Error("Failed to serialise WebSocket call reply", "error", err)
+		return nil
+	}
+	return b
+}
@@ -1,8 +1,12 @@
 package websocket
 
 import (
+	"encoding/json"
 	"errors"
 	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,30 +25,126 @@ const (
 	// pingPeriod sends pings at this interval. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// maxMessageSize is the maximum message size allowed from peer.
-	maxMessageSize = 512
+	// defaultMaxMessageSize is the maximum message size allowed from peer,
+	// unless set with Hub.SetMaxMessageSize.
+	defaultMaxMessageSize = 64 << 10
 
 	// sendBufferSize is the buffer size for the client send channel.
 	sendBufferSize = 256
 )
 
+// Authorizer reports whether a client holds a permission. *auth.Claims
+// implements it.
+type Authorizer interface {
+	HasPermission(permission string) bool
+}
+
+// Identity is who a client authenticated as when it connected.
+type Identity struct {
+	Subject  string
+	Username string
+	// Permissions decides which events the client receives; see
+	// Hub.RequirePermission.
+	Permissions Authorizer
+	// ExpiresAt is when the client's credentials expire. The connection is
+	// closed then, and the client has to reconnect with fresh credentials.
+	// The zero value never expires.
+	ExpiresAt time.Time
+}
+
 // Client is a middleman between the WebSocket connection and the hub.
 type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// identity is nil for unauthenticated connections, which may subscribe
+	// to any topic.
+	identity *Identity
+
+	// topics are the client's subscriptions, and viewing maps those that
+	// are resources, such as "items:42", to when the client subscribed to
+	// them; see Hub.Presence. Only the hub's Run loop uses them, or callers
+	// holding its mu.
+	topics  map[string]bool
+	viewing map[string]time.Time
+
+	// since is the sequence ID of the last event the client received before
+	// reconnecting, until it has resumed from there. Only the hub's Run loop
+	// uses it once the client is registered.
+	since *uint64
+
+	// initialTopics are subscribed to when the client is registered, for
+	// clients that cannot send a subscribe message; see OpenStream.
+	initialTopics []string
+
+	// encoding is how messages are encoded on conn.
+	encoding Encoding
+
+	// id, connectedAt and, unless the client chose it, backpressure are set
+	// by the hub when the client is registered.
+	id           string
+	remoteAddr   string
+	connectedAt  time.Time
+	backpressure Backpressure
+
+	// backlog holds the messages of a Coalesce client that did not fit in
+	// its send buffer, oldest first. Only the hub's Run loop uses it;
+	// backlogSize is its length for other readers.
+	backlog     []publication
+	backlogSize atomic.Int32
+
+	// dropped and coalesced count the client's messages lost to its
+	// backpressure policy.
+	dropped, coalesced atomic.Uint64
+
+	// lastActive is when the client last sent a message, in Unix
+	// nanoseconds.
+	lastActive atomic.Int64
+
+	// calls are the times of the client's calls within the hub's call
+	// window. Only readPump uses them.
+	calls []time.Time
+}
+
+// ClientOptions describe a client connecting to a hub.
+type ClientOptions struct {
+	// Identity is who the client authenticated as, or nil when the
+	// connection was not authenticated.
+	Identity *Identity
+	// Since is the sequence ID of the last event a reconnecting client
+	// received, or nil; the client is sent the events it missed after its
+	// first subscription.
+	Since *uint64
+	// Backpressure is the client's policy when it does not keep up; empty
+	// uses the hub's.
+	Backpressure Backpressure
+	// RemoteAddr is the client's address, as listed by Hub.Clients.
+	RemoteAddr string
+	// Encoding is how messages are encoded on the connection.
+	Encoding Encoding
+}
+
+// newClient returns an unregistered client of hub with opts.
+func newClient(hub *Hub, conn *websocket.Conn, opts ClientOptions) *Client {
+	return &Client{
+		hub:          hub,
+		conn:         conn,
+		send:         make(chan []byte, sendBufferSize),
+		identity:     opts.Identity,
+		since:        opts.Since,
+		backpressure: opts.Backpressure,
+		remoteAddr:   opts.RemoteAddr,
+		encoding:     opts.Encoding,
+	}
 }
 
 // NewClient creates a new Client attached to the given hub and connection,
 // registers it with the hub, and starts the read/write pumps.
 // The caller should not interact with conn after calling NewClient.
 // Returns an error if the hub has already been shut down.
-func NewClient(hub *Hub, conn *websocket.Conn) (*Client, error) {
-	client := &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan []byte, sendBufferSize),
-	}
+func NewClient(hub *Hub, conn *websocket.Conn, opts ClientOptions) (*Client, error) {
+	client := newClient(hub, conn, opts)
 	if err := hub.Register(client); err != nil {
 		conn.Close()
 		return nil, err
@@ -54,9 +154,47 @@ func NewClient(hub *Hub, conn *websocket.Conn) (*Client, error) {
 	return client, nil
 }
 
-// readPump pumps messages from the WebSocket connection to the hub.
-// It runs in its own goroutine. When the connection is closed (or an
-// error occurs), the client unregisters from the hub.
+// Identity returns who the client authenticated as, or nil.
+func (c *Client) Identity() *Identity {
+	return c.identity
+}
+
+// canReceive reports whether the client may receive messages requiring
+// permission. required is false for topics no permission has been
+// configured for, which only unauthenticated clients may receive.
+func (c *Client) canReceive(permission string, required bool) bool {
+	if c.identity == nil || c.identity.Permissions == nil {
+		return true
+	}
+	return required && c.identity.Permissions.HasPermission(permission)
+}
+
+// receives reports whether the client's subscriptions cover messages
+// published to topic, or broadcast if it is empty. Only the hub's Run loop
+// may call it.
+func (c *Client) receives(topic string) bool {
+	if topic == "" || c.topics[topic] {
+		return true
+	}
+	collection, _, ok := strings.Cut(topic, ":")
+	return ok && c.topics[collection]
+}
+
+// subscriptions returns the client's topics, sorted. Only the hub's Run loop,
+// or callers holding its mu, may call it.
+func (c *Client) subscriptions() []string {
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// readPump pumps messages from the WebSocket connection to the hub, which
+// handles subscriptions, and serves the calls among them. It runs in its own
+// goroutine. When the connection is closed (or an error occurs), the client
+// unregisters from the hub.
 func (c *Client) readPump() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -66,7 +204,7 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadLimit(c.hub.maxMessageSize)
 	if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 		slog.Error("Failed to set read deadline", "error", err)
 		return
@@ -76,15 +214,29 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		frameType, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
 				slog.Warn("WebSocket unexpected close", "error", err)
 			}
 			return
 		}
-		// Inbound messages from clients are currently ignored.
-		// Future: route client messages through the hub or a handler.
+		c.lastActive.Store(time.Now().UnixNano())
+		if frameType == websocket.BinaryMessage && c.encoding == Msgpack {
+			if data, err = decodeMsgpack(data); err != nil {
+				data = nil
+			}
+		}
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			// The hub answers messages of no known type with an error.
+			msg = ClientMessage{}
+		}
+		if msg.Method != "" {
+			c.call(msg)
+			continue
+		}
+		c.hub.receive(c, msg)
 	}
 }
 
@@ -93,6 +245,12 @@ func (c *Client) readPump() {
 // dead connections.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
+	var expired <-chan time.Time
+	if c.identity != nil && !c.identity.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.identity.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error("Panic in WebSocket writePump", "recover", r)
@@ -111,6 +269,9 @@ func (c *Client) writePump() {
 			if err := c.writePing(); err != nil {
 				return
 			}
+		case <-expired:
+			c.writeClose(websocket.ClosePolicyViolation, "credentials expired")
+			return
 		}
 	}
 }
@@ -151,9 +312,18 @@ func (c *Client) writePing() error {
 	return c.conn.WriteMessage(websocket.PingMessage, nil)
 }
 
-// writeMessage sends a single text message as one WebSocket frame.
-func (c *Client) writeMessage(data []byte) error {
-	w, err := c.conn.NextWriter(websocket.TextMessage)
+// writeClose sends a close frame with the given code and reason.
+func (c *Client) writeClose(code int, reason string) {
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+}
+
+// writeMessage sends a single message as one WebSocket frame, in the
+// client's encoding. Large messages are compressed if the connection
+// negotiated compression.
+func (c *Client) writeMessage(message []byte) error {
+	frameType, data := c.frame(message)
+	c.conn.EnableWriteCompression(len(data) >= minCompressSize)
+	w, err := c.conn.NextWriter(frameType)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,10 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -107,7 +111,7 @@ func TestNewClient(t *testing.T) {
 
 			srvConn, _ := newTestWSPair(t)
 
-			client, err := NewClient(hub, srvConn)
+			client, err := NewClient(hub, srvConn, ClientOptions{})
 			if tt.wantErr != nil {
 				require.ErrorIs(t, err, tt.wantErr)
 				assert.Nil(t, client)
@@ -478,4 +482,177 @@ func TestClient_WritePump(t *testing.T) {
 		_, _, err := peerConn.ReadMessage()
 		assert.Error(t, err, "peer should observe close after send channel is closed")
 	})
+
+	t.Run("expired credentials close the connection", func(t *testing.T) {
+		t.Parallel()
+
+		hub := NewHub()
+		go hub.Run()
+		defer hub.Shutdown()
+
+		srvConn, peerConn := newTestWSPair(t)
+		sc := newSendCloser()
+		identity := &Identity{Subject: "7", ExpiresAt: time.Now().Add(50 * time.Millisecond)}
+		c := &Client{hub: hub, conn: srvConn, send: sc.ch, identity: identity}
+		t.Cleanup(sc.close)
+
+		go c.writePump()
+
+		require.NoError(t, peerConn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, _, err := peerConn.ReadMessage()
+		var closeErr *gorilla.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, gorilla.ClosePolicyViolation, closeErr.Code)
+		assert.Equal(t, "credentials expired", closeErr.Text)
+	})
+}
+
+// echoDispatcher answers "echo" calls with their params, and fails others.
+type echoDispatcher struct{}
+
+func (echoDispatcher) Call(_ context.Context, _ *Identity, method string, params json.RawMessage) (json.RawMessage, error) {
+	switch method {
+	case "echo":
+		return params, nil
+	case "missing":
+		return nil, &CallError{Status: http.StatusNotFound, Message: "Item not found"}
+	default:
+		return nil, errors.New("database unavailable")
+	}
+}
+
+// TestClient_Call sends calls over a connection and checks the replies.
+func TestClient_Call(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		dispatcher Dispatcher
+		call       string
+		want       string
+	}{
+		{
+			name:       "result",
+			dispatcher: echoDispatcher{},
+			call:       `{"id":1,"method":"echo","params":{"name":"Pen"}}`,
+			want:       `{"type":"result","id":1,"payload":{"name":"Pen"}}`,
+		},
+		{
+			name:       "string id and no result",
+			dispatcher: echoDispatcher{},
+			call:       `{"id":"a","method":"echo"}`,
+			want:       `{"type":"result","id":"a","payload":null}`,
+		},
+		{
+			name:       "call error",
+			dispatcher: echoDispatcher{},
+			call:       `{"id":2,"method":"missing"}`,
+			want:       `{"type":"error","id":2,"payload":{"error":"Item not found","status":404}}`,
+		},
+		{
+			name:       "internal error is not leaked",
+			dispatcher: echoDispatcher{},
+			call:       `{"id":3,"method":"fail"}`,
+			want:       `{"type":"error","id":3,"payload":{"error":"Internal server error","status":500}}`,
+		},
+		{
+			name:       "no id",
+			dispatcher: echoDispatcher{},
+			call:       `{"method":"echo"}`,
+			want:       `{"type":"error","payload":{"error":"call has no id","status":400}}`,
+		},
+		{
+			name: "no dispatcher",
+			call: `{"id":4,"method":"echo"}`,
+			want: `{"type":"error","id":4,"payload":{"error":"calls are not supported","status":501}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hub := NewHub()
+			if tt.dispatcher != nil {
+				hub.SetDispatcher(tt.dispatcher)
+			}
+			go hub.Run()
+			defer hub.Shutdown()
+
+			srvConn, peerConn := newTestWSPair(t)
+			_, err := NewClient(hub, srvConn, ClientOptions{})
+			require.NoError(t, err)
+
+			require.NoError(t, peerConn.WriteMessage(gorilla.TextMessage, []byte(tt.call)))
+			require.NoError(t, peerConn.SetReadDeadline(time.Now().Add(2*time.Second)))
+			_, data, err := peerConn.ReadMessage()
+			require.NoError(t, err)
+			var reply map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &reply))
+			assert.NotEmpty(t, reply["time"])
+			delete(reply, "time")
+			got, err := json.Marshal(reply)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+// TestClient_CallRateLimit checks that calls beyond the hub's rate limit
+// fail, and that the limit applies to each connection.
+func TestClient_CallRateLimit(t *testing.T) {
+	t.Parallel()
+	hub := NewHub()
+	hub.SetDispatcher(echoDispatcher{})
+	hub.SetCallRateLimit(2, time.Minute)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	call := func(peerConn *gorilla.Conn, id int) ErrorPayload {
+		t.Helper()
+		require.NoError(t, peerConn.WriteMessage(gorilla.TextMessage, []byte(fmt.Sprintf(`{"id":%d,"method":"echo"}`, id))))
+		require.NoError(t, peerConn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, data, err := peerConn.ReadMessage()
+		require.NoError(t, err)
+		var reply struct {
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(data, &reply))
+		return reply.Payload
+	}
+
+	srvConn, peerConn := newTestWSPair(t)
+	_, err := NewClient(hub, srvConn, ClientOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, call(peerConn, 1).Status)
+	assert.Zero(t, call(peerConn, 2).Status)
+	assert.Equal(t, ErrorPayload{Error: "rate limit exceeded", Status: http.StatusTooManyRequests}, call(peerConn, 3))
+
+	otherSrvConn, otherPeerConn := newTestWSPair(t)
+	_, err = NewClient(hub, otherSrvConn, ClientOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, call(otherPeerConn, 1).Status, "each connection has its own limit")
+}
+
+// TestClient_MaxMessageSize checks that clients sending messages over the
+// hub's limit are disconnected.
+func TestClient_MaxMessageSize(t *testing.T) {
+	t.Parallel()
+	hub := NewHub()
+	hub.SetMaxMessageSize(64)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	srvConn, peerConn := newTestWSPair(t)
+	_, err := NewClient(hub, srvConn, ClientOptions{})
+	require.NoError(t, err)
+	waitForClientCount(t, hub, 1)
+
+	require.NoError(t, peerConn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["items"]}`)))
+	require.NoError(t, peerConn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = peerConn.ReadMessage()
+	require.NoError(t, err, "a message within the limit is answered")
+
+	require.NoError(t, peerConn.WriteMessage(gorilla.TextMessage, []byte(`{"type":"subscribe","topics":["`+strings.Repeat("a", 64)+`"]}`)))
+	waitForClientCount(t, hub, 0)
 }
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackProtocol is the WebSocket subprotocol of clients that exchange
+// MessagePack-encoded messages in binary frames instead of JSON in text
+// frames.
+const MsgpackProtocol = "msgpack"
+
+// minCompressSize is the size from which messages are compressed on
+// connections that negotiated permessage-deflate; smaller ones are not worth
+// the cost.
+const minCompressSize = 512
+
+// Encoding is how messages are encoded on a client's connection.
+type Encoding string
+
+// Encodings of client connections.
+const (
+	// JSON sends each message as JSON in a text frame. It is the default,
+	// also used for the zero Encoding.
+	JSON Encoding = "json"
+	// Msgpack sends each message as MessagePack in a binary frame, with the
+	// same fields as its JSON; time is a MessagePack timestamp.
+	Msgpack Encoding = "msgpack"
+)
+
+// msgpackMessage is a Message as it is encoded in MessagePack.
+type msgpackMessage struct {
+	Type    string      `msgpack:"type"`
+	ID      interface{} `msgpack:"id,omitempty"`
+	Seq     uint64      `msgpack:"seq,omitempty"`
+	EventID string      `msgpack:"event_id,omitempty"`
+	Time    time.Time   `msgpack:"time"`
+	Payload interface{} `msgpack:"payload"`
+}
+
+// encodeMsgpack converts message, a serialised Message, to MessagePack.
+func encodeMsgpack(message []byte) ([]byte, error) {
+	var msg Message
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+	out := msgpackMessage{Type: msg.Type, Seq: msg.Seq, EventID: msg.EventID, Time: msg.Time}
+	var err error
+	if out.ID, err = decodeJSONValue(msg.ID); err != nil {
+		return nil, fmt.Errorf("decode id: %w", err)
+	}
+	if out.Payload, err = decodeJSONValue(msg.Payload); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	return msgpack.Marshal(out)
+}
+
+// decodeMsgpack converts a message a client sent in MessagePack to JSON, so
+// that it is read like any other.
+func decodeMsgpack(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// decodeJSONValue decodes raw, keeping integers exact rather than turning
+// them into float64s. Empty raw decodes to nil.
+func decodeJSONValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return exactNumbers(v), nil
+}
+
+// exactNumbers replaces the json.Numbers in v by int64s, or float64s for
+// those that are not integers.
+func exactNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, elem := range v {
+			v[k] = exactNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = exactNumbers(elem)
+		}
+	}
+	return v
+}
+
+// frame returns the WebSocket frame type and data of message, a serialised
+// Message, in the client's encoding. Messages that are not a Message are
+// sent as they are.
+func (c *Client) frame(message []byte) (int, []byte) {
+	if c.encoding != Msgpack {
+		return websocket.TextMessage, message
+	}
+	data, err := encodeMsgpack(message)
+	if err != nil {
+		return websocket.TextMessage, message
+	}
+	return websocket.BinaryMessage, data
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEncodeMsgpack(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		message string
+		want    map[string]interface{}
+	}{
+		{
+			name:    "event",
+			message: `{"type":"item.updated","seq":9007199254740993,"time":"2026-01-02T03:04:05Z","payload":{"id":42,"price":1.5,"tags":["a"],"deleted_at":null}}`,
+			want: map[string]interface{}{
+				"type":    "item.updated",
+				"seq":     uint64(9007199254740993),
+				"time":    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				"payload": map[string]interface{}{"id": int64(42), "price": 1.5, "tags": []interface{}{"a"}, "deleted_at": nil},
+			},
+		},
+		{
+			name:    "call reply",
+			message: `{"type":"result","id":"a","time":"2026-01-02T03:04:05Z","payload":null}`,
+			want: map[string]interface{}{
+				"type":    "result",
+				"id":      "a",
+				"time":    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				"payload": nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data, err := encodeMsgpack([]byte(tt.message))
+			require.NoError(t, err)
+
+			var got map[string]interface{}
+			dec := msgpack.NewDecoder(bytes.NewReader(data))
+			// Integers decode as int64 or uint64, whatever their encoded size.
+			dec.UseLooseInterfaceDecoding(true)
+			require.NoError(t, dec.Decode(&got))
+			if tm, ok := got["time"].(time.Time); ok {
+				got["time"] = tm.UTC()
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := encodeMsgpack([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestDecodeMsgpack(t *testing.T) {
+	t.Parallel()
+	data, err := msgpack.Marshal(map[string]interface{}{
+		"id":     7,
+		"method": "items.patch",
+		"params": map[string]interface{}{"id": 42, "body": map[string]interface{}{"price": 2.5}},
+	})
+	require.NoError(t, err)
+
+	got, err := decodeMsgpack(data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":7,"method":"items.patch","params":{"id":42,"body":{"price":2.5}}}`, string(got))
+
+	_, err = decodeMsgpack([]byte{0xc1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package websocket
+
+// defaultHistorySize is how many of the latest events a hub keeps for
+// clients resuming after a reconnect.
+const defaultHistorySize = 1024
+
+// eventIDWindow is how many of the latest EventIDs a hub remembers to drop
+// redelivered events.
+const eventIDWindow = 4096
+
+// history is a bounded ring buffer of the latest sequenced publications,
+// oldest first. Only the hub's Run loop uses it.
+type history struct {
+	items []publication
+	// start is the index of the oldest publication, and size the number
+	// buffered.
+	start, size int
+}
+
+func newHistory(capacity int) *history {
+	return &history{items: make([]publication, capacity)}
+}
+
+// push adds pub, dropping the oldest publication if the buffer is full.
+func (r *history) push(pub publication) {
+	if len(r.items) == 0 {
+		return
+	}
+	if r.size < len(r.items) {
+		r.items[(r.start+r.size)%len(r.items)] = pub
+		r.size++
+		return
+	}
+	r.items[r.start] = pub
+	r.start = (r.start + 1) % len(r.items)
+}
+
+// since returns the publications sequenced after seq, the last of which is
+// latest. It reports false if some of them are no longer buffered, or seq
+// is not one of this stream's.
+func (r *history) since(seq, latest uint64) ([]publication, bool) {
+	if seq > latest {
+		return nil, false
+	}
+	missed := latest - seq
+	if missed > uint64(r.size) {
+		return nil, false
+	}
+	out := make([]publication, 0, missed)
+	for i := r.size - int(missed); i < r.size; i++ {
+		out = append(out, r.items[(r.start+i)%len(r.items)])
+	}
+	return out, true
+}
+
+// eventIDs is a bounded set of the latest EventIDs. Only the hub's Run loop
+// uses it.
+type eventIDs struct {
+	seen map[string]bool
+	// ring holds the IDs in seen, oldest at next once it is full.
+	ring []string
+	next int
+}
+
+func newEventIDs(capacity int) *eventIDs {
+	return &eventIDs{seen: make(map[string]bool, capacity), ring: make([]string, 0, capacity)}
+}
+
+// add records id, forgetting the oldest ID if the set is full. It reports
+// whether id was new.
+func (s *eventIDs) add(id string) bool {
+	if s.seen[id] {
+		return false
+	}
+	if len(s.ring) < cap(s.ring) {
+		s.ring = append(s.ring, id)
+	} else {
+		delete(s.seen, s.ring[s.next])
+		s.ring[s.next] = id
+		s.next = (s.next + 1) % len(s.ring)
+	}
+	s.seen[id] = true
+	return true
+}
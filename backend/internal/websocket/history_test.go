@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	// pushed returns a history of capacity holding the publications
+	// sequenced 1 to n.
+	pushed := func(capacity, n int) *history {
+		r := newHistory(capacity)
+		for seq := 1; seq <= n; seq++ {
+			r.push(publication{seq: uint64(seq)})
+		}
+		return r
+	}
+	seqs := func(pubs []publication) []uint64 {
+		out := []uint64{}
+		for _, pub := range pubs {
+			out = append(out, pub.seq)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		history  *history
+		since    uint64
+		latest   uint64
+		wantSeqs []uint64
+		wantOK   bool
+	}{
+		{name: "missed some", history: pushed(4, 3), since: 1, latest: 3, wantSeqs: []uint64{2, 3}, wantOK: true},
+		{name: "missed none", history: pushed(4, 3), since: 3, latest: 3, wantSeqs: []uint64{}, wantOK: true},
+		{name: "missed all buffered", history: pushed(4, 6), since: 2, latest: 6, wantSeqs: []uint64{3, 4, 5, 6}, wantOK: true},
+		{name: "missed overwritten", history: pushed(4, 6), since: 1, latest: 6, wantOK: false},
+		{name: "since ahead of stream", history: pushed(4, 3), since: 7, latest: 3, wantOK: false},
+		{name: "no history", history: pushed(0, 3), since: 2, latest: 3, wantOK: false},
+		{name: "no history, missed none", history: pushed(0, 3), since: 3, latest: 3, wantSeqs: []uint64{}, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := tt.history.since(tt.since, tt.latest)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantSeqs, seqs(got))
+			}
+		})
+	}
+}
+
+func TestEventIDs(t *testing.T) {
+	t.Parallel()
+
+	ids := newEventIDs(2)
+	assert.True(t, ids.add("a"))
+	assert.True(t, ids.add("b"))
+	assert.False(t, ids.add("a"), "seen IDs are reported")
+	assert.True(t, ids.add("c"))
+	assert.True(t, ids.add("a"), "the oldest ID is forgotten once the set is full")
+	assert.False(t, ids.add("c"))
+	assert.True(t, ids.add("b"))
+	assert.Len(t, ids.seen, 2)
+}
@@ -3,36 +3,91 @@
 package websocket
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"time"
 )
 
 // broadcastBufferSize is the capacity of the Hub's broadcast channel.
 // Messages exceeding this buffer are dropped with a warning log.
 const broadcastBufferSize = 256
 
+// maxSubscriptions is the number of topics a client may subscribe to.
+const maxSubscriptions = 100
+
+// backplaneTimeout bounds each publication to the backplane.
+const backplaneTimeout = 5 * time.Second
+
+// Subscribing to the backplane is retried after a failure, or after the
+// subscription closes, waiting from backplaneRetryMin to backplaneRetryMax,
+// twice as long after each failed attempt.
+const (
+	backplaneRetryMin = 500 * time.Millisecond
+	backplaneRetryMax = 30 * time.Second
+)
+
 // ErrHubClosed is returned when attempting to register a client on a shut-down hub.
 var ErrHubClosed = errHubClosed{}
 
+// ErrPermissionDenied is wrapped by the errors refusing subscriptions to
+// topics the client lacks the permission for.
+var ErrPermissionDenied = errors.New("permission denied")
+
 type errHubClosed struct{}
 
 func (errHubClosed) Error() string { return "hub is closed" }
 
-// BroadcastSender is implemented by any type that can broadcast messages
-// to all connected WebSocket clients. Use this interface for decoupled
-// dependency injection (e.g., handlers broadcast events without importing Hub).
+// BroadcastSender is implemented by any type that can send messages to
+// connected WebSocket clients. Use this interface for decoupled dependency
+// injection (e.g., handlers publish events without importing Hub).
 type BroadcastSender interface {
+	// Broadcast sends message to every client.
 	Broadcast(message []byte)
+	// Publish sends message to the clients subscribed to topic, or to the
+	// collection topic it belongs to; see Topic.
+	Publish(topic string, message []byte)
+}
+
+// publication is a message published to a topic, or broadcast to every
+// client when topic is empty. seq is set once the hub has sequenced it, and
+// viewer, for presence events, to the ID of the client they are about.
+type publication struct {
+	topic   string
+	message []byte
+	seq     uint64
+	viewer  string
 }
 
-// Hub manages the set of active WebSocket clients and broadcasts messages
-// to all of them. It is safe for concurrent use.
+// clientRequest is a message a client sent, queued for the Run loop, or
+// the reply to a call it made, to be sent to it.
+type clientRequest struct {
+	client  *Client
+	message ClientMessage
+	reply   []byte
+}
+
+// Hub manages the set of active WebSocket clients and routes messages to
+// them. It is safe for concurrent use.
 type Hub struct {
 	// clients holds the set of registered clients.
 	clients map[*Client]bool
 
-	// broadcast receives messages to send to all clients.
-	broadcast chan []byte
+	// subscribers holds the clients subscribed to each topic.
+	subscribers map[string]map[*Client]bool
+
+	// broadcast receives messages to send to all clients or to a topic's
+	// subscribers. One channel keeps them in the order they were sent.
+	broadcast chan publication
+
+	// requests receives messages from clients, such as subscriptions.
+	requests chan clientRequest
 
 	// register receives clients requesting registration.
 	register chan *Client
@@ -40,9 +95,61 @@ type Hub struct {
 	// unregister receives clients requesting removal.
 	unregister chan *Client
 
-	// mu protects the clients map for reads outside the Run loop.
+	// topicPermissions maps a collection topic to the permission needed to
+	// subscribe to it and its entity topics.
+	topicPermissions map[string]string
+
+	// calls serves the calls clients make; nil rejects them.
+	calls Dispatcher
+
+	// mu protects the clients and subscribers maps for reads outside the Run
+	// loop, topicPermissions and calls.
 	mu sync.RWMutex
 
+	// maxMessageSize is the largest message a client may send; 0 is no
+	// limit.
+	maxMessageSize int64
+
+	// callLimit is the number of calls a client may make per callWindow; 0
+	// is no limit.
+	callLimit  int
+	callWindow time.Duration
+
+	// backpressure is the policy of clients that do not choose one.
+	backpressure Backpressure
+
+	// backlogged holds the clients with a backlog to flush. Only the Run
+	// loop uses it.
+	backlogged map[*Client]bool
+
+	stats hubStats
+
+	// node identifies this hub on the backplane.
+	node string
+
+	// seq is the sequence ID of the latest event, and history buffers the
+	// latest events for resuming clients. Only the Run loop uses them.
+	seq     uint64
+	history *history
+
+	// eventIDs are the EventIDs of the latest events, to drop those
+	// delivered again. Only the Run loop uses it.
+	eventIDs *eventIDs
+
+	// backplane relays publications to and from the hubs of other nodes, and
+	// outbound queues them for it. Both are nil without a backplane.
+	backplane Backplane
+	outbound  chan Envelope
+
+	// backplaneRetry is the first delay before subscribing to the backplane
+	// again.
+	backplaneRetry time.Duration
+
+	// remote is the presence on other nodes, which is shared every
+	// presenceInterval.
+	remote           *remotePresence
+	presenceInterval time.Duration
+
 	// done signals the Run loop to stop.
 	done chan struct{}
 
@@ -53,68 +160,576 @@ type Hub struct {
 // NewHub creates a new Hub ready to accept clients.
 func NewHub() *Hub {
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, broadcastBufferSize),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		done:       make(chan struct{}),
+		node:             newID(),
+		seq:              initialSeq(),
+		history:          newHistory(defaultHistorySize),
+		eventIDs:         newEventIDs(eventIDWindow),
+		backplaneRetry:   backplaneRetryMin,
+		remote:           newRemotePresence(),
+		presenceInterval: presenceInterval,
+		maxMessageSize:   defaultMaxMessageSize,
+		backpressure:     Disconnect,
+		backlogged:       make(map[*Client]bool),
+		clients:          make(map[*Client]bool),
+		subscribers:      make(map[string]map[*Client]bool),
+		topicPermissions: make(map[string]string),
+		broadcast:        make(chan publication, broadcastBufferSize),
+		requests:         make(chan clientRequest),
+		register:         make(chan *Client),
+		unregister:       make(chan *Client),
+		done:             make(chan struct{}),
 	}
 }
 
 // Run starts the hub's event loop. It should be launched as a goroutine.
-// It processes register, unregister, and broadcast events until Shutdown is called.
+// It processes register, unregister, client request and broadcast events
+// until Shutdown is called.
 func (h *Hub) Run() {
+	if h.backplane != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go h.forward(ctx)
+		go h.consume(ctx)
+		go h.sharePresence(ctx)
+	}
+	flush := time.NewTicker(backlogFlushInterval)
+	defer flush.Stop()
+
 	for {
 		select {
 		case <-h.done:
 			h.closeAllClients()
 			return
 		case client := <-h.register:
+			client.id = newID()
+			client.connectedAt = time.Now().UTC()
+			client.lastActive.Store(client.connectedAt.UnixNano())
+			if client.backpressure == "" {
+				client.backpressure = h.backpressure
+			}
 			h.mu.Lock()
 			h.clients[client] = true
+			for _, topic := range client.initialTopics {
+				h.subscribe(client, topic)
+			}
 			h.mu.Unlock()
+			if client.initialTopics != nil {
+				client.initialTopics = nil
+				h.resumePending(client)
+			}
+			h.announce(client, PresenceJoined, client.viewed())
 			slog.Info("WebSocket client registered", "clients", h.ClientCount())
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
+			h.leave(client)
 			h.mu.Unlock()
 			slog.Info("WebSocket client unregistered", "clients", h.ClientCount())
-		case message := <-h.broadcast:
+		case req := <-h.requests:
+			if req.reply != nil {
+				h.sendReply(req.client, req.reply)
+			} else {
+				h.handleRequest(req.client, req.message)
+			}
+		case pub := <-h.broadcast:
+			pub, ok := h.sequence(pub)
+			if !ok {
+				continue
+			}
 			h.mu.RLock()
-			var slow []*Client
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					slow = append(slow, client)
+			var recipients []*Client
+			switch {
+			case pub.topic == "":
+				recipients = make([]*Client, 0, len(h.clients))
+				for client := range h.clients {
+					recipients = append(recipients, client)
 				}
+			case pub.viewer != "":
+				recipients = h.viewersOf(pub.topic, pub.viewer)
+			default:
+				recipients = h.subscribersOf(pub.topic)
 			}
 			h.mu.RUnlock()
-			if len(slow) > 0 {
-				h.mu.Lock()
-				for _, client := range slow {
-					if _, ok := h.clients[client]; ok {
-						delete(h.clients, client)
-						close(client.send)
-					}
+			h.deliver(recipients, pub)
+		case <-flush.C:
+			h.flushBacklogs()
+		}
+	}
+}
+
+// subscribersOf returns the clients subscribed to topic or to the collection
+// topic it belongs to, each once. The caller must hold h.mu.
+func (h *Hub) subscribersOf(topic string) []*Client {
+	var recipients []*Client
+	for client := range h.subscribers[topic] {
+		recipients = append(recipients, client)
+	}
+	if collection, _, ok := strings.Cut(topic, ":"); ok {
+		for client := range h.subscribers[collection] {
+			if !h.subscribers[topic][client] {
+				recipients = append(recipients, client)
+			}
+		}
+	}
+	return recipients
+}
+
+// deliver sends pub to recipients, applying the backpressure policy of
+// those whose send buffer is full.
+func (h *Hub) deliver(recipients []*Client, pub publication) {
+	var slow []*Client
+	for _, client := range recipients {
+		if !h.enqueue(client, pub) {
+			slow = append(slow, client)
+		}
+	}
+	h.disconnectSlow(slow)
+}
+
+// leave removes client, announcing that it left the resources it viewed.
+// Only the Run loop may call it, holding h.mu.
+func (h *Hub) leave(client *Client) {
+	if h.clients[client] {
+		h.announce(client, PresenceLeft, client.viewed())
+	}
+	h.removeClient(client)
+}
+
+// removeClient unregisters client, drops its subscriptions and closes its
+// send channel. The caller must hold h.mu.
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	for topic := range client.topics {
+		h.unsubscribe(client, topic)
+	}
+	delete(h.clients, client)
+	delete(h.backlogged, client)
+	close(client.send)
+}
+
+// handleRequest applies a message from client and replies to it.
+func (h *Hub) handleRequest(client *Client, msg ClientMessage) {
+	h.mu.Lock()
+	if _, ok := h.clients[client]; !ok {
+		h.mu.Unlock()
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case SubscribeMessage:
+		if err = h.checkSubscription(client, msg.Topics); err == nil {
+			var joined []string
+			for _, topic := range msg.Topics {
+				if isResource(topic) && !client.topics[topic] {
+					joined = append(joined, topic)
 				}
-				h.mu.Unlock()
+				h.subscribe(client, topic)
+			}
+			h.announce(client, PresenceJoined, joined)
+		}
+	case UnsubscribeMessage:
+		for _, topic := range msg.Topics {
+			if _, ok := client.viewing[topic]; ok {
+				h.announce(client, PresenceLeft, []string{topic})
+			}
+			h.unsubscribe(client, topic)
+		}
+	default:
+		err = fmt.Errorf("unknown message type %q", msg.Type)
+	}
+	h.mu.Unlock()
+
+	switch {
+	case err != nil:
+		h.reply(client, ErrorMessage, ErrorPayload{Error: err.Error()})
+	case msg.Type == SubscribeMessage:
+		h.reply(client, SubscribedMessage, Subscriptions{Topics: client.subscriptions()})
+		// Resume on the first subscription, as the events the client
+		// missed are those of the topics it subscribes to again.
+		h.resumePending(client)
+	default:
+		h.reply(client, UnsubscribedMessage, Subscriptions{Topics: client.subscriptions()})
+	}
+}
+
+// reply sends client a message of msgType that is not part of the event
+// stream. Only the Run loop may call it.
+func (h *Hub) reply(client *Client, msgType string, payload interface{}) {
+	msg, err := NewMessage(msgType, payload)
+	if err != nil {
+		slog.Error("Failed to create WebSocket reply", "type", msgType, "error", err)
+		return
+	}
+	b, err := msg.Bytes()
+	if err != nil {
+		slog.Error("Failed to serialise WebSocket reply", "type", msgType, "error", err)
+		return
+	}
+	h.send(client, b)
+}
+
+// sendReply sends reply to client unless it has been removed. Only the Run
+// loop may call it.
+func (h *Hub) sendReply(client *Client, reply []byte) {
+	if h.clients[client] {
+		h.send(client, reply)
+	}
+}
+
+// send queues message for client, dropping it if the client's send buffer
+// is full; the client's backpressure policy applies to the next event. Only
+// the Run loop may call it.
+func (h *Hub) send(client *Client, message []byte) {
+	select {
+	case client.send <- message:
+	default:
+	}
+}
+
+// resumePending resumes client from the since it connected with, unless it
+// has already. Only the Run loop may call it.
+func (h *Hub) resumePending(client *Client) {
+	if client.since == nil {
+		return
+	}
+	since := *client.since
+	client.since = nil
+	h.resume(client, since)
+}
+
+// resume sends client the buffered events after since that it receives,
+// or a resync message if some are no longer buffered or they would not fit
+// in its send buffer. Only the Run loop may call it.
+func (h *Hub) resume(client *Client, since uint64) {
+	missed, ok := h.history.since(since, h.seq)
+	var replay [][]byte
+	if ok {
+		for _, pub := range missed {
+			// Presence is not replayed: it may be stale, and Presence tells
+			// the current one.
+			if pub.viewer == "" && client.receives(pub.topic) {
+				replay = append(replay, pub.message)
 			}
 		}
 	}
+	// Only the Run loop sends to clients, so the free space cannot shrink.
+	if !ok || len(client.backlog) > 0 || len(replay) > cap(client.send)-len(client.send) {
+		slog.Info("WebSocket client cannot resume, asking it to resync", "since", since, "seq", h.seq)
+		h.reply(client, ResyncMessage, ResyncPayload{Seq: h.seq})
+		return
+	}
+	for _, message := range replay {
+		client.send <- message
+	}
 }
 
-// Broadcast sends a message to all connected clients.
-// It is safe for concurrent use and implements BroadcastSender.
+// sequence stamps pub's message with the next sequence ID and buffers it
+// for resuming clients. Messages that are not a Message are delivered as
+// they are. It returns false for events with an EventID already delivered,
+// which are dropped. Only the Run loop may call it.
+func (h *Hub) sequence(pub publication) (publication, bool) {
+	var msg Message
+	if err := json.Unmarshal(pub.message, &msg); err != nil || msg.Type == "" {
+		return pub, true
+	}
+	if msg.EventID != "" && !h.eventIDs.add(msg.EventID) {
+		slog.Debug("Dropping redelivered WebSocket event", "event_id", msg.EventID)
+		return pub, false
+	}
+	msg.Seq = h.seq + 1
+	if msg.Time.IsZero() {
+		msg.Time = time.Now().UTC()
+	}
+	b, err := msg.Bytes()
+	if err != nil {
+		slog.Error("Failed to sequence WebSocket message", "type", msg.Type, "error", err)
+		return pub, true
+	}
+	h.seq = msg.Seq
+	pub.message, pub.seq = b, msg.Seq
+	if msg.Type == PresenceJoined || msg.Type == PresenceLeft {
+		pub.viewer = viewerOf(msg)
+	}
+	h.history.push(pub)
+	return pub, true
+}
+
+// checkSubscription returns an error unless client may subscribe to all of
+// topics. The caller must hold h.mu.
+func (h *Hub) checkSubscription(client *Client, topics []string) error {
+	if len(topics) == 0 {
+		return errors.New("no topics given")
+	}
+	added := 0
+	for _, topic := range topics {
+		if !ValidTopic(topic) {
+			return fmt.Errorf("invalid topic %q", topic)
+		}
+		collection, _, _ := strings.Cut(topic, ":")
+		permission, required := h.topicPermissions[collection]
+		if !client.canReceive(permission, required) {
+			return fmt.Errorf("%w for topic %q", ErrPermissionDenied, topic)
+		}
+		if !client.topics[topic] {
+			added++
+		}
+	}
+	if len(client.topics)+added > maxSubscriptions {
+		return fmt.Errorf("at most %d subscriptions allowed", maxSubscriptions)
+	}
+	return nil
+}
+
+// subscribe adds topic to client's subscriptions. The caller must hold h.mu.
+func (h *Hub) subscribe(client *Client, topic string) {
+	if client.topics == nil {
+		client.topics = make(map[string]bool)
+	}
+	client.topics[topic] = true
+	if isResource(topic) {
+		if client.viewing == nil {
+			client.viewing = make(map[string]time.Time)
+		}
+		if _, ok := client.viewing[topic]; !ok {
+			client.viewing[topic] = time.Now().UTC()
+		}
+	}
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = make(map[*Client]bool)
+	}
+	h.subscribers[topic][client] = true
+}
+
+// unsubscribe removes topic from client's subscriptions. The caller must
+// hold h.mu.
+func (h *Hub) unsubscribe(client *Client, topic string) {
+	delete(client.topics, topic)
+	delete(client.viewing, topic)
+	delete(h.subscribers[topic], client)
+	if len(h.subscribers[topic]) == 0 {
+		delete(h.subscribers, topic)
+	}
+}
+
+// Broadcast sends a message to all connected clients, whatever their
+// subscriptions, including those of other nodes when a backplane is set.
+// It is meant for messages every client may see; resource events go through
+// Publish. It is safe for concurrent use and implements BroadcastSender.
 func (h *Hub) Broadcast(message []byte) {
 	select {
-	case h.broadcast <- message:
+	case h.broadcast <- publication{message: message}:
 	default:
+		h.stats.rejected.Add(1)
 		slog.Warn("WebSocket broadcast channel full, message dropped")
 	}
+	h.relay(publication{message: message})
+}
+
+// Publish sends a message to the clients subscribed to topic or to its
+// collection topic, on every node when a backplane is set. It is safe for
+// concurrent use and implements BroadcastSender.
+func (h *Hub) Publish(topic string, message []byte) {
+	if topic == "" {
+		slog.Warn("WebSocket message published without a topic, message dropped")
+		return
+	}
+	select {
+	case h.broadcast <- publication{topic: topic, message: message}:
+	default:
+		h.stats.rejected.Add(1)
+		slog.Warn("WebSocket publish channel full, message dropped", "topic", topic)
+	}
+	h.relay(publication{topic: topic, message: message})
+}
+
+// PublishWait is Publish for messages that must not be dropped, such as the
+// events of an outbox: when the hub's queue is full, it waits for room. It
+// returns ctx's error if ctx is done first, or ErrHubClosed if the hub is
+// shut down.
+func (h *Hub) PublishWait(ctx context.Context, topic string, message []byte) error {
+	if topic == "" {
+		return errors.New("no topic given")
+	}
+	select {
+	case h.broadcast <- publication{topic: topic, message: message}:
+	case <-h.done:
+		return ErrHubClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	h.relay(publication{topic: topic, message: message})
+	return nil
+}
+
+// SetBackplane relays the messages published on this hub to the hubs of
+// other nodes through b, and delivers theirs to this hub's clients. It must
+// be called before Run.
+func (h *Hub) SetBackplane(b Backplane) {
+	h.backplane = b
+	h.outbound = make(chan Envelope, broadcastBufferSize)
+}
+
+// SetHistorySize sets how many of the latest events are buffered for
+// clients resuming after a reconnect; 0 makes every resuming client resync.
+// It must be called before Run.
+func (h *Hub) SetHistorySize(size int) {
+	h.history = newHistory(size)
+}
+
+// SetMaxMessageSize sets the largest message, in bytes, a client may send;
+// clients sending more are disconnected. 0 removes the limit. It must be
+// called before Run.
+func (h *Hub) SetMaxMessageSize(size int64) {
+	h.maxMessageSize = size
+}
+
+// SetCallRateLimit limits the calls each client makes over its connection
+// to limit per window; calls beyond it fail with 429 Too Many Requests. A
+// limit of 0 removes it. It must be called before Run.
+func (h *Hub) SetCallRateLimit(limit int, window time.Duration) {
+	h.callLimit = limit
+	h.callWindow = window
+}
+
+// SetBackpressure sets the policy of clients that do not choose one. It
+// must be called before Run.
+func (h *Hub) SetBackpressure(policy Backpressure) {
+	h.backpressure = policy
+}
+
+// SetDispatcher serves the calls clients make with d. Without a dispatcher,
+// calls are answered with an error.
+func (h *Hub) SetDispatcher(d Dispatcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = d
+}
+
+// dispatcher returns the dispatcher set by SetDispatcher, or nil.
+func (h *Hub) dispatcher() Dispatcher {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.calls
+}
+
+// NodeID returns the ID identifying this hub's messages on the backplane.
+func (h *Hub) NodeID() string {
+	return h.node
+}
+
+// relay queues pub for the backplane, if any.
+func (h *Hub) relay(pub publication) {
+	if h.backplane == nil {
+		return
+	}
+	select {
+	case h.outbound <- Envelope{Origin: h.node, Topic: pub.topic, Message: pub.message}:
+	default:
+		slog.Warn("WebSocket backplane channel full, message not relayed", "topic", pub.topic)
+	}
+}
+
+// forward publishes the queued envelopes to the backplane until ctx is done.
+// It runs apart from the Run loop so a slow backplane never delays local
+// delivery.
+func (h *Hub) forward(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-h.outbound:
+			pctx, cancel := context.WithTimeout(ctx, backplaneTimeout)
+			err := h.backplane.Publish(pctx, env)
+			cancel()
+			if err != nil {
+				slog.Warn("Failed to relay WebSocket message to backplane", "topic", env.Topic, "error", err)
+			}
+		}
+	}
+}
+
+// consume delivers the envelopes other nodes publish to the backplane until
+// ctx is done. Envelopes from this hub were delivered when published. A
+// failed or closed subscription is retried with backoff, so that a backplane
+// which is down at startup, or goes away, is used once it is back.
+func (h *Hub) consume(ctx context.Context) {
+	delay := h.backplaneRetry
+	for {
+		envelopes, err := h.backplane.Subscribe(ctx)
+		if err != nil {
+			slog.Warn("Failed to subscribe to WebSocket backplane; messages from other nodes are not delivered until it succeeds",
+				"error", err, "retryIn", delay)
+		} else if h.receiveEnvelopes(ctx, envelopes) {
+			delay = h.backplaneRetry
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			slog.Warn("WebSocket backplane subscription closed; subscribing again", "retryIn", delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, backplaneRetryMax)
+	}
+}
+
+// receiveEnvelopes passes the envelopes of other nodes to the Run loop until
+// envelopes is closed or ctx is done, and reports whether it received any.
+func (h *Hub) receiveEnvelopes(ctx context.Context, envelopes <-chan Envelope) bool {
+	received := false
+	for env := range envelopes {
+		received = true
+		if env.Origin == h.node {
+			continue
+		}
+		if env.Presence != nil {
+			h.remote.replace(env.Origin, env.Presence.Viewers, time.Now())
+			continue
+		}
+		h.remote.apply(env.Origin, env.Message, time.Now())
+		select {
+		case h.broadcast <- publication{topic: env.Topic, message: env.Message}:
+		case <-ctx.Done():
+			return received
+		}
+	}
+	return received
+}
+
+// RequirePermission restricts subscriptions to collection, such as "items",
+// and its entity topics, such as "items:42", to authenticated clients
+// holding permission. Authenticated clients cannot subscribe to collections
+// without a permission; unauthenticated clients can subscribe to any.
+func (h *Hub) RequirePermission(collection, permission string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.topicPermissions[collection] = permission
+}
+
+// receive queues a message client sent for the Run loop. It is a no-op
+// once the hub has been shut down.
+func (h *Hub) receive(client *Client, msg ClientMessage) {
+	select {
+	case h.requests <- clientRequest{client: client, message: msg}:
+	case <-h.done:
+	}
+}
+
+// answer queues the reply to a call client made for the Run loop, which
+// owns the client's send channel. It is a no-op once the hub has been shut
+// down.
+func (h *Hub) answer(client *Client, reply []byte) {
+	select {
+	case h.requests <- clientRequest{client: client, reply: reply}:
+	case <-h.done:
+	}
 }
 
 // Shutdown gracefully stops the hub's Run loop and closes all client connections.
@@ -125,6 +740,11 @@ func (h *Hub) Shutdown() {
 	})
 }
 
+// Done returns a channel that is closed when the hub is shut down.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
 // Register safely registers a client with the hub. It returns ErrHubClosed if
 // the hub has been shut down, preventing the caller from blocking forever.
 func (h *Hub) Register(c *Client) error {
@@ -152,12 +772,34 @@ func (h *Hub) ClientCount() int {
 	return len(h.clients)
 }
 
+// SubscriberCount returns the number of clients subscribed to topic itself.
+func (h *Hub) SubscriberCount(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[topic])
+}
+
+// initialSeq returns the sequence ID a hub starts after: its start time in
+// milliseconds, times 1024. The IDs of a hub's events thus exceed those of
+// earlier runs, and rarely fall within the history of another node, so
+// that clients reconnecting to another hub resync rather than being sent
+// the wrong events. They stay exact in JavaScript numbers.
+func initialSeq() uint64 {
+	return uint64(time.Now().UnixMilli()) << 10
+}
+
+// newID returns a random ID for a hub or client.
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // closeAllClients removes all clients and closes their send channels.
 func (h *Hub) closeAllClients() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	for client := range h.clients {
-		close(client.send)
-		delete(h.clients, client)
+		h.removeClient(client)
 	}
 }
@@ -1,7 +1,9 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"math"
 	"testing"
 	"time"
@@ -101,14 +103,14 @@ func TestHub_Broadcast(t *testing.T) {
 
 	select {
 	case received := <-c1.send:
-		assert.Equal(t, msg, received)
+		assert.Equal(t, string(msg), unsequenced(t, received))
 	case <-time.After(time.Second):
 		t.Fatal("client 1 did not receive broadcast in time")
 	}
 
 	select {
 	case received := <-c2.send:
-		assert.Equal(t, msg, received)
+		assert.Equal(t, string(msg), unsequenced(t, received))
 	case <-time.After(time.Second):
 		t.Fatal("client 2 did not receive broadcast in time")
 	}
@@ -153,6 +155,354 @@ func TestHub_BroadcastDropsSlowClient(t *testing.T) {
 	waitForClientCount(t, hub, 0)
 }
 
+// permissions is an Authorizer granting a fixed set of permissions.
+type permissions []string
+
+func (p permissions) HasPermission(permission string) bool {
+	for _, have := range p {
+		if have == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// request sends msg as c and returns the hub's reply.
+func request(t *testing.T, hub *Hub, c *Client, msg ClientMessage) Message {
+	t.Helper()
+	hub.receive(c, msg)
+	return next(t, c)
+}
+
+// next returns the next message c receives.
+func next(t *testing.T, c *Client) Message {
+	t.Helper()
+	select {
+	case b := <-c.send:
+		var msg Message
+		require.NoError(t, json.Unmarshal(b, &msg))
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message from hub")
+		return Message{}
+	}
+}
+
+// unsequenced returns message without the seq and time the hub stamps it
+// with, as published in the tests: {"type":...,"payload":...}.
+func unsequenced(t *testing.T, message []byte) string {
+	t.Helper()
+	var msg Message
+	require.NoError(t, json.Unmarshal(message, &msg))
+	return `{"type":"` + msg.Type + `","payload":` + string(msg.Payload) + `}`
+}
+
+// events returns the unsequenced messages c receives until its channel
+// stays quiet.
+func events(t *testing.T, c *Client) []string {
+	t.Helper()
+	var got []string
+	for _, message := range received(c) {
+		got = append(got, unsequenced(t, message))
+	}
+	return got
+}
+
+// received collects the messages c receives until its channel stays quiet.
+func received(c *Client) [][]byte {
+	var got [][]byte
+	for {
+		select {
+		case m := <-c.send:
+			got = append(got, m)
+		case <-time.After(100 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func TestHub_Publish(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	all := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	one := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	both := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	none := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	for _, c := range []*Client{all, one, both, none} {
+		require.NoError(t, hub.Register(c))
+	}
+	waitForClientCount(t, hub, 4)
+	request(t, hub, all, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+	request(t, hub, one, ClientMessage{Type: SubscribeMessage, Topics: []string{"items:42"}})
+	request(t, hub, both, ClientMessage{Type: SubscribeMessage, Topics: []string{"items", "items:42"}})
+	joined := next(t, one)
+	assert.Equal(t, PresenceJoined, joined.Type, "one is told both views items:42 too")
+
+	item42 := `{"type":"item.updated","payload":{"id":42}}`
+	item7 := `{"type":"item.updated","payload":{"id":7}}`
+	batch := `{"type":"item.batch","payload":{}}`
+	user := `{"type":"user.updated","payload":{"id":42}}`
+	notice := `{"type":"notice","payload":null}`
+	hub.Publish("items:42", []byte(item42))
+	hub.Publish("items:7", []byte(item7))
+	hub.Publish("items", []byte(batch))
+	hub.Publish("users:42", []byte(user))
+	hub.Broadcast([]byte(notice))
+
+	assert.Equal(t, []string{item42, item7, batch, notice}, events(t, all), "collection subscribers get every member")
+	assert.Equal(t, []string{item42, notice}, events(t, one))
+	assert.Equal(t, []string{item42, item7, batch, notice}, events(t, both), "overlapping subscriptions deliver once")
+	assert.Equal(t, []string{notice}, events(t, none))
+}
+
+func TestHub_Subscriptions(t *testing.T) {
+	t.Parallel()
+
+	reader := &Identity{Subject: "1", Permissions: permissions{"items:read"}}
+	tooMany := make([]string, maxSubscriptions+1)
+	for i := range tooMany {
+		tooMany[i] = Topic("items", uint(i))
+	}
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		messages []ClientMessage
+		wantType string
+		want     interface{}
+	}{
+		{
+			name:     "subscribe",
+			messages: []ClientMessage{{Type: SubscribeMessage, Topics: []string{"users", "items:42"}}},
+			wantType: SubscribedMessage,
+			want:     Subscriptions{Topics: []string{"items:42", "users"}},
+		},
+		{
+			name: "unsubscribe",
+			messages: []ClientMessage{
+				{Type: SubscribeMessage, Topics: []string{"users", "items:42"}},
+				{Type: UnsubscribeMessage, Topics: []string{"users", "items"}},
+			},
+			wantType: UnsubscribedMessage,
+			want:     Subscriptions{Topics: []string{"items:42"}},
+		},
+		{
+			name:     "permitted topic",
+			identity: reader,
+			messages: []ClientMessage{{Type: SubscribeMessage, Topics: []string{"items:42"}}},
+			wantType: SubscribedMessage,
+			want:     Subscriptions{Topics: []string{"items:42"}},
+		},
+		{
+			name:     "topic without the permission",
+			identity: &Identity{Subject: "2", Permissions: permissions{"users:read"}},
+			messages: []ClientMessage{{Type: SubscribeMessage, Topics: []string{"users", "items"}}},
+			wantType: ErrorMessage,
+			want:     ErrorPayload{Error: `permission denied for topic "items"`},
+		},
+		{
+			name:     "topic nobody may subscribe to",
+			identity: reader,
+			messages: []ClientMessage{{Type: SubscribeMessage, Topics: []string{"audit"}}},
+			wantType: ErrorMessage,
+			want:     ErrorPayload{Error: `permission denied for topic "audit"`},
+		},
+		{
+			name:     "invalid topic",
+			messages: []ClientMessage{{Type: SubscribeMessage, Topics: []string{"items:"}}},
+			wantType: ErrorMessage,
+			want:     ErrorPayload{Error: `invalid topic "items:"`},
+		},
+		{
+			name:     "no topics",
+			messages: []ClientMessage{{Type: SubscribeMessage}},
+			wantType: ErrorMessage,
+			want:     ErrorPayload{Error: "no topics given"},
+		},
+		{
+			name:     "too many topics",
+			messages: []ClientMessage{{Type: SubscribeMessage, Topics: tooMany}},
+			wantType: ErrorMessage,
+			want:     ErrorPayload{Error: "at most 100 subscriptions allowed"},
+		},
+		{
+			name:     "unknown type",
+			messages: []ClientMessage{{Type: "publish"}},
+			wantType: ErrorMessage,
+			want:     ErrorPayload{Error: `unknown message type "publish"`},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			hub := NewHub()
+			go hub.Run()
+			defer hub.Shutdown()
+			hub.RequirePermission("items", "items:read")
+			hub.RequirePermission("users", "users:read")
+
+			c := &Client{hub: hub, send: make(chan []byte, sendBufferSize), identity: tt.identity}
+			require.NoError(t, hub.Register(c))
+			waitForClientCount(t, hub, 1)
+
+			var reply Message
+			for _, msg := range tt.messages {
+				reply = request(t, hub, c, msg)
+			}
+			assert.Equal(t, tt.wantType, reply.Type)
+			want, err := json.Marshal(tt.want)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(reply.Payload))
+		})
+	}
+}
+
+func TestHub_Resume(t *testing.T) {
+	t.Parallel()
+
+	published := []struct {
+		topic   string
+		message string
+	}{
+		{"items:1", `{"type":"item.created","payload":{"id":1}}`},
+		{"users:1", `{"type":"user.created","payload":{"id":1}}`},
+		{"items:2", `{"type":"item.created","payload":{"id":2}}`},
+		{"", `{"type":"notice","payload":null}`},
+		{"items:1", `{"type":"item.updated","payload":{"id":1}}`},
+	}
+
+	tests := []struct {
+		name string
+		// since picks the client's since from the sequence IDs of the
+		// published events; nil connects afresh.
+		since       func(seqs []uint64) *uint64
+		historySize int
+		sendBuffer  int
+		wantReplay  []string
+		wantResync  bool
+	}{
+		{
+			name:        "replays the missed events of the subscriptions",
+			since:       func(seqs []uint64) *uint64 { return &seqs[0] },
+			historySize: 8,
+			sendBuffer:  sendBufferSize,
+			wantReplay:  []string{published[2].message, published[3].message, published[4].message},
+		},
+		{
+			name:        "missed nothing",
+			since:       func(seqs []uint64) *uint64 { return &seqs[4] },
+			historySize: 8,
+			sendBuffer:  sendBufferSize,
+		},
+		{
+			name:        "fresh connection",
+			since:       func([]uint64) *uint64 { return nil },
+			historySize: 8,
+			sendBuffer:  sendBufferSize,
+		},
+		{
+			name:        "missed events no longer buffered",
+			since:       func(seqs []uint64) *uint64 { return &seqs[0] },
+			historySize: 3,
+			sendBuffer:  sendBufferSize,
+			wantResync:  true,
+		},
+		{
+			name:        "since of another stream",
+			since:       func(seqs []uint64) *uint64 { seq := seqs[4] + 100; return &seq },
+			historySize: 8,
+			sendBuffer:  sendBufferSize,
+			wantResync:  true,
+		},
+		{
+			name:        "replay exceeds the send buffer",
+			since:       func(seqs []uint64) *uint64 { return &seqs[0] },
+			historySize: 8,
+			sendBuffer:  2,
+			wantResync:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			hub := NewHub()
+			hub.SetHistorySize(tt.historySize)
+			go hub.Run()
+			defer hub.Shutdown()
+
+			observer := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+			require.NoError(t, hub.Register(observer))
+			waitForClientCount(t, hub, 1)
+			request(t, hub, observer, ClientMessage{Type: SubscribeMessage, Topics: []string{"items", "users"}})
+			for _, p := range published {
+				if p.topic == "" {
+					hub.Broadcast([]byte(p.message))
+				} else {
+					hub.Publish(p.topic, []byte(p.message))
+				}
+			}
+			var seqs []uint64
+			for _, message := range received(observer) {
+				var msg Message
+				require.NoError(t, json.Unmarshal(message, &msg))
+				seqs = append(seqs, msg.Seq)
+			}
+			require.Len(t, seqs, len(published))
+			for i := 1; i < len(seqs); i++ {
+				assert.Equal(t, seqs[i-1]+1, seqs[i], "sequence IDs increase by one")
+			}
+
+			c := &Client{hub: hub, send: make(chan []byte, tt.sendBuffer), since: tt.since(seqs)}
+			require.NoError(t, hub.Register(c))
+			waitForClientCount(t, hub, 2)
+			reply := request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+			assert.Equal(t, SubscribedMessage, reply.Type)
+
+			if tt.wantResync {
+				msgs := received(c)
+				require.Len(t, msgs, 1)
+				var resync Message
+				require.NoError(t, json.Unmarshal(msgs[0], &resync))
+				assert.Equal(t, ResyncMessage, resync.Type)
+				assert.JSONEq(t, fmt.Sprintf(`{"seq":%d}`, seqs[len(seqs)-1]), string(resync.Payload))
+				return
+			}
+			assert.Equal(t, tt.wantReplay, events(t, c))
+
+			request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"users"}})
+			assert.Empty(t, received(c), "only the first subscription resumes")
+		})
+	}
+}
+
+func TestHub_UnregisterDropsSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	c := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	require.NoError(t, hub.Register(c))
+	waitForClientCount(t, hub, 1)
+	request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+	assert.Equal(t, 1, hub.SubscriberCount("items"))
+
+	hub.Unregister(c)
+	waitForClientCount(t, hub, 0)
+	assert.Equal(t, 0, hub.SubscriberCount("items"))
+}
+
 func TestHub_ImplementsBroadcastSender(t *testing.T) {
 	t.Parallel()
 
@@ -193,7 +543,7 @@ func TestHub_BroadcastChannelFull(t *testing.T) {
 
 	// Fill the broadcast channel to capacity.
 	for i := 0; i < cap(hub.broadcast); i++ {
-		hub.broadcast <- []byte("fill")
+		hub.broadcast <- publication{message: []byte("fill")}
 	}
 
 	// The next Broadcast must not block — it should drop the message.
@@ -209,6 +559,66 @@ func TestHub_BroadcastChannelFull(t *testing.T) {
 	case <-time.After(time.Second):
 		t.Fatal("Broadcast blocked when channel was full")
 	}
+	assert.Equal(t, uint64(1), hub.Stats().Rejected)
+}
+
+func TestHub_PublishWait(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	for i := 0; i < cap(hub.broadcast); i++ {
+		hub.broadcast <- publication{message: []byte("fill")}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := hub.PublishWait(ctx, "items", []byte("waiting"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "a full queue is waited on, not dropped")
+	assert.Zero(t, hub.Stats().Rejected)
+
+	go hub.Run()
+	require.NoError(t, hub.PublishWait(context.Background(), "items", []byte("published")))
+	hub.Shutdown()
+	for i := 0; i < cap(hub.broadcast); i++ {
+		hub.broadcast <- publication{message: []byte("fill")}
+	}
+	err = hub.PublishWait(context.Background(), "items", []byte("closed"))
+	assert.ErrorIs(t, err, ErrHubClosed)
+}
+
+func TestHub_DropsRedeliveredEvents(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	c := &Client{hub: hub, send: make(chan []byte, sendBufferSize)}
+	require.NoError(t, hub.Register(c))
+	waitForClientCount(t, hub, 1)
+	request(t, hub, c, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+
+	first := `{"type":"item.created","event_id":"a","payload":{"id":1}}`
+	second := `{"type":"item.created","event_id":"b","payload":{"id":2}}`
+	anonymous := `{"type":"item.updated","payload":{"id":1}}`
+	ctx := context.Background()
+	require.NoError(t, hub.PublishWait(ctx, "items:1", []byte(first)))
+	require.NoError(t, hub.PublishWait(ctx, "items:2", []byte(second)))
+	require.NoError(t, hub.PublishWait(ctx, "items:1", []byte(first)))
+	hub.Publish("items:1", []byte(anonymous))
+	hub.Publish("items:1", []byte(anonymous))
+
+	var got []Message
+	for _, message := range received(c) {
+		var msg Message
+		require.NoError(t, json.Unmarshal(message, &msg))
+		got = append(got, msg)
+	}
+	require.Len(t, got, 4, "only events with an ID already delivered are dropped")
+	assert.Equal(t, "a", got[0].EventID)
+	assert.Equal(t, "b", got[1].EventID)
+	assert.Empty(t, got[2].EventID)
+	assert.Equal(t, got[1].Seq+1, got[2].Seq, "dropped events take no seq")
 }
 
 func TestNewMessage(t *testing.T) {
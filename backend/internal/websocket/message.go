@@ -3,6 +3,9 @@ package websocket
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
+	"time"
 )
 
 // Message is the envelope for all WebSocket messages sent to clients.
@@ -10,6 +13,23 @@ type Message struct {
 	// Type identifies the event kind, e.g. "item.created", "item.updated".
 	Type string `json:"type"`
 
+	// ID is the id of the call a result or error message answers.
+	ID json.RawMessage `json:"id,omitempty"`
+
+	// Seq is the position of the event in the hub's stream, increasing with
+	// every event the hub delivers. Clients pass the last one they received
+	// as "since" when reconnecting to be sent what they missed. Replies to
+	// client messages have none.
+	Seq uint64 `json:"seq,omitempty"`
+
+	// EventID identifies a change event delivered through an outbox, which
+	// delivers events at least once: clients drop events with an EventID
+	// they have already received. The hub drops those it delivered lately.
+	EventID string `json:"event_id,omitempty"`
+
+	// Time is when the message was created.
+	Time time.Time `json:"time"`
+
 	// Payload carries the event-specific data (typically the affected entity).
 	Payload json.RawMessage `json:"payload"`
 }
@@ -23,6 +43,7 @@ func NewMessage(msgType string, payload interface{}) (Message, error) {
 	}
 	return Message{
 		Type:    msgType,
+		Time:    time.Now().UTC(),
 		Payload: data,
 	}, nil
 }
@@ -35,3 +56,77 @@ func (m Message) Bytes() ([]byte, error) {
 	}
 	return b, nil
 }
+
+// Types of the messages clients send.
+const (
+	// SubscribeMessage subscribes the client to ClientMessage.Topics.
+	SubscribeMessage = "subscribe"
+	// UnsubscribeMessage ends the client's subscriptions to
+	// ClientMessage.Topics.
+	UnsubscribeMessage = "unsubscribe"
+)
+
+// Types of the replies to client messages.
+const (
+	// SubscribedMessage answers a subscribe with Subscriptions.
+	SubscribedMessage = "subscribed"
+	// UnsubscribedMessage answers an unsubscribe with Subscriptions.
+	UnsubscribedMessage = "unsubscribed"
+	// ErrorMessage answers a message that could not be applied with an
+	// ErrorPayload.
+	ErrorMessage = "error"
+	// ResultMessage answers a call with the method's result as payload.
+	ResultMessage = "result"
+	// ResyncMessage tells a client reconnecting with "since" that the events
+	// it missed are no longer buffered, with a ResyncPayload. The client
+	// should reload the state it shows.
+	ResyncMessage = "resync"
+)
+
+// ClientMessage is a message a client sends to the server, such as
+// {"type":"subscribe","topics":["items","users:7"]}, or a call such as
+// {"id":1,"method":"items.update","params":{"id":42,"body":{...}}}. Calls
+// have a Method instead of a Type, and are answered with a result or error
+// message carrying their ID.
+type ClientMessage struct {
+	Type   string   `json:"type,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Subscriptions is the payload of subscribed and unsubscribed replies: the
+// topics the client is subscribed to afterwards.
+type Subscriptions struct {
+	Topics []string `json:"topics"`
+}
+
+// ErrorPayload is the payload of error replies. Status is set for failed
+// calls, to the HTTP status the equivalent REST request would have had.
+type ErrorPayload struct {
+	Error  string `json:"error"`
+	Status int    `json:"status,omitempty"`
+}
+
+// ResyncPayload is the payload of resync messages. Events after Seq follow
+// it.
+type ResyncPayload struct {
+	Seq uint64 `json:"seq"`
+}
+
+// topicPattern matches a collection topic, such as "items", or an entity
+// topic, such as "items:42".
+var topicPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*(:[A-Za-z0-9_-]+)?$`)
+
+// Topic returns the topic of the entity of collection with the given ID.
+// Messages published to it also reach subscribers of the collection.
+func Topic(collection string, id uint) string {
+	return collection + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ValidTopic reports whether topic is a well-formed topic.
+func ValidTopic(topic string) bool {
+	return len(topic) <= 128 && topicPattern.MatchString(topic)
+}
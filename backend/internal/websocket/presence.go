@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// presenceInterval is how often a hub shares the presence on its node with
+// the other nodes through the backplane. A node's presence is forgotten
+// when it has not been shared for presenceExpiry intervals, e.g. because the
+// node stopped.
+const (
+	presenceInterval = 10 * time.Second
+	presenceExpiry   = 3
+)
+
+// Types of the presence events the hub publishes to a resource's topic when
+// a client starts or stops viewing it. They are sent to the other clients
+// viewing the resource, not to those subscribed to its collection.
+const (
+	PresenceJoined = "presence.joined"
+	PresenceLeft   = "presence.left"
+)
+
+// Presence is a client viewing a resource, such as "items:42": subscribed to
+// its entity topic.
+type Presence struct {
+	ClientID string `json:"client_id"`
+	// Subject and Username identify the client's user; both are empty for
+	// unauthenticated clients.
+	Subject  string `json:"subject,omitempty"`
+	Username string `json:"username,omitempty"`
+	Resource string `json:"resource"`
+	// Since is when the client started viewing the resource, and LastActive
+	// when it last sent a message.
+	Since      time.Time `json:"since"`
+	LastActive time.Time `json:"last_active"`
+}
+
+// isResource reports whether topic is the topic of a single resource, such
+// as "items:42", rather than of a collection.
+func isResource(topic string) bool {
+	return strings.Contains(topic, ":")
+}
+
+// Presence returns who is viewing resource, or any resource of the
+// collection if resource is a collection topic such as "items", oldest
+// first. With a backplane, the viewers connected to other nodes are
+// included, as last shared by their node. identity is who asks, or nil; it
+// needs the permission to subscribe to resource, and errors are as for
+// OpenStream.
+func (h *Hub) Presence(identity *Identity, resource string) ([]Presence, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if err := h.checkSubscription(&Client{identity: identity}, []string{resource}); err != nil {
+		return nil, err
+	}
+
+	matches := func(topic string) bool {
+		return topic == resource || strings.HasPrefix(topic, resource+":")
+	}
+	presences := h.remote.list(matches, time.Now().Add(-presenceExpiry*h.presenceInterval))
+	for client := range h.clients {
+		for topic := range client.viewing {
+			if matches(topic) {
+				presences = append(presences, client.presenceOn(topic))
+			}
+		}
+	}
+	sort.Slice(presences, func(i, j int) bool {
+		if !presences[i].Since.Equal(presences[j].Since) {
+			return presences[i].Since.Before(presences[j].Since)
+		}
+		if presences[i].ClientID != presences[j].ClientID {
+			return presences[i].ClientID < presences[j].ClientID
+		}
+		return presences[i].Resource < presences[j].Resource
+	})
+	return presences, nil
+}
+
+// presenceOn returns the client's presence on resource, one of the topics
+// it views. Only the hub's Run loop, or callers holding its mu, may call it.
+func (c *Client) presenceOn(resource string) Presence {
+	p := Presence{
+		ClientID:   c.id,
+		Resource:   resource,
+		Since:      c.viewing[resource],
+		LastActive: time.Unix(0, c.lastActive.Load()).UTC(),
+	}
+	if c.identity != nil {
+		p.Subject, p.Username = c.identity.Subject, c.identity.Username
+	}
+	return p
+}
+
+// announce publishes a presence event of msgType for client on each of
+// resources it views. Only the Run loop may call it.
+func (h *Hub) announce(client *Client, msgType string, resources []string) {
+	for _, resource := range resources {
+		msg, err := NewMessage(msgType, client.presenceOn(resource))
+		if err != nil {
+			slog.Error("Failed to create presence event", "type", msgType, "error", err)
+			continue
+		}
+		b, err := msg.Bytes()
+		if err != nil {
+			slog.Error("Failed to serialise presence event", "type", msgType, "error", err)
+			continue
+		}
+		h.Publish(resource, b)
+	}
+}
+
+// viewersOf returns the clients viewing resource, except the one with ID
+// viewer. The caller must hold h.mu.
+func (h *Hub) viewersOf(resource, viewer string) []*Client {
+	var recipients []*Client
+	for client := range h.subscribers[resource] {
+		if client.id != viewer {
+			recipients = append(recipients, client)
+		}
+	}
+	return recipients
+}
+
+// viewerOf returns the ID of the client msg, a presence event, is about, or
+// "" if it is malformed, to be delivered like other events.
+func viewerOf(msg Message) string {
+	var p Presence
+	_ = json.Unmarshal(msg.Payload, &p)
+	return p.ClientID
+}
+
+// viewed returns the resources client views. Only the Run loop may call it.
+func (c *Client) viewed() []string {
+	resources := make([]string, 0, len(c.viewing))
+	for resource := range c.viewing {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// sharePresence sends a snapshot of the presence on this node to the other
+// nodes every presenceInterval until ctx is done. Between snapshots, they
+// follow the presence events relayed to them.
+func (h *Hub) sharePresence(ctx context.Context) {
+	ticker := time.NewTicker(h.presenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// The snapshot is queued while holding mu, so that the presence
+		// events of later changes are relayed after it.
+		h.mu.RLock()
+		snapshot := &PresenceSnapshot{Viewers: []Presence{}}
+		for client := range h.clients {
+			for _, resource := range client.viewed() {
+				snapshot.Viewers = append(snapshot.Viewers, client.presenceOn(resource))
+			}
+		}
+		select {
+		case h.outbound <- Envelope{Origin: h.node, Presence: snapshot}:
+		default:
+			slog.Warn("WebSocket backplane channel full, presence not shared")
+		}
+		h.mu.RUnlock()
+	}
+}
+
+// remotePresence is the presence on other nodes, kept from their snapshots
+// and presence events. It is safe for concurrent use.
+type remotePresence struct {
+	mu    sync.Mutex
+	nodes map[string]*nodePresence
+}
+
+// nodePresence is the presence on a node, by client ID and resource, and
+// when the node last shared it.
+type nodePresence struct {
+	viewers map[[2]string]Presence
+	seen    time.Time
+}
+
+func newRemotePresence() *remotePresence {
+	return &remotePresence{nodes: make(map[string]*nodePresence)}
+}
+
+// replace sets the presence on node to viewers, shared at now.
+func (r *remotePresence) replace(node string, viewers []Presence, now time.Time) {
+	np := &nodePresence{viewers: make(map[[2]string]Presence, len(viewers)), seen: now}
+	for _, p := range viewers {
+		np.viewers[[2]string{p.ClientID, p.Resource}] = p
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[node] = np
+}
+
+// apply updates the presence on node with message, a message it published
+// at now, if it is a presence event.
+func (r *remotePresence) apply(node string, message []byte, now time.Time) {
+	if !bytes.Contains(message, []byte(`"presence.`)) {
+		return
+	}
+	var msg Message
+	if err := json.Unmarshal(message, &msg); err != nil || (msg.Type != PresenceJoined && msg.Type != PresenceLeft) {
+		return
+	}
+	var p Presence
+	if err := json.Unmarshal(msg.Payload, &p); err != nil || p.ClientID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	np, ok := r.nodes[node]
+	if !ok {
+		np = &nodePresence{viewers: make(map[[2]string]Presence)}
+		r.nodes[node] = np
+	}
+	np.seen = now
+	key := [2]string{p.ClientID, p.Resource}
+	if msg.Type == PresenceJoined {
+		np.viewers[key] = p
+	} else {
+		delete(np.viewers, key)
+	}
+}
+
+// list returns the presences on the resources matching, of the nodes seen
+// since expired; the others are forgotten.
+func (r *remotePresence) list(matches func(resource string) bool, expired time.Time) []Presence {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	presences := []Presence{}
+	for node, np := range r.nodes {
+		if np.seen.Before(expired) {
+			delete(r.nodes, node)
+			continue
+		}
+		for _, p := range np.viewers {
+			if matches(p.Resource) {
+				presences = append(presences, p)
+			}
+		}
+	}
+	return presences
+}
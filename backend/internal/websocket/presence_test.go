@@ -0,0 +1,180 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// presenceEvent returns the presence event msg, of type msgType.
+func presenceEvent(t *testing.T, msg Message, msgType string) Presence {
+	t.Helper()
+	require.Equal(t, msgType, msg.Type)
+	var p Presence
+	require.NoError(t, json.Unmarshal(msg.Payload, &p))
+	return p
+}
+
+// viewers returns the usernames of presences, with their resources.
+func viewers(presences []Presence) []string {
+	got := []string{}
+	for _, p := range presences {
+		got = append(got, p.Username+"@"+p.Resource)
+	}
+	return got
+}
+
+func TestHub_Presence(t *testing.T) {
+	t.Parallel()
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.RequirePermission("items", "items:read")
+
+	reader := &Identity{Subject: "9", Permissions: permissions{"items:read"}}
+	alice := newClient(hub, nil, ClientOptions{Identity: &Identity{Subject: "1", Username: "alice", Permissions: permissions{"items:read"}}})
+	bob := newClient(hub, nil, ClientOptions{Identity: &Identity{Subject: "2", Username: "bob", Permissions: permissions{"items:read"}}})
+	lister := newClient(hub, nil, ClientOptions{})
+	for _, c := range []*Client{alice, bob, lister} {
+		require.NoError(t, hub.Register(c))
+	}
+	waitForClientCount(t, hub, 3)
+	request(t, hub, lister, ClientMessage{Type: SubscribeMessage, Topics: []string{"items"}})
+
+	request(t, hub, alice, ClientMessage{Type: SubscribeMessage, Topics: []string{"items:42"}})
+	request(t, hub, bob, ClientMessage{Type: SubscribeMessage, Topics: []string{"items:42", "items:7"}})
+	joined := presenceEvent(t, next(t, alice), PresenceJoined)
+	assert.Equal(t, bob.id, joined.ClientID)
+	assert.Equal(t, "2", joined.Subject)
+	assert.Equal(t, "bob", joined.Username)
+	assert.Equal(t, "items:42", joined.Resource)
+	assert.WithinDuration(t, time.Now(), joined.Since, time.Second)
+	assert.WithinDuration(t, time.Now(), joined.LastActive, time.Second)
+
+	presences, err := hub.Presence(reader, "items:42")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice@items:42", "bob@items:42"}, viewers(presences))
+	presences, err = hub.Presence(reader, "items")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"alice@items:42", "bob@items:42", "bob@items:7"}, viewers(presences), "a collection lists its resources' viewers")
+
+	request(t, hub, alice, ClientMessage{Type: UnsubscribeMessage, Topics: []string{"items:42"}})
+	left := presenceEvent(t, next(t, bob), PresenceLeft)
+	assert.Equal(t, alice.id, left.ClientID)
+	presences, err = hub.Presence(reader, "items:42")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob@items:42"}, viewers(presences))
+
+	request(t, hub, alice, ClientMessage{Type: SubscribeMessage, Topics: []string{"items:7"}})
+	presenceEvent(t, next(t, bob), PresenceJoined)
+	hub.Unregister(bob)
+	left = presenceEvent(t, next(t, alice), PresenceLeft)
+	assert.Equal(t, "bob", left.Username)
+	assert.Equal(t, "items:7", left.Resource)
+	presences, err = hub.Presence(reader, "items")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice@items:7"}, viewers(presences))
+
+	assert.Empty(t, received(alice), "clients are not told of their own presence")
+	assert.Empty(t, received(lister), "collection subscribers are not told of presence")
+}
+
+func TestHub_PresenceErrors(t *testing.T) {
+	t.Parallel()
+	hub := NewHub()
+	hub.RequirePermission("items", "items:read")
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		resource string
+		wantErr  string
+		denied   bool
+	}{
+		{
+			name:     "permission denied",
+			identity: &Identity{Subject: "1", Permissions: permissions{}},
+			resource: "items:42",
+			wantErr:  `permission denied for topic "items:42"`,
+			denied:   true,
+		},
+		{
+			name:     "invalid resource",
+			resource: "items:",
+			wantErr:  `invalid topic "items:"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hub.Presence(tt.identity, tt.resource)
+			require.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, tt.denied, errors.Is(err, ErrPermissionDenied))
+		})
+	}
+}
+
+func TestHub_PresenceAcrossNodes(t *testing.T) {
+	t.Parallel()
+
+	backplane := NewMemoryBackplane()
+	hubs := make([]*Hub, 3)
+	for i := range hubs {
+		hub := NewHub()
+		hub.SetBackplane(backplane)
+		hub.presenceInterval = 20 * time.Millisecond
+		hubs[i] = hub
+	}
+	for _, hub := range hubs[:2] {
+		go hub.Run()
+		defer hub.Shutdown()
+	}
+	assert.Eventually(t, func() bool {
+		return backplane.subscriberCount() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	listViewers := func(hub *Hub, resource string) []string {
+		presences, err := hub.Presence(nil, resource)
+		require.NoError(t, err)
+		return viewers(presences)
+	}
+	alice := newClient(hubs[0], nil, ClientOptions{Identity: &Identity{Subject: "1", Username: "alice"}})
+	require.NoError(t, hubs[0].Register(alice))
+	waitForClientCount(t, hubs[0], 1)
+	request(t, hubs[0], alice, ClientMessage{Type: SubscribeMessage, Topics: []string{"items:42"}})
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"alice@items:42"}, listViewers(hubs[1], "items"))
+	}, time.Second, 5*time.Millisecond, "other nodes list the viewers of a node")
+
+	// A node started later learns of the viewers from the next snapshot.
+	go hubs[2].Run()
+	defer hubs[2].Shutdown()
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"alice@items:42"}, listViewers(hubs[2], "items:42"))
+	}, time.Second, 5*time.Millisecond)
+
+	request(t, hubs[0], alice, ClientMessage{Type: UnsubscribeMessage, Topics: []string{"items:42"}})
+	for _, hub := range hubs[1:] {
+		assert.Eventually(t, func() bool {
+			return len(listViewers(hub, "items")) == 0
+		}, time.Second, 5*time.Millisecond, "viewers leaving are removed")
+	}
+
+	bob := newClient(hubs[1], nil, ClientOptions{Identity: &Identity{Subject: "2", Username: "bob"}})
+	require.NoError(t, hubs[1].Register(bob))
+	waitForClientCount(t, hubs[1], 1)
+	request(t, hubs[1], bob, ClientMessage{Type: SubscribeMessage, Topics: []string{"items:7"}})
+	assert.Eventually(t, func() bool {
+		return len(listViewers(hubs[2], "items")) == 1
+	}, time.Second, 5*time.Millisecond)
+	hubs[1].Shutdown()
+	assert.Eventually(t, func() bool {
+		return len(listViewers(hubs[2], "items")) == 0
+	}, time.Second, 5*time.Millisecond, "the viewers of a stopped node expire")
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackplane is a Backplane over a Redis pub/sub channel, shared by every
+// node connected to the same Redis server.
+type RedisBackplane struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewRedisBackplane creates a RedisBackplane publishing to channel through
+// client. Closing the backplane closes client.
+func NewRedisBackplane(client redis.UniversalClient, channel string) *RedisBackplane {
+	return &RedisBackplane{client: client, channel: channel}
+}
+
+// Publish implements Backplane.
+func (b *RedisBackplane) Publish(ctx context.Context, env Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("encode backplane envelope: %w", err)
+	}
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("publish to redis channel %s: %w", b.channel, err)
+	}
+	return nil
+}
+
+// Subscribe implements Backplane. It returns once Redis has confirmed the
+// subscription; the client resubscribes by itself after a lost connection,
+// although messages published meanwhile are lost.
+func (b *RedisBackplane) Subscribe(ctx context.Context) (<-chan Envelope, error) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("subscribe to redis channel %s: %w", b.channel, err)
+	}
+
+	out := make(chan Envelope, broadcastBufferSize)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var env Envelope
+				if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+					slog.Warn("Ignoring malformed backplane message", "channel", b.channel, "error", err)
+					continue
+				}
+				select {
+				case out <- env:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Ping implements Backplane by pinging the Redis server.
+func (b *RedisBackplane) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Close implements Backplane.
+func (b *RedisBackplane) Close() error {
+	return b.client.Close()
+}
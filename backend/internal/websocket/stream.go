@@ -0,0 +1,42 @@
+package websocket
+
+// Stream is a hub client that is not a WebSocket connection, such as a
+// Server-Sent Events response. It is sent the same messages as WebSocket
+// clients subscribed to the same topics.
+type Stream struct {
+	client *Client
+}
+
+// OpenStream registers a Stream subscribed to topics with hub. opts are as
+// for NewClient, except that the events missed since opts.Since are sent
+// right away. It returns an error wrapping ErrPermissionDenied for topics
+// opts.Identity may not subscribe to, ErrHubClosed if the hub has been shut
+// down, or another error describing why topics are invalid.
+func OpenStream(hub *Hub, topics []string, opts ClientOptions) (*Stream, error) {
+	client := newClient(hub, nil, opts)
+
+	hub.mu.RLock()
+	err := hub.checkSubscription(client, topics)
+	hub.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	client.initialTopics = topics
+	if err := hub.Register(client); err != nil {
+		return nil, err
+	}
+	return &Stream{client: client}, nil
+}
+
+// Messages returns the messages for the stream, each a serialised Message.
+// It is closed when the hub drops the stream, because it was shut down or
+// the stream did not keep up with the Disconnect policy.
+func (s *Stream) Messages() <-chan []byte {
+	return s.client.send
+}
+
+// Close unregisters the stream from its hub.
+func (s *Stream) Close() {
+	s.client.hub.Unregister(s.client)
+}
@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenStream(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.RequirePermission("items", "items:read")
+	hub.RequirePermission("users", "users:read")
+	reader := &Identity{Subject: "1", Permissions: permissions{"items:read"}}
+
+	_, err := OpenStream(hub, []string{"items", "users"}, ClientOptions{Identity: reader})
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	_, err = OpenStream(hub, nil, ClientOptions{Identity: reader})
+	assert.EqualError(t, err, "no topics given")
+
+	stream, err := OpenStream(hub, []string{"items"}, ClientOptions{Identity: reader})
+	require.NoError(t, err)
+	waitForClientCount(t, hub, 1)
+	assert.Equal(t, 1, hub.SubscriberCount("items"), "subscribed on registration")
+
+	hub.Publish("users:1", []byte(`{"type":"user.created","payload":{"id":1}}`))
+	hub.Publish("items:1", []byte(`{"type":"item.created","payload":{"id":1}}`))
+	assert.Equal(t, []string{`{"type":"item.created","payload":{"id":1}}`}, events(t, stream.client), "no subscribed reply precedes the events")
+
+	stream.Close()
+	waitForClientCount(t, hub, 0)
+	_, open := <-stream.Messages()
+	assert.False(t, open)
+
+	hub.Shutdown()
+	_, err = OpenStream(hub, []string{"items"}, ClientOptions{})
+	assert.ErrorIs(t, err, ErrHubClosed)
+}
@@ -50,7 +50,8 @@ func HandleGormError(op string, err error) error {
 	}
 
 	// Check for duplicate key violations
-	if strings.Contains(err.Error(), "Duplicate entry") {
+	if strings.Contains(err.Error(), "Duplicate entry") ||
+		strings.Contains(err.Error(), "UNIQUE constraint failed") {
 		return NewDatabaseError(op, ErrDuplicateKey)
 	}
 
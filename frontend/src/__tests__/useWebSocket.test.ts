@@ -3,6 +3,8 @@ import { renderHook, act } from '@testing-library/react';
 
 interface MockWSInstance extends EventTarget {
   url: string;
+  /** The URL provider, evaluated again on every reconnect. */
+  urlProvider: () => string;
   sent: string[];
   open: () => void;
   close: () => void;
@@ -16,11 +18,13 @@ const { MockRWS, getInstance } = vi.hoisted(() => {
 
   class MockRWS extends EventTarget implements MockWSInstance {
     url: string;
+    urlProvider: () => string;
     sent: string[] = [];
-    constructor(url: string) {
+    constructor(url: string | (() => string)) {
       super();
       _instance = this;
-      this.url = url;
+      this.urlProvider = typeof url === 'function' ? url : () => url;
+      this.url = this.urlProvider();
     }
     send(data: string) { this.sent.push(data); }
     close() { this.dispatchEvent(new CloseEvent('close')); }
@@ -121,6 +125,51 @@ describe('useWebSocket', () => {
     expect(onMessage1).not.toHaveBeenCalled();
   });
 
+  it('subscribes to topics whenever the socket opens', () => {
+    renderHook(() => useWebSocket({ topics: ['items', 'users:1'] }));
+    const subscribe = '{"type":"subscribe","topics":["items","users:1"]}';
+    act(() => getInstance().open());
+    expect(getInstance().sent).toEqual([subscribe]);
+
+    act(() => getInstance().close());
+    act(() => getInstance().open());
+    expect(getInstance().sent).toEqual([subscribe, subscribe]);
+  });
+
+  it('resumes from the last seq received when reconnecting', () => {
+    renderHook(() => useWebSocket());
+    const url = getInstance().urlProvider;
+    expect(url()).not.toContain('since=');
+
+    act(() => getInstance().receive('{"type":"item.created","seq":41,"payload":{}}'));
+    act(() => getInstance().receive('{"type":"subscribed","payload":{"topics":["items"]}}'));
+    expect(url()).toContain('?since=41');
+
+    act(() => getInstance().receive('{"type":"resync","payload":{"seq":90}}'));
+    expect(url()).toContain('?since=90');
+  });
+
+  it('resolves calls with their result and rejects failed ones', async () => {
+    const { result } = renderHook(() => useWebSocket());
+    act(() => getInstance().open());
+
+    const created = result.current.call('items.create', { body: { name: 'Pen', price: 1 } });
+    const deleted = result.current.call('items.delete', { id: 99 });
+    expect(getInstance().sent).toEqual([
+      '{"id":1,"method":"items.create","params":{"body":{"name":"Pen","price":1}}}',
+      '{"id":2,"method":"items.delete","params":{"id":99}}',
+    ]);
+
+    act(() => getInstance().receive('{"type":"error","id":2,"payload":{"error":"Item not found","status":404}}'));
+    act(() => getInstance().receive('{"type":"result","id":1,"payload":{"id":1,"name":"Pen"}}'));
+    await expect(created).resolves.toEqual({ id: 1, name: 'Pen' });
+    await expect(deleted).rejects.toMatchObject({ message: 'Item not found', status: 404 });
+
+    const pending = result.current.call('items.restore', { id: 1 });
+    act(() => getInstance().close());
+    await expect(pending).rejects.toMatchObject({ message: 'Connection closed' });
+  });
+
   it('returns connectionStatus to open after a reconnect', () => {
     const { result } = renderHook(() => useWebSocket());
     act(() => getInstance().open());
@@ -19,14 +19,47 @@ export interface Item {
   description?: string;
   created_at: string;
   updated_at: string;
+  deleted_at?: string | null;
+}
+
+export interface CursorPage<T> {
+  data: T[];
+  next_cursor?: string;
+}
+
+export interface Envelope<T> {
+  data: T[];
+  total: number;
+  limit: number;
+  offset: number;
+  next?: string;
+  prev?: string;
+}
+
+export type BatchOperation =
+  | { op: 'create'; item: Omit<Item, 'id' | 'created_at' | 'updated_at'> }
+  | { op: 'update'; id: number; item: Partial<Omit<Item, 'id' | 'created_at' | 'updated_at'>> & { version?: number } }
+  | { op: 'delete'; id: number };
+
+export interface BatchResult {
+  index: number;
+  op: BatchOperation['op'];
+  status: number;
+  id?: number;
+  item?: Item;
+  error?: string;
 }
 
 export const itemService = {
-  list: async (limit?: number, offset?: number): Promise<Item[]> => {
+  // sort is a comma-separated field list, "-" prefix for descending (e.g. "-price,name").
+  // filter is a filter expression, e.g. 'price gt 10 and name contains "foo"'.
+  list: async (limit?: number, offset?: number, sort?: string, filter?: string): Promise<Item[]> => {
     try {
       const params = new URLSearchParams();
       if (limit !== undefined) params.set('limit', String(limit));
       if (offset !== undefined) params.set('offset', String(offset));
+      if (sort) params.set('sort', sort);
+      if (filter) params.set('filter', filter);
       const query = params.toString() ? `?${params.toString()}` : '';
       const response = await api.get<Item[]>(`/api/v1/items${query}`);
       return response.data;
@@ -36,6 +69,31 @@ export const itemService = {
     }
   },
 
+  listPage: async (cursor = '', limit?: number): Promise<CursorPage<Item>> => {
+    try {
+      const params = new URLSearchParams({ cursor });
+      if (limit !== undefined) params.set('limit', String(limit));
+      const response = await api.get<CursorPage<Item>>(`/api/v1/items?${params.toString()}`);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch items page:', error);
+      throw error;
+    }
+  },
+
+  listEnvelope: async (limit?: number, offset?: number): Promise<Envelope<Item>> => {
+    try {
+      const params = new URLSearchParams({ envelope: 'true' });
+      if (limit !== undefined) params.set('limit', String(limit));
+      if (offset !== undefined) params.set('offset', String(offset));
+      const response = await api.get<Envelope<Item>>(`/api/v1/items?${params.toString()}`);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch items page:', error);
+      throw error;
+    }
+  },
+
   get: async (id: number): Promise<Item> => {
     try {
       const response = await api.get<Item>(`/api/v1/items/${id}`);
@@ -46,9 +104,11 @@ export const itemService = {
     }
   },
 
-  create: async (item: Omit<Item, 'id' | 'created_at' | 'updated_at'>): Promise<Item> => {
+  create: async (item: Omit<Item, 'id' | 'created_at' | 'updated_at'>, idempotencyKey?: string): Promise<Item> => {
     try {
-      const response = await api.post<Item>('/api/v1/items', item);
+      const response = await api.post<Item>('/api/v1/items', item, {
+        headers: idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : undefined,
+      });
       return response.data;
     } catch (error) {
       console.error('Failed to create item:', error);
@@ -66,6 +126,20 @@ export const itemService = {
     }
   },
 
+  // Sends a JSON Merge Patch: only the given fields change. Include version to
+  // have the patch rejected if the item was modified since it was read.
+  patch: async (id: number, patch: Partial<Pick<Item, 'name' | 'price'>> & { version?: number }): Promise<Item> => {
+    try {
+      const response = await api.patch<Item>(`/api/v1/items/${id}`, patch, {
+        headers: { 'Content-Type': 'application/merge-patch+json' },
+      });
+      return response.data;
+    } catch (error) {
+      console.error('Failed to patch item:', error);
+      throw error;
+    }
+  },
+
   delete: async (id: number): Promise<void> => {
     try {
       await api.delete(`/api/v1/items/${id}`);
@@ -74,6 +148,280 @@ export const itemService = {
       throw error;
     }
   },
+
+  restore: async (id: number): Promise<Item> => {
+    try {
+      const response = await api.post<Item>(`/api/v1/items/${id}/restore`);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to restore item:', error);
+      throw error;
+    }
+  },
+
+  // All operations are applied or none are; when the request fails, the
+  // per-operation results are in the error response body.
+  batch: async (operations: BatchOperation[]): Promise<BatchResult[]> => {
+    try {
+      const response = await api.post<{ results: BatchResult[] }>('/api/v1/items:batch', { operations });
+      return response.data.results;
+    } catch (error) {
+      console.error('Failed to apply item batch:', error);
+      throw error;
+    }
+  },
+};
+
+export interface User {
+  id: number;
+  username: string;
+  email: string;
+  name?: string;
+  roles?: string[] | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export type UserInput = Pick<User, 'username' | 'email' | 'name'> & { password?: string };
+
+export const userService = {
+  list: async (limit?: number, offset?: number): Promise<User[]> => {
+    try {
+      const params = new URLSearchParams();
+      if (limit !== undefined) params.set('limit', String(limit));
+      if (offset !== undefined) params.set('offset', String(offset));
+      const query = params.toString() ? `?${params.toString()}` : '';
+      const response = await api.get<User[]>(`/api/v1/users${query}`);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch users:', error);
+      throw error;
+    }
+  },
+
+  get: async (id: number): Promise<User> => {
+    try {
+      const response = await api.get<User>(`/api/v1/users/${id}`);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch user:', error);
+      throw error;
+    }
+  },
+
+  create: async (user: UserInput): Promise<User> => {
+    try {
+      const response = await api.post<User>('/api/v1/users', user);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to create user:', error);
+      throw error;
+    }
+  },
+
+  update: async (id: number, user: UserInput): Promise<User> => {
+    try {
+      const response = await api.put<User>(`/api/v1/users/${id}`, user);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to update user:', error);
+      throw error;
+    }
+  },
+
+  delete: async (id: number): Promise<void> => {
+    try {
+      await api.delete(`/api/v1/users/${id}`);
+    } catch (error) {
+      console.error('Failed to delete user:', error);
+      throw error;
+    }
+  },
+};
+
+export interface TokenPair {
+  access_token: string;
+  refresh_token: string;
+  token_type: string;
+  expires_in: number;
+}
+
+export const authService = {
+  login: async (username: string, password: string): Promise<TokenPair> => {
+    try {
+      const response = await api.post<TokenPair>('/api/v1/auth/login', { username, password });
+      authService.setAccessToken(response.data.access_token);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to log in:', error);
+      throw error;
+    }
+  },
+
+  refresh: async (refreshToken: string): Promise<TokenPair> => {
+    try {
+      const response = await api.post<TokenPair>('/api/v1/auth/refresh', { refresh_token: refreshToken });
+      authService.setAccessToken(response.data.access_token);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to refresh tokens:', error);
+      throw error;
+    }
+  },
+
+  setAccessToken: (token?: string) => {
+    if (token) {
+      api.defaults.headers.common.Authorization = `Bearer ${token}`;
+    } else {
+      delete api.defaults.headers.common.Authorization;
+    }
+  },
+};
+
+export interface Role {
+  name: string;
+  permissions: string[];
+}
+
+export interface APIKey {
+  id: number;
+  name: string;
+  prefix: string;
+  user_id: number;
+  scopes: string[];
+  rate_limit: number;
+  expires_at: string | null;
+  last_used_at: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface APIKeyInput {
+  name: string;
+  scopes: string[];
+  rate_limit?: number;
+  expires_at?: string;
+}
+
+// CreatedAPIKey carries the key itself, which is only returned on creation.
+export interface CreatedAPIKey extends APIKey {
+  key: string;
+}
+
+export type Backpressure = 'disconnect' | 'drop-oldest' | 'coalesce';
+
+export interface WebSocketClient {
+  id: string;
+  transport: 'websocket' | 'sse';
+  remote_addr: string;
+  username?: string;
+  topics: string[];
+  backpressure: Backpressure;
+  encoding: 'json' | 'msgpack';
+  queue_depth: number;
+  connected_at: string;
+  uptime_seconds: number;
+  dropped: number;
+  coalesced: number;
+}
+
+export interface WebSocketStats {
+  rejected: number;
+  dropped: number;
+  coalesced: number;
+  disconnected: number;
+}
+
+export interface WebSocketClients {
+  clients: WebSocketClient[];
+  stats: WebSocketStats;
+}
+
+export const adminService = {
+  listRoles: async (): Promise<Role[]> => {
+    try {
+      const response = await api.get<Role[]>('/api/v1/admin/roles');
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch roles:', error);
+      throw error;
+    }
+  },
+
+  setUserRoles: async (id: number, roles: string[]): Promise<User> => {
+    try {
+      const response = await api.put<User>(`/api/v1/admin/users/${id}/roles`, { roles });
+      return response.data;
+    } catch (error) {
+      console.error('Failed to assign roles:', error);
+      throw error;
+    }
+  },
+
+  listAPIKeys: async (): Promise<APIKey[]> => {
+    try {
+      const response = await api.get<APIKey[]>('/api/v1/admin/api-keys');
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch API keys:', error);
+      throw error;
+    }
+  },
+
+  createAPIKey: async (input: APIKeyInput): Promise<CreatedAPIKey> => {
+    try {
+      const response = await api.post<CreatedAPIKey>('/api/v1/admin/api-keys', input);
+      return response.data;
+    } catch (error) {
+      console.error('Failed to create API key:', error);
+      throw error;
+    }
+  },
+
+  revokeAPIKey: async (id: number): Promise<void> => {
+    try {
+      await api.delete(`/api/v1/admin/api-keys/${id}`);
+    } catch (error) {
+      console.error('Failed to revoke API key:', error);
+      throw error;
+    }
+  },
+
+  listWebSocketClients: async (): Promise<WebSocketClients> => {
+    try {
+      const response = await api.get<WebSocketClients>('/api/v1/admin/ws/clients');
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch WebSocket clients:', error);
+      throw error;
+    }
+  },
+};
+
+// Presence is a client viewing a resource, that is subscribed to its topic,
+// as listed by presenceService and announced in presence.joined and
+// presence.left messages.
+export interface Presence {
+  client_id: string;
+  subject?: string;
+  username?: string;
+  resource: string;
+  since: string;
+  last_active: string;
+}
+
+export const presenceService = {
+  // get lists who is viewing resource, such as "items:42", or any resource
+  // of a collection, such as "items".
+  get: async (resource: string): Promise<Presence[]> => {
+    try {
+      const response = await api.get<Presence[]>('/api/v1/presence', { params: { resource } });
+      return response.data;
+    } catch (error) {
+      console.error('Failed to fetch presence:', error);
+      throw error;
+    }
+  },
 };
 
 export const healthService = {
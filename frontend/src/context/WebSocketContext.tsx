@@ -6,18 +6,27 @@ interface WebSocketContextValue {
   lastMessage: WebSocketMessage | null;
   connectionStatus: ConnectionStatus;
   subscribe: (type: string, handler: (msg: WebSocketMessage) => void) => () => void;
+  call: <T = unknown>(method: string, params?: unknown) => Promise<T>;
 }
 
 const WebSocketContext = createContext<WebSocketContextValue | null>(null);
 
-export function WebSocketProvider({ children }: { children: React.ReactNode }) {
+const DEFAULT_TOPICS = ['items'];
+
+export function WebSocketProvider({
+  children,
+  topics = DEFAULT_TOPICS,
+}: {
+  children: React.ReactNode;
+  topics?: string[];
+}) {
   const handlersRef = useRef<Map<string, Set<(msg: WebSocketMessage) => void>>>(new Map());
 
   const handleMessage = useCallback((msg: WebSocketMessage) => {
     handlersRef.current.get(msg.type)?.forEach((h) => h(msg));
   }, []);
 
-  const { lastMessage, connectionStatus } = useWebSocket({ onMessage: handleMessage });
+  const { lastMessage, connectionStatus, call } = useWebSocket({ onMessage: handleMessage, topics });
 
   const subscribe = useCallback(
     (type: string, handler: (msg: WebSocketMessage) => void) => {
@@ -32,7 +41,7 @@ export function WebSocketProvider({ children }: { children: React.ReactNode }) {
   );
 
   return (
-    <WebSocketContext.Provider value={{ lastMessage, connectionStatus, subscribe }}>
+    <WebSocketContext.Provider value={{ lastMessage, connectionStatus, subscribe, call }}>
       {children}
     </WebSocketContext.Provider>
   );
@@ -7,28 +7,70 @@ export type ConnectionStatus = 'connecting' | 'open' | 'closing' | 'closed';
 /** Shape of every message the backend sends over the WebSocket. */
 export interface WebSocketMessage {
   type: string;
+  /** Id of the call a 'result' or 'error' message answers. */
+  id?: number | string;
+  /** Position in the event stream; absent on replies to client messages. */
+  seq?: number;
+  /** Id of a change event; an event delivered again carries the same id. */
+  event_id?: string;
+  time?: string;
   payload: unknown;
 }
 
+/** How many event ids are remembered to drop events delivered again. */
+const EVENT_ID_WINDOW = 1000;
+
 export interface UseWebSocketOptions {
   /** Called whenever a message arrives, after JSON parsing. */
   onMessage?: (message: WebSocketMessage) => void;
   /** URL path, e.g. '/ws'. Defaults to '/ws'. */
   path?: string;
+  /** Access token, sent as the subprotocol after 'bearer' when auth is enabled. */
+  accessToken?: string;
+  /** Topics to subscribe to, e.g. 'items' or 'items:42'; renewed on every (re)connect. */
+  topics?: string[];
+}
+
+/** Failure of a call, with the HTTP status the REST request would have had. */
+export class CallError extends Error {
+  constructor(message: string, readonly status: number) {
+    super(message);
+    this.name = 'CallError';
+  }
 }
 
 export interface UseWebSocketResult {
   lastMessage: WebSocketMessage | null;
   connectionStatus: ConnectionStatus;
   sendMessage: (data: unknown) => void;
+  /**
+   * Calls a method over the socket, e.g. call('items.update', { id: 42, body: item }),
+   * resolving with its result. Calls pending when the socket closes are rejected.
+   */
+  call: <T = unknown>(method: string, params?: unknown) => Promise<T>;
+}
+
+interface PendingCall {
+  resolve: (result: unknown) => void;
+  reject: (error: Error) => void;
 }
 
 export function useWebSocket(options: UseWebSocketOptions = {}): UseWebSocketResult {
-  const { onMessage, path = '/ws' } = options;
+  const { onMessage, path = '/ws', accessToken, topics } = options;
+  // Joined so that a new array with the same topics does not reconnect.
+  const topicKey = topics?.join(',') ?? '';
   const wsRef = useRef<ReconnectingWebSocket | null>(null);
   const [lastMessage, setLastMessage] = useState<WebSocketMessage | null>(null);
   const [connectionStatus, setConnectionStatus] = useState<ConnectionStatus>('connecting');
   const onMessageRef = useRef(onMessage);
+  // Seq of the last event received, sent as ?since= on reconnect so the
+  // server replays what was missed (or sends a 'resync' message).
+  const lastSeqRef = useRef<number | null>(null);
+  // Ids of the latest change events, oldest first; events are delivered at
+  // least once, so the same event may arrive twice.
+  const eventIdsRef = useRef<Set<string>>(new Set());
+  const nextCallIdRef = useRef(1);
+  const pendingCallsRef = useRef<Map<number, PendingCall>>(new Map());
 
   // Keep the callback ref up-to-date without re-creating the socket
   useEffect(() => {
@@ -36,15 +78,56 @@ export function useWebSocket(options: UseWebSocketOptions = {}): UseWebSocketRes
   }, [onMessage]);
 
   useEffect(() => {
-    const url = `${WS_BASE_URL}${path}`;
-    const rws = new ReconnectingWebSocket(url);
+    const url = () =>
+      lastSeqRef.current === null
+        ? `${WS_BASE_URL}${path}`
+        : `${WS_BASE_URL}${path}?since=${lastSeqRef.current}`;
+    const rws = new ReconnectingWebSocket(url, accessToken ? ['bearer', accessToken] : undefined);
     wsRef.current = rws;
 
-    const handleOpen = () => setConnectionStatus('open');
-    const handleClose = () => setConnectionStatus('closed');
+    const handleOpen = () => {
+      setConnectionStatus('open');
+      if (topicKey) {
+        rws.send(JSON.stringify({ type: 'subscribe', topics: topicKey.split(',') }));
+      }
+    };
+    const rejectPending = () => {
+      pendingCallsRef.current.forEach((p) => p.reject(new CallError('Connection closed', 0)));
+      pendingCallsRef.current.clear();
+    };
+    const handleClose = () => {
+      setConnectionStatus('closed');
+      rejectPending();
+    };
     const handleMessage = (event: MessageEvent) => {
       try {
         const parsed: WebSocketMessage = JSON.parse(event.data as string);
+        const pending = typeof parsed.id === 'number' ? pendingCallsRef.current.get(parsed.id) : undefined;
+        if (pending) {
+          pendingCallsRef.current.delete(parsed.id as number);
+          if (parsed.type === 'result') {
+            pending.resolve(parsed.payload);
+          } else {
+            const { error, status } = parsed.payload as { error: string; status: number };
+            pending.reject(new CallError(error, status));
+          }
+          return;
+        }
+        if (typeof parsed.seq === 'number') {
+          lastSeqRef.current = parsed.seq;
+        } else if (parsed.type === 'resync') {
+          lastSeqRef.current = (parsed.payload as { seq: number }).seq;
+        }
+        if (parsed.event_id) {
+          const seen = eventIdsRef.current;
+          if (seen.has(parsed.event_id)) {
+            return;
+          }
+          seen.add(parsed.event_id);
+          if (seen.size > EVENT_ID_WINDOW) {
+            seen.delete(seen.values().next().value as string);
+          }
+        }
         setLastMessage(parsed);
         onMessageRef.current?.(parsed);
       } catch {
@@ -61,12 +144,21 @@ export function useWebSocket(options: UseWebSocketOptions = {}): UseWebSocketRes
       rws.removeEventListener('close', handleClose);
       rws.removeEventListener('message', handleMessage);
       rws.close();
+      rejectPending();
     };
-  }, [path]);
+  }, [path, accessToken, topicKey]);
 
   const sendMessage = useCallback((data: unknown) => {
     wsRef.current?.send(JSON.stringify(data));
   }, []);
 
-  return { lastMessage, connectionStatus, sendMessage };
+  const call = useCallback(<T,>(method: string, params?: unknown) => {
+    const id = nextCallIdRef.current++;
+    return new Promise<T>((resolve, reject) => {
+      pendingCallsRef.current.set(id, { resolve: resolve as (result: unknown) => void, reject });
+      wsRef.current?.send(JSON.stringify({ id, method, params }));
+    });
+  }, []);
+
+  return { lastMessage, connectionStatus, sendMessage, call };
 }
@@ -80,10 +80,16 @@ const Items = () => {
       debounceRef.current = setTimeout(() => { void fetchItems(true); }, 300);
     });
 
+    // Sent after a reconnect when the events missed meanwhile are gone.
+    const unsubResync = subscribe('resync', () => {
+      void fetchItems(true);
+    });
+
     return () => {
       unsubCreated();
       unsubUpdated();
       unsubDeleted();
+      unsubResync();
       if (debounceRef.current) clearTimeout(debounceRef.current);
     };
   }, [subscribe, fetchItems]);